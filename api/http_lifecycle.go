@@ -6,13 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 
 	"github.com/github/git-lfs/auth"
 	"github.com/github/git-lfs/config"
 	"github.com/github/git-lfs/httputil"
+	"github.com/github/git-lfs/tools"
 )
 
 var (
@@ -166,7 +166,7 @@ func (l *HttpLifecycle) body(schema *RequestSchema) (io.ReadCloser, error) {
 		return nil, err
 	}
 
-	return ioutil.NopCloser(bytes.NewReader(body)), nil
+	return tools.NewReadSeekCloserWrapper(bytes.NewReader(body)), nil
 }
 
 // queryParameters returns a url.Values containing all of the provided query
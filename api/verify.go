@@ -9,6 +9,7 @@ import (
 
 	"github.com/github/git-lfs/errutil"
 	"github.com/github/git-lfs/httputil"
+	"github.com/github/git-lfs/tools"
 )
 
 // VerifyUpload calls the "verify" API link relation on obj if it exists
@@ -32,7 +33,7 @@ func VerifyUpload(obj *ObjectResource) error {
 	req.Header.Set("Content-Type", MediaType)
 	req.Header.Set("Content-Length", strconv.Itoa(len(by)))
 	req.ContentLength = int64(len(by))
-	req.Body = ioutil.NopCloser(bytes.NewReader(by))
+	req.Body = tools.NewReadSeekCloserWrapper(bytes.NewReader(by))
 	res, err := DoRequest(req, true)
 	if err != nil {
 		return err
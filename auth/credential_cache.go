@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rubyist/tracerx"
+)
+
+var (
+	// credsCache holds credentials from a credential helper that carry an
+	// authtype or an expiry, keyed by credsCacheKey. These are usually
+	// short-lived tokens, so they are reused for the life of the process
+	// until they expire or are rejected, rather than filled per request.
+	credsCache   = make(map[string]Creds)
+	credsCacheMu sync.Mutex
+
+	// tokenCreds holds every set of credentials with an authtype that has
+	// been filled, keyed by the Authorization header they produce, so that
+	// RefreshCreds can find the credentials behind a rejected header.
+	tokenCreds   = make(map[string]Creds)
+	tokenCredsMu sync.Mutex
+
+	// authChallenges holds the WWW-Authenticate challenges most recently
+	// sent by each server, keyed by scheme and host. They are given to the
+	// credential helper as "wwwauth[]" so that it can pick a token.
+	authChallenges   = make(map[string][]string)
+	authChallengesMu sync.Mutex
+)
+
+// SetAuthChallenges records the WWW-Authenticate challenges from a 401 response
+// to a request for the given URL, to be passed to the credential helper the next
+// time credentials are filled for its host.
+func SetAuthChallenges(u *url.URL, challenges []string) {
+	if len(challenges) == 0 {
+		return
+	}
+
+	authChallengesMu.Lock()
+	authChallenges[u.Scheme+"://"+u.Host] = challenges
+	authChallengesMu.Unlock()
+}
+
+func getAuthChallenges(u *url.URL) []string {
+	authChallengesMu.Lock()
+	defer authChallengesMu.Unlock()
+	return authChallenges[u.Scheme+"://"+u.Host]
+}
+
+// RefreshCreds replaces the credentials in the Authorization header of req if
+// they came from a credential helper with an authtype, such as a bearer token,
+// and the server rejected them. The helper is asked for a new token along with
+// the server's latest challenges, rather than the user being prompted for a
+// password. The new credentials are set on req.
+//
+// The old token is not rejected here, as SaveCredentials has already done so
+// when the server's 401 was handled.
+//
+// Returns nil credentials if req was not authorized with such a token.
+func RefreshCreds(req *http.Request) (Creds, error) {
+	header := req.Header.Get("Authorization")
+
+	tokenCredsMu.Lock()
+	creds := tokenCreds[header]
+	delete(tokenCreds, header)
+	tokenCredsMu.Unlock()
+
+	if creds == nil {
+		return nil, nil
+	}
+
+	u := &url.URL{Scheme: creds["protocol"], Host: creds["host"], Path: "/" + creds["path"]}
+	if username := creds["username"]; len(username) > 0 {
+		u.User = url.User(username)
+	}
+
+	tracerx.Printf("Refreshing %s credentials for %s://%s", creds["authtype"], u.Scheme, u.Host)
+	req.Header.Del("Authorization")
+	return fillCredentials(req, u)
+}
+
+// cachedCreds returns the unexpired cached credentials for the given input to
+// 'git credential fill', or nil if there are none.
+func cachedCreds(input Creds) Creds {
+	key := credsCacheKey(input)
+
+	credsCacheMu.Lock()
+	defer credsCacheMu.Unlock()
+
+	creds, ok := credsCache[key]
+	if !ok {
+		return nil
+	}
+
+	if credsExpired(creds) {
+		tracerx.Printf("Cached credentials for %s://%s have expired", input["protocol"], input["host"])
+		delete(credsCache, key)
+		return nil
+	}
+
+	return creds
+}
+
+// cacheCreds caches the credentials filled for the given input, if they are
+// the kind that should be reused for the life of the process.
+func cacheCreds(input, creds Creds) {
+	if len(creds["authtype"]) > 0 {
+		tokenCredsMu.Lock()
+		tokenCreds[authorizationHeader(creds)] = creds
+		tokenCredsMu.Unlock()
+	} else if len(creds["password_expiry_utc"]) == 0 {
+		return
+	}
+
+	credsCacheMu.Lock()
+	credsCache[credsCacheKey(input)] = creds
+	credsCacheMu.Unlock()
+}
+
+// uncacheCreds removes the given credentials from the cache, if present.
+func uncacheCreds(creds Creds) {
+	header := authorizationHeader(creds)
+
+	credsCacheMu.Lock()
+	defer credsCacheMu.Unlock()
+
+	for key, cached := range credsCache {
+		if cached["protocol"] == creds["protocol"] && cached["host"] == creds["host"] &&
+			authorizationHeader(cached) == header {
+			delete(credsCache, key)
+		}
+	}
+}
+
+// credsCacheKey returns the key identifying the URL and user that the given
+// input to 'git credential fill' is for.
+func credsCacheKey(c Creds) string {
+	return strings.Join([]string{c["protocol"], c["host"], c["path"], c["username"]}, "\x00")
+}
+
+// credsExpired returns whether the given credentials have a
+// "password_expiry_utc" in the past.
+func credsExpired(creds Creds) bool {
+	expiry, err := strconv.ParseInt(creds["password_expiry_utc"], 10, 64)
+	if err != nil {
+		return false
+	}
+	return time.Now().Unix() >= expiry
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/github/git-lfs/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFillCredentialsWithAuthtype(t *testing.T) {
+	defer resetCredsCache()
+	defer config.Config.ResetConfig()
+	config.Config.SetConfig("lfs.url", "https://token-server.com")
+
+	var inputs []Creds
+	defer SetCredentialsFunc(SetCredentialsFunc(func(input Creds, subCommand string) (Creds, error) {
+		inputs = append(inputs, input)
+		return Creds{
+			"protocol":   input["protocol"],
+			"host":       input["host"],
+			"authtype":   "Bearer",
+			"credential": "token-" + strconv.Itoa(len(inputs)),
+		}, nil
+	}))
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "https://token-server.com/foo", nil)
+		creds, err := GetCreds(req)
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer", creds["authtype"])
+		assert.Equal(t, "Bearer token-1", req.Header.Get("Authorization"))
+	}
+
+	if assert.Equal(t, 1, len(inputs)) {
+		assert.Equal(t, "authtype", inputs[0]["capability[]"])
+		assert.Equal(t, "", inputs[0]["wwwauth[]"])
+	}
+}
+
+func TestFillCredentialsRefillsExpiredCredentials(t *testing.T) {
+	defer resetCredsCache()
+	defer config.Config.ResetConfig()
+	config.Config.SetConfig("lfs.url", "https://expiring-server.com")
+
+	var fills int
+	defer SetCredentialsFunc(SetCredentialsFunc(func(input Creds, subCommand string) (Creds, error) {
+		fills++
+		expiry := time.Now().Add(time.Hour)
+		if fills == 1 {
+			expiry = time.Now().Add(-time.Minute)
+		}
+
+		return Creds{
+			"protocol":            input["protocol"],
+			"host":                input["host"],
+			"username":            "user",
+			"password":            "pass-" + strconv.Itoa(fills),
+			"password_expiry_utc": strconv.FormatInt(expiry.Unix(), 10),
+		}, nil
+	}))
+
+	for _, expected := range []string{"pass-1", "pass-2", "pass-2"} {
+		req, _ := http.NewRequest("GET", "https://expiring-server.com/foo", nil)
+		creds, err := GetCreds(req)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, creds["password"])
+	}
+	assert.Equal(t, 2, fills)
+}
+
+func TestRefreshCredsWithChallenge(t *testing.T) {
+	defer resetCredsCache()
+	defer config.Config.ResetConfig()
+	config.Config.SetConfig("lfs.url", "https://refresh-server.com")
+
+	var calls []string
+	var lastInput Creds
+	defer SetCredentialsFunc(SetCredentialsFunc(func(input Creds, subCommand string) (Creds, error) {
+		calls = append(calls, subCommand+" "+input["credential"])
+		if subCommand != "fill" {
+			return nil, nil
+		}
+
+		lastInput = input
+		return Creds{
+			"protocol":   input["protocol"],
+			"host":       input["host"],
+			"authtype":   "Bearer",
+			"credential": "token-" + strconv.Itoa(len(calls)),
+		}, nil
+	}))
+
+	req, _ := http.NewRequest("GET", "https://refresh-server.com/foo", nil)
+	old, err := GetCreds(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer token-1", req.Header.Get("Authorization"))
+
+	u, _ := url.Parse("https://refresh-server.com/foo")
+	SetAuthChallenges(u, []string{`Bearer realm="sso"`, `Basic realm="git"`})
+	SaveCredentials(old, &http.Response{StatusCode: 401})
+
+	creds, err := RefreshCreds(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "token-3", creds["credential"])
+	assert.Equal(t, "Bearer token-3", req.Header.Get("Authorization"))
+	assert.Equal(t, []string{"fill ", "reject token-1", "fill "}, calls)
+	assert.Equal(t, "Bearer realm=\"sso\"\nBasic realm=\"git\"", lastInput["wwwauth[]"])
+
+	// basic credentials are not refreshed
+	req.Header.Set("Authorization", "Basic abc")
+	creds, err = RefreshCreds(req)
+	assert.Nil(t, err)
+	assert.Nil(t, creds)
+}
+
+func TestCredsBufferWritesMultipleValues(t *testing.T) {
+	buf := Creds{"wwwauth[]": "Bearer realm=\"a\"\nBasic realm=\"b\""}.Buffer()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, []string{`wwwauth[]=Bearer realm="a"`, `wwwauth[]=Basic realm="b"`}, lines)
+}
+
+func resetCredsCache() {
+	credsCache = make(map[string]Creds)
+	tokenCreds = make(map[string]Creds)
+	authChallenges = make(map[string][]string)
+}
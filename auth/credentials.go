@@ -138,6 +138,19 @@ func fillCredentials(req *http.Request, u *url.URL) (Creds, error) {
 		input["username"] = u.User.Username()
 	}
 
+	if creds := cachedCreds(input); creds != nil {
+		tracerx.Printf("Using cached credentials for %s", u)
+		setRequestCreds(req, creds)
+		return creds, nil
+	}
+
+	// Tell git that we can use credentials with an authtype other than
+	// Basic, and pass along any challenges from the server.
+	input["capability[]"] = "authtype"
+	if challenges := getAuthChallenges(u); len(challenges) > 0 {
+		input["wwwauth[]"] = strings.Join(challenges, "\n")
+	}
+
 	creds, err := execCreds(input, "fill")
 	if creds == nil || len(creds) < 1 {
 		errmsg := fmt.Sprintf("Git credentials for %s not found", u)
@@ -154,11 +167,80 @@ func fillCredentials(req *http.Request, u *url.URL) (Creds, error) {
 	}
 
 	tracerx.Printf("Filled credentials for %s", u)
-	setRequestAuth(req, creds["username"], creds["password"])
+	cacheCreds(input, creds)
+	setRequestCreds(req, creds)
 
 	return creds, err
 }
 
+// FillProxyCredentials asks 'git credential' for the password of the user given
+// in a proxy URL, using the scheme and host of the proxy.
+func FillProxyCredentials(u *url.URL) (Creds, error) {
+	input := Creds{"protocol": u.Scheme, "host": u.Host}
+	if u.User != nil && u.User.Username() != "" {
+		input["username"] = u.User.Username()
+	}
+
+	creds, err := execCreds(input, "fill")
+	if err != nil {
+		return nil, err
+	}
+
+	if creds == nil || len(creds) < 1 {
+		return nil, fmt.Errorf("Git credentials for proxy %s not found.", u.Host)
+	}
+
+	tracerx.Printf("Filled proxy credentials for %s", u.Host)
+	return creds, nil
+}
+
+// SaveProxyCredentials tells 'git credential' whether the credentials returned
+// by FillProxyCredentials were accepted by the proxy.
+func SaveProxyCredentials(creds Creds, ok bool) {
+	if creds == nil {
+		return
+	}
+
+	if ok {
+		execCreds(creds, "approve")
+	} else {
+		execCreds(creds, "reject")
+	}
+}
+
+// FillCertPassword asks 'git credential' for the passphrase of the encrypted
+// private key at the given path. Like git, it uses the "cert" protocol with the
+// path of the key, so existing helpers can cache the passphrase.
+func FillCertPassword(path string) (Creds, error) {
+	input := Creds{"protocol": "cert", "path": path}
+
+	creds, err := execCreds(input, "fill")
+	if err != nil {
+		return nil, err
+	}
+
+	if creds == nil || len(creds["password"]) < 1 {
+		return nil, fmt.Errorf("Passphrase for %s not found.", path)
+	}
+
+	tracerx.Printf("Filled passphrase for %s", path)
+	return creds, nil
+}
+
+// SaveCertPassword tells 'git credential' whether the passphrase returned by
+// FillCertPassword was able to decrypt the key.
+func SaveCertPassword(creds Creds, ok bool) {
+	if creds == nil {
+		return
+	}
+
+	if ok {
+		execCreds(creds, "approve")
+	} else {
+		execCreds(creds, "reject")
+	}
+}
+
 func SaveCredentials(creds Creds, res *http.Response) {
 	if creds == nil {
 		return
@@ -166,6 +248,7 @@ func SaveCredentials(creds Creds, res *http.Response) {
 
 	switch res.StatusCode {
 	case 401, 403:
+		uncacheCreds(creds)
 		execCreds(creds, "reject")
 	default:
 		if res.StatusCode < 300 {
@@ -174,16 +257,26 @@ func SaveCredentials(creds Creds, res *http.Response) {
 	}
 }
 
+// Creds holds the key/value pairs read from and written to 'git credential'.
+// Multi-valued keys, which end in "[]", hold their values separated by
+// newlines.
 type Creds map[string]string
 
 func (c Creds) Buffer() *bytes.Buffer {
 	buf := new(bytes.Buffer)
 
 	for k, v := range c {
-		buf.Write([]byte(k))
-		buf.Write([]byte("="))
-		buf.Write([]byte(v))
-		buf.Write([]byte("\n"))
+		values := []string{v}
+		if strings.HasSuffix(k, "[]") {
+			values = strings.Split(v, "\n")
+		}
+
+		for _, value := range values {
+			buf.Write([]byte(k))
+			buf.Write([]byte("="))
+			buf.Write([]byte(value))
+			buf.Write([]byte("\n"))
+		}
 	}
 
 	return buf
@@ -234,7 +327,12 @@ func execCredsCommand(input Creds, subCommand string) (Creds, error) {
 		if len(pieces) < 2 || len(pieces[1]) < 1 {
 			continue
 		}
-		creds[pieces[0]] = pieces[1]
+
+		if existing, ok := creds[pieces[0]]; ok && strings.HasSuffix(pieces[0], "[]") {
+			creds[pieces[0]] = existing + "\n" + pieces[1]
+		} else {
+			creds[pieces[0]] = pieces[1]
+		}
 	}
 
 	return creds, nil
@@ -253,17 +351,37 @@ func setRequestAuthFromUrl(req *http.Request, u *url.URL) bool {
 }
 
 func setRequestAuth(req *http.Request, user, pass string) {
+	setRequestCreds(req, Creds{"username": user, "password": pass})
+}
+
+// setRequestCreds sets the Authorization header of req from credentials given
+// by 'git credential fill'.
+func setRequestCreds(req *http.Request, creds Creds) {
 	if config.Config.NtlmAccess(GetOperationForRequest(req)) {
 		return
 	}
 
+	if auth := authorizationHeader(creds); len(auth) > 0 {
+		req.Header.Set("Authorization", auth)
+	}
+}
+
+// authorizationHeader returns the value of the Authorization header for the
+// given credentials. Credentials with an "authtype" and "credential", as
+// returned by helpers for tokens, are sent as "<authtype> <credential>".
+// Otherwise, the username and password are used for Basic authentication.
+func authorizationHeader(creds Creds) string {
+	if authtype := creds["authtype"]; len(authtype) > 0 && len(creds["credential"]) > 0 {
+		return authtype + " " + creds["credential"]
+	}
+
+	user, pass := creds["username"], creds["password"]
 	if len(user) == 0 && len(pass) == 0 {
-		return
+		return ""
 	}
 
 	token := fmt.Sprintf("%s:%s", user, pass)
-	auth := "Basic " + strings.TrimSpace(base64.StdEncoding.EncodeToString([]byte(token)))
-	req.Header.Set("Authorization", auth)
+	return "Basic " + strings.TrimSpace(base64.StdEncoding.EncodeToString([]byte(token)))
 }
 
 var execCreds CredentialFunc = execCredsCommand
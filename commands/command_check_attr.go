@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/github/git-lfs/config"
+	"github.com/github/git-lfs/git"
+	"github.com/github/git-lfs/git/gitattr"
+	"github.com/github/git-lfs/git/odb"
+	"github.com/github/git-lfs/lfs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkAttrAll    bool
+	checkAttrCached bool
+	checkAttrSource string
+	checkAttrStdin  bool
+
+	checkAttrCmd = &cobra.Command{
+		Use: "check-attr",
+		Run: checkAttrCommand,
+	}
+)
+
+func checkAttrCommand(cmd *cobra.Command, args []string) {
+	requireInRepo()
+
+	if checkAttrCached && len(checkAttrSource) > 0 {
+		Exit("Cannot use --cached with --source")
+	}
+
+	names, paths := checkAttrArgs(args)
+	if checkAttrAll && len(names) > 0 {
+		Exit("Cannot give attributes with --all")
+	}
+	if !checkAttrAll && len(names) == 0 {
+		Exit("No attributes given")
+	}
+
+	if checkAttrStdin {
+		if len(paths) > 0 {
+			Exit("Cannot give paths with --stdin")
+		}
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			paths = append(paths, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			ExitWithError(err)
+		}
+	} else if len(paths) == 0 {
+		Exit("No paths given")
+	}
+
+	var db *odb.ObjectDatabase
+	if checkAttrCached || len(checkAttrSource) > 0 {
+		var err error
+		if db, err = lfs.OpenObjectDatabase(); err != nil {
+			ExitWithError(err)
+		}
+		defer db.Close()
+	}
+
+	attrs, err := checkAttrAttributes(db)
+	if err != nil {
+		ExitWithError(err)
+	}
+
+	for _, p := range paths {
+		relPath, err := checkAttrPath(p)
+		if err != nil {
+			Exit("%s", err)
+		}
+
+		states, err := attrs.Get(relPath)
+		if err != nil {
+			ExitWithError(err)
+		}
+
+		if checkAttrAll {
+			names = make([]string, 0, len(states))
+			for name := range states {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		}
+		for _, name := range names {
+			value, ok := states[name]
+			if !ok {
+				value = gitattr.Unspecified
+			}
+			Print("%s: %s: %s", p, name, value)
+		}
+	}
+}
+
+// checkAttrArgs splits args into attribute names and paths as git check-attr
+// does: at "--" if it was given, or else with every argument an attribute
+// with --stdin, every argument a path with --all, and the first an attribute
+// and the rest paths otherwise.
+func checkAttrArgs(args []string) ([]string, []string) {
+	// cobra drops the "--", so look for it in the command line itself
+	for i, arg := range os.Args {
+		if arg == "--" {
+			after := len(os.Args) - i - 1
+			return args[:len(args)-after], args[len(args)-after:]
+		}
+	}
+
+	switch {
+	case checkAttrStdin:
+		return args, nil
+	case checkAttrAll:
+		return nil, args
+	case len(args) == 0:
+		return nil, nil
+	default:
+		return args[:1], args[1:]
+	}
+}
+
+// checkAttrAttributes returns the attributes from the .gitattributes files of
+// the working tree, or of the index with --cached, or of a tree with
+// --source, which are read from db.
+func checkAttrAttributes(db *odb.ObjectDatabase) (*gitattr.Attributes, error) {
+	if !checkAttrCached && len(checkAttrSource) == 0 {
+		// a bare repository has only info/attributes
+		if len(config.LocalWorkingDir) == 0 {
+			return gitAttributes(gitattr.NewEmptySource())
+		}
+		return gitAttributes(gitattr.NewWorkingTreeSource(config.LocalWorkingDir))
+	}
+
+	var source gitattr.Source
+	var err error
+	if checkAttrCached {
+		source, err = gitattr.NewIndexSource(db)
+	} else {
+		var tree *git.Ref
+		if tree, err = git.ResolveRef(checkAttrSource + "^{tree}"); err != nil {
+			return nil, err
+		}
+		source, err = gitattr.NewTreeSource(db, tree.Sha)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return gitAttributes(source)
+}
+
+// checkAttrPath returns path, given relative to the current directory, as a
+// slash-separated path relative to the root of the repository.
+func checkAttrPath(path string) (string, error) {
+	if len(config.LocalWorkingDir) == 0 {
+		return filepath.ToSlash(path), nil
+	}
+
+	// the repository may be reached through a symlink
+	root, err := filepath.EvalSymlinks(config.LocalWorkingDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err == nil {
+			wd, err = filepath.EvalSymlinks(wd)
+		}
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(wd, path)
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside repository", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func init() {
+	checkAttrCmd.Flags().BoolVarP(&checkAttrAll, "all", "a", false, "List every attribute which is set, unset or has a value for each path.")
+	checkAttrCmd.Flags().BoolVarP(&checkAttrCached, "cached", "", false, "Read the .gitattributes files from the index.")
+	checkAttrCmd.Flags().StringVarP(&checkAttrSource, "source", "", "", "Read the .gitattributes files from the given tree-ish.")
+	checkAttrCmd.Flags().BoolVarP(&checkAttrStdin, "stdin", "", false, "Read paths from standard input, one per line.")
+	RootCmd.AddCommand(checkAttrCmd)
+}
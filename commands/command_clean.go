@@ -2,9 +2,11 @@ package commands
 
 import (
 	"os"
+	"time"
 
 	"github.com/github/git-lfs/errutil"
 	"github.com/github/git-lfs/lfs"
+	"github.com/github/git-lfs/localstorage"
 	"github.com/github/git-lfs/progress"
 	"github.com/spf13/cobra"
 )
@@ -24,12 +26,23 @@ func cleanCommand(cmd *cobra.Command, args []string) {
 	var cb progress.CopyCallback
 	var file *os.File
 	var fileSize int64
+	var fileInfo os.FileInfo
+	started := time.Now()
 	if len(args) > 0 {
 		fileName = args[0]
 
 		stat, err := os.Stat(fileName)
 		if err == nil && stat != nil {
 			fileSize = stat.Size()
+			fileInfo = stat
+
+			// git only cleans files whose stat info has changed since they
+			// were last added, but that includes touched & copied files
+			if pointer, ok := lfs.CachedCleanPointer(fileName, stat); ok {
+				Debug("%s unchanged, using cached pointer", fileName)
+				lfs.EncodePointer(os.Stdout, pointer)
+				return
+			}
 
 			localCb, localFile, err := lfs.CopyCallbackFile("clean", fileName, 1, 1)
 			if err != nil {
@@ -59,23 +72,24 @@ func cleanCommand(cmd *cobra.Command, args []string) {
 		Panic(err, "Error cleaning asset.")
 	}
 
-	tmpfile := cleaned.Filename
-	mediafile, err := lfs.LocalMediaPath(cleaned.Oid)
-	if err != nil {
-		Panic(err, "Unable to get local media path.")
-	}
-
-	if stat, _ := os.Stat(mediafile); stat != nil {
-		if stat.Size() != cleaned.Size && len(cleaned.Pointer.Extensions) == 0 {
-			Exit("Files don't match:\n%s\n%s", mediafile, tmpfile)
+	// If the object already exists, the cleaned copy is discarded by Teardown
+	if obj, err := localstorage.CurrentStore().Stat(cleaned.Oid); err == nil {
+		if obj.Size != cleaned.Size && len(cleaned.Pointer.Extensions) == 0 {
+			Exit("Files don't match:\n%s\n%s", lfs.LocalMediaPathReadOnly(cleaned.Oid), fileName)
 		}
-		Debug("%s exists", mediafile)
+		Debug("%s exists", cleaned.Oid)
 	} else {
-		if err := os.Rename(tmpfile, mediafile); err != nil {
-			Panic(err, "Unable to move %s to %s\n", tmpfile, mediafile)
+		if err := cleaned.Commit(); err != nil {
+			Panic(err, "Unable to add %s to the local store\n", cleaned.Oid)
 		}
 
-		Debug("Writing %s", mediafile)
+		Debug("Writing %s", cleaned.Oid)
+	}
+
+	if fileInfo != nil {
+		if err := lfs.CacheCleanPointer(fileName, fileInfo, started, cleaned.Pointer); err != nil {
+			Debug("Unable to cache pointer for %s: %v", fileName, err)
+		}
 	}
 
 	lfs.EncodePointer(os.Stdout, cleaned.Pointer)
@@ -1,8 +1,12 @@
 package commands
 
 import (
+	"fmt"
+	"net/url"
+
 	"github.com/github/git-lfs/config"
 	"github.com/github/git-lfs/git"
+	"github.com/github/git-lfs/httputil"
 	"github.com/github/git-lfs/lfs"
 	"github.com/spf13/cobra"
 )
@@ -33,6 +37,9 @@ func envCommand(cmd *cobra.Command, args []string) {
 		if len(endpoint.SshUserAndHost) > 0 {
 			Print("  SSH=%s:%s", endpoint.SshUserAndHost, endpoint.SshPath)
 		}
+		if proxy := endpointProxy(endpoint, cfg.CurrentRemote); len(proxy) > 0 {
+			Print("  Proxy=%s", proxy)
+		}
 	}
 
 	for _, remote := range cfg.Remotes() {
@@ -41,6 +48,9 @@ func envCommand(cmd *cobra.Command, args []string) {
 		if len(remoteEndpoint.SshUserAndHost) > 0 {
 			Print("  SSH=%s:%s", remoteEndpoint.SshUserAndHost, remoteEndpoint.SshPath)
 		}
+		if proxy := endpointProxy(remoteEndpoint, remote); len(proxy) > 0 {
+			Print("  Proxy=%s", proxy)
+		}
 	}
 
 	for _, env := range lfs.Environ() {
@@ -53,6 +63,29 @@ func envCommand(cmd *cobra.Command, args []string) {
 	}
 }
 
+// endpointProxy returns the proxy used to reach the given endpoint on behalf of
+// the given remote, without any password, or an empty string if none is used.
+func endpointProxy(e config.Endpoint, remote string) string {
+	u, err := url.Parse(e.Url)
+	if err != nil {
+		return ""
+	}
+
+	proxy, err := httputil.ProxyForUrl(u, remote)
+	if err != nil {
+		return fmt.Sprintf("<invalid: %s>", err)
+	}
+
+	if proxy == nil {
+		return ""
+	}
+
+	if proxy.User != nil {
+		proxy.User = url.User(proxy.User.Username())
+	}
+	return proxy.String()
+}
+
 func init() {
 	RootCmd.AddCommand(envCmd)
 }
@@ -87,10 +87,13 @@ func fetchCommand(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	if fetchPruneArg {
-		verify := config.Config.FetchPruneConfig().PruneVerifyRemoteAlways
+	fetchconf := config.Config.FetchPruneConfig()
+	if fetchconf.PruneAfterFetch && fetchconf.StorageMaxSize > 0 {
+		// no dry-run or verbose options in fetch, assume false
+		prune(fetchconf.PruneVerifyRemoteAlways, false, false, fetchconf.StorageMaxSize)
+	} else if fetchPruneArg {
 		// no dry-run or verbose options in fetch, assume false
-		prune(verify, false, false)
+		prune(fetchconf.PruneVerifyRemoteAlways, false, false, 0)
 	}
 
 	if !success {
@@ -112,7 +115,7 @@ func pointersToFetchForRef(ref string) ([]*lfs.WrappedPointer, error) {
 	opts := lfs.NewScanRefsOptions()
 	opts.ScanMode = lfs.ScanRefsMode
 	opts.SkipDeletedBlobs = true
-	return lfs.ScanRefs(ref, "", opts)
+	return lfs.ScanRefs(scanCtx, ref, "", opts)
 }
 
 func fetchRefToChan(ref string, include, exclude []string) chan *lfs.WrappedPointer {
@@ -139,7 +142,7 @@ func fetchRef(ref string, include, exclude []string) bool {
 // Fetch all previous versions of objects from since to ref (not including final state at ref)
 // So this will fetch all the '-' sides of the diff from since to ref
 func fetchPreviousVersions(ref string, since time.Time, include, exclude []string) bool {
-	pointers, err := lfs.ScanPreviousVersions(ref, since)
+	pointers, err := lfs.ScanPreviousVersions(scanCtx, ref, since)
 	if err != nil {
 		Panic(err, "Could not scan for Git LFS previous versions")
 	}
@@ -218,7 +221,7 @@ func scanAll() []*lfs.WrappedPointer {
 	Print("Scanning for all objects ever referenced...")
 	spinner := progress.NewSpinner()
 	var numObjs int64
-	pointerchan, err := lfs.ScanRefsToChan("", "", opts)
+	pointerchan, err := lfs.ScanRefsToChan(scanCtx, "", "", opts)
 	if err != nil {
 		Panic(err, "Could not scan for Git LFS files")
 	}
@@ -3,18 +3,28 @@ package commands
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 
 	"github.com/github/git-lfs/config"
 	"github.com/github/git-lfs/git"
+	"github.com/github/git-lfs/git/githistory"
 	"github.com/github/git-lfs/lfs"
+	"github.com/github/git-lfs/localstorage"
+	"github.com/github/git-lfs/progress"
 	"github.com/spf13/cobra"
 )
 
 var (
-	fsckDryRun bool
+	fsckDryRun       bool
+	fsckAll          bool
+	fsckRepair       bool
+	fsckPointerCheck bool
+	fsckStaged       bool
 
 	fsckCmd = &cobra.Command{
 		Use: "fsck",
@@ -22,104 +32,374 @@ var (
 	}
 )
 
-func doFsck() (bool, error) {
-	requireInRepo()
+// fsckObject is a Git LFS object checked by fsck, with the first path it was
+// found at.
+type fsckObject struct {
+	pointer *lfs.WrappedPointer
+	missing bool
+	corrupt bool
+	// err is set if the object could not be opened for another reason
+	err error
+}
+
+func (o *fsckObject) ok() bool {
+	return !o.missing && !o.corrupt && o.err == nil
+}
+
+// fsckPointers returns every Git LFS pointer in the history of the given refs,
+// or of the current ref and the index if there are none, or of every ref and
+// the index with --all. Pointers are returned for each blob, so the same
+// object may be in more than one.
+func fsckPointers(args []string) ([]*lfs.WrappedPointer, error) {
+	opt := lfs.NewScanRefsOptions()
+	var refLeft string
+	switch {
+	case fsckAll:
+		if len(args) > 0 {
+			Exit("Cannot use --all with explicit refs")
+		}
+	case len(args) > 0:
+		refs, err := git.ResolveRefs(args)
+		if err != nil {
+			return nil, err
+		}
+		opt.ScanMode = lfs.ScanRefsListMode
+		for _, ref := range refs {
+			opt.Refs = append(opt.Refs, ref.Sha)
+		}
+	default:
+		ref, err := git.CurrentRef()
+		if err != nil {
+			return nil, err
+		}
+		refLeft = ref.Sha
+	}
 
-	ref, err := git.CurrentRef()
+	blobs, err := lfs.ScanBlobs(scanCtx, refLeft, "", opt)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	// The LFS scanner methods return unexported *lfs.wrappedPointer objects.
-	// All we care about is the pointer OID and file name
-	pointerIndex := make(map[string]string)
+	var pointers []*lfs.WrappedPointer
+	for _, b := range blobs {
+		if b.Pointer != nil {
+			pointers = append(pointers, &lfs.WrappedPointer{Sha1: b.Sha1, Name: b.Name, Size: b.Pointer.Size, Pointer: b.Pointer})
+		}
+	}
+
+	if len(args) == 0 {
+		indexed, err := lfs.ScanIndex(scanCtx)
+		if err != nil {
+			return nil, err
+		}
+		pointers = append(pointers, indexed...)
+	}
+
+	sort.Sort(fsckPointersByName(pointers))
+	return pointers, nil
+}
 
-	pointers, err := lfs.ScanRefs(ref.Sha, "", nil)
+// fsckMalformed returns the pointers whose blobs are not exactly the pointer
+// that Git LFS would have written, e.g. with different whitespace or key
+// order, in the order they are given. Pointers with an older version line are
+// not malformed. Each blob is checked once.
+func fsckMalformed(pointers []*lfs.WrappedPointer) ([]*lfs.WrappedPointer, error) {
+	db, err := githistory.NewObjectDatabase(config.LocalGitStorageDir)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	defer db.Close()
+
+	var malformed []*lfs.WrappedPointer
+	seen := make(map[string]bool)
+	for _, p := range pointers {
+		if len(p.Sha1) == 0 || seen[p.Sha1] {
+			continue
+		}
+		seen[p.Sha1] = true
+
+		_, data, err := db.Read(p.Sha1)
+		if err != nil {
+			return nil, err
+		}
+		if !p.IsCanonical(data) {
+			malformed = append(malformed, p)
+		}
 	}
+	return malformed, nil
+}
 
+// fsckObjects returns the object of each pointer, once per oid, ordered by the
+// path it was first found at.
+func fsckObjects(pointers []*lfs.WrappedPointer) []*fsckObject {
+	var objects []*fsckObject
+	seen := make(map[string]bool)
 	for _, p := range pointers {
-		pointerIndex[p.Oid] = p.Name
+		if seen[p.Oid] {
+			continue
+		}
+		seen[p.Oid] = true
+		objects = append(objects, &fsckObject{pointer: p})
+	}
+	return objects
+}
+
+// fsckCheckObjects hashes the local copy of every object in parallel, and
+// sets whether it is missing or corrupt. Progress is shown on stderr if it is
+// a terminal.
+func fsckCheckObjects(objects []*fsckObject) {
+	workers := runtime.NumCPU()
+	if workers > len(objects) {
+		workers = len(objects)
 	}
 
-	// TODO(zeroshirts): do we want to look for LFS stuff in past commits?
-	p2, err := lfs.ScanIndex()
+	queue := make(chan *fsckObject, len(objects))
+	for _, o := range objects {
+		queue <- o
+	}
+	close(queue)
+
+	done := make(chan struct{}, len(objects))
+	for i := 0; i < workers; i++ {
+		go func() {
+			for o := range queue {
+				fsckCheckObject(o)
+				done <- struct{}{}
+			}
+		}()
+	}
+
+	spinner := fsckSpinner()
+	for i := 1; i <= len(objects); i++ {
+		<-done
+		if spinner != nil {
+			spinner.Print(os.Stderr, fmt.Sprintf("Checking objects: %d of %d", i, len(objects)))
+		}
+	}
+	if spinner != nil {
+		spinner.Finish(os.Stderr, fmt.Sprintf("Checked %d objects", len(objects)))
+	}
+}
+
+// fsckCheckObject compares the sha256 of the local copy of o with its oid.
+func fsckCheckObject(o *fsckObject) {
+	o.missing, o.corrupt, o.err = false, false, nil
+
+	Debug("Examining %v (%v)", o.pointer.Name, lfs.LocalMediaPathReadOnly(o.pointer.Oid))
+
+	f, err := localstorage.CurrentStore().Open(o.pointer.Oid)
+	if os.IsNotExist(err) {
+		o.missing = true
+		return
+	}
+	if _, ok := err.(*os.PathError); ok {
+		o.err = err
+		return
+	}
 	if err != nil {
-		return false, err
+		// the object is compressed, but its header can not be read
+		o.corrupt = true
+		return
 	}
+	defer f.Close()
 
-	for _, p := range p2 {
-		pointerIndex[p.Oid] = p.Name
+	oidHash := sha256.New()
+	if _, err := io.Copy(oidHash, f); err != nil {
+		// compressed objects which can not be decompressed are corrupt too
+		o.corrupt = true
+		return
 	}
+	o.corrupt = hex.EncodeToString(oidHash.Sum(nil)) != o.pointer.Oid
+}
 
-	ok := true
+// fsckSpinner returns a spinner to show progress with, or nil if stderr is not
+// a terminal.
+func fsckSpinner() *progress.Spinner {
+	stat, err := os.Stderr.Stat()
+	if err != nil || (stat.Mode()&os.ModeCharDevice) == 0 {
+		return nil
+	}
+	return progress.NewSpinner()
+}
 
-	for oid, name := range pointerIndex {
-		path := lfs.LocalMediaPathReadOnly(oid)
+// fsckMoveCorrupt moves a corrupt object to .git/lfs/bad, so that it is
+// missing rather than used, and returns the path it was moved to.
+func fsckMoveCorrupt(oid string) (string, error) {
+	badDir := filepath.Join(config.LocalGitStorageDir, "lfs", "bad")
+	if err := os.MkdirAll(badDir, 0755); err != nil {
+		return "", err
+	}
 
-		Debug("Examining %v (%v)", name, path)
+	badFile := filepath.Join(badDir, oid)
+	if err := os.Rename(lfs.LocalMediaPathReadOnly(oid), badFile); err != nil {
+		return "", err
+	}
+	return badFile, nil
+}
 
-		f, err := os.Open(path)
-		if pErr, pOk := err.(*os.PathError); pOk {
-			Print("Object %s (%s) could not be checked: %s", name, oid, pErr.Err)
-			ok = false
-			continue
+// fsckRepairObjects takes each missing object from a reference repository or
+// downloads it from the default remote, and reports whether it now passes.
+// It returns whether every object was repaired.
+func fsckRepairObjects(objects []*fsckObject) bool {
+	var download []*fsckObject
+	var totalSize int64
+	for _, o := range objects {
+		lfs.LinkOrCopyFromReference(o.pointer.Oid, o.pointer.Size)
+		if !lfs.ObjectExistsOfSize(o.pointer.Oid, o.pointer.Size) {
+			download = append(download, o)
+			totalSize += o.pointer.Size
 		}
-		if err != nil {
-			return false, err
+	}
+
+	if len(download) > 0 {
+		if remote, err := git.DefaultRemote(); err == nil {
+			config.Config.CurrentRemote = remote
+			q := lfs.NewDownloadQueue(len(download), totalSize, false)
+			for _, o := range download {
+				q.Add(lfs.NewDownloadable(o.pointer))
+			}
+			q.Wait()
+			for _, err := range q.Errors() {
+				LoggedError(err, "Error downloading object: %v", err)
+			}
+		} else {
+			Error("Could not find a remote to download objects from: %v", err)
 		}
+	}
 
-		oidHash := sha256.New()
-		_, err = io.Copy(oidHash, f)
-		f.Close()
-		if err != nil {
-			return false, err
+	fsckCheckObjects(objects)
+
+	repaired := true
+	for _, o := range objects {
+		if o.ok() {
+			Print("Object %s (%s) was repaired", o.pointer.Name, o.pointer.Oid)
+		} else {
+			Print("Object %s (%s) could not be repaired", o.pointer.Name, o.pointer.Oid)
+			repaired = false
 		}
+	}
+	return repaired
+}
+
+func doFsck(args []string) (bool, error) {
+	requireInRepo()
+
+	pointers, err := fsckPointers(args)
+	if err != nil {
+		return false, err
+	}
+
+	malformed, err := fsckMalformed(pointers)
+	if err != nil {
+		return false, err
+	}
+
+	objects := fsckObjects(pointers)
+	fsckCheckObjects(objects)
 
-		recalculatedOid := hex.EncodeToString(oidHash.Sum(nil))
-		if recalculatedOid != oid {
-			ok = false
+	ok := true
+
+	for _, p := range malformed {
+		Print("Pointer %s (%s) is malformed", p.Name, p.Sha1)
+		ok = false
+	}
+
+	var broken []*fsckObject
+	for _, o := range objects {
+		name, oid := o.pointer.Name, o.pointer.Oid
+
+		switch {
+		case o.missing:
+			Print("Object %s (%s) is missing", name, oid)
+		case o.err != nil:
+			Print("Object %s (%s) could not be checked: %s", name, oid, unwrapPathError(o.err))
+		case o.corrupt:
 			Print("Object %s (%s) is corrupt", name, oid)
 			if fsckDryRun {
-				continue
+				break
 			}
 
-			badDir := filepath.Join(config.LocalGitStorageDir, "lfs", "bad")
-			if err := os.MkdirAll(badDir, 0755); err != nil {
-				return false, err
-			}
-
-			badFile := filepath.Join(badDir, oid)
-			if err := os.Rename(path, badFile); err != nil {
+			badFile, err := fsckMoveCorrupt(oid)
+			if err != nil {
 				return false, err
 			}
 			Print("  moved to %s", badFile)
+		default:
+			continue
+		}
+
+		ok = false
+		broken = append(broken, o)
+	}
+
+	if fsckRepair && len(broken) > 0 {
+		if fsckRepairObjects(broken) && len(malformed) == 0 {
+			ok = true
 		}
 	}
+
 	return ok, nil
 }
 
-// TODO(zeroshirts): 'git fsck' reports status (percentage, current#/total) as
-// it checks... we should do the same, as we are rehashing potentially gigs and
-// gigs of content.
-//
+// unwrapPathError returns the underlying error of an *os.PathError, which
+// already names the object by its path.
+func unwrapPathError(err error) error {
+	if pErr, ok := err.(*os.PathError); ok {
+		return pErr.Err
+	}
+	return err
+}
+
+// fsckPointersByName sorts pointers by path, then oid, so that problems are
+// reported in the same order every time.
+type fsckPointersByName []*lfs.WrappedPointer
+
+func (p fsckPointersByName) Len() int      { return len(p) }
+func (p fsckPointersByName) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p fsckPointersByName) Less(i, j int) bool {
+	if p[i].Name != p[j].Name {
+		return p[i].Name < p[j].Name
+	}
+	return p[i].Oid < p[j].Oid
+}
+
 // NOTE(zeroshirts): Ideally git would have hooks for fsck such that we could
 // chain a lfs-fsck, but I don't think it does.
 func fsckCommand(cmd *cobra.Command, args []string) {
+	if fsckDryRun && fsckRepair {
+		Exit("Cannot use --dry-run with --repair")
+	}
+	if fsckPointerCheck && fsckRepair {
+		Exit("Cannot use --pointers with --repair")
+	}
+	if fsckStaged && !fsckPointerCheck {
+		Exit("Cannot use --staged without --pointers")
+	}
+
 	lfs.InstallHooks(false)
 
-	ok, err := doFsck()
+	var ok bool
+	var err error
+	if fsckPointerCheck {
+		ok, err = doFsckPointers(args)
+	} else {
+		ok, err = doFsck(args)
+	}
 	if err != nil {
 		Panic(err, "Error checking Git LFS files")
 	}
 
-	if ok {
-		Print("Git LFS fsck OK")
+	if !ok {
+		os.Exit(1)
 	}
+	Print("Git LFS fsck OK")
 }
 
 func init() {
 	fsckCmd.Flags().BoolVarP(&fsckDryRun, "dry-run", "d", false, "List corrupt objects without deleting them.")
+	fsckCmd.Flags().BoolVarP(&fsckAll, "all", "a", false, "Check the objects in the history of every ref.")
+	fsckCmd.Flags().BoolVarP(&fsckRepair, "repair", "r", false, "Download missing and corrupt objects from the remote.")
+	fsckCmd.Flags().BoolVarP(&fsckPointerCheck, "pointers", "p", false, "Check that files are pointers if and only if they are tracked by Git LFS.")
+	fsckCmd.Flags().BoolVarP(&fsckStaged, "staged", "", false, "With --pointers, check only the changes in the index.")
 	RootCmd.AddCommand(fsckCmd)
 }
@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"sort"
+
+	"github.com/github/git-lfs/git"
+	"github.com/github/git-lfs/git/gitattr"
+	"github.com/github/git-lfs/git/odb"
+	"github.com/github/git-lfs/lfs"
+)
+
+// fsckPointerBlobs returns the blob at each path changed by the commits that
+// git log lists for the given revisions, or by the history of the current ref
+// if there are none, or every ref with --all. The paths changed in the index
+// are included too unless revisions are given, or are the only ones with
+// --staged.
+func fsckPointerBlobs(args []string) ([]*lfs.ScannedBlob, error) {
+	if fsckStaged && (fsckAll || len(args) > 0) {
+		Exit("Cannot use --staged with --all or explicit refs")
+	}
+	if fsckAll && len(args) > 0 {
+		Exit("Cannot use --all with explicit refs")
+	}
+
+	var blobs []*lfs.ScannedBlob
+	if !fsckStaged {
+		revArgs := args
+		if fsckAll {
+			revArgs = []string{"--all"}
+		} else if len(args) == 0 {
+			// there is no history to check before the first commit
+			if _, err := git.CurrentRef(); err == nil {
+				revArgs = []string{"HEAD"}
+			}
+		}
+
+		if len(revArgs) > 0 {
+			changed, err := lfs.ScanChangedBlobs(scanCtx, revArgs)
+			if err != nil {
+				return nil, err
+			}
+			blobs = append(blobs, changed...)
+		}
+	}
+
+	if len(args) == 0 {
+		staged, err := lfs.ScanStagedBlobs(scanCtx)
+		if err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, staged...)
+	}
+
+	return blobs, nil
+}
+
+// doFsckPointers reports each file which is tracked by Git LFS but is not a
+// pointer, such as one committed without Git LFS installed, and each pointer
+// which is not tracked by Git LFS. It returns whether there were none.
+func doFsckPointers(args []string) (bool, error) {
+	requireInRepo()
+
+	blobs, err := fsckPointerBlobs(args)
+	if err != nil {
+		return false, err
+	}
+
+	problems, err := fsckPointerProblems(blobs)
+	if err != nil {
+		return false, err
+	}
+	for _, b := range problems {
+		if b.Pointer == nil {
+			Print("File %s (%s) should be a Git LFS pointer", b.Name, b.Sha1)
+		} else {
+			Print("Pointer %s (%s) is not in a Git LFS path", b.Name, b.Sha1)
+		}
+	}
+	return len(problems) == 0, nil
+}
+
+// fsckPointerProblems returns each of blobs which is tracked by Git LFS but is
+// not a pointer, or is a pointer but is not tracked, once for each path and
+// blob, sorted by path. Whether a path is tracked is taken from the
+// .gitattributes files of the commit which changed it, or of the index for
+// staged changes.
+func fsckPointerProblems(blobs []*lfs.ScannedBlob) ([]*lfs.ScannedBlob, error) {
+	db, err := lfs.OpenObjectDatabase()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// git log lists the blobs of each commit together, so only the
+	// attributes of the last commit are kept
+	var attrs *gitattr.Attributes
+	var attrsCommit string
+
+	// each path and blob is reported once, however many commits it is in
+	var problems []*lfs.ScannedBlob
+	seen := make(map[string]bool)
+	for _, b := range blobs {
+		// an empty file is the same whether or not it is cleaned
+		if b.Size == 0 || seen[b.Name+"\x00"+b.Sha1] {
+			continue
+		}
+
+		if attrs == nil || attrsCommit != b.Commit {
+			if attrs, err = fsckCommitAttributes(db, b.Commit); err != nil {
+				return nil, err
+			}
+			attrsCommit = b.Commit
+		}
+		tracked, err := attrs.Tracked(b.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if tracked == (b.Pointer != nil) {
+			continue
+		}
+		seen[b.Name+"\x00"+b.Sha1] = true
+		problems = append(problems, b)
+	}
+
+	sort.Sort(fsckBlobsByName(problems))
+	return problems, nil
+}
+
+// fsckCommitAttributes returns the attributes given by the .gitattributes
+// files in the tree of commit, or in the index if it is empty.
+func fsckCommitAttributes(db *odb.ObjectDatabase, commit string) (*gitattr.Attributes, error) {
+	var source gitattr.Source
+	if len(commit) == 0 {
+		var err error
+		if source, err = gitattr.NewIndexSource(db); err != nil {
+			return nil, err
+		}
+	} else {
+		c, err := db.ReadCommit(commit)
+		if err != nil {
+			return nil, err
+		}
+		if source, err = gitattr.NewTreeSource(db, c.Tree); err != nil {
+			return nil, err
+		}
+	}
+	return gitAttributes(source)
+}
+
+// fsckBlobsByName sorts blobs by path, then sha, so that problems are
+// reported in the same order every time.
+type fsckBlobsByName []*lfs.ScannedBlob
+
+func (b fsckBlobsByName) Len() int      { return len(b) }
+func (b fsckBlobsByName) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b fsckBlobsByName) Less(i, j int) bool {
+	if b[i].Name != b[j].Name {
+		return b[i].Name < b[j].Name
+	}
+	return b[i].Sha1 < b[j].Sha1
+}
@@ -16,13 +16,14 @@ var (
 		Run: installHooksCommand,
 	}
 
-	forceInstall      = false
-	localInstall      = false
-	skipSmudgeInstall = false
+	forceInstall        = false
+	localInstall        = false
+	skipSmudgeInstall   = false
+	pointerCheckInstall = false
 )
 
 func installCommand(cmd *cobra.Command, args []string) {
-	if localInstall {
+	if localInstall || pointerCheckInstall {
 		requireInRepo()
 	}
 
@@ -41,6 +42,14 @@ func installCommand(cmd *cobra.Command, args []string) {
 		installHooksCommand(cmd, args)
 	}
 
+	if pointerCheckInstall {
+		if err := lfs.InstallPointerCheckHook(forceInstall); err != nil {
+			Error("%s", err)
+			Exit("Run `git lfs install --pointer-check --force` to overwrite your hook.")
+		}
+		Print("Updated pre-commit hook.")
+	}
+
 	Print("Git LFS initialized.")
 }
 
@@ -53,6 +62,7 @@ func init() {
 	installCmd.Flags().BoolVarP(&forceInstall, "force", "f", false, "Set the Git LFS global config, overwriting previous values.")
 	installCmd.Flags().BoolVarP(&localInstall, "local", "l", false, "Set the Git LFS config for the local Git repository only.")
 	installCmd.Flags().BoolVarP(&skipSmudgeInstall, "skip-smudge", "s", false, "Skip automatic downloading of objects on clone or pull.")
+	installCmd.Flags().BoolVarP(&pointerCheckInstall, "pointer-check", "", false, "Install a pre-commit hook which checks that Git LFS files are committed as pointers.")
 	installCmd.AddCommand(installHooksCmd)
 	RootCmd.AddCommand(installCmd)
 }
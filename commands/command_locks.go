@@ -1,6 +1,9 @@
 package commands
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/github/git-lfs/api"
 	"github.com/github/git-lfs/config"
 	"github.com/spf13/cobra"
@@ -17,6 +20,14 @@ var (
 func locksCommand(cmd *cobra.Command, args []string) {
 	setLockRemoteFor(config.Config)
 
+	if locksCmdFlags.Watch {
+		if len(locksCmdFlags.Id) > 0 {
+			Exit("--id cannot be used with --watch")
+		}
+		locksWatchCommand(args)
+		return
+	}
+
 	filters, err := locksCmdFlags.Filters()
 	if err != nil {
 		Error(err.Error())
@@ -56,12 +67,74 @@ func locksCommand(cmd *cobra.Command, args []string) {
 	}
 }
 
+// locksWatchCommand polls the server for locks against the given paths (or all
+// locks, if none are given) and reports every lock that is created or released
+// until the process is interrupted.
+func locksWatchCommand(args []string) {
+	var paths []string
+	if len(locksCmdFlags.Path) > 0 {
+		args = append(args, locksCmdFlags.Path)
+	}
+	for _, arg := range args {
+		path, err := lockPath(arg)
+		if err != nil {
+			Exit("Unable to watch %q: %s", arg, err)
+		}
+		paths = append(paths, path)
+	}
+
+	interval := locksCmdFlags.Interval
+	if interval < 1 {
+		interval = config.Config.GitConfigInt("lfs.lockwatchinterval", 10)
+	}
+	command, _ := config.Config.GitConfig("lfs.lockwatchcommand")
+
+	watcher := newLockWatcher(paths)
+	if _, err := watcher.Poll(); err != nil {
+		Exit("Error communicating with LFS API: %s", err)
+	}
+
+	if !locksCmdFlags.Json {
+		Print("Watching %d lock(s), polling every %ds", len(watcher.known), interval)
+	}
+
+	encoder := json.NewEncoder(OutputWriter)
+	for {
+		time.Sleep(time.Duration(interval) * time.Second)
+
+		events, err := watcher.Poll()
+		if err != nil {
+			// Keep watching through transient failures, the
+			// next successful poll will catch up on any events.
+			Error("Error polling locks: %s", err)
+			continue
+		}
+
+		for _, event := range events {
+			if locksCmdFlags.Json {
+				encoder.Encode(event)
+			} else {
+				Print("%s\t%s\t%s <%s>", event.Type, event.Lock.Path, event.Lock.Committer.Name, event.Lock.Committer.Email)
+			}
+
+			if len(command) > 0 {
+				if err := runLockEventCommand(command, event); err != nil {
+					Error("Error running %q: %s", command, err)
+				}
+			}
+		}
+	}
+}
+
 func init() {
 	locksCmd.Flags().StringVarP(&lockRemote, "remote", "r", config.Config.CurrentRemote, lockRemoteHelp)
 
 	locksCmd.Flags().StringVarP(&locksCmdFlags.Path, "path", "p", "", "filter locks results matching a particular path")
 	locksCmd.Flags().StringVarP(&locksCmdFlags.Id, "id", "i", "", "filter locks results matching a particular ID")
 	locksCmd.Flags().IntVarP(&locksCmdFlags.Limit, "limit", "l", 0, "optional limit for number of results to return")
+	locksCmd.Flags().BoolVarP(&locksCmdFlags.Watch, "watch", "w", false, "poll for locks being created or released on the given paths")
+	locksCmd.Flags().BoolVarP(&locksCmdFlags.Json, "json", "j", false, "print lock events as JSON, one per line (with --watch)")
+	locksCmd.Flags().IntVarP(&locksCmdFlags.Interval, "interval", "", 0, "number of seconds to wait between polls (with --watch)")
 
 	RootCmd.AddCommand(locksCmd)
 }
@@ -78,6 +151,16 @@ type locksFlags struct {
 	// limit is an optional request parameter sent to the server used to
 	// limit the
 	Limit int
+	// Watch specifies whether or not the `git lfs locks` command was
+	// invoked with "--watch", polling the server for lock changes instead
+	// of listing the current locks once.
+	Watch bool
+	// Json specifies whether lock events should be written as JSON
+	// objects, one per line, instead of human-readable text.
+	Json bool
+	// Interval is the number of seconds to wait between each poll when
+	// watching. If zero, the value of "lfs.lockwatchinterval" is used.
+	Interval int
 }
 
 // Filters produces a slice of api.Filter instances based on the internal state
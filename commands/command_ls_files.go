@@ -37,7 +37,7 @@ func lsFilesCommand(cmd *cobra.Command, args []string) {
 		showOidLen = 64
 	}
 
-	files, err := lfs.ScanTree(ref)
+	files, err := lfs.ScanTree(scanCtx, ref)
 	if err != nil {
 		Panic(err, "Could not scan for Git LFS tree: %s", err)
 	}
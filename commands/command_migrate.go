@@ -0,0 +1,287 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/github/git-lfs/config"
+	"github.com/github/git-lfs/git"
+	"github.com/github/git-lfs/git/gitattr"
+	"github.com/github/git-lfs/git/githistory"
+	"github.com/github/git-lfs/git/odb"
+	"github.com/github/git-lfs/subprocess"
+	"github.com/github/git-lfs/tools"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateCmd = &cobra.Command{
+		Use: "migrate",
+		Run: migrateCommand,
+	}
+
+	migrateIncludeArg    string
+	migrateExcludeArg    string
+	migrateEverythingArg bool
+)
+
+func migrateCommand(cmd *cobra.Command, args []string) {
+	printHelp("migrate")
+}
+
+// migrateRefs returns the refs whose history is rewritten: every local branch
+// and tag with --everything, otherwise those given, or the current branch if
+// none are.
+func migrateRefs(args []string) []*git.Ref {
+	if migrateEverythingArg {
+		if len(args) > 0 {
+			Exit("Cannot use --everything with explicit refs")
+		}
+		refs, err := git.LocalRefs()
+		if err != nil {
+			Exit("Could not list local refs: %v", err)
+		}
+		return refs
+	}
+
+	if len(args) == 0 {
+		ref, err := git.CurrentRef()
+		if err != nil {
+			Exit("Could not find the current branch: %v", err)
+		}
+		return []*git.Ref{ref}
+	}
+
+	refs, err := git.ResolveRefs(args)
+	if err != nil {
+		Exit("Could not resolve refs: %v", err)
+	}
+	for i, ref := range refs {
+		if ref.Type == git.RefTypeOther {
+			Exit("%q is not a branch or tag", args[i])
+		}
+	}
+	return refs
+}
+
+// migratePatterns returns the --include and --exclude patterns.
+func migratePatterns() (include, exclude []string) {
+	return tools.CleanPaths(migrateIncludeArg, ","), tools.CleanPaths(migrateExcludeArg, ",")
+}
+
+// migratePathMatches returns whether the path of a file in a tree matches any
+// of the patterns, as a .gitattributes file at the root of the tree would
+// match them.
+func migratePathMatches(filePath string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if gitattr.NewPattern(pattern, "").Match(filePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateBlobFilter returns whether a file is to be migrated, given the
+// --include and --exclude patterns. .gitattributes files never are.
+func migrateBlobFilter(include, exclude []string) func(string) bool {
+	return func(filePath string) bool {
+		if path.Base(filePath) == ".gitattributes" {
+			return false
+		}
+		return migratePathMatches(filePath, include) && !migratePathMatches(filePath, exclude)
+	}
+}
+
+// requireCleanWorkingCopy exits if there are uncommitted changes, which
+// rewriting the current branch would lose.
+func requireCleanWorkingCopy() {
+	if config.LocalWorkingDir == "" {
+		return
+	}
+
+	status, err := subprocess.SimpleExec("git", "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		Exit("Could not check the working copy for changes: %v", err)
+	}
+	if len(status) > 0 {
+		Exit("Your working copy has uncommitted changes. Commit or stash them before migrating.")
+	}
+}
+
+// openMigrateObjectDatabase opens the git objects of the current repository,
+// exiting if they cannot be read.
+func openMigrateObjectDatabase() *githistory.ObjectDatabase {
+	db, err := githistory.NewObjectDatabase(config.LocalGitStorageDir)
+	if err != nil {
+		Exit("Could not open the object database: %v", err)
+	}
+	return db
+}
+
+// migrate rewrites the history of refs in db with the given options, updates
+// the refs and prints the old and new sha of each rewritten commit. If the
+// current branch is rewritten, the working copy is reset to it.
+func migrate(db *githistory.ObjectDatabase, refs []*git.Ref, opt *githistory.RewriteOptions, reason string) {
+	for _, ref := range refs {
+		opt.Include = append(opt.Include, ref.Sha)
+	}
+
+	rewriter := githistory.NewRewriter(db)
+	commits, err := rewriter.Rewrite(opt)
+	if err != nil {
+		Exit("Error rewriting history: %v", err)
+	}
+
+	for _, sha := range commits {
+		if newSha := rewriter.Rewritten(sha); newSha != sha {
+			Print("%s %s", sha, newSha)
+		}
+	}
+
+	updated, err := rewriter.UpdateRefs(refs, reason)
+	if err != nil {
+		Exit("Error updating refs: %v", err)
+	}
+
+	current, err := git.CurrentRef()
+	if err != nil || config.LocalWorkingDir == "" {
+		return
+	}
+	for _, ref := range updated {
+		if ref.FullName() == current.FullName() {
+			if _, err := subprocess.SimpleExec("git", "reset", "--hard", "-q", "HEAD"); err != nil {
+				Exit("Error updating the working copy: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// migrateAttributes adds the lines which track a set of patterns with Git LFS
+// to .gitattributes files in rewritten trees, or removes them.
+type migrateAttributes struct {
+	db    *githistory.ObjectDatabase
+	lines []string
+	add   bool
+	// maps the sha of each .gitattributes file to its rewritten sha, with ""
+	// for trees without one, or for files which end up empty
+	rewritten map[string]string
+}
+
+func newMigrateAttributes(db *githistory.ObjectDatabase, patterns []string, add bool) *migrateAttributes {
+	lines := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		encoded := strings.Replace(pattern, " ", "[[:space:]]", -1)
+		lines = append(lines, fmt.Sprintf("%s filter=lfs diff=lfs merge=lfs -text", encoded))
+	}
+	return &migrateAttributes{db: db, lines: lines, add: add, rewritten: make(map[string]string)}
+}
+
+// rewriteTree adds the lines to, or removes them from, the .gitattributes file
+// in the tree, adding or removing the file as needed.
+func (a *migrateAttributes) rewriteTree(t *odb.Tree) (*odb.Tree, error) {
+	existing := t.Entry(".gitattributes")
+	if existing != nil && !existing.IsFile() {
+		return t, nil
+	}
+	if existing == nil && !a.add {
+		return t, nil
+	}
+
+	oldSha := ""
+	if existing != nil {
+		oldSha = existing.Sha
+	}
+
+	newSha, ok := a.rewritten[oldSha]
+	if !ok {
+		var err error
+		if newSha, err = a.rewrite(oldSha); err != nil {
+			return nil, err
+		}
+		a.rewritten[oldSha] = newSha
+	}
+
+	if len(newSha) == 0 {
+		t.RemoveEntry(".gitattributes")
+		return t, nil
+	}
+
+	mode := "100644"
+	if existing != nil {
+		mode = existing.Mode
+	}
+	t.SetEntry(&odb.TreeEntry{Mode: mode, Name: ".gitattributes", Sha: newSha})
+	return t, nil
+}
+
+// rewrite returns the sha of the .gitattributes file with the given sha once
+// the lines have been added or removed, or "" if it is left empty.
+func (a *migrateAttributes) rewrite(sha string) (string, error) {
+	var data []byte
+	if len(sha) > 0 {
+		_, contents, err := a.db.Read(sha)
+		if err != nil {
+			return "", err
+		}
+		data = contents
+	}
+
+	var buf bytes.Buffer
+	if a.add {
+		existing := make(map[string]bool)
+		for _, line := range strings.Split(string(data), "\n") {
+			existing[strings.TrimSpace(line)] = true
+		}
+
+		buf.Write(data)
+		for _, line := range a.lines {
+			if existing[line] {
+				continue
+			}
+			if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+				buf.WriteByte('\n')
+			}
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+		}
+	} else {
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			if !a.tracks(line) {
+				buf.WriteString(line)
+			}
+		}
+		if len(strings.TrimSpace(buf.String())) == 0 {
+			return "", nil
+		}
+	}
+
+	if len(sha) > 0 && bytes.Equal(buf.Bytes(), data) {
+		return sha, nil
+	}
+	return a.db.WriteBytes("blob", buf.Bytes())
+}
+
+// tracks returns whether a line of a .gitattributes file tracks one of the
+// patterns with Git LFS.
+func (a *migrateAttributes) tracks(line string) bool {
+	parsed, ok := gitattr.ParseLine(line)
+	if !ok || !parsed.Tracked() {
+		return false
+	}
+	for _, l := range a.lines {
+		if own, _ := gitattr.ParseLine(l); own.Pattern == parsed.Pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	migrateCmd.PersistentFlags().StringVarP(&migrateIncludeArg, "include", "I", "", "Include a list of paths")
+	migrateCmd.PersistentFlags().StringVarP(&migrateExcludeArg, "exclude", "X", "", "Exclude a list of paths")
+	migrateCmd.PersistentFlags().BoolVarP(&migrateEverythingArg, "everything", "", false, "Migrate all local branches and tags")
+	RootCmd.AddCommand(migrateCmd)
+}
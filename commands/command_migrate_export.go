@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/github/git-lfs/config"
+	"github.com/github/git-lfs/git"
+	"github.com/github/git-lfs/git/githistory"
+	"github.com/github/git-lfs/git/odb"
+	"github.com/github/git-lfs/lfs"
+	"github.com/github/git-lfs/localstorage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateExportCmd = &cobra.Command{
+		Use: "export",
+		Run: migrateExportCommand,
+	}
+)
+
+// migrateExportCommand rewrites history so that the Git LFS files matching
+// --include are stored in git, and no longer tracked in .gitattributes.
+func migrateExportCommand(cmd *cobra.Command, args []string) {
+	requireInRepo()
+
+	include, exclude := migratePatterns()
+	if len(include) == 0 {
+		Exit("Usage: git lfs migrate export --include=<patterns> [--everything|<ref>...]")
+	}
+
+	refs := migrateRefs(args)
+	requireCleanWorkingCopy()
+
+	db := openMigrateObjectDatabase()
+	defer db.Close()
+
+	filter := migrateBlobFilter(include, exclude)
+	pointers := migrateExportScan(db, refs, filter)
+	migrateExportFetch(pointers)
+
+	attrs := newMigrateAttributes(db, include, false)
+	opt := &githistory.RewriteOptions{
+		BlobFilter: filter,
+		BlobFn:     migrateExportBlob,
+		TreeFn: func(treePath string, t *odb.Tree) (*odb.Tree, error) {
+			return attrs.rewriteTree(t)
+		},
+	}
+
+	migrate(db, refs, opt, "git lfs migrate export")
+}
+
+// migrateExportScan returns a pointer to each Git LFS object in the history of
+// refs which is to be exported.
+func migrateExportScan(db *githistory.ObjectDatabase, refs []*git.Ref, filter func(string) bool) []*lfs.WrappedPointer {
+	var pointers []*lfs.WrappedPointer
+	seen := make(map[string]bool)
+
+	opt := &githistory.RewriteOptions{
+		BlobFilter: filter,
+		BlobFn: func(filePath string, b *githistory.Blob) (*githistory.Blob, error) {
+			p := migrateDecodePointer(b)
+			if p != nil && !seen[p.Oid] {
+				seen[p.Oid] = true
+				pointers = append(pointers, &lfs.WrappedPointer{Name: filePath, Size: p.Size, Pointer: p})
+			}
+			return nil, nil
+		},
+	}
+	for _, ref := range refs {
+		opt.Include = append(opt.Include, ref.Sha)
+	}
+
+	// nothing is changed, so nothing is written
+	if _, err := githistory.NewRewriter(db).Rewrite(opt); err != nil {
+		Exit("Error scanning history: %v", err)
+	}
+	return pointers
+}
+
+// migrateExportFetch downloads any of the objects which are not in the local
+// store from the default remote, and exits if any are still missing.
+func migrateExportFetch(pointers []*lfs.WrappedPointer) {
+	var missing []*lfs.WrappedPointer
+	var totalSize int64
+	for _, p := range pointers {
+		lfs.LinkOrCopyFromReference(p.Oid, p.Size)
+		if !lfs.ObjectExistsOfSize(p.Oid, p.Size) {
+			missing = append(missing, p)
+			totalSize += p.Size
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	if remote, err := git.DefaultRemote(); err == nil {
+		config.Config.CurrentRemote = remote
+		q := lfs.NewDownloadQueue(len(missing), totalSize, false)
+		for _, p := range missing {
+			q.Add(lfs.NewDownloadable(p))
+		}
+		q.Wait()
+		for _, err := range q.Errors() {
+			LoggedError(err, "Error downloading object: %v", err)
+		}
+	}
+
+	var unavailable []*lfs.WrappedPointer
+	for _, p := range missing {
+		if !lfs.ObjectExistsOfSize(p.Oid, p.Size) {
+			unavailable = append(unavailable, p)
+		}
+	}
+	if len(unavailable) == 0 {
+		return
+	}
+
+	Error("Unable to export, %d Git LFS objects are not available:", len(unavailable))
+	for _, p := range unavailable {
+		Error("    %s (%s)", p.Name, p.Oid)
+	}
+	os.Exit(2)
+}
+
+// migrateExportBlob returns the contents of the Git LFS object that a file
+// points to, or nil if it is not a pointer.
+func migrateExportBlob(filePath string, b *githistory.Blob) (*githistory.Blob, error) {
+	p := migrateDecodePointer(b)
+	if p == nil {
+		return nil, nil
+	}
+
+	if len(p.Extensions) > 0 {
+		return migrateExportSmudged(filePath, p)
+	}
+
+	reader, err := localstorage.CurrentStore().Open(p.Oid)
+	if err != nil {
+		return nil, err
+	}
+	lfs.RecordObjectAccess(p.Oid)
+	return &githistory.Blob{Size: p.Size, Contents: reader}, nil
+}
+
+// migrateExportSmudged runs the smudge extensions of the pointer into a
+// temporary file, and returns its contents, since their size is not known
+// until they have all run.
+func migrateExportSmudged(filePath string, p *lfs.Pointer) (*githistory.Blob, error) {
+	tmp, err := lfs.TempFile("migrate-export")
+	if err != nil {
+		return nil, err
+	}
+	contents := &migrateTempFile{tmp}
+
+	if err := lfs.PointerSmudge(tmp, p, filePath, false, nil); err != nil {
+		contents.Close()
+		return nil, err
+	}
+
+	size, err := tmp.Seek(0, os.SEEK_CUR)
+	if err == nil {
+		_, err = tmp.Seek(0, os.SEEK_SET)
+	}
+	if err != nil {
+		contents.Close()
+		return nil, err
+	}
+	return &githistory.Blob{Size: size, Contents: contents}, nil
+}
+
+// migrateDecodePointer returns the pointer in the blob, or nil if it is not a
+// pointer.
+func migrateDecodePointer(b *githistory.Blob) *lfs.Pointer {
+	if b.Size >= 1024 {
+		return nil
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(b.Contents, b.Size))
+	if err != nil {
+		return nil
+	}
+	p, err := lfs.DecodePointer(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+// migrateTempFile is a temporary file which is removed when it is closed.
+type migrateTempFile struct {
+	*os.File
+}
+
+func (f *migrateTempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+func init() {
+	migrateCmd.AddCommand(migrateExportCmd)
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/github/git-lfs/errutil"
+	"github.com/github/git-lfs/git/githistory"
+	"github.com/github/git-lfs/git/odb"
+	"github.com/github/git-lfs/lfs"
+	"github.com/github/git-lfs/localstorage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateImportCmd = &cobra.Command{
+		Use: "import",
+		Run: migrateImportCommand,
+	}
+)
+
+// migrateImportCommand rewrites history so that the files matching --include
+// are stored as Git LFS pointers, and tracked in .gitattributes.
+func migrateImportCommand(cmd *cobra.Command, args []string) {
+	requireInRepo()
+
+	include, exclude := migratePatterns()
+	if len(include) == 0 {
+		Exit("Usage: git lfs migrate import --include=<patterns> [--everything|<ref>...]")
+	}
+
+	refs := migrateRefs(args)
+	requireCleanWorkingCopy()
+
+	db := openMigrateObjectDatabase()
+	defer db.Close()
+
+	attrs := newMigrateAttributes(db, include, true)
+	opt := &githistory.RewriteOptions{
+		BlobFilter: migrateBlobFilter(include, exclude),
+		BlobFn:     migrateImportBlob,
+		TreeFn: func(treePath string, t *odb.Tree) (*odb.Tree, error) {
+			if treePath != "" {
+				return t, nil
+			}
+			return attrs.rewriteTree(t)
+		},
+	}
+
+	migrate(db, refs, opt, "git lfs migrate import")
+	lfs.InstallHooks(false)
+}
+
+// migrateImportBlob adds the contents of a file to the local Git LFS objects,
+// and returns a pointer to them. Files which are already pointers are left as
+// they are.
+func migrateImportBlob(filePath string, b *githistory.Blob) (*githistory.Blob, error) {
+	cleaned, err := lfs.PointerClean(b.Contents, filePath, b.Size, nil)
+	if errutil.IsCleanPointerError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error cleaning %s: %v", filePath, err)
+	}
+
+	if _, err := localstorage.CurrentStore().Stat(cleaned.Oid); err == nil {
+		cleaned.Teardown()
+	} else if err := cleaned.Commit(); err != nil {
+		cleaned.Teardown()
+		return nil, fmt.Errorf("Unable to add %s to the local store: %v", cleaned.Oid, err)
+	}
+
+	pointer := []byte(cleaned.Pointer.Encoded())
+	return &githistory.Blob{Size: int64(len(pointer)), Contents: bytes.NewReader(pointer)}, nil
+}
+
+func init() {
+	migrateCmd.AddCommand(migrateImportCmd)
+}
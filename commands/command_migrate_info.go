@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/github/git-lfs/lfs"
+	"github.com/github/git-lfs/tools"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateInfoCmd = &cobra.Command{
+		Use: "info",
+		Run: migrateInfoCommand,
+	}
+
+	migrateInfoAboveArg string
+	migrateInfoTopArg   int
+	migrateInfoJsonArg  bool
+)
+
+// migrateInfoEntry is the total size of the blobs in history matching a
+// pattern, with blobs which are Git LFS pointers counted separately by the size
+// of the objects they point to.
+type migrateInfoEntry struct {
+	Pattern  string `json:"pattern"`
+	Files    int    `json:"files"`
+	Size     int64  `json:"size"`
+	LFSFiles int    `json:"lfs_files"`
+	LFSSize  int64  `json:"lfs_size"`
+}
+
+// migrateInfoCommand reports which kinds of file take up the most space in
+// history, grouped by extension, or by the --include pattern they match.
+func migrateInfoCommand(cmd *cobra.Command, args []string) {
+	requireInRepo()
+
+	var above int64
+	if len(migrateInfoAboveArg) > 0 {
+		size, err := tools.ParseSize(migrateInfoAboveArg)
+		if err != nil {
+			Exit("Invalid --above size: %q", migrateInfoAboveArg)
+		}
+		above = size
+	}
+	if migrateInfoTopArg < 0 {
+		Exit("Invalid --top count: %d", migrateInfoTopArg)
+	}
+
+	include, exclude := migratePatterns()
+	refs := migrateRefs(args)
+
+	opt := lfs.NewScanRefsOptions()
+	opt.ScanMode = lfs.ScanRefsListMode
+	for _, ref := range refs {
+		opt.Refs = append(opt.Refs, ref.Sha)
+	}
+
+	blobs, err := lfs.ScanBlobs(scanCtx, "", "", opt)
+	if err != nil {
+		Exit("Could not scan history: %v", err)
+	}
+
+	entries := migrateInfoEntries(blobs, include, exclude, above)
+	if migrateInfoTopArg > 0 && len(entries) > migrateInfoTopArg {
+		entries = entries[:migrateInfoTopArg]
+	}
+
+	if migrateInfoJsonArg {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			Exit("Error encoding entries: %v", err)
+		}
+		Print("%s", data)
+		return
+	}
+
+	printMigrateInfoEntries(entries)
+}
+
+// migrateInfoEntries groups the blobs which are at least above bytes by the
+// first --include pattern they match, or by extension if there are none, and
+// returns the groups ordered from the largest to the smallest.
+func migrateInfoEntries(blobs []*lfs.ScannedBlob, include, exclude []string, above int64) []*migrateInfoEntry {
+	byPattern := make(map[string]*migrateInfoEntry)
+	for _, b := range blobs {
+		size := b.Size
+		if b.Pointer != nil {
+			size = b.Pointer.Size
+		}
+		if size < above || migratePathMatches(b.Name, exclude) {
+			continue
+		}
+
+		pattern, ok := migrateInfoPattern(b.Name, include)
+		if !ok {
+			continue
+		}
+
+		entry, ok := byPattern[pattern]
+		if !ok {
+			entry = &migrateInfoEntry{Pattern: pattern}
+			byPattern[pattern] = entry
+		}
+
+		if b.Pointer != nil {
+			entry.LFSFiles++
+			entry.LFSSize += size
+		} else {
+			entry.Files++
+			entry.Size += size
+		}
+	}
+
+	entries := make([]*migrateInfoEntry, 0, len(byPattern))
+	for _, entry := range byPattern {
+		entries = append(entries, entry)
+	}
+	sort.Sort(migrateInfoEntriesBySize(entries))
+	return entries
+}
+
+// migrateInfoPattern returns the pattern to group a file under: the first of
+// the include patterns which it matches, or "*.<ext>" if there are none. Files
+// without an extension are grouped by name.
+func migrateInfoPattern(filePath string, include []string) (string, bool) {
+	if len(include) > 0 {
+		for _, pattern := range include {
+			if migratePathMatches(filePath, []string{pattern}) {
+				return pattern, true
+			}
+		}
+		return "", false
+	}
+
+	name := path.Base(filePath)
+	if ext := path.Ext(name); len(ext) > 0 && ext != name {
+		return "*" + ext, true
+	}
+	return name, true
+}
+
+func printMigrateInfoEntries(entries []*migrateInfoEntry) {
+	width := len("PATTERN")
+	for _, entry := range entries {
+		if len(entry.Pattern) > width {
+			width = len(entry.Pattern)
+		}
+	}
+
+	format := fmt.Sprintf("%%-%ds  %%10s  %%6s  %%10s  %%9s", width)
+	Print(format, "PATTERN", "SIZE", "FILES", "LFS SIZE", "LFS FILES")
+	for _, entry := range entries {
+		Print(format, entry.Pattern,
+			humanizeBytes(entry.Size), fmt.Sprintf("%d", entry.Files),
+			humanizeBytes(entry.LFSSize), fmt.Sprintf("%d", entry.LFSFiles))
+	}
+}
+
+// migrateInfoEntriesBySize sorts entries by the size of their plain git
+// blobs, then by the size of their Git LFS objects, largest first.
+type migrateInfoEntriesBySize []*migrateInfoEntry
+
+func (e migrateInfoEntriesBySize) Len() int      { return len(e) }
+func (e migrateInfoEntriesBySize) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e migrateInfoEntriesBySize) Less(i, j int) bool {
+	if e[i].Size != e[j].Size {
+		return e[i].Size > e[j].Size
+	}
+	if e[i].LFSSize != e[j].LFSSize {
+		return e[i].LFSSize > e[j].LFSSize
+	}
+	return e[i].Pattern < e[j].Pattern
+}
+
+func init() {
+	migrateInfoCmd.Flags().StringVarP(&migrateInfoAboveArg, "above", "", "", "Only count files at least this size")
+	migrateInfoCmd.Flags().IntVarP(&migrateInfoTopArg, "top", "", 5, "Show this many patterns, or 0 for all")
+	migrateInfoCmd.Flags().BoolVarP(&migrateInfoJsonArg, "json", "j", false, "Print the entries as JSON")
+	migrateCmd.AddCommand(migrateInfoCmd)
+}
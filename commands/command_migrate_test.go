@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/github/git-lfs/lfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigratePathMatches(t *testing.T) {
+	for _, c := range []struct {
+		Path     string
+		Patterns []string
+		Matches  bool
+	}{
+		{"a.bin", []string{"*.bin"}, true},
+		{"dir/a.bin", []string{"*.bin"}, true},
+		{"dir/a.txt", []string{"*.bin"}, false},
+		{"dir/a.bin", []string{"/a.bin"}, false},
+		{"a.bin", []string{"/a.bin"}, true},
+		{"dir/a.bin", []string{"dir/*.bin"}, true},
+		{"other/dir/a.bin", []string{"dir/*.bin"}, false},
+		{"dir/sub/a.txt", []string{"dir/**"}, true},
+		{"dirt/a.txt", []string{"dir/**"}, false},
+		{"a.psd", []string{"*.bin", "*.psd"}, true},
+		{"dir/sub/a.bin", []string{"dir/**/a.bin"}, true},
+		{"dir/sub/a.bin", []string{"dir/*.bin"}, false},
+		{"a b.psd", []string{"a[[:space:]]b.psd"}, true},
+	} {
+		assert.Equal(t, c.Matches, migratePathMatches(c.Path, c.Patterns), "%s %v", c.Path, c.Patterns)
+	}
+}
+
+func TestMigrateBlobFilterSkipsAttributes(t *testing.T) {
+	filter := migrateBlobFilter([]string{"*"}, []string{"*.txt"})
+
+	assert.True(t, filter("a.bin"))
+	assert.False(t, filter("a.txt"))
+	assert.False(t, filter(".gitattributes"))
+	assert.False(t, filter("dir/.gitattributes"))
+}
+
+func TestMigrateAttributesTracks(t *testing.T) {
+	attrs := newMigrateAttributes(nil, []string{"*.bin", "a b.psd"}, false)
+
+	assert.True(t, attrs.tracks("*.bin filter=lfs diff=lfs merge=lfs -text\n"))
+	assert.True(t, attrs.tracks("*.bin  filter=lfs"))
+	assert.True(t, attrs.tracks("a[[:space:]]b.psd filter=lfs diff=lfs merge=lfs -text"))
+	assert.False(t, attrs.tracks("*.bin text"))
+	assert.False(t, attrs.tracks("# *.bin filter=lfs"))
+	assert.False(t, attrs.tracks("*.dat filter=lfs diff=lfs merge=lfs -text"))
+	assert.False(t, attrs.tracks("\n"))
+}
+
+func TestMigrateInfoEntries(t *testing.T) {
+	blobs := []*lfs.ScannedBlob{
+		{Name: "a.bin", Size: 100},
+		{Name: "dir/b.bin", Size: 50},
+		{Name: "c.psd", Size: 130, Pointer: &lfs.Pointer{Size: 4000}},
+		{Name: "small.psd", Size: 10},
+		{Name: "Makefile", Size: 10},
+		{Name: "vendor/d.bin", Size: 500},
+	}
+
+	entries := migrateInfoEntries(blobs, nil, []string{"vendor/**"}, 0)
+	assert.Equal(t, []*migrateInfoEntry{
+		{Pattern: "*.bin", Files: 2, Size: 150},
+		{Pattern: "*.psd", Files: 1, Size: 10, LFSFiles: 1, LFSSize: 4000},
+		{Pattern: "Makefile", Files: 1, Size: 10},
+	}, entries)
+
+	entries = migrateInfoEntries(blobs, []string{"dir/**", "*.psd"}, nil, 50)
+	assert.Equal(t, []*migrateInfoEntry{
+		{Pattern: "dir/**", Files: 1, Size: 50},
+		{Pattern: "*.psd", LFSFiles: 1, LFSSize: 4000},
+	}, entries)
+}
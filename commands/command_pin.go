@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/github/git-lfs/git"
+	"github.com/github/git-lfs/lfs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pinCmd = &cobra.Command{
+		Use: "pin",
+		Run: pinCommand,
+	}
+
+	pinOidRE = regexp.MustCompile(`\A[0-9a-f]{64}\z`)
+)
+
+// pinCommand pins each of the given oids, paths or refs so that prune never
+// deletes their objects, or lists the pins if none are given.
+func pinCommand(cmd *cobra.Command, args []string) {
+	requireInRepo()
+
+	pins, err := lfs.ReadPins()
+	if err != nil {
+		Exit("Error reading pins: %v", err)
+	}
+
+	if len(args) == 0 {
+		Print("Listing pins")
+		for _, pin := range pins {
+			Print("    %s", pinDescription(pin))
+		}
+		return
+	}
+
+	var headPointers []*lfs.WrappedPointer
+	var added []*lfs.Pin
+	for _, arg := range args {
+		if pinOidRE.MatchString(arg) {
+			added = append(added, &lfs.Pin{Type: lfs.PinTypeObject, Value: arg})
+			continue
+		}
+
+		if _, err := os.Stat(arg); err == nil {
+			if headPointers == nil {
+				headPointers, err = lfs.ScanTree(scanCtx, "HEAD")
+				if err != nil {
+					Exit("Could not scan for Git LFS files: %v", err)
+				}
+			}
+
+			path := pinPathRelativeToRepo(arg)
+			found := false
+			for _, p := range headPointers {
+				if pinPathMatches(path, p.Name) {
+					added = append(added, &lfs.Pin{Type: lfs.PinTypeObject, Value: p.Oid, Path: p.Name})
+					found = true
+				}
+			}
+			if !found {
+				Exit("No Git LFS files found at %q in HEAD", arg)
+			}
+			continue
+		}
+
+		ref, err := git.ResolveRef(arg)
+		if err != nil {
+			Exit("%q is not an oid, a path or a ref", arg)
+		}
+		added = append(added, &lfs.Pin{Type: lfs.PinTypeRef, Value: ref.FullName()})
+	}
+
+AddedLoop:
+	for _, pin := range added {
+		for _, existing := range pins {
+			if *existing == *pin {
+				Print("%s already pinned", pinDescription(pin))
+				continue AddedLoop
+			}
+		}
+		pins = append(pins, pin)
+		Print("Pinned %s", pinDescription(pin))
+	}
+
+	if err := lfs.WritePins(pins); err != nil {
+		Exit("Error writing pins: %v", err)
+	}
+}
+
+// pinDescription describes a pin for output, by its path if it has one.
+func pinDescription(pin *lfs.Pin) string {
+	switch {
+	case pin.Type == lfs.PinTypeRef:
+		return "ref " + pin.Value
+	case len(pin.Path) > 0:
+		return pin.Path + " (" + pin.Value + ")"
+	default:
+		return pin.Value
+	}
+}
+
+// pinPathRelativeToRepo returns the given path relative to the root of the
+// working directory, as it appears in git trees.
+func pinPathRelativeToRepo(path string) string {
+	cwdchan := make(chan string, 1)
+	cwdchan <- path
+	close(cwdchan)
+
+	relchan, err := lfs.ConvertCwdFilesRelativeToRepo(cwdchan)
+	if err != nil {
+		Exit("%s", err)
+	}
+	return filepath.ToSlash(<-relchan)
+}
+
+// pinPathMatches returns whether name is the pinned path, or is inside it.
+func pinPathMatches(path, name string) bool {
+	return path == "." || name == path || strings.HasPrefix(name, path+"/")
+}
+
+func init() {
+	RootCmd.AddCommand(pinCmd)
+}
@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/github/git-lfs/api"
+	"github.com/github/git-lfs/git"
+	"github.com/github/git-lfs/lfs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	preReceiveCmd = &cobra.Command{
+		Use: "pre-receive",
+		Run: preReceiveCommand,
+	}
+	preReceiveStore = ""
+)
+
+// preReceiveCommand is run through a Git server's pre-receive hook, before any
+// refs are updated. The hook receives the refs being pushed on stdin in the
+// form:
+//
+//	<old sha1> <new sha1> <ref>
+//
+// The commits that are new to the repository are listed with:
+//
+//	git rev-list --objects <new sha1>... ^<sha1 of every existing ref>...
+//
+// and the push is rejected if any Git LFS object that they point to is not on
+// the Git LFS server, or in the local store given with --store, or if any file
+// that they add or modify in a Git LFS path is not a pointer.
+//
+// In the case of deleting a ref, there is nothing to check.
+func preReceiveCommand(cmd *cobra.Command, args []string) {
+	requireInRepo()
+	requireStdin("This should be run through Git's pre-receive hook.")
+
+	var newShas []string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[1] == prePushDeleteBranch {
+			continue
+		}
+		newShas = append(newShas, fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		ExitWithError(err)
+	}
+	if len(newShas) == 0 {
+		return
+	}
+
+	pointers, err := preReceivePointers(newShas)
+	if err != nil {
+		Panic(err, "Error scanning for Git LFS files")
+	}
+	missing, err := preReceiveMissing(pointers)
+	if err != nil {
+		Panic(err, "Error checking for Git LFS objects")
+	}
+
+	blobs, err := lfs.ScanChangedBlobs(scanCtx, append(newShas, "--not", "--all"))
+	if err != nil {
+		Panic(err, "Error scanning for Git LFS files")
+	}
+	problems, err := fsckPointerProblems(blobs)
+	if err != nil {
+		Panic(err, "Error checking for Git LFS pointers")
+	}
+
+	var unconverted int
+	for _, p := range missing {
+		if len(preReceiveStore) > 0 {
+			Error("Object %s (%s) is missing from %s", p.Name, p.Oid, preReceiveStore)
+		} else {
+			Error("Object %s (%s) is missing from the Git LFS server", p.Name, p.Oid)
+		}
+	}
+	for _, b := range problems {
+		// a pointer outside of a Git LFS path is harmless
+		if b.Pointer != nil {
+			continue
+		}
+		Error("File %s (%s) should be a Git LFS pointer", b.Name, b.Sha1)
+		unconverted++
+	}
+
+	if len(missing) > 0 || unconverted > 0 {
+		Error("Push rejected: %d missing Git LFS object(s), %d file(s) not converted to Git LFS pointers", len(missing), unconverted)
+		os.Exit(1)
+	}
+}
+
+// preReceivePointers returns the pointers in the history of newShas which is
+// not already in the history of a ref in the repository.
+func preReceivePointers(newShas []string) ([]*lfs.WrappedPointer, error) {
+	refs, err := git.AllRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	opt := lfs.NewScanRefsOptions()
+	opt.ScanMode = lfs.ScanRefsListMode
+	opt.Refs = append(opt.Refs, newShas...)
+	for _, ref := range refs {
+		opt.Refs = append(opt.Refs, "^"+ref.Sha)
+	}
+	return lfs.ScanRefs(scanCtx, "", "", opt)
+}
+
+// preReceiveMissing returns each of pointers whose object is not in the local
+// store given with --store, or on the Git LFS server otherwise.
+func preReceiveMissing(pointers []*lfs.WrappedPointer) ([]*lfs.WrappedPointer, error) {
+	var missing []*lfs.WrappedPointer
+
+	if len(preReceiveStore) > 0 {
+		for _, p := range pointers {
+			path := filepath.Join(preReceiveStore, p.Oid[0:2], p.Oid[2:4], p.Oid)
+			if stat, err := os.Stat(path); err != nil || stat.Size() != p.Size {
+				missing = append(missing, p)
+			}
+		}
+		return missing, nil
+	}
+
+	const batchSize = 100
+	for start := 0; start < len(pointers); start += batchSize {
+		end := start + batchSize
+		if end > len(pointers) {
+			end = len(pointers)
+		}
+
+		objects := make([]*api.ObjectResource, 0, end-start)
+		for _, p := range pointers[start:end] {
+			objects = append(objects, &api.ObjectResource{Oid: p.Oid, Size: p.Size})
+		}
+
+		objs, _, err := api.Batch(objects, "download", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		found := make(map[string]bool, len(objs))
+		for _, o := range objs {
+			if _, ok := o.Rel("download"); ok && o.Error == nil {
+				found[o.Oid] = true
+			}
+		}
+		for _, p := range pointers[start:end] {
+			if !found[p.Oid] {
+				missing = append(missing, p)
+			}
+		}
+	}
+
+	return missing, nil
+}
+
+func init() {
+	preReceiveCmd.Flags().StringVarP(&preReceiveStore, "store", "", "", "Check for objects in this directory instead of on the Git LFS server")
+	RootCmd.AddCommand(preReceiveCmd)
+}
@@ -3,7 +3,8 @@ package commands
 import (
 	"bytes"
 	"fmt"
-	"os"
+	"path"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/github/git-lfs/lfs"
 	"github.com/github/git-lfs/localstorage"
 	"github.com/github/git-lfs/progress"
+	"github.com/github/git-lfs/tools"
 	"github.com/rubyist/tracerx"
 	"github.com/spf13/cobra"
 )
@@ -26,8 +28,13 @@ var (
 	pruneVerboseArg     bool
 	pruneVerifyArg      bool
 	pruneDoNotVerifyArg bool
+	pruneToSizeArg      string
 )
 
+// pruneToSizeFromConfig is the value of --to-size when given without a size,
+// meaning that the size in lfs.storage.maxsize is used.
+const pruneToSizeFromConfig = "lfs.storage.maxsize"
+
 func pruneCommand(cmd *cobra.Command, args []string) {
 
 	// Guts of this must be re-usable from fetch --prune so just parse & dispatch
@@ -38,7 +45,21 @@ func pruneCommand(cmd *cobra.Command, args []string) {
 	verify := !pruneDoNotVerifyArg &&
 		(config.Config.FetchPruneConfig().PruneVerifyRemoteAlways || pruneVerifyArg)
 
-	prune(verify, pruneDryRunArg, pruneVerboseArg)
+	var maxSize int64
+	if pruneToSizeArg == pruneToSizeFromConfig {
+		maxSize = config.Config.FetchPruneConfig().StorageMaxSize
+		if maxSize == 0 {
+			Exit("Cannot specify --to-size without a size unless lfs.storage.maxsize is set")
+		}
+	} else if len(pruneToSizeArg) > 0 {
+		size, err := tools.ParseSize(pruneToSizeArg)
+		if err != nil || size == 0 {
+			Exit("Invalid --to-size %q, expected a size such as 500m or 10g", pruneToSizeArg)
+		}
+		maxSize = size
+	}
+
+	prune(verify, pruneDryRunArg, pruneVerboseArg, maxSize)
 
 }
 
@@ -57,18 +78,24 @@ type PruneProgress struct {
 }
 type PruneProgressChan chan PruneProgress
 
-func prune(verifyRemote, dryRun, verbose bool) {
+// prune deletes the local objects that are not retained. If maxSize is not 0,
+// retained objects are then deleted, least recently used first, until the
+// local objects take up no more than maxSize bytes. Objects in the current
+// checkout, other worktrees or unpushed commits are never deleted.
+func prune(verifyRemote, dryRun, verbose bool, maxSize int64) {
 	localObjects := make([]localstorage.Object, 0, 100)
 	retainedObjects := lfs.NewStringSetWithCapacity(100)
+	protectedObjects := lfs.NewStringSetWithCapacity(100)
+	pinnedObjects := lfs.NewStringSet()
 	var reachableObjects lfs.StringSet
 	var taskwait sync.WaitGroup
 
 	// Add all the base funcs to the waitgroup before starting them, in case
 	// one completes really fast & hits 0 unexpectedly
 	// each main process can Add() to the wg itself if it subdivides the task
-	taskwait.Add(4) // 1..4: localObjects, current & recent refs, unpushed, worktree
+	taskwait.Add(5) // 1..5: localObjects, current & recent refs, unpushed, worktree, pinned
 	if verifyRemote {
-		taskwait.Add(1) // 5
+		taskwait.Add(1) // 6
 	}
 
 	progressChan := make(PruneProgressChan, 100)
@@ -85,10 +112,15 @@ func prune(verifyRemote, dryRun, verbose bool) {
 
 	// Now find files to be retained from many sources
 	retainChan := make(chan string, 100)
-
-	go pruneTaskGetRetainedCurrentAndRecentRefs(retainChan, errorChan, &taskwait)
-	go pruneTaskGetRetainedUnpushed(retainChan, errorChan, &taskwait)
-	go pruneTaskGetRetainedWorktree(retainChan, errorChan, &taskwait)
+	// Files which must be retained even if over the size limit
+	protectChan := make(chan string, 100)
+	// Files which are pinned, and so are also protected
+	pinChan := make(chan string, 100)
+
+	go pruneTaskGetRetainedCurrentAndRecentRefs(retainChan, protectChan, errorChan, &taskwait)
+	go pruneTaskGetRetainedUnpushed(protectChan, errorChan, &taskwait)
+	go pruneTaskGetRetainedWorktree(protectChan, errorChan, &taskwait)
+	go pruneTaskGetPinned(pinChan, errorChan, &taskwait)
 	if verifyRemote {
 		reachableObjects = lfs.NewStringSetWithCapacity(100)
 		go pruneTaskGetReachableObjects(&reachableObjects, errorChan, &taskwait)
@@ -98,20 +130,36 @@ func prune(verifyRemote, dryRun, verbose bool) {
 	var retainwait sync.WaitGroup
 	retainwait.Add(1)
 	go pruneTaskCollectRetained(&retainedObjects, retainChan, progressChan, &retainwait)
+	var protectwait sync.WaitGroup
+	protectwait.Add(1)
+	go pruneTaskCollectProtected(&protectedObjects, protectChan, retainChan, &protectwait)
+	var pinwait sync.WaitGroup
+	pinwait.Add(1)
+	go pruneTaskCollectProtected(&pinnedObjects, pinChan, protectChan, &pinwait)
 
 	// Report progress
 	var progresswait sync.WaitGroup
 	progresswait.Add(1)
 	go pruneTaskDisplayProgress(progressChan, &progresswait)
 
-	taskwait.Wait()   // wait for subtasks
-	close(retainChan) // triggers retain collector to end now all tasks have
-	retainwait.Wait() // make sure all retained objects added
+	taskwait.Wait()    // wait for subtasks
+	close(pinChan)     // triggers pin collector to end now all tasks have
+	pinwait.Wait()     // make sure all pinned objects passed on to be protected
+	close(protectChan) // triggers protect collector to end now all tasks have
+	protectwait.Wait() // make sure all protected objects passed on to be retained
+	close(retainChan)  // triggers retain collector to end now all tasks have
+	retainwait.Wait()  // make sure all retained objects added
 
 	close(errorChan) // triggers error collector to end now all tasks have
 	errorwait.Wait() // make sure all errors have been processed
 	pruneCheckErrors(taskErrors)
 
+	if !dryRun {
+		if err := lfs.PruneScanCache(); err != nil {
+			Debug("Unable to prune the scan cache: %v", err)
+		}
+	}
+
 	prunableObjects := make([]string, 0, len(localObjects)/2)
 
 	// Build list of prunables (also queue for verify at same time if applicable)
@@ -131,20 +179,33 @@ func prune(verifyRemote, dryRun, verbose bool) {
 		verifyc = verifyQueue.Watch()
 	}
 
+	prunableFiles := make([]localstorage.Object, 0, len(localObjects)/2)
 	for _, file := range localObjects {
 		if !retainedObjects.Contains(file.Oid) {
-			prunableObjects = append(prunableObjects, file.Oid)
-			totalSize += file.Size
-			if verbose {
-				// Save up verbose output for the end, spinner still going
-				verboseOutput.WriteString(fmt.Sprintf(" * %v (%v)\n", file.Oid, humanizeBytes(file.Size)))
-			}
+			prunableFiles = append(prunableFiles, file)
+		}
+	}
+	if maxSize > 0 {
+		evictedFiles, remainingSize := pruneObjectsOverSize(localObjects, retainedObjects, protectedObjects, maxSize)
+		prunableFiles = append(prunableFiles, evictedFiles...)
+		if remainingSize > maxSize {
+			Print("Unable to prune to %v, %v is needed by the current checkout, worktrees, unpushed commits or pins",
+				humanizeBytes(maxSize), humanizeBytes(remainingSize))
+		}
+	}
 
-			if verifyRemote {
-				tracerx.Printf("VERIFYING: %v", file.Oid)
-				pointer := lfs.NewPointer(file.Oid, file.Size, nil)
-				verifyQueue.Add(lfs.NewDownloadable(&lfs.WrappedPointer{Pointer: pointer}))
-			}
+	for _, file := range prunableFiles {
+		prunableObjects = append(prunableObjects, file.Oid)
+		totalSize += file.Size
+		if verbose {
+			// Save up verbose output for the end, spinner still going
+			verboseOutput.WriteString(fmt.Sprintf(" * %v (%v)\n", file.Oid, humanizeBytes(file.Size)))
+		}
+
+		if verifyRemote {
+			tracerx.Printf("VERIFYING: %v", file.Oid)
+			pointer := lfs.NewPointer(file.Oid, file.Size, nil)
+			verifyQueue.Add(lfs.NewDownloadable(&lfs.WrappedPointer{Pointer: pointer}))
 		}
 	}
 
@@ -169,6 +230,10 @@ func prune(verifyRemote, dryRun, verbose bool) {
 		progresswait.Wait()
 	}
 
+	if dryRun || verbose {
+		pruneReportPinned(localObjects, pinnedObjects, verbose)
+	}
+
 	if len(prunableObjects) == 0 {
 		Print("Nothing to prune")
 		return
@@ -188,6 +253,30 @@ func prune(verifyRemote, dryRun, verbose bool) {
 
 }
 
+// pruneReportPinned prints how many of the local objects are retained because
+// they are pinned, listing them if verbose.
+func pruneReportPinned(localObjects []localstorage.Object, pinnedObjects lfs.StringSet, verbose bool) {
+	var count int
+	var size int64
+	var output bytes.Buffer
+	for _, file := range localObjects {
+		if !pinnedObjects.Contains(file.Oid) {
+			continue
+		}
+		count++
+		size += file.Size
+		output.WriteString(fmt.Sprintf(" * %v (%v)\n", file.Oid, humanizeBytes(file.Size)))
+	}
+
+	if count == 0 {
+		return
+	}
+	Print("%d pinned files retained (%v)", count, humanizeBytes(size))
+	if verbose {
+		Print("%s", output.String())
+	}
+}
+
 func pruneCheckVerified(prunableObjects []string, reachableObjects, verifiedObjects lfs.StringSet) {
 	// There's no issue if an object is not reachable and missing, only if reachable & missing
 	var problems bytes.Buffer
@@ -259,6 +348,79 @@ func pruneTaskCollectRetained(outRetainedObjects *lfs.StringSet, retainChan chan
 
 }
 
+// pruneTaskCollectProtected adds each protected object to outProtectedObjects,
+// and passes it on to be retained. It also collects pinned objects, passing
+// them on to be protected.
+func pruneTaskCollectProtected(outProtectedObjects *lfs.StringSet, protectChan, retainChan chan string,
+	protectwait *sync.WaitGroup) {
+
+	defer protectwait.Done()
+
+	for oid := range protectChan {
+		outProtectedObjects.Add(oid)
+		retainChan <- oid
+	}
+
+}
+
+// pruneObjectsOverSize returns the retained local objects to delete, least
+// recently used first, so that the local objects take up no more than maxSize
+// bytes, along with the size they will take up afterwards. Protected objects
+// are never returned, so that may still be more than maxSize.
+func pruneObjectsOverSize(localObjects []localstorage.Object, retainedObjects, protectedObjects lfs.StringSet,
+	maxSize int64) ([]localstorage.Object, int64) {
+
+	var size int64
+	candidates := make(pruneObjectsByAccessTime, 0, len(localObjects))
+	for _, file := range localObjects {
+		if !retainedObjects.Contains(file.Oid) {
+			continue
+		}
+		size += file.Size
+		if protectedObjects.Contains(file.Oid) {
+			continue
+		}
+		accessTime, err := lfs.ObjectAccessTime(file.Oid)
+		if err != nil {
+			tracerx.Printf("PRUNE: Unable to find when %v was last used: %v", file.Oid, err)
+			continue
+		}
+		candidates = append(candidates, pruneObjectAccess{file, accessTime})
+	}
+
+	if size <= maxSize {
+		return nil, size
+	}
+
+	sort.Sort(candidates)
+	var evicted []localstorage.Object
+	for _, candidate := range candidates {
+		if size <= maxSize {
+			break
+		}
+		evicted = append(evicted, candidate.Object)
+		size -= candidate.Size
+		tracerx.Printf("EVICT: %v last used %v", candidate.Oid, candidate.AccessTime)
+	}
+	return evicted, size
+}
+
+// pruneObjectAccess is a local object and the time it was last used.
+type pruneObjectAccess struct {
+	localstorage.Object
+	AccessTime time.Time
+}
+
+// pruneObjectsByAccessTime sorts objects by the time they were last used,
+// oldest first.
+type pruneObjectsByAccessTime []pruneObjectAccess
+
+func (a pruneObjectsByAccessTime) Len() int      { return len(a) }
+func (a pruneObjectsByAccessTime) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a pruneObjectsByAccessTime) Less(i, j int) bool {
+	return a[i].AccessTime.Before(a[j].AccessTime)
+}
+
 func pruneTaskCollectErrors(outtaskErrors *[]error, errorChan chan error, errorwait *sync.WaitGroup) {
 	defer errorwait.Done()
 
@@ -274,15 +436,12 @@ func pruneDeleteFiles(prunableObjects []string) {
 	var deletedFiles int
 	for i, oid := range prunableObjects {
 		spinner.Print(OutputWriter, fmt.Sprintf("Deleting object %d/%d", i, len(prunableObjects)))
-		mediaFile, err := lfs.LocalMediaPath(oid)
-		if err != nil {
-			problems.WriteString(fmt.Sprintf("Unable to find media path for %v: %v\n", oid, err))
+		if err := localstorage.CurrentStore().Delete(oid); err != nil {
+			problems.WriteString(fmt.Sprintf("Failed to remove object %v: %v\n", oid, err))
 			continue
 		}
-		err = os.Remove(mediaFile)
-		if err != nil {
-			problems.WriteString(fmt.Sprintf("Failed to remove file %v: %v\n", mediaFile, err))
-			continue
+		if err := lfs.RemoveFromSharedStorage(oid); err != nil {
+			problems.WriteString(fmt.Sprintf("Failed to remove %v from shared storage: %v\n", oid, err))
 		}
 		deletedFiles++
 	}
@@ -312,7 +471,7 @@ func pruneTaskGetRetainedAtRef(ref string, retainChan chan string, errorChan cha
 	opts := lfs.NewScanRefsOptions()
 	opts.ScanMode = lfs.ScanRefsMode
 	opts.SkipDeletedBlobs = true
-	refchan, err := lfs.ScanRefsToChan(ref, "", opts)
+	refchan, err := lfs.ScanRefsToChan(scanCtx, ref, "", opts)
 	if err != nil {
 		errorChan <- err
 		return
@@ -331,7 +490,7 @@ func pruneTaskGetRetainedAtRef(ref string, retainChan chan string, errorChan cha
 func pruneTaskGetPreviousVersionsOfRef(ref string, since time.Time, retainChan chan string, errorChan chan error, waitg *sync.WaitGroup) {
 	defer waitg.Done()
 
-	refchan, err := lfs.ScanPreviousVersionsToChan(ref, since)
+	refchan, err := lfs.ScanPreviousVersionsToChan(scanCtx, ref, since)
 	if err != nil {
 		errorChan <- err
 		return
@@ -347,7 +506,7 @@ func pruneTaskGetPreviousVersionsOfRef(ref string, since time.Time, retainChan c
 }
 
 // Background task, must call waitg.Done() once at end
-func pruneTaskGetRetainedCurrentAndRecentRefs(retainChan chan string, errorChan chan error, waitg *sync.WaitGroup) {
+func pruneTaskGetRetainedCurrentAndRecentRefs(retainChan, protectChan chan string, errorChan chan error, waitg *sync.WaitGroup) {
 	defer waitg.Done()
 
 	// We actually increment the waitg in this func since we kick off sub-goroutines
@@ -361,7 +520,7 @@ func pruneTaskGetRetainedCurrentAndRecentRefs(retainChan chan string, errorChan
 	}
 	commits.Add(ref.Sha)
 	waitg.Add(1)
-	go pruneTaskGetRetainedAtRef(ref.Sha, retainChan, errorChan, waitg)
+	go pruneTaskGetRetainedAtRef(ref.Sha, protectChan, errorChan, waitg)
 
 	// Now recent
 	fetchconf := config.Config.FetchPruneConfig()
@@ -407,7 +566,7 @@ func pruneTaskGetRetainedUnpushed(retainChan chan string, errorChan chan error,
 
 	remoteName := config.Config.FetchPruneConfig().PruneRemoteName
 
-	refchan, err := lfs.ScanUnpushedToChan(remoteName)
+	refchan, err := lfs.ScanUnpushedToChan(scanCtx, remoteName)
 	if err != nil {
 		errorChan <- err
 		return
@@ -454,6 +613,68 @@ func pruneTaskGetRetainedWorktree(retainChan chan string, errorChan chan error,
 
 }
 
+// Background task, must call waitg.Done() once at end
+func pruneTaskGetPinned(pinChan chan string, errorChan chan error, waitg *sync.WaitGroup) {
+	defer waitg.Done()
+
+	pins, err := lfs.ReadPins()
+	if err != nil {
+		errorChan <- err
+		return
+	}
+
+	// Don't scan the same commit twice if pinned by more than one ref
+	commits := lfs.NewStringSet()
+	for _, pin := range pins {
+		switch pin.Type {
+		case lfs.PinTypeObject:
+			pinChan <- pin.Value
+			tracerx.Printf("RETAIN: %v pinned", pin.Value)
+		case lfs.PinTypeRef:
+			ref, err := git.ResolveRef(pin.Value)
+			if err != nil {
+				Error("WARNING: Ignoring pinned ref %v: %v", pin.Value, err)
+				continue
+			}
+			if commits.Add(ref.Sha) {
+				waitg.Add(1)
+				go pruneTaskGetRetainedAtRef(ref.Sha, pinChan, errorChan, waitg)
+			}
+		}
+	}
+
+	patterns := config.Config.FetchPruneConfig().PruneProtectRefs
+	if len(patterns) == 0 {
+		return
+	}
+	refs, err := git.LocalRefs()
+	if err != nil {
+		errorChan <- err
+		return
+	}
+	for _, ref := range refs {
+		if pruneRefIsProtected(ref, patterns) && commits.Add(ref.Sha) {
+			tracerx.Printf("PRUNE: Retaining protected ref %v", ref.FullName())
+			waitg.Add(1)
+			go pruneTaskGetRetainedAtRef(ref.Sha, pinChan, errorChan, waitg)
+		}
+	}
+}
+
+// pruneRefIsProtected returns whether ref matches any of the lfs.pruneprotectrefs
+// glob patterns, by either its full or short name.
+func pruneRefIsProtected(ref *git.Ref, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, ref.FullName()); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, ref.Name); matched {
+			return true
+		}
+	}
+	return false
+}
+
 // Background task, must call waitg.Done() once at end
 func pruneTaskGetReachableObjects(outObjectSet *lfs.StringSet, errorChan chan error, waitg *sync.WaitGroup) {
 	defer waitg.Done()
@@ -464,7 +685,7 @@ func pruneTaskGetReachableObjects(outObjectSet *lfs.StringSet, errorChan chan er
 	opts.ScanMode = lfs.ScanAllMode
 	opts.SkipDeletedBlobs = false
 
-	pointerchan, err := lfs.ScanRefsToChan("", "", opts)
+	pointerchan, err := lfs.ScanRefsToChan(scanCtx, "", "", opts)
 	if err != nil {
 		errorChan <- fmt.Errorf("Error scanning for reachable objects: %v", err)
 		return
@@ -485,5 +706,7 @@ func init() {
 	pruneCmd.Flags().BoolVarP(&pruneVerboseArg, "verbose", "v", false, "Print full details of what is/would be deleted")
 	pruneCmd.Flags().BoolVarP(&pruneVerifyArg, "verify-remote", "c", false, "Verify that remote has LFS files before deleting")
 	pruneCmd.Flags().BoolVar(&pruneDoNotVerifyArg, "no-verify-remote", false, "Override lfs.pruneverifyremotealways and don't verify")
+	pruneCmd.Flags().StringVar(&pruneToSizeArg, "to-size", "", "Also delete least recently used files until under this size")
+	pruneCmd.Flags().Lookup("to-size").NoOptDefVal = pruneToSizeFromConfig
 	RootCmd.AddCommand(pruneCmd)
 }
@@ -49,6 +49,11 @@ func pull(includePaths, excludePaths []string) {
 	c := fetchRefToChan(ref.Sha, includePaths, excludePaths)
 	checkoutFromFetchChan(includePaths, excludePaths, c)
 
+	fetchconf := config.Config.FetchPruneConfig()
+	if fetchconf.PruneAfterFetch && fetchconf.StorageMaxSize > 0 {
+		prune(fetchconf.PruneVerifyRemoteAlways, false, false, fetchconf.StorageMaxSize)
+	}
+
 }
 
 func init() {
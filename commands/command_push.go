@@ -31,7 +31,7 @@ func uploadsBetweenRefs(ctx *uploadContext, left string, right string) {
 	scanOpt.ScanMode = lfs.ScanRefsMode
 	scanOpt.RemoteName = config.Config.CurrentRemote
 
-	pointers, err := lfs.ScanRefs(left, right, scanOpt)
+	pointers, err := lfs.ScanRefs(scanCtx, left, right, scanOpt)
 	if err != nil {
 		Panic(err, "Error scanning for Git LFS files")
 	}
@@ -57,7 +57,7 @@ func uploadsBetweenRefAndRemote(ctx *uploadContext, refnames []string) {
 	}
 
 	for _, ref := range refs {
-		pointers, err := lfs.ScanRefs(ref.Name, "", scanOpt)
+		pointers, err := lfs.ScanRefs(scanCtx, ref.Name, "", scanOpt)
 		if err != nil {
 			Panic(err, "Error scanning for Git LFS files in the %q ref", ref.Name)
 		}
@@ -9,6 +9,7 @@ import (
 	"github.com/github/git-lfs/config"
 	"github.com/github/git-lfs/errutil"
 	"github.com/github/git-lfs/lfs"
+	"github.com/github/git-lfs/localstorage"
 	"github.com/spf13/cobra"
 )
 
@@ -47,11 +48,11 @@ func smudgeCommand(cmd *cobra.Command, args []string) {
 			Exit(err.Error())
 		}
 
-		stat, err := os.Stat(localPath)
+		obj, err := localstorage.CurrentStore().Stat(ptr.Oid)
 		if err != nil {
 			Print("%d --", ptr.Size)
 		} else {
-			Print("%d %s", stat.Size(), localPath)
+			Print("%d %s", obj.Size, localPath)
 		}
 		return
 	}
@@ -24,7 +24,7 @@ func statusCommand(cmd *cobra.Command, args []string) {
 		Panic(err, "Could not get the current ref")
 	}
 
-	stagedPointers, err := lfs.ScanIndex()
+	stagedPointers, err := lfs.ScanIndex(scanCtx)
 	if err != nil {
 		Panic(err, "Could not scan staging for Git LFS objects")
 	}
@@ -48,7 +48,7 @@ func statusCommand(cmd *cobra.Command, args []string) {
 	remoteRef, err := git.CurrentRemoteRef()
 	if err == nil {
 
-		pointers, err := lfs.ScanRefs(ref.Sha, "^"+remoteRef.Sha, nil)
+		pointers, err := lfs.ScanRefs(scanCtx, ref.Sha, "^"+remoteRef.Sha, nil)
 		if err != nil {
 			Panic(err, "Could not scan for Git LFS objects")
 		}
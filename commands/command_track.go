@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 	"os"
@@ -11,7 +10,7 @@ import (
 
 	"github.com/github/git-lfs/config"
 	"github.com/github/git-lfs/git"
-
+	"github.com/github/git-lfs/git/gitattr"
 	"github.com/github/git-lfs/lfs"
 	"github.com/spf13/cobra"
 )
@@ -155,14 +154,16 @@ func findPaths() []mediaPath {
 			continue
 		}
 
-		scanner := bufio.NewScanner(attributes)
+		lines, err := gitattr.ParseLines(attributes)
+		attributes.Close()
+		if err != nil {
+			continue
+		}
 
-		for scanner.Scan() {
-			line := scanner.Text()
-			if strings.Contains(line, "filter=lfs") {
-				fields := strings.Fields(line)
+		for _, line := range lines {
+			if line.Tracked() {
 				relfile, _ := filepath.Rel(config.LocalWorkingDir, path)
-				pattern := fields[0]
+				pattern := line.Pattern
 				if reldir := filepath.Dir(relfile); len(reldir) > 0 {
 					pattern = filepath.Join(reldir, pattern)
 				}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"github.com/github/git-lfs/git"
+	"github.com/github/git-lfs/lfs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	unpinCmd = &cobra.Command{
+		Use: "unpin",
+		Run: unpinCommand,
+	}
+)
+
+// unpinCommand removes the pins for each of the given oids, paths or refs, so
+// that prune may delete their objects again.
+func unpinCommand(cmd *cobra.Command, args []string) {
+	requireInRepo()
+
+	if len(args) < 1 {
+		Print("git lfs unpin <oid|path|ref> [oid|path|ref]*")
+		return
+	}
+
+	pins, err := lfs.ReadPins()
+	if err != nil {
+		Exit("Error reading pins: %v", err)
+	}
+
+	unpinned := make([]bool, len(pins))
+	for _, arg := range args {
+		// pinned paths and refs may no longer exist, so match them by name
+		path := pinPathRelativeToRepo(arg)
+		refName := arg
+		if ref, err := git.ResolveRef(arg); err == nil {
+			refName = ref.FullName()
+		}
+
+		found := false
+		for i, pin := range pins {
+			var matches bool
+			switch pin.Type {
+			case lfs.PinTypeObject:
+				matches = pin.Value == arg || (len(pin.Path) > 0 && pinPathMatches(path, pin.Path))
+			case lfs.PinTypeRef:
+				matches = pin.Value == arg || pin.Value == refName
+			}
+			if matches {
+				unpinned[i] = true
+				found = true
+			}
+		}
+		if !found {
+			Print("%s is not pinned", arg)
+		}
+	}
+
+	remaining := make([]*lfs.Pin, 0, len(pins))
+	for i, pin := range pins {
+		if unpinned[i] {
+			Print("Unpinned %s", pinDescription(pin))
+		} else {
+			remaining = append(remaining, pin)
+		}
+	}
+
+	if err := lfs.WritePins(remaining); err != nil {
+		Exit("Error writing pins: %v", err)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(unpinCmd)
+}
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -15,6 +16,7 @@ import (
 	"github.com/github/git-lfs/config"
 	"github.com/github/git-lfs/errutil"
 	"github.com/github/git-lfs/git"
+	"github.com/github/git-lfs/git/gitattr"
 	"github.com/github/git-lfs/lfs"
 	"github.com/github/git-lfs/tools"
 	"github.com/spf13/cobra"
@@ -40,8 +42,18 @@ var (
 		},
 	}
 	ManPages = make(map[string]string, 20)
+
+	// scanCtx is given to the scans of the repository that commands run,
+	// so that Cancel can stop them.
+	scanCtx, cancelScans = context.WithCancel(context.Background())
 )
 
+// Cancel stops any scans of the repository that are running, killing the git
+// commands they have started. It is called when git-lfs is interrupted.
+func Cancel() {
+	cancelScans()
+}
+
 // Error prints a formatted message to Stderr.  It also gets printed to the
 // panic log if one is created for this command.
 func Error(format string, args ...interface{}) {
@@ -229,6 +241,31 @@ func determineIncludeExcludePaths(config *config.Configuration, includeArg, excl
 		tools.CleanPathsDefault(excludeArg, ",", config.FetchExcludePaths())
 }
 
+// gitAttributes returns the attributes given by the .gitattributes files of
+// source, with those in info/attributes and the user's core.attributesFile.
+func gitAttributes(source gitattr.Source) (*gitattr.Attributes, error) {
+	return gitattr.New(source, config.LocalGitStorageDir, gitAttributesFile())
+}
+
+// gitAttributesFile returns the path of the user's attributes file, from
+// core.attributesFile or git's default in $XDG_CONFIG_HOME.
+func gitAttributesFile() string {
+	if file, ok := config.Config.GitConfig("core.attributesfile"); ok && len(file) > 0 {
+		if strings.HasPrefix(file, "~/") {
+			file = filepath.Join(config.Config.Getenv("HOME"), file[2:])
+		}
+		return file
+	}
+
+	if xdg := config.Config.Getenv("XDG_CONFIG_HOME"); len(xdg) > 0 {
+		return filepath.Join(xdg, "git", "attributes")
+	}
+	if home := config.Config.Getenv("HOME"); len(home) > 0 {
+		return filepath.Join(home, ".config", "git", "attributes")
+	}
+	return ""
+}
+
 func printHelp(commandName string) {
 	if txt, ok := ManPages[commandName]; ok {
 		fmt.Fprintf(os.Stderr, "%s\n", strings.TrimSpace(txt))
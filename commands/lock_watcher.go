@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/github/git-lfs/api"
+	"github.com/github/git-lfs/subprocess"
+)
+
+const (
+	// lockCreatedEvent is the type of lockEvent emitted when a lock appears
+	// that was not present in the previous poll.
+	lockCreatedEvent = "created"
+	// lockReleasedEvent is the type of lockEvent emitted when a lock that
+	// was present in the previous poll is no longer active.
+	lockReleasedEvent = "released"
+
+	// lockWatchPageSize is the number of locks requested per page when
+	// polling, chosen to keep the number of round trips per poll low.
+	lockWatchPageSize = 100
+)
+
+// lockEvent describes a single change in the set of active locks, as observed
+// between two polls of a lockWatcher.
+type lockEvent struct {
+	// Type is either lockCreatedEvent or lockReleasedEvent.
+	Type string `json:"type"`
+	// Lock is the lock that was created or released. For released locks,
+	// this is the most recent copy of the lock that the server returned.
+	Lock api.Lock `json:"lock"`
+}
+
+// lockWatcher polls the Git LFS locking API and reports locks which were
+// created or released since the last poll.
+type lockWatcher struct {
+	// paths optionally scopes each poll to locks held against the given
+	// paths. If empty, all locks on the remote are watched.
+	paths []string
+	// search executes a single page of a lock search against the API.
+	search func(*api.LockSearchRequest) (*api.LockList, error)
+	// known holds the active locks seen in the last poll, keyed by ID. It
+	// is nil until the first poll has completed.
+	known map[string]api.Lock
+}
+
+// newLockWatcher returns a new *lockWatcher which watches the given paths
+// using the package-local API client.
+func newLockWatcher(paths []string) *lockWatcher {
+	return &lockWatcher{paths: paths, search: searchLocks}
+}
+
+// Poll fetches the current set of locks from the server and returns the
+// events describing how it differs from the set seen in the previous poll. The
+// first call to Poll only records the current state and never returns events.
+//
+// If the server could not be queried, an error is returned and the state of
+// the watcher is left unchanged.
+func (w *lockWatcher) Poll() ([]*lockEvent, error) {
+	seen, err := w.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	events := w.diff(seen)
+
+	w.known = make(map[string]api.Lock, len(seen))
+	for id, lock := range seen {
+		if lock.Active() {
+			w.known[id] = lock
+		}
+	}
+
+	return events, nil
+}
+
+// diff compares the given set of locks returned by the server against the
+// known set of active locks, and returns the resulting events sorted by path.
+func (w *lockWatcher) diff(seen map[string]api.Lock) []*lockEvent {
+	if w.known == nil {
+		return nil
+	}
+
+	var events []*lockEvent
+	for id, lock := range seen {
+		if _, ok := w.known[id]; !ok && lock.Active() {
+			events = append(events, &lockEvent{lockCreatedEvent, lock})
+		}
+	}
+
+	for id, lock := range w.known {
+		if latest, ok := seen[id]; !ok {
+			events = append(events, &lockEvent{lockReleasedEvent, lock})
+		} else if !latest.Active() {
+			events = append(events, &lockEvent{lockReleasedEvent, latest})
+		}
+	}
+
+	sort.Sort(lockEventsByPath(events))
+	return events
+}
+
+// fetch walks every page of results for each watched path, following the
+// NextCursor returned by the server, and returns all locks seen keyed by ID.
+func (w *lockWatcher) fetch() (map[string]api.Lock, error) {
+	var queries []*api.LockSearchRequest
+	if len(w.paths) == 0 {
+		queries = append(queries, &api.LockSearchRequest{})
+	}
+	for _, path := range w.paths {
+		queries = append(queries, &api.LockSearchRequest{
+			Filters: []api.Filter{{Property: "path", Value: path}},
+		})
+	}
+
+	seen := make(map[string]api.Lock)
+	for _, query := range queries {
+		query.Limit = lockWatchPageSize
+
+		for {
+			list, err := w.search(query)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, lock := range list.Locks {
+				seen[lock.Id] = lock
+			}
+
+			if len(list.NextCursor) == 0 || list.NextCursor == query.Cursor {
+				break
+			}
+			query.Cursor = list.NextCursor
+		}
+	}
+
+	return seen, nil
+}
+
+// searchLocks runs a single lock search request through the package-local API
+// client, returning the server's error as a Go error, if one was given.
+func searchLocks(query *api.LockSearchRequest) (*api.LockList, error) {
+	s, resp := API.Locks.Search(query)
+	if _, err := API.Do(s); err != nil {
+		return nil, err
+	}
+
+	if len(resp.Err) > 0 {
+		return nil, errors.New(resp.Err)
+	}
+
+	return resp, nil
+}
+
+// runLockEventCommand runs the given command through "sh -c" for a single
+// lockEvent, in the same way that git runs shell commands from its
+// configuration. The event type, lock path and lock ID are given in the
+// GIT_LFS_LOCK_EVENT, GIT_LFS_LOCK_PATH and GIT_LFS_LOCK_ID environment
+// variables, and the JSON encoding of the event is given on stdin.
+func runLockEventCommand(command string, event *lockEvent) error {
+	if len(strings.TrimSpace(command)) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	cmd := subprocess.ExecCommand("sh", "-c", command)
+	cmd.Env = append(append([]string{}, cmd.Env...),
+		"GIT_LFS_LOCK_EVENT="+event.Type,
+		"GIT_LFS_LOCK_PATH="+event.Lock.Path,
+		"GIT_LFS_LOCK_ID="+event.Lock.Id,
+	)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// lockEventsByPath sorts a slice of *lockEvent by the path of each lock, then
+// by event type, so that output is stable between runs.
+type lockEventsByPath []*lockEvent
+
+func (e lockEventsByPath) Len() int      { return len(e) }
+func (e lockEventsByPath) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e lockEventsByPath) Less(i, j int) bool {
+	if e[i].Lock.Path == e[j].Lock.Path {
+		return e[i].Type < e[j].Type
+	}
+	return e[i].Lock.Path < e[j].Lock.Path
+}
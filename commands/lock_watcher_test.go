@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/github/git-lfs/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockWatcherFirstPollHasNoEvents(t *testing.T) {
+	w := &lockWatcher{search: stubLockSearch([]api.Lock{
+		{Id: "1", Path: "a.dat"},
+	})}
+
+	events, err := w.Poll()
+
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+	assert.Len(t, w.known, 1)
+}
+
+func TestLockWatcherReportsCreatedAndReleased(t *testing.T) {
+	w := &lockWatcher{search: stubLockSearch([]api.Lock{
+		{Id: "1", Path: "a.dat"},
+		{Id: "2", Path: "b.dat"},
+	})}
+	_, err := w.Poll()
+	assert.Nil(t, err)
+
+	w.search = stubLockSearch([]api.Lock{
+		{Id: "2", Path: "b.dat", UnlockedAt: time.Now()},
+		{Id: "3", Path: "c.dat"},
+	})
+	events, err := w.Poll()
+	assert.Nil(t, err)
+
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, lockReleasedEvent, events[0].Type)
+		assert.Equal(t, "a.dat", events[0].Lock.Path)
+		assert.Equal(t, lockReleasedEvent, events[1].Type)
+		assert.Equal(t, "b.dat", events[1].Lock.Path)
+		assert.False(t, events[1].Lock.Active())
+		assert.Equal(t, lockCreatedEvent, events[2].Type)
+		assert.Equal(t, "c.dat", events[2].Lock.Path)
+	}
+	assert.Len(t, w.known, 1)
+}
+
+func TestLockWatcherFollowsCursor(t *testing.T) {
+	var cursors []string
+	w := &lockWatcher{search: func(req *api.LockSearchRequest) (*api.LockList, error) {
+		cursors = append(cursors, req.Cursor)
+		assert.Equal(t, lockWatchPageSize, req.Limit)
+
+		switch req.Cursor {
+		case "":
+			return &api.LockList{Locks: []api.Lock{{Id: "1"}}, NextCursor: "1"}, nil
+		case "1":
+			return &api.LockList{Locks: []api.Lock{{Id: "2"}}, NextCursor: "2"}, nil
+		default:
+			return &api.LockList{Locks: []api.Lock{{Id: "3"}}}, nil
+		}
+	}}
+
+	_, err := w.Poll()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"", "1", "2"}, cursors)
+	assert.Len(t, w.known, 3)
+}
+
+func TestLockWatcherFiltersByPath(t *testing.T) {
+	var paths []string
+	w := &lockWatcher{
+		paths: []string{"a.dat", "b.dat"},
+		search: func(req *api.LockSearchRequest) (*api.LockList, error) {
+			if assert.Len(t, req.Filters, 1) {
+				assert.Equal(t, "path", req.Filters[0].Property)
+				paths = append(paths, req.Filters[0].Value)
+			}
+			return &api.LockList{}, nil
+		},
+	}
+
+	_, err := w.Poll()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a.dat", "b.dat"}, paths)
+}
+
+func TestRunLockEventCommandPassesEventInEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-watch-command")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	command := `printf '%s|%s|%s|' "$GIT_LFS_LOCK_EVENT" "$GIT_LFS_LOCK_PATH" "$GIT_LFS_LOCK_ID" > '` + out + `' && cat >> '` + out + `'`
+	event := &lockEvent{lockCreatedEvent, api.Lock{Id: "1", Path: "a b; $(false).dat"}}
+
+	assert.Nil(t, runLockEventCommand(command, event))
+
+	contents, err := ioutil.ReadFile(out)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(contents), "created|a b; $(false).dat|1|{"), string(contents))
+}
+
+func stubLockSearch(locks []api.Lock) func(*api.LockSearchRequest) (*api.LockList, error) {
+	return func(*api.LockSearchRequest) (*api.LockList, error) {
+		return &api.LockList{Locks: locks}, nil
+	}
+}
@@ -44,6 +44,14 @@ type FetchPruneConfig struct {
 	PruneVerifyRemoteAlways bool
 	// Name of remote to check for unpushed and verify checks
 	PruneRemoteName string
+	// Maximum size of the local object store in bytes, enforced by
+	// prune --to-size (default 0 = no limit)
+	StorageMaxSize int64
+	// Whether to prune to StorageMaxSize after every fetch
+	PruneAfterFetch bool
+	// Glob patterns of refs whose objects prune always retains, as though
+	// they were pinned
+	PruneProtectRefs []string
 }
 
 type Configuration struct {
@@ -57,7 +65,10 @@ type Configuration struct {
 
 	loading           sync.Mutex // guards initialization of gitConfig and remotes
 	gitConfig         map[string]string
+	gitConfigValues   map[string][]string
 	origConfig        map[string]string
+	urlAliases        []urlAlias
+	origUrlAliases    []urlAlias
 	remotes           []string
 	extensions        map[string]Extension
 	fetchIncludePaths []string
@@ -84,7 +95,8 @@ func NewConfig() *Configuration {
 // NOTE: this method should only be called during testing.
 func NewFromValues(gitconfig map[string]string) *Configuration {
 	config := &Configuration{
-		gitConfig: make(map[string]string, 0),
+		gitConfig:       make(map[string]string, 0),
+		gitConfigValues: make(map[string][]string),
 	}
 
 	buf := bytes.NewBuffer([]byte{})
@@ -169,12 +181,12 @@ func (c *Configuration) GetenvBool(key string, def bool) bool {
 func (c *Configuration) GitRemoteUrl(remote string, forpush bool) string {
 	if forpush {
 		if u, ok := c.GitConfig("remote." + remote + ".pushurl"); ok {
-			return u
+			return c.ReplaceUrlAlias(u, false)
 		}
 	}
 
 	if u, ok := c.GitConfig("remote." + remote + ".url"); ok {
-		return u
+		return c.ReplaceUrlAlias(u, forpush)
 	}
 
 	return ""
@@ -193,12 +205,12 @@ func (c *Configuration) Endpoint(operation string) Endpoint {
 
 	if operation == "upload" {
 		if url, ok := c.GitConfig("lfs.pushurl"); ok {
-			return NewEndpointWithConfig(url, c)
+			return NewEndpointWithConfig(c.ReplaceUrlAlias(url, false), c)
 		}
 	}
 
 	if url, ok := c.GitConfig("lfs.url"); ok {
-		return NewEndpointWithConfig(url, c)
+		return NewEndpointWithConfig(c.ReplaceUrlAlias(url, operation == "upload"), c)
 	}
 
 	if len(c.CurrentRemote) > 0 && c.CurrentRemote != defaultRemote {
@@ -210,6 +222,42 @@ func (c *Configuration) Endpoint(operation string) Endpoint {
 	return c.RemoteEndpoint(defaultRemote, operation)
 }
 
+// EndpointConfigKey returns the git config key that the endpoint for the given
+// operation is read from. If the endpoint is derived from the git remote url,
+// the key that would override it for the current remote is returned instead.
+// An empty string is returned if the endpoint was set manually.
+func (c *Configuration) EndpointConfigKey(operation string) string {
+	if c.manualEndpoint != nil {
+		return ""
+	}
+
+	if operation == "upload" {
+		if _, ok := c.GitConfig("lfs.pushurl"); ok {
+			return "lfs.pushurl"
+		}
+	}
+
+	if _, ok := c.GitConfig("lfs.url"); ok {
+		return "lfs.url"
+	}
+
+	remote := c.CurrentRemote
+	if len(remote) == 0 || len(c.RemoteEndpoint(remote, operation).Url) == 0 {
+		remote = defaultRemote
+	}
+
+	if operation == "upload" {
+		if _, ok := c.GitConfig("remote." + remote + ".lfspushurl"); ok {
+			return "remote." + remote + ".lfspushurl"
+		}
+		if _, ok := c.GitConfig("remote." + remote + ".pushurl"); ok {
+			return "remote." + remote + ".lfspushurl"
+		}
+	}
+
+	return "remote." + remote + ".lfsurl"
+}
+
 func (c *Configuration) ConcurrentTransfers() int {
 	if c.NtlmAccess("download") {
 		return 1
@@ -342,6 +390,41 @@ func (c *Configuration) FetchExcludePaths() []string {
 	return c.fetchExcludePaths
 }
 
+// LfsAlternates returns the LFS object directories listed in lfs.alternates,
+// which are checked for objects before they are downloaded. Relative paths are
+// resolved against the root of the working directory.
+func (c *Configuration) LfsAlternates() []string {
+	var dirs []string
+	for _, dir := range c.GitConfigAll("lfs.alternates") {
+		if len(dir) == 0 {
+			continue
+		}
+		if !filepath.IsAbs(dir) && len(LocalWorkingDir) > 0 {
+			dir = filepath.Join(LocalWorkingDir, dir)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// SharedStorageDir returns the user-wide LFS object directory given by
+// lfs.storage.shared, which objects are shared through between repositories,
+// or an empty string if it is not set. A leading "~/" is replaced with the
+// user's home directory.
+func (c *Configuration) SharedStorageDir() string {
+	dir, _ := c.GitConfig("lfs.storage.shared")
+	if len(dir) == 0 {
+		return ""
+	}
+	if strings.HasPrefix(dir, "~/") {
+		dir = filepath.Join(c.Getenv("HOME"), dir[2:])
+	}
+	if !filepath.IsAbs(dir) && len(LocalWorkingDir) > 0 {
+		dir = filepath.Join(LocalWorkingDir, dir)
+	}
+	return dir
+}
+
 func (c *Configuration) RemoteEndpoint(remote, operation string) Endpoint {
 	if len(remote) == 0 {
 		remote = defaultRemote
@@ -350,11 +433,11 @@ func (c *Configuration) RemoteEndpoint(remote, operation string) Endpoint {
 	// Support separate push URL if specified and pushing
 	if operation == "upload" {
 		if url, ok := c.GitConfig("remote." + remote + ".lfspushurl"); ok {
-			return NewEndpointWithConfig(url, c)
+			return NewEndpointWithConfig(c.ReplaceUrlAlias(url, false), c)
 		}
 	}
 	if url, ok := c.GitConfig("remote." + remote + ".lfsurl"); ok {
-		return NewEndpointWithConfig(url, c)
+		return NewEndpointWithConfig(c.ReplaceUrlAlias(url, operation == "upload"), c)
 	}
 
 	// finally fall back on git remote url (also supports pushurl)
@@ -425,6 +508,22 @@ func (c *Configuration) GitConfig(key string) (string, bool) {
 	return value, ok
 }
 
+// GitConfigAll returns every value given for a multi-valued git config key, in
+// the order that they were read. Keys set with only a single value return a
+// slice containing just that value.
+func (c *Configuration) GitConfigAll(key string) []string {
+	c.loadGitConfig()
+	key = strings.ToLower(key)
+	if values, ok := c.gitConfigValues[key]; ok {
+		return values
+	}
+
+	if value, ok := c.gitConfig[key]; ok {
+		return []string{value}
+	}
+	return nil
+}
+
 func (c *Configuration) AllGitConfig() map[string]string {
 	c.loadGitConfig()
 	return c.gitConfig
@@ -476,6 +575,21 @@ func (c *Configuration) FetchPruneConfig() *FetchPruneConfig {
 		if v, ok := c.GitConfig("lfs.pruneremotetocheck"); ok {
 			c.fetchPruneConfig.PruneRemoteName = v
 		}
+		if v, ok := c.GitConfig("lfs.storage.maxsize"); ok {
+			n, err := tools.ParseSize(v)
+			if err == nil {
+				c.fetchPruneConfig.StorageMaxSize = n
+			}
+		}
+		if v, ok := c.GitConfig("lfs.storage.pruneafterfetch"); ok {
+			if b, err := parseConfigBool(v); err == nil {
+				c.fetchPruneConfig.PruneAfterFetch = b
+			}
+		}
+		for _, v := range c.GitConfigAll("lfs.pruneprotectrefs") {
+			c.fetchPruneConfig.PruneProtectRefs = append(c.fetchPruneConfig.PruneProtectRefs,
+				tools.CleanPaths(v, ",")...)
+		}
 
 	}
 	return c.fetchPruneConfig
@@ -485,6 +599,56 @@ func (c *Configuration) SkipDownloadErrors() bool {
 	return c.GetenvBool("GIT_LFS_SKIP_DOWNLOAD_ERRORS", false) || c.GitConfigBool("lfs.skipdownloaderrors")
 }
 
+// CleanCache returns whether `git lfs clean` may reuse the pointer from the
+// last time a file was cleaned, if the file has not changed since. Default true.
+func (c *Configuration) CleanCache() bool {
+	value, ok := c.GitConfig("lfs.cleancache")
+	if !ok || len(value) == 0 {
+		return true
+	}
+
+	useCache, err := parseConfigBool(value)
+	if err != nil {
+		return false
+	}
+
+	return useCache
+}
+
+// NativeObjects returns whether git objects are read directly from the object
+// database when scanning for Git LFS pointers, instead of by running `git
+// cat-file` and `git ls-tree`. Default false.
+func (c *Configuration) NativeObjects() bool {
+	value, ok := c.GitConfig("lfs.nativeobjects")
+	if !ok || len(value) == 0 {
+		return false
+	}
+
+	native, err := parseConfigBool(value)
+	if err != nil {
+		return false
+	}
+
+	return native
+}
+
+// ScanCache returns whether the Git LFS pointers found under a tree, and
+// changed by a commit, are cached on disk so that later scans of the same
+// trees and commits need not read them again. Default true.
+func (c *Configuration) ScanCache() bool {
+	value, ok := c.GitConfig("lfs.scancache")
+	if !ok || len(value) == 0 {
+		return true
+	}
+
+	useCache, err := parseConfigBool(value)
+	if err != nil {
+		return false
+	}
+
+	return useCache
+}
+
 func parseConfigBool(str string) (bool, error) {
 	switch strings.ToLower(str) {
 	case "true", "1", "on", "yes", "t":
@@ -504,6 +668,7 @@ func (c *Configuration) loadGitConfig() bool {
 	}
 
 	c.gitConfig = make(map[string]string)
+	c.gitConfigValues = make(map[string][]string)
 	c.extensions = make(map[string]Extension)
 	uniqRemotes := make(map[string]bool)
 
@@ -627,7 +792,14 @@ func (c *Configuration) readGitConfig(output string, uniqRemotes map[string]bool
 			continue
 		}
 
+		if alias, ok := parseUrlAlias(pieces[0], value); ok {
+			c.urlAliases = append(c.urlAliases, alias)
+		}
+
 		c.gitConfig[key] = value
+		if c.gitConfigValues != nil {
+			c.gitConfigValues[key] = append(c.gitConfigValues[key], value)
+		}
 
 		if len(keyParts) == 2 && keyParts[0] == "lfs" {
 			switch keyParts[1] {
@@ -668,6 +840,7 @@ func (c *Configuration) SetConfig(key, value string) {
 	}
 
 	c.gitConfig[key] = value
+	delete(c.gitConfigValues, key)
 }
 
 func (c *Configuration) ClearConfig() {
@@ -677,17 +850,22 @@ func (c *Configuration) ClearConfig() {
 		for k, v := range c.gitConfig {
 			c.origConfig[k] = v
 		}
+		c.origUrlAliases = c.urlAliases
 		c.loading.Unlock()
 	}
 
 	c.gitConfig = make(map[string]string)
+	c.gitConfigValues = make(map[string][]string)
+	c.urlAliases = nil
 }
 
 func (c *Configuration) ResetConfig() {
 	c.loading.Lock()
 	c.gitConfig = make(map[string]string)
+	c.gitConfigValues = make(map[string][]string)
 	for k, v := range c.origConfig {
 		c.gitConfig[k] = v
 	}
+	c.urlAliases = c.origUrlAliases
 	c.loading.Unlock()
 }
@@ -395,6 +395,72 @@ func TestBatchAbsentIsTrue(t *testing.T) {
 	assert.True(t, v)
 }
 
+func TestCleanCache(t *testing.T) {
+	tests := map[string]bool{
+		"":         true,
+		"true":     true,
+		"false":    false,
+		"0":        false,
+		"elephant": false,
+	}
+
+	for value, expected := range tests {
+		config := &Configuration{
+			gitConfig: map[string]string{"lfs.cleancache": value},
+		}
+
+		if actual := config.CleanCache(); actual != expected {
+			t.Errorf("lfs.cleancache %q == %v, not %v", value, actual, expected)
+		}
+	}
+
+	assert.True(t, (&Configuration{}).CleanCache())
+}
+
+func TestScanCache(t *testing.T) {
+	tests := map[string]bool{
+		"":         true,
+		"true":     true,
+		"false":    false,
+		"0":        false,
+		"elephant": false,
+	}
+
+	for value, expected := range tests {
+		config := &Configuration{
+			gitConfig: map[string]string{"lfs.scancache": value},
+		}
+
+		if actual := config.ScanCache(); actual != expected {
+			t.Errorf("lfs.scancache %q == %v, not %v", value, actual, expected)
+		}
+	}
+
+	assert.True(t, (&Configuration{}).ScanCache())
+}
+
+func TestNativeObjects(t *testing.T) {
+	tests := map[string]bool{
+		"":         false,
+		"true":     true,
+		"1":        true,
+		"false":    false,
+		"elephant": false,
+	}
+
+	for value, expected := range tests {
+		config := &Configuration{
+			gitConfig: map[string]string{"lfs.nativeobjects": value},
+		}
+
+		if actual := config.NativeObjects(); actual != expected {
+			t.Errorf("lfs.nativeobjects %q == %v, not %v", value, actual, expected)
+		}
+	}
+
+	assert.False(t, (&Configuration{}).NativeObjects())
+}
+
 func TestAccessConfig(t *testing.T) {
 	type accessTest struct {
 		Access        string
@@ -514,6 +580,9 @@ func TestFetchPruneConfigDefault(t *testing.T) {
 	assert.Equal(t, 3, fp.PruneOffsetDays)
 	assert.Equal(t, "origin", fp.PruneRemoteName)
 	assert.False(t, fp.PruneVerifyRemoteAlways)
+	assert.Equal(t, int64(0), fp.StorageMaxSize)
+	assert.False(t, fp.PruneAfterFetch)
+	assert.Empty(t, fp.PruneProtectRefs)
 
 }
 func TestFetchPruneConfigCustom(t *testing.T) {
@@ -525,6 +594,9 @@ func TestFetchPruneConfigCustom(t *testing.T) {
 			"lfs.pruneoffsetdays":         "30",
 			"lfs.pruneverifyremotealways": "true",
 			"lfs.pruneremotetocheck":      "upstream",
+			"lfs.storage.maxsize":         "10g",
+			"lfs.storage.pruneafterfetch": "true",
+			"lfs.pruneprotectrefs":        "refs/tags/*, refs/heads/release/*",
 		},
 	}
 	fp := config.FetchPruneConfig()
@@ -535,6 +607,9 @@ func TestFetchPruneConfigCustom(t *testing.T) {
 	assert.Equal(t, 30, fp.PruneOffsetDays)
 	assert.Equal(t, "upstream", fp.PruneRemoteName)
 	assert.True(t, fp.PruneVerifyRemoteAlways)
+	assert.Equal(t, int64(10*1024*1024*1024), fp.StorageMaxSize)
+	assert.True(t, fp.PruneAfterFetch)
+	assert.Equal(t, []string{"refs/tags/*", "refs/heads/release/*"}, fp.PruneProtectRefs)
 }
 
 func TestFetchIncludeExcludesAreCleaned(t *testing.T) {
@@ -546,3 +621,27 @@ func TestFetchIncludeExcludesAreCleaned(t *testing.T) {
 	assert.Equal(t, []string{"/path/to/clean"}, config.FetchIncludePaths())
 	assert.Equal(t, []string{"/other/path/to/clean"}, config.FetchExcludePaths())
 }
+
+func TestEndpointConfigKey(t *testing.T) {
+	config := NewFromValues(map[string]string{
+		"remote.origin.url":     "https://example.com/repo",
+		"remote.origin.pushurl": "https://push.example.com/repo",
+		"remote.other.url":      "https://example.com/other",
+	})
+
+	assert.Equal(t, "remote.origin.lfsurl", config.EndpointConfigKey("download"))
+	assert.Equal(t, "remote.origin.lfspushurl", config.EndpointConfigKey("upload"))
+
+	config.CurrentRemote = "other"
+	assert.Equal(t, "remote.other.lfsurl", config.EndpointConfigKey("upload"))
+
+	config.SetConfig("lfs.url", "https://lfs.example.com")
+	assert.Equal(t, "lfs.url", config.EndpointConfigKey("download"))
+	assert.Equal(t, "lfs.url", config.EndpointConfigKey("upload"))
+
+	config.SetConfig("lfs.pushurl", "https://lfs-push.example.com")
+	assert.Equal(t, "lfs.pushurl", config.EndpointConfigKey("upload"))
+
+	config.SetManualEndpoint(Endpoint{Url: "https://manual.example.com"})
+	assert.Equal(t, "", config.EndpointConfigKey("download"))
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/github/git-lfs/git"
@@ -14,9 +15,10 @@ import (
 
 var (
 	LocalWorkingDir    string
-	LocalGitDir        string // parent of index / config / hooks etc
-	LocalGitStorageDir string // parent of objects/lfs (may be same as LocalGitDir but may not)
-	LocalReferenceDir  string // alternative local media dir (relative to clone reference repo)
+	LocalGitDir        string   // parent of index / config / hooks etc
+	LocalGitStorageDir string   // parent of objects/lfs (may be same as LocalGitDir but may not)
+	LocalReferenceDir  string   // alternative local media dir (relative to clone reference repo)
+	LocalReferenceDirs []string // all alternative local media dirs, from every git alternate
 	LocalLogDir        string
 )
 
@@ -31,7 +33,10 @@ func ResolveGitBasicDirs() {
 		LocalWorkingDir = tools.ResolveSymlinks(LocalWorkingDir)
 
 		LocalGitStorageDir = resolveGitStorageDir(LocalGitDir)
-		LocalReferenceDir = resolveReferenceDir(LocalGitStorageDir)
+		LocalReferenceDirs = resolveReferenceDirs(LocalGitStorageDir)
+		if len(LocalReferenceDirs) > 0 {
+			LocalReferenceDir = LocalReferenceDirs[0]
+		}
 
 	} else {
 		errMsg := err.Error()
@@ -42,19 +47,73 @@ func ResolveGitBasicDirs() {
 	}
 }
 
-func resolveReferenceDir(gitStorageDir string) string {
-	cloneReferencePath := filepath.Join(gitStorageDir, "objects", "info", "alternates")
-	if tools.FileExists(cloneReferencePath) {
-		buffer, err := ioutil.ReadFile(cloneReferencePath)
-		if err == nil {
-			path := strings.TrimSpace(string(buffer[:]))
-			referenceLfsStoragePath := filepath.Join(filepath.Dir(path), "lfs", "objects")
-			if tools.DirExists(referenceLfsStoragePath) {
-				return referenceLfsStoragePath
+// maxAlternateDepth is the number of nested alternates files that are followed,
+// matching the limit in git.
+const maxAlternateDepth = 5
+
+// resolveReferenceDirs returns the LFS object directories alongside every git
+// object directory listed in the objects/info/alternates file of the given git
+// storage dir. Alternates of alternates are followed, and relative paths are
+// resolved against the object directory that lists them, as git does.
+func resolveReferenceDirs(gitStorageDir string) []string {
+	objectsDir := filepath.Clean(filepath.Join(gitStorageDir, "objects"))
+	seen := map[string]bool{objectsDir: true}
+
+	var dirs []string
+	for _, objectsDir := range readAlternates(objectsDir, seen, 0) {
+		referenceLfsStoragePath := filepath.Join(filepath.Dir(objectsDir), "lfs", "objects")
+		if tools.DirExists(referenceLfsStoragePath) {
+			dirs = append(dirs, referenceLfsStoragePath)
+		}
+	}
+	return dirs
+}
+
+// readAlternates returns the git object directories listed in the
+// info/alternates file of objectsDir, each followed by its own alternates.
+func readAlternates(objectsDir string, seen map[string]bool, depth int) []string {
+	if depth >= maxAlternateDepth {
+		tracerx.Printf("Ignoring alternates of %q, nested too deeply", objectsDir)
+		return nil
+	}
+
+	buffer, err := ioutil.ReadFile(filepath.Join(objectsDir, "info", "alternates"))
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	for _, line := range strings.Split(string(buffer), "\n") {
+		path := strings.TrimSpace(line)
+		if len(path) == 0 || strings.HasPrefix(path, "#") {
+			continue
+		}
+
+		// git quotes paths which contain special characters
+		if strings.HasPrefix(path, `"`) {
+			unquoted, err := strconv.Unquote(path)
+			if err != nil {
+				tracerx.Printf("Error parsing alternate %s: %s", path, err)
+				continue
 			}
+			path = unquoted
+		}
+
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(objectsDir, path)
+		}
+		path = filepath.Clean(path)
+
+		if seen[path] {
+			continue
 		}
+		seen[path] = true
+
+		dirs = append(dirs, path)
+		dirs = append(dirs, readAlternates(path, seen, depth+1)...)
 	}
-	return ""
+
+	return dirs
 }
 
 // From a git dir, get the location that objects are to be stored (we will store lfs alongside)
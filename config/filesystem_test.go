@@ -0,0 +1,108 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveReferenceDirsFromAllAlternates(t *testing.T) {
+	root, err := ioutil.TempDir("", "lfs-alternates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// resolve symlinks such as /tmp on macOS, so paths can be compared
+	root, _ = filepath.EvalSymlinks(root)
+
+	gitDir := filepath.Join(root, "repo", ".git")
+	first := filepath.Join(root, "first.git")
+	second := filepath.Join(root, "second", ".git")
+	nested := filepath.Join(root, "nested.git")
+	nolfs := filepath.Join(root, "nolfs.git")
+	quoted := filepath.Join(root, "with \"quote\".git")
+
+	for _, dir := range []string{gitDir, first, second, nested, nolfs, quoted} {
+		mkdirAll(t, filepath.Join(dir, "objects", "info"))
+	}
+	for _, dir := range []string{first, second, nested, quoted} {
+		mkdirAll(t, filepath.Join(dir, "lfs", "objects"))
+	}
+
+	writeAlternates(t, gitDir,
+		"# a comment\n"+
+			filepath.Join(first, "objects")+"\n"+
+			"\n"+
+			"../../../second/.git/objects\n"+
+			filepath.Join(nolfs, "objects")+"\n"+
+			`"`+filepath.Join(root, `with \"quote\".git`, "objects")+`"`+"\n")
+
+	// alternates of alternates are followed, and cycles are ignored
+	writeAlternates(t, first, filepath.Join(nested, "objects")+"\n")
+	writeAlternates(t, nested, filepath.Join(first, "objects")+"\n"+filepath.Join(gitDir, "objects")+"\n")
+
+	dirs := resolveReferenceDirs(gitDir)
+	assert.Equal(t, []string{
+		filepath.Join(first, "lfs", "objects"),
+		filepath.Join(nested, "lfs", "objects"),
+		filepath.Join(second, "lfs", "objects"),
+		filepath.Join(quoted, "lfs", "objects"),
+	}, dirs)
+}
+
+func TestResolveReferenceDirsWithoutAlternates(t *testing.T) {
+	root, err := ioutil.TempDir("", "lfs-alternates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	assert.Nil(t, resolveReferenceDirs(root))
+}
+
+func TestLfsAlternates(t *testing.T) {
+	oldWorkingDir := LocalWorkingDir
+	LocalWorkingDir = filepath.Join("/", "work", "repo")
+	defer func() { LocalWorkingDir = oldWorkingDir }()
+
+	config := NewFromValues(map[string]string{})
+	config.readGitConfig("lfs.alternates=/shared/lfs/objects\nlfs.alternates=cache\n", map[string]bool{}, false)
+
+	assert.Equal(t, []string{
+		filepath.Join("/", "shared", "lfs", "objects"),
+		filepath.Join("/", "work", "repo", "cache"),
+	}, config.LfsAlternates())
+}
+
+func TestSharedStorageDir(t *testing.T) {
+	oldWorkingDir := LocalWorkingDir
+	LocalWorkingDir = filepath.Join("/", "work", "repo")
+	defer func() { LocalWorkingDir = oldWorkingDir }()
+
+	config := NewFromValues(map[string]string{})
+	assert.Equal(t, "", config.SharedStorageDir())
+
+	config = NewFromValues(map[string]string{"lfs.storage.shared": "~/lfs-cache"})
+	config.envVars = map[string]string{"HOME": filepath.Join("/", "home", "user")}
+	assert.Equal(t, filepath.Join("/", "home", "user", "lfs-cache"), config.SharedStorageDir())
+
+	config = NewFromValues(map[string]string{"lfs.storage.shared": "cache"})
+	assert.Equal(t, filepath.Join("/", "work", "repo", "cache"), config.SharedStorageDir())
+}
+
+func mkdirAll(t *testing.T, dir string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeAlternates(t *testing.T, gitDir, contents string) {
+	path := filepath.Join(gitDir, "objects", "info", "alternates")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
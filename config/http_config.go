@@ -0,0 +1,199 @@
+package config
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// HttpConfig returns the value of the "http.<url>.<key>" setting whose <url>
+// best matches rawurl, falling back on "http.<key>" if no URL-specific setting
+// matches. URLs are matched as git-config(1) does for http.<url>.* settings:
+//
+//  1. The scheme and port must be equal, with default ports filled in.
+//  2. The host must be equal, or match a pattern where "*" matches exactly
+//     one dot-separated label. Exact hosts are preferred over patterns.
+//  3. The path of the setting must be a prefix of the URL path, matching at
+//     a "/" boundary. Longer matching paths are preferred.
+//  4. If the setting includes a user name, it must be equal to the user in
+//     the URL. Settings with a matching user are preferred.
+func (c *Configuration) HttpConfig(rawurl, key string) (string, bool) {
+	matches := c.httpConfigMatches(rawurl, key)
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	return c.gitConfig[matches[len(matches)-1].key], true
+}
+
+// HttpConfigAll returns every value for a multi-valued "http.<url>.<key>"
+// setting that matches rawurl, ordered from the least to the most specific
+// match, as with "http.extraHeader". As in git, an empty value resets the list
+// of values collected so far.
+func (c *Configuration) HttpConfigAll(rawurl, key string) []string {
+	var values []string
+	for _, match := range c.httpConfigMatches(rawurl, key) {
+		for _, value := range c.GitConfigAll(match.key) {
+			if len(value) == 0 {
+				values = nil
+				continue
+			}
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// HttpConfigBool parses the value returned by HttpConfig as a boolean,
+// returning def if it is unset or cannot be parsed.
+func (c *Configuration) HttpConfigBool(rawurl, key string, def bool) bool {
+	value, ok := c.HttpConfig(rawurl, key)
+	if !ok || len(value) == 0 {
+		return def
+	}
+
+	b, err := parseConfigBool(value)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// httpConfigMatch is a single "http.*.<key>" git config key that matches a
+// given URL, along with how specific that match is.
+type httpConfigMatch struct {
+	key       string
+	hostExact bool
+	pathLen   int
+	hasUser   bool
+}
+
+// httpConfigMatches returns all of the "http.*.<key>" git config keys that
+// match rawurl, sorted from the least to the most specific.
+func (c *Configuration) httpConfigMatches(rawurl, key string) []*httpConfigMatch {
+	c.loadGitConfig()
+
+	suffix := "." + strings.ToLower(key)
+	global := "http" + suffix
+
+	var matches []*httpConfigMatch
+	if _, ok := c.gitConfig[global]; ok {
+		matches = append(matches, &httpConfigMatch{key: global, pathLen: -1})
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return matches
+	}
+
+	for configKey := range c.gitConfig {
+		if configKey == global || !strings.HasPrefix(configKey, "http.") || !strings.HasSuffix(configKey, suffix) {
+			continue
+		}
+
+		pattern, err := url.Parse(configKey[len("http.") : len(configKey)-len(suffix)])
+		if err != nil {
+			continue
+		}
+
+		if match := matchHttpConfigUrl(pattern, u); match != nil {
+			match.key = configKey
+			matches = append(matches, match)
+		}
+	}
+
+	sort.Sort(httpConfigMatches(matches))
+	return matches
+}
+
+// matchHttpConfigUrl returns a *httpConfigMatch describing how the pattern URL
+// given in a "http.<url>.*" key matches u, or nil if it does not match.
+func matchHttpConfigUrl(pattern, u *url.URL) *httpConfigMatch {
+	if len(pattern.Host) == 0 || !strings.EqualFold(pattern.Scheme, u.Scheme) {
+		return nil
+	}
+
+	if portForUrl(pattern) != portForUrl(u) {
+		return nil
+	}
+
+	hostExact := strings.EqualFold(pattern.Hostname(), u.Hostname())
+	if !hostExact && !matchHostPattern(pattern.Hostname(), u.Hostname()) {
+		return nil
+	}
+
+	patternPath := strings.TrimSuffix(pattern.Path, "/")
+	if len(patternPath) > 0 {
+		path := strings.ToLower(u.Path)
+		if path != patternPath && !strings.HasPrefix(path, patternPath+"/") {
+			return nil
+		}
+	}
+
+	hasUser := pattern.User != nil && len(pattern.User.Username()) > 0
+	if hasUser && (u.User == nil || u.User.Username() != pattern.User.Username()) {
+		return nil
+	}
+
+	return &httpConfigMatch{
+		hostExact: hostExact,
+		pathLen:   len(patternPath),
+		hasUser:   hasUser,
+	}
+}
+
+// matchHostPattern returns whether host matches the given pattern, where each
+// "*" label in the pattern matches exactly one label of the host.
+func matchHostPattern(pattern, host string) bool {
+	patternLabels := strings.Split(strings.ToLower(pattern), ".")
+	hostLabels := strings.Split(strings.ToLower(host), ".")
+	if len(patternLabels) != len(hostLabels) {
+		return false
+	}
+
+	for i, label := range patternLabels {
+		if label != "*" && label != hostLabels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// portForUrl returns the port of u, or the default port for its scheme if no
+// port was given.
+func portForUrl(u *url.URL) string {
+	if port := u.Port(); len(port) > 0 {
+		return port
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
+
+// httpConfigMatches sorts a slice of *httpConfigMatch from the least to the
+// most specific match.
+type httpConfigMatches []*httpConfigMatch
+
+func (m httpConfigMatches) Len() int      { return len(m) }
+func (m httpConfigMatches) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m httpConfigMatches) Less(i, j int) bool {
+	if m[i].pathLen < 0 || m[j].pathLen < 0 {
+		return m[i].pathLen < m[j].pathLen
+	}
+	if m[i].hostExact != m[j].hostExact {
+		return !m[i].hostExact
+	}
+	if m[i].pathLen != m[j].pathLen {
+		return m[i].pathLen < m[j].pathLen
+	}
+	if m[i].hasUser != m[j].hasUser {
+		return !m[i].hasUser
+	}
+	return m[i].key < m[j].key
+}
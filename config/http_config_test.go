@@ -0,0 +1,105 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpConfigFallsBackToGlobal(t *testing.T) {
+	config := NewFromValues(map[string]string{
+		"http.sslverify": "false",
+	})
+
+	value, ok := config.HttpConfig("https://example.com/foo", "sslVerify")
+	assert.True(t, ok)
+	assert.Equal(t, "false", value)
+
+	_, ok = config.HttpConfig("https://example.com/foo", "proxy")
+	assert.False(t, ok)
+}
+
+func TestHttpConfigPrefersLongestPath(t *testing.T) {
+	config := NewFromValues(map[string]string{
+		"http.proxy":                              "global",
+		"http.https://example.com.proxy":          "host",
+		"http.https://example.com/foo.proxy":      "foo",
+		"http.https://example.com/foo/bar/.proxy": "bar",
+	})
+
+	for rawurl, expected := range map[string]string{
+		"https://example.com/":               "host",
+		"https://example.com/foo":            "foo",
+		"https://example.com/foo/baz":        "foo",
+		"https://example.com/foobar":         "host",
+		"https://example.com/foo/bar/info":   "bar",
+		"https://other.com/foo/bar/info/lfs": "global",
+	} {
+		value, _ := config.HttpConfig(rawurl, "proxy")
+		assert.Equal(t, expected, value, rawurl)
+	}
+}
+
+func TestHttpConfigMatchesSchemeAndPort(t *testing.T) {
+	config := NewFromValues(map[string]string{
+		"http.https://example.com.sslcainfo":     "https",
+		"http.http://example.com:8080.sslcainfo": "http-8080",
+	})
+
+	value, _ := config.HttpConfig("https://example.com/foo", "sslcainfo")
+	assert.Equal(t, "https", value)
+
+	value, _ = config.HttpConfig("https://example.com:443/foo", "sslcainfo")
+	assert.Equal(t, "https", value)
+
+	value, _ = config.HttpConfig("http://example.com:8080/foo", "sslcainfo")
+	assert.Equal(t, "http-8080", value)
+
+	_, ok := config.HttpConfig("http://example.com/foo", "sslcainfo")
+	assert.False(t, ok)
+}
+
+func TestHttpConfigMatchesHostWildcardsAndUsers(t *testing.T) {
+	config := NewFromValues(map[string]string{
+		"http.https://*.example.com.cookiefile":         "wildcard",
+		"http.https://git.example.com.cookiefile":       "exact",
+		"http.https://user@git.example.com.cookiefile":  "user",
+		"http.https://other@api.example.com.cookiefile": "other",
+		"http.https://*.*.example.com/deep.cookiefile":  "deep",
+	})
+
+	for rawurl, expected := range map[string]string{
+		"https://api.example.com/":          "wildcard",
+		"https://git.example.com/":          "exact",
+		"https://user@git.example.com/":     "user",
+		"https://a.b.example.com/deep/repo": "deep",
+	} {
+		value, _ := config.HttpConfig(rawurl, "cookiefile")
+		assert.Equal(t, expected, value, rawurl)
+	}
+
+	_, ok := config.HttpConfig("https://example.com/", "cookiefile")
+	assert.False(t, ok)
+}
+
+func TestHttpConfigAllCollectsMatchingValues(t *testing.T) {
+	config := NewFromValues(map[string]string{
+		"http.extraheader":                      "X-Global: 1",
+		"http.https://example.com/.extraheader": "X-Host: 2",
+	})
+
+	assert.Equal(t, []string{"X-Global: 1", "X-Host: 2"},
+		config.HttpConfigAll("https://example.com/foo", "extraheader"))
+	assert.Equal(t, []string{"X-Global: 1"},
+		config.HttpConfigAll("https://other.com/foo", "extraheader"))
+}
+
+func TestHttpConfigAllResetsOnEmptyValue(t *testing.T) {
+	config := NewFromValues(map[string]string{})
+	config.readGitConfig("http.extraheader=X-A: 1\nhttp.extraheader=X-B: 2\nhttp.https://example.com.extraheader=\nhttp.https://example.com.extraheader=X-C: 3\n", map[string]bool{}, false)
+
+	assert.Equal(t, []string{"X-A: 1", "X-B: 2"},
+		config.HttpConfigAll("https://other.com/", "extraheader"))
+	assert.Equal(t, []string{"X-C: 3"},
+		config.HttpConfigAll("https://example.com/", "extraheader"))
+}
@@ -0,0 +1,76 @@
+package config
+
+import "strings"
+
+// urlAlias is a single url.<base>.insteadOf or url.<base>.pushInsteadOf rule
+// from the git config, which rewrites URLs starting with prefix to start with
+// base instead.
+type urlAlias struct {
+	base   string
+	prefix string
+	push   bool
+}
+
+// ReplaceUrlAlias rewrites rawurl using the url.<base>.insteadOf rules in the
+// git config, in the same way that git rewrites remote URLs: of all the rules
+// whose value is a prefix of rawurl, the one with the longest prefix is used.
+//
+// If push is true, url.<base>.pushInsteadOf rules are tried first, and the
+// url.<base>.insteadOf rules are only used if none of them match. Like git,
+// callers should not set push for URLs that were configured explicitly for
+// pushing, such as remote.<name>.pushurl.
+//
+// If no rule matches, rawurl is returned unmodified.
+func (c *Configuration) ReplaceUrlAlias(rawurl string, push bool) string {
+	c.loadGitConfig()
+
+	if push {
+		if rewritten, ok := c.replaceUrlAlias(rawurl, true); ok {
+			return rewritten
+		}
+	}
+
+	rewritten, _ := c.replaceUrlAlias(rawurl, false)
+	return rewritten
+}
+
+func (c *Configuration) replaceUrlAlias(rawurl string, push bool) (string, bool) {
+	var longest *urlAlias
+	for i, alias := range c.urlAliases {
+		if alias.push != push || !strings.HasPrefix(rawurl, alias.prefix) {
+			continue
+		}
+
+		if longest == nil || len(alias.prefix) > len(longest.prefix) {
+			longest = &c.urlAliases[i]
+		}
+	}
+
+	if longest == nil {
+		return rawurl, false
+	}
+
+	return longest.base + rawurl[len(longest.prefix):], true
+}
+
+// parseUrlAlias parses a url.<base>.insteadOf or url.<base>.pushInsteadOf git
+// config entry. The key must be given as it was read from the git config, so
+// that the case of <base> is preserved.
+func parseUrlAlias(key, value string) (urlAlias, bool) {
+	lower := strings.ToLower(key)
+	if !strings.HasPrefix(lower, "url.") || len(value) == 0 {
+		return urlAlias{}, false
+	}
+
+	for suffix, push := range map[string]bool{".insteadof": false, ".pushinsteadof": true} {
+		if strings.HasSuffix(lower, suffix) && len(key) > len("url.")+len(suffix) {
+			return urlAlias{
+				base:   key[len("url.") : len(key)-len(suffix)],
+				prefix: value,
+				push:   push,
+			}, true
+		}
+	}
+
+	return urlAlias{}, false
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplaceUrlAliasUsesLongestPrefix(t *testing.T) {
+	config := NewFromValues(map[string]string{
+		"url.https://github.com/.insteadof":         "gh:",
+		"url.https://github.com/MyOrg/.insteadof":   "gh:myorg/",
+		"url.git@mirror.internal:Repos/.insteadof":  "https://example.com/",
+		"url.ssh://git@github.com/.pushinsteadof":   "https://github.com/",
+		"url.ssh://git@github.com/.pushInsteadOf":   "gh:",
+		"url.https://unused.com/.somethingelse":     "gh:",
+		"url.https://unused.com/.pushinsteadofnope": "gh:",
+	})
+
+	assert.Equal(t, "https://github.com/other/repo", config.ReplaceUrlAlias("gh:other/repo", false))
+	assert.Equal(t, "https://github.com/MyOrg/repo", config.ReplaceUrlAlias("gh:myorg/repo", false))
+	assert.Equal(t, "git@mirror.internal:Repos/foo.git", config.ReplaceUrlAlias("https://example.com/foo.git", false))
+	assert.Equal(t, "https://other.com/foo", config.ReplaceUrlAlias("https://other.com/foo", false))
+
+	// pushInsteadOf takes precedence when pushing
+	assert.Equal(t, "ssh://git@github.com/other/repo", config.ReplaceUrlAlias("gh:other/repo", true))
+	assert.Equal(t, "ssh://git@github.com/foo/bar", config.ReplaceUrlAlias("https://github.com/foo/bar", true))
+	assert.Equal(t, "https://github.com/foo/bar", config.ReplaceUrlAlias("https://github.com/foo/bar", false))
+}
+
+func TestEndpointFromInsteadOfAlias(t *testing.T) {
+	config := NewFromValues(map[string]string{
+		"remote.origin.url":                       "gh:org/repo",
+		"url.https://github.com/.insteadof":       "gh:",
+		"url.ssh://git@github.com/.pushinsteadof": "gh:",
+	})
+
+	endpoint := config.Endpoint("download")
+	assert.Equal(t, "https://github.com/org/repo.git/info/lfs", endpoint.Url)
+	assert.Equal(t, "", endpoint.SshUserAndHost)
+
+	endpoint = config.Endpoint("upload")
+	assert.Equal(t, "https://github.com/org/repo.git/info/lfs", endpoint.Url)
+	assert.Equal(t, "git@github.com", endpoint.SshUserAndHost)
+	assert.Equal(t, "org/repo", endpoint.SshPath)
+}
+
+func TestEndpointPushUrlIgnoresPushInsteadOf(t *testing.T) {
+	config := NewFromValues(map[string]string{
+		"remote.origin.url":                          "gh:org/repo",
+		"remote.origin.pushurl":                      "gh:org/repo-push",
+		"url.https://github.com/.insteadof":          "gh:",
+		"url.https://push.github.com/.pushinsteadof": "gh:",
+	})
+
+	assert.Equal(t, "https://github.com/org/repo-push", config.GitRemoteUrl("origin", true))
+}
+
+func TestEndpointRewritesLfsUrl(t *testing.T) {
+	config := NewFromValues(map[string]string{
+		"lfs.url": "lfs:org/repo",
+		"url.https://lfs.internal.com/.insteadof": "lfs:",
+	})
+
+	endpoint := config.Endpoint("download")
+	assert.Equal(t, "https://lfs.internal.com/org/repo", endpoint.Url)
+}
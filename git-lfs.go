@@ -21,6 +21,7 @@ func main() {
 	go func() {
 		for {
 			sig := <-c
+			commands.Cancel()
 			once.Do(clearTempObjects)
 			fmt.Fprintf(os.Stderr, "\nExiting because of %q signal.\n", sig)
 
@@ -37,6 +37,23 @@ type Ref struct {
 	Sha  string
 }
 
+// FullName returns the fully qualified name of the ref, e.g. refs/heads/master
+// for the local branch master. It is the reverse of ParseRefToTypeAndName.
+func (r *Ref) FullName() string {
+	switch r.Type {
+	case RefTypeLocalBranch:
+		return "refs/heads/" + r.Name
+	case RefTypeRemoteBranch:
+		return "refs/remotes/" + r.Name
+	case RefTypeLocalTag:
+		return "refs/tags/" + r.Name
+	case RefTypeRemoteTag:
+		return "refs/remotes/tags/" + r.Name
+	default:
+		return r.Name
+	}
+}
+
 // Some top level information about a commit (only first line of message)
 type CommitSummary struct {
 	Sha            string
@@ -221,6 +238,38 @@ func LocalRefs() ([]*Ref, error) {
 	return refs, cmd.Wait()
 }
 
+// AllRefs returns every ref in the repository, including remote branches,
+// notes and the stash, but not HEAD.
+func AllRefs() ([]*Ref, error) {
+	cmd := subprocess.ExecCommand("git", "for-each-ref", "--format=%(objectname) %(refname)")
+
+	outp, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to call git for-each-ref: %v", err)
+	}
+
+	var refs []*Ref
+
+	if err := cmd.Start(); err != nil {
+		return refs, err
+	}
+
+	scanner := bufio.NewScanner(outp)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 || len(parts[0]) != 40 || len(parts[1]) < 1 {
+			tracerx.Printf("Invalid line from git for-each-ref: %q", line)
+			continue
+		}
+
+		rtype, name := ParseRefToTypeAndName(parts[1])
+		refs = append(refs, &Ref{name, rtype, parts[0]})
+	}
+
+	return refs, cmd.Wait()
+}
+
 // ValidateRemote checks that a named remote is valid for use
 // Mainly to check user-supplied remotes & fail more nicely
 func ValidateRemote(remote string) error {
@@ -503,14 +552,16 @@ func GetCommitSummary(commit string) (*CommitSummary, error) {
 }
 
 func GitAndRootDirs() (string, string, error) {
-	cmd := subprocess.ExecCommand("git", "rev-parse", "--git-dir", "--show-toplevel")
+	// git rev-parse --show-toplevel fails outside of a work tree, such as in
+	// a bare repository, so only ask for it inside one
+	cmd := subprocess.ExecCommand("git", "rev-parse", "--git-dir", "--is-inside-work-tree")
 	buf := &bytes.Buffer{}
 	cmd.Stderr = buf
 
 	out, err := cmd.Output()
 	output := string(out)
 	if err != nil {
-		return "", "", fmt.Errorf("Failed to call git rev-parse --git-dir --show-toplevel: %q", buf.String())
+		return "", "", fmt.Errorf("Failed to call git rev-parse --git-dir --is-inside-work-tree: %q", buf.String())
 	}
 
 	paths := strings.Split(output, "\n")
@@ -525,16 +576,16 @@ func GitAndRootDirs() (string, string, error) {
 		return "", "", fmt.Errorf("Error converting %q to absolute: %s", paths[0], err)
 	}
 
-	if pathLen == 1 || len(paths[1]) == 0 {
+	if pathLen == 1 || paths[1] != "true" {
 		return absGitDir, "", nil
 	}
 
-	absRootDir, err := filepath.Abs(paths[1])
+	rootDir, err := RootDir()
 	if err != nil {
-		return "", "", fmt.Errorf("Error converting %q to absolute: %s", paths[1], err)
+		return "", "", err
 	}
 
-	return absGitDir, absRootDir, nil
+	return absGitDir, rootDir, nil
 }
 
 func RootDir() (string, error) {
@@ -455,3 +455,57 @@ func TestLocalRefs(t *testing.T) {
 		t.Errorf("Unexpected local refs: %v", actual)
 	}
 }
+
+func TestAllRefs(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	outputs := repo.AddCommits([]*test.CommitInput{
+		{
+			Files: []*test.FileInput{
+				{Filename: "file1.txt", Size: 20},
+			},
+		},
+	})
+
+	test.RunGitCommand(t, true, "tag", "v1")
+	test.RunGitCommand(t, true, "update-ref", "refs/remotes/origin/master", "HEAD")
+	test.RunGitCommand(t, true, "update-ref", "refs/custom/ref", "HEAD")
+
+	refs, err := AllRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := make(map[string]RefType)
+	for _, r := range refs {
+		assert.Equal(t, outputs[0].Sha, r.Sha, r.Name)
+		actual[r.Name] = r.Type
+	}
+
+	assert.Equal(t, map[string]RefType{
+		"master":          RefTypeLocalBranch,
+		"v1":              RefTypeLocalTag,
+		"origin/master":   RefTypeRemoteBranch,
+		"refs/custom/ref": RefTypeOther,
+	}, actual)
+}
+
+func TestRefFullName(t *testing.T) {
+	for _, fullref := range []string{
+		"refs/heads/master",
+		"refs/heads/release/1.0",
+		"refs/remotes/origin/master",
+		"refs/tags/v1.0",
+		"HEAD",
+		"refs/stash",
+	} {
+		rtype, name := ParseRefToTypeAndName(fullref)
+		ref := &Ref{Name: name, Type: rtype}
+		assert.Equal(t, fullref, ref.FullName())
+	}
+}
@@ -0,0 +1,211 @@
+// Package gitattr reads gitattributes files and works out the attributes of
+// paths as git does, from the working tree, the index or any tree, without
+// running git check-attr.
+// NOTE: Subject to change, do not rely on this package from outside git-lfs source
+package gitattr
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The states of an attribute which has no value, as git check-attr prints
+// them. Any other state is the value given with "attr=value".
+const (
+	Set         = "set"
+	Unset       = "unset"
+	Unspecified = "unspecified"
+)
+
+// Attr is an attribute on a line of a gitattributes file.
+type Attr struct {
+	Name string
+	// Value is Set for "attr", Unset for "-attr", Unspecified for "!attr"
+	// and the value for "attr=value".
+	Value string
+}
+
+// String returns the attribute as it would be written in a gitattributes
+// file.
+func (a *Attr) String() string {
+	switch a.Value {
+	case Set:
+		return a.Name
+	case Unset:
+		return "-" + a.Name
+	case Unspecified:
+		return "!" + a.Name
+	default:
+		return a.Name + "=" + a.Value
+	}
+}
+
+// Line is a line of a gitattributes file which gives attributes to the paths
+// matching a pattern, or defines a macro.
+type Line struct {
+	// Pattern is the pattern as written, unquoted, or an empty string for
+	// a macro definition.
+	Pattern string
+	// Macro is the name of the macro that the line defines, if it is a
+	// "[attr]name" line.
+	Macro string
+	Attrs []*Attr
+}
+
+// Tracked returns whether the line gives the paths it matches the Git LFS
+// filter.
+func (l *Line) Tracked() bool {
+	for _, a := range l.Attrs {
+		if a.Name == "filter" && a.Value == "lfs" {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLines reads the lines of a gitattributes file. Blank lines, comments
+// and negative patterns, which git does not allow, are skipped.
+func ParseLines(r io.Reader) ([]*Line, error) {
+	var lines []*Line
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		if line, ok := ParseLine(scanner.Text()); ok {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// ParseLine parses a single line of a gitattributes file. It returns false
+// for blank lines, comments, negative patterns and malformed macro
+// definitions.
+func ParseLine(text string) (*Line, bool) {
+	text = strings.TrimLeft(text, " \t\r\n")
+	if len(text) == 0 || text[0] == '#' {
+		return nil, false
+	}
+
+	var pattern string
+	if text[0] == '"' {
+		quoted, rest, ok := unquotePattern(text)
+		if !ok {
+			return nil, false
+		}
+		pattern, text = quoted, rest
+	} else {
+		end := strings.IndexAny(text, " \t\r\n")
+		if end < 0 {
+			end = len(text)
+		}
+		pattern, text = text[:end], text[end:]
+	}
+
+	line := &Line{Pattern: pattern}
+	if strings.HasPrefix(pattern, "[attr]") {
+		line.Pattern = ""
+		line.Macro = pattern[len("[attr]"):]
+		if !validAttrName(line.Macro) {
+			return nil, false
+		}
+	} else if strings.HasPrefix(pattern, "!") || len(pattern) == 0 {
+		return nil, false
+	}
+
+	for _, field := range strings.Fields(text) {
+		if attr, ok := parseAttr(field); ok {
+			line.Attrs = append(line.Attrs, attr)
+		}
+	}
+	return line, true
+}
+
+func parseAttr(field string) (*Attr, bool) {
+	attr := &Attr{Value: Set}
+	switch field[0] {
+	case '-':
+		attr.Value = Unset
+		field = field[1:]
+	case '!':
+		attr.Value = Unspecified
+		field = field[1:]
+	default:
+		if eq := strings.IndexByte(field, '='); eq >= 0 {
+			attr.Value = field[eq+1:]
+			field = field[:eq]
+		}
+	}
+
+	attr.Name = field
+	return attr, validAttrName(field)
+}
+
+// validAttrName returns whether name is allowed as an attribute name: ASCII
+// letters, digits, '-', '.' and '_', not starting with '-'.
+func validAttrName(name string) bool {
+	if len(name) == 0 || name[0] == '-' {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c != '-' && c != '.' && c != '_' &&
+			(c < '0' || c > '9') && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// unquotePattern reads a pattern quoted as git quotes paths, in double quotes
+// with C escapes, from the start of text, and returns it and the rest of the
+// line.
+func unquotePattern(text string) (string, string, bool) {
+	var buf bytes.Buffer
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '"':
+			return buf.String(), text[i+1:], true
+		case c != '\\':
+			buf.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i >= len(text) {
+			return "", "", false
+		}
+		switch c = text[i]; c {
+		case 'a':
+			buf.WriteByte('\a')
+		case 'b':
+			buf.WriteByte('\b')
+		case 'f':
+			buf.WriteByte('\f')
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case 'v':
+			buf.WriteByte('\v')
+		case '0', '1', '2', '3':
+			if i+3 > len(text) {
+				return "", "", false
+			}
+			n, err := strconv.ParseUint(text[i:i+3], 8, 8)
+			if err != nil {
+				return "", "", false
+			}
+			buf.WriteByte(byte(n))
+			i += 2
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return "", "", false
+}
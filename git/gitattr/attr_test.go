@@ -0,0 +1,114 @@
+package gitattr_test // to avoid import cycles
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/github/git-lfs/git/gitattr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLine(t *testing.T) {
+	for _, c := range []struct {
+		Text  string
+		Line  *Line
+		Valid bool
+	}{
+		{"*.dat filter=lfs diff=lfs merge=lfs -text", &Line{Pattern: "*.dat", Attrs: []*Attr{
+			{"filter", "lfs"}, {"diff", "lfs"}, {"merge", "lfs"}, {"text", Unset},
+		}}, true},
+		{"  a/b\t text !eol\r", &Line{Pattern: "a/b", Attrs: []*Attr{{"text", Set}, {"eol", Unspecified}}}, true},
+		{`"a b\tc.\303\251" filter=lfs`, &Line{Pattern: "a b\tc.é", Attrs: []*Attr{{"filter", "lfs"}}}, true},
+		{"[attr]lfs filter=lfs -text", &Line{Macro: "lfs", Attrs: []*Attr{{"filter", "lfs"}, {"text", Unset}}}, true},
+		{"*.dat -bad-=x =x ok", &Line{Pattern: "*.dat", Attrs: []*Attr{{"ok", Set}}}, true},
+		{"*.dat", &Line{Pattern: "*.dat"}, true},
+		{"", nil, false},
+		{"   ", nil, false},
+		{"# *.dat filter=lfs", nil, false},
+		{"!*.dat filter=lfs", nil, false},
+		{`"unterminated filter=lfs`, nil, false},
+		{"[attr]-bad text", nil, false},
+	} {
+		line, ok := ParseLine(c.Text)
+		assert.Equal(t, c.Valid, ok, c.Text)
+		assert.Equal(t, c.Line, line, c.Text)
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	lines, err := ParseLines(strings.NewReader("# tracked\n*.dat filter=lfs\n\n!x text\n*.txt text\n"))
+	assert.Nil(t, err)
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "*.dat", lines[0].Pattern)
+		assert.True(t, lines[0].Tracked())
+		assert.Equal(t, "*.txt", lines[1].Pattern)
+		assert.False(t, lines[1].Tracked())
+	}
+}
+
+func TestAttrString(t *testing.T) {
+	for _, line := range []string{"a filter=lfs", "a text", "a -text", "a !text"} {
+		parsed, ok := ParseLine(line)
+		if assert.True(t, ok) && assert.Len(t, parsed.Attrs, 1) {
+			assert.Equal(t, line[2:], parsed.Attrs[0].String())
+		}
+	}
+}
+
+func TestPatternMatch(t *testing.T) {
+	for _, c := range []struct {
+		Pattern string
+		Dir     string
+		Path    string
+		Matches bool
+	}{
+		{"*.dat", "", "a.dat", true},
+		{"*.dat", "", "dir/sub/a.dat", true},
+		{"*.dat", "", "a.dat/b", false},
+		{"*.dat", "dir", "dir/sub/a.dat", true},
+		{"*.dat", "dir", "other/a.dat", false},
+		{"*.dat", "dir", "dirt/a.dat", false},
+		{"a.dat", "", "dir/a.dat", true},
+		{"/a.dat", "", "dir/a.dat", false},
+		{"/a.dat", "dir", "dir/a.dat", true},
+		{"sub/*.dat", "", "sub/a.dat", true},
+		{"sub/*.dat", "", "sub/x/a.dat", false},
+		{"sub/*.dat", "", "dir/sub/a.dat", false},
+		{"sub/*.dat", "dir", "dir/sub/a.dat", true},
+		{"dir/", "", "dir", false},
+		{"dir/", "", "dir/a.dat", false},
+		{"dir/**", "", "dir/a.dat", true},
+		{"dir/**", "", "dir/sub/a.dat", true},
+		{"dir/**", "", "dirt/a.dat", false},
+		{"**/a.dat", "", "a.dat", true},
+		{"**/a.dat", "", "x/y/a.dat", true},
+		{"a/**/b", "", "a/b", true},
+		{"a/**/b", "", "a/x/y/b", true},
+		{"a/**/b", "", "a/xb", false},
+		{"a/**b", "", "a/xb", true},
+		{"a/**b", "", "a/x/b", false},
+		{"a/*/b", "", "a/x/b", true},
+		{"a/*/b", "", "a/x/y/b", false},
+		{"a?c", "", "abc", true},
+		{"a?c", "", "dir/abc", true},
+		{"a/?/c", "", "a///c", false},
+		{"a[[:space:]]b.dat", "", "a b.dat", true},
+		{"a[[:space:]]b.dat", "", "a_b.dat", false},
+		{"[a-c]*.dat", "", "b1.dat", true},
+		{"[!a-c]*.dat", "", "b1.dat", false},
+		{"[^a-c]*.dat", "", "d1.dat", true},
+		{"[]]x", "", "]x", true},
+		{"[[:digit:][:upper:]]x", "", "Qx", true},
+		{"[[:bogus:]]x", "", "bx", false},
+		{"[a", "", "[a", false},
+		{`\*.dat`, "", "*.dat", true},
+		{`\*.dat`, "", "a.dat", false},
+		{"*", "", "dir/a.dat", true},
+		{"**", "", "dir/a.dat", true},
+		{"*/a.dat", "", "dir/a.dat", true},
+		{"*/a.dat", "", "x/dir/a.dat", false},
+	} {
+		assert.Equal(t, c.Matches, NewPattern(c.Pattern, c.Dir).Match(c.Path),
+			"%q in %q against %q", c.Pattern, c.Dir, c.Path)
+	}
+}
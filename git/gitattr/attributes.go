@@ -0,0 +1,205 @@
+package gitattr
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// builtinMacros are the macros which git defines itself.
+var builtinMacros = map[string][]*Attr{
+	"binary": {{"diff", Unset}, {"merge", Unset}, {"text", Unset}},
+}
+
+// file is a parsed gitattributes file, with the patterns of its lines.
+type file struct {
+	lines    []*Line
+	patterns []*Pattern
+}
+
+func newFile(lines []*Line, dir string) *file {
+	f := &file{lines: lines, patterns: make([]*Pattern, len(lines))}
+	for i, line := range lines {
+		if len(line.Macro) == 0 {
+			f.patterns[i] = NewPattern(line.Pattern, dir)
+		}
+	}
+	return f
+}
+
+// Attributes works out the attributes of paths as git does, from the
+// .gitattributes files of a Source, $GIT_DIR/info/attributes and the user's
+// attributes file. Lines in info/attributes take precedence over those in any
+// .gitattributes file, which take precedence over those in the directories
+// above them and in the user's file. Within a file, later lines take
+// precedence. Macros may only be defined in the user's file, the root
+// .gitattributes and info/attributes. It is not safe for concurrent use.
+type Attributes struct {
+	source Source
+	global *file
+	info   *file
+	// dirs are the .gitattributes files of the source read so far, by
+	// directory, with nil for directories without one
+	dirs   map[string]*file
+	macros map[string][]*Attr
+}
+
+// New returns Attributes for the .gitattributes files of source in the
+// repository with the given git directory, and the user's attributes file
+// at globalFile, which need not exist.
+func New(source Source, gitDir, globalFile string) (*Attributes, error) {
+	a := &Attributes{source: source, dirs: make(map[string]*file), macros: make(map[string][]*Attr)}
+	for name, attrs := range builtinMacros {
+		a.macros[name] = attrs
+	}
+
+	var err error
+	if len(globalFile) > 0 {
+		if a.global, err = readFile(globalFile); err != nil {
+			return nil, err
+		}
+	}
+	if len(gitDir) > 0 {
+		if a.info, err = readFile(filepath.Join(gitDir, "info", "attributes")); err != nil {
+			return nil, err
+		}
+	}
+	root, err := a.dir("")
+	if err != nil {
+		return nil, err
+	}
+
+	// later definitions win, so those with the highest precedence go last
+	for _, f := range []*file{a.global, root, a.info} {
+		if f == nil {
+			continue
+		}
+		for _, line := range f.lines {
+			if len(line.Macro) > 0 {
+				a.macros[line.Macro] = line.Attrs
+			}
+		}
+	}
+	return a, nil
+}
+
+// readFile parses the gitattributes file at path, at the root of the
+// repository, or returns nil if there is none.
+func readFile(path string) (*file, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	lines, err := ParseLines(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return newFile(lines, ""), nil
+}
+
+// dir returns the .gitattributes file in dir from the source, or nil if
+// there is none.
+func (a *Attributes) dir(dir string) (*file, error) {
+	if f, ok := a.dirs[dir]; ok {
+		return f, nil
+	}
+
+	data, err := a.source.ReadFile(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var f *file
+	if data != nil {
+		lines, err := ParseLines(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		f = newFile(lines, dir)
+	}
+	a.dirs[dir] = f
+	return f, nil
+}
+
+// Get returns the attributes of the file at path, slash-separated and
+// relative to the root of the repository, which are set, unset or have a
+// value, by name. Unspecified attributes are left out.
+func (a *Attributes) Get(path string) (map[string]string, error) {
+	// the files which apply, from the lowest precedence to the highest
+	files := []*file{a.global}
+	dirs := []string{""}
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' {
+			dirs = append(dirs, path[:i])
+		}
+	}
+	for _, dir := range dirs {
+		f, err := a.dir(dir)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	files = append(files, a.info)
+
+	// Like git, work back from the line with the highest precedence,
+	// giving each attribute the first state found for it.
+	states := make(map[string]string)
+	for i := len(files) - 1; i >= 0; i-- {
+		f := files[i]
+		if f == nil {
+			continue
+		}
+		for j := len(f.lines) - 1; j >= 0; j-- {
+			if f.patterns[j] != nil && f.patterns[j].Match(path) {
+				a.fill(states, f.lines[j].Attrs)
+			}
+		}
+	}
+
+	for name, value := range states {
+		if value == Unspecified {
+			delete(states, name)
+		}
+	}
+	return states, nil
+}
+
+// fill gives each of attrs which has no state yet its state, from the last to
+// the first, and expands any macro which is set.
+func (a *Attributes) fill(states map[string]string, attrs []*Attr) {
+	for i := len(attrs) - 1; i >= 0; i-- {
+		attr := attrs[i]
+		if _, ok := states[attr.Name]; ok {
+			continue
+		}
+		states[attr.Name] = attr.Value
+
+		if macro, ok := a.macros[attr.Name]; ok && attr.Value == Set {
+			a.fill(states, macro)
+		}
+	}
+}
+
+// Value returns the state of the attribute name for the file at path, which
+// is Unspecified if no line gives it one.
+func (a *Attributes) Value(path, name string) (string, error) {
+	states, err := a.Get(path)
+	if err != nil {
+		return "", err
+	}
+	if value, ok := states[name]; ok {
+		return value, nil
+	}
+	return Unspecified, nil
+}
+
+// Tracked returns whether the file at path is given the Git LFS filter.
+func (a *Attributes) Tracked(path string) (bool, error) {
+	value, err := a.Value(path, "filter")
+	return value == "lfs", err
+}
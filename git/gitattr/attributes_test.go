@@ -0,0 +1,150 @@
+package gitattr_test // to avoid import cycles
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/github/git-lfs/git/gitattr"
+	"github.com/github/git-lfs/git/odb"
+	"github.com/github/git-lfs/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttributesMatchGit(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	globalFile := filepath.Join(repo.Path, "..", filepath.Base(repo.Path)+"-attributes")
+	defer os.Remove(globalFile)
+	writeFile(t, globalFile, "[attr]art lfs -diff\n*.psd art\n*.txt eol=lf\n")
+	test.RunGitCommand(t, true, "config", "core.attributesFile", globalFile)
+
+	writeFile(t, ".gitattributes", strings.Join([]string{
+		"[attr]lfs filter=lfs diff=lfs merge=lfs -text",
+		"*.dat lfs",
+		"*.bin binary",
+		"/top.dat -lfs",
+		`"with space.dat" !filter`,
+		"docs/** text",
+		"a/**/b.dat foo=bar",
+		"!*.neg text",
+	}, "\n"))
+	writeFile(t, "sub/.gitattributes", "*.dat -filter\nkeep.dat lfs\n[attr]ignored text\n*.ign ignored\n")
+	writeFile(t, "sub/deep/.gitattributes", "*.psd -art\n*.txt eol=crlf\n")
+	writeFile(t, filepath.Join(repo.GitDir, "info", "attributes"), "info.dat -diff\n[attr]lfs filter=lfs\n")
+
+	paths := []string{
+		"a.dat", "top.dat", "with space.dat", "info.dat", "x.bin", "a.psd", "a.txt",
+		"sub/a.dat", "sub/keep.dat", "sub/x.ign", "sub/deep/a.psd", "sub/deep/a.txt", "sub/deep/info.dat",
+		"docs/a.md", "docs/sub/b.dat", "a/b.dat", "a/x/y/b.dat", "x.neg", "none",
+	}
+	want := gitCheckAttrAll(t, paths)
+
+	attrs, err := New(NewWorkingTreeSource(repo.Path), repo.GitDir, globalFile)
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, p := range paths {
+		got, err := attrs.Get(p)
+		assert.Nil(t, err)
+		assert.Equal(t, want[p], got, p)
+	}
+
+	tracked, err := attrs.Tracked("sub/keep.dat")
+	assert.Nil(t, err)
+	assert.True(t, tracked)
+	value, err := attrs.Value("none", "filter")
+	assert.Nil(t, err)
+	assert.Equal(t, Unspecified, value)
+}
+
+func TestAttributesFromTreeAndIndex(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	writeFile(t, ".gitattributes", "*.dat filter=lfs\n")
+	writeFile(t, "dir/.gitattributes", "*.bin filter=lfs\n")
+	test.RunGitCommand(t, true, "add", ".")
+	test.RunGitCommand(t, true, "commit", "-m", "attributes")
+
+	writeFile(t, "dir/.gitattributes", "*.dat -filter\n")
+	test.RunGitCommand(t, true, "add", ".")
+	writeFile(t, ".gitattributes", "*.psd filter=lfs\n")
+
+	db, err := odb.Open(filepath.Join(repo.GitDir, "objects"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer db.Close()
+
+	commit, err := db.ReadCommit(strings.TrimSpace(test.RunGitCommand(t, true, "rev-parse", "HEAD")))
+	if !assert.Nil(t, err) {
+		return
+	}
+	tree, err := NewTreeSource(db, commit.Tree)
+	assert.Nil(t, err)
+	index, err := NewIndexSource(db)
+	assert.Nil(t, err)
+
+	for _, c := range []struct {
+		Source  Source
+		Tracked map[string]bool
+	}{
+		{tree, map[string]bool{"a.dat": true, "dir/a.dat": true, "dir/a.bin": true, "a.psd": false, "no/such/a.dat": true}},
+		{index, map[string]bool{"a.dat": true, "dir/a.dat": false, "dir/a.bin": false, "a.psd": false}},
+		{NewWorkingTreeSource(repo.Path), map[string]bool{"a.dat": false, "dir/a.dat": false, "a.psd": true, ".gitattributes/a.dat": false}},
+		{NewEmptySource(), map[string]bool{"a.dat": false, "a.psd": false}},
+	} {
+		attrs, err := New(c.Source, repo.GitDir, "")
+		if !assert.Nil(t, err) {
+			continue
+		}
+		for p, want := range c.Tracked {
+			tracked, err := attrs.Tracked(p)
+			assert.Nil(t, err)
+			assert.Equal(t, want, tracked, "%T %s", c.Source, p)
+		}
+	}
+}
+
+// gitCheckAttrAll returns the attributes which git check-attr --all gives
+// each path, by name.
+func gitCheckAttrAll(t *testing.T, paths []string) map[string]map[string]string {
+	// git warns about the lines that it ignores on stderr
+	args := append([]string{"check-attr", "-z", "--all", "--"}, paths...)
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		t.Fatalf("Error running git check-attr: %v", err)
+	}
+
+	all := make(map[string]map[string]string)
+	for _, p := range paths {
+		all[p] = make(map[string]string)
+	}
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		all[fields[i]][fields[i+1]] = fields[i+2]
+	}
+	return all
+}
+
+func writeFile(t *testing.T, path, contents string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,302 @@
+package gitattr
+
+import "strings"
+
+// Pattern matches paths as a pattern in the gitattributes file of a directory
+// does. Like a .gitignore pattern, a pattern without a slash matches the base
+// name of paths at any depth below the directory, and any other is matched
+// against the whole path relative to the directory, where "*" and "?" do not
+// match a slash and "**" matches any number of directories. Unlike a
+// .gitignore pattern, one ending in a slash matches directories only, so
+// never matches a file.
+type Pattern struct {
+	// dir is the directory of the gitattributes file, relative to the
+	// root, or an empty string for the root.
+	dir      string
+	pattern  string
+	basename bool
+	dirOnly  bool
+}
+
+// NewPattern returns the pattern as written in the gitattributes file of dir,
+// a slash-separated path relative to the root of the repository.
+func NewPattern(pattern, dir string) *Pattern {
+	p := &Pattern{dir: strings.Trim(dir, "/")}
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	p.basename = !strings.Contains(pattern, "/")
+	p.pattern = strings.TrimPrefix(pattern, "/")
+	return p
+}
+
+// Match returns whether the file at path, slash-separated and relative to the
+// root of the repository, matches the pattern.
+func (p *Pattern) Match(path string) bool {
+	if p.dirOnly {
+		return false
+	}
+
+	if len(p.dir) > 0 {
+		if !strings.HasPrefix(path, p.dir+"/") {
+			return false
+		}
+		path = path[len(p.dir)+1:]
+	}
+
+	if p.basename {
+		return wildmatch(p.pattern, path[strings.LastIndex(path, "/")+1:])
+	}
+	return wildmatch(p.pattern, path)
+}
+
+// The results of matching part of a pattern, as in git's wildmatch.c. An
+// abort means that no later position of a "*" can match either, so that it
+// need not be tried.
+const (
+	wmNoMatch = iota
+	wmMatch
+	wmAbortAll
+	wmAbortToStarStar
+)
+
+// wildmatch returns whether text matches pattern, as git's wildmatch() does
+// with WM_PATHNAME.
+func wildmatch(pattern, text string) bool {
+	return dowild(pattern, text) == wmMatch
+}
+
+// at returns the byte at i in s, or 0 past its end, as the C strings of
+// wildmatch.c would.
+func at(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+func dowild(pattern, text string) int {
+	p, t := 0, 0
+	for ; p < len(pattern); p, t = p+1, t+1 {
+		pc := pattern[p]
+		tc := at(text, t)
+		if tc == 0 && pc != '*' {
+			return wmAbortAll
+		}
+
+		switch pc {
+		case '\\':
+			// a literal match of the next character
+			p++
+			if at(pattern, p) != tc {
+				return wmNoMatch
+			}
+		default:
+			if pc != tc {
+				return wmNoMatch
+			}
+		case '?':
+			if tc == '/' {
+				return wmNoMatch
+			}
+		case '*':
+			matchSlash := false
+			p++
+			if at(pattern, p) == '*' {
+				prev := p - 2
+				for p++; at(pattern, p) == '*'; p++ {
+				}
+				if (prev < 0 || pattern[prev] == '/') &&
+					(p == len(pattern) || pattern[p] == '/' || (pattern[p] == '\\' && at(pattern, p+1) == '/')) {
+					// "**/" may match nothing at all, so that
+					// "a/**/b" matches "a/b"
+					if at(pattern, p) == '/' && dowild(pattern[p+1:], text[t:]) == wmMatch {
+						return wmMatch
+					}
+					matchSlash = true
+				}
+			}
+
+			if p == len(pattern) {
+				// a trailing "**" matches everything, and a
+				// trailing "*" everything up to the next slash
+				if !matchSlash && strings.IndexByte(text[t:], '/') >= 0 {
+					return wmNoMatch
+				}
+				return wmMatch
+			} else if !matchSlash && pattern[p] == '/' {
+				// "*/" matches the rest of this directory name
+				slash := strings.IndexByte(text[t:], '/')
+				if slash < 0 {
+					return wmNoMatch
+				}
+				t += slash
+				continue
+			}
+
+			for ; t < len(text); t++ {
+				tc = text[t]
+				// the text before the next literal in the
+				// pattern must all be matched by the "*"
+				if !isGlobSpecial(pattern[p]) {
+					for ; t < len(text) && (matchSlash || text[t] != '/'); t++ {
+						if text[t] == pattern[p] {
+							break
+						}
+					}
+					if at(text, t) != pattern[p] {
+						return wmNoMatch
+					}
+					tc = text[t]
+				}
+
+				if matched := dowild(pattern[p:], text[t:]); matched != wmNoMatch {
+					if !matchSlash || matched != wmAbortToStarStar {
+						return matched
+					}
+				} else if !matchSlash && tc == '/' {
+					return wmAbortToStarStar
+				}
+			}
+			return wmAbortAll
+		case '[':
+			end, matched, ok := matchClass(pattern, p, tc)
+			if !ok {
+				return wmAbortAll
+			}
+			if !matched || tc == '/' {
+				return wmNoMatch
+			}
+			p = end
+		}
+	}
+
+	if t < len(text) {
+		return wmNoMatch
+	}
+	return wmMatch
+}
+
+func isGlobSpecial(c byte) bool {
+	return c == '*' || c == '?' || c == '[' || c == '\\'
+}
+
+// matchClass matches c against the bracket expression starting at
+// pattern[start], and returns the index of its closing bracket, whether c
+// matched it and whether it was well formed.
+func matchClass(pattern string, start int, c byte) (int, bool, bool) {
+	p := start + 1
+	pc := at(pattern, p)
+	negated := false
+	if pc == '!' || pc == '^' {
+		negated = true
+		p++
+		pc = at(pattern, p)
+	}
+
+	var prev byte
+	matched := false
+	for {
+		if pc == 0 {
+			return 0, false, false
+		}
+
+		switch {
+		case pc == '\\':
+			p++
+			pc = at(pattern, p)
+			if pc == 0 {
+				return 0, false, false
+			}
+			if c == pc {
+				matched = true
+			}
+		case pc == '-' && prev != 0 && at(pattern, p+1) != 0 && at(pattern, p+1) != ']':
+			p++
+			pc = pattern[p]
+			if pc == '\\' {
+				p++
+				pc = at(pattern, p)
+				if pc == 0 {
+					return 0, false, false
+				}
+			}
+			if c >= prev && c <= pc {
+				matched = true
+			}
+			pc = 0
+		case pc == '[' && at(pattern, p+1) == ':':
+			s := p + 2
+			end := strings.IndexByte(pattern[s:], ']')
+			if end < 0 {
+				return 0, false, false
+			}
+			end += s
+			if end == s || pattern[end-1] != ':' {
+				// not a "[:class:]", so the '[' is literal
+				if c == '[' {
+					matched = true
+				}
+				break
+			}
+
+			in, ok := inCharClass(pattern[s:end-1], c)
+			if !ok {
+				return 0, false, false
+			}
+			if in {
+				matched = true
+			}
+			p = end
+			pc = 0
+		default:
+			if c == pc {
+				matched = true
+			}
+		}
+
+		prev = pc
+		p++
+		pc = at(pattern, p)
+		if pc == ']' {
+			break
+		}
+	}
+	return p, matched != negated, true
+}
+
+// inCharClass returns whether c is in the POSIX character class with the
+// given name, and whether there is such a class.
+func inCharClass(class string, c byte) (bool, bool) {
+	lower := c >= 'a' && c <= 'z'
+	upper := c >= 'A' && c <= 'Z'
+	digit := c >= '0' && c <= '9'
+	switch class {
+	case "alnum":
+		return lower || upper || digit, true
+	case "alpha":
+		return lower || upper, true
+	case "blank":
+		return c == ' ' || c == '\t', true
+	case "cntrl":
+		return c < 0x20 || c == 0x7f, true
+	case "digit":
+		return digit, true
+	case "graph":
+		return c > 0x20 && c < 0x7f, true
+	case "lower":
+		return lower, true
+	case "print":
+		return c >= 0x20 && c < 0x7f, true
+	case "punct":
+		return c > 0x20 && c < 0x7f && !lower && !upper && !digit, true
+	case "space":
+		return c == ' ' || (c >= '\t' && c <= '\r'), true
+	case "upper":
+		return upper, true
+	case "xdigit":
+		return digit || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'), true
+	}
+	return false, false
+}
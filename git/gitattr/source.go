@@ -0,0 +1,167 @@
+package gitattr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/github/git-lfs/git/odb"
+	"github.com/github/git-lfs/subprocess"
+	"github.com/rubyist/tracerx"
+)
+
+// Source reads the .gitattributes files of a working tree, an index or a
+// tree.
+type Source interface {
+	// ReadFile returns the contents of the .gitattributes file in dir, a
+	// slash-separated path relative to the root with an empty string for
+	// the root itself, or nil if there is none.
+	ReadFile(dir string) ([]byte, error)
+}
+
+type emptySource struct{}
+
+// NewEmptySource returns a Source with no .gitattributes files, such as that
+// of a bare repository, so that only info/attributes and the user's
+// attributes file apply.
+func NewEmptySource() Source {
+	return emptySource{}
+}
+
+func (emptySource) ReadFile(dir string) ([]byte, error) {
+	return nil, nil
+}
+
+type workingTreeSource struct {
+	root string
+}
+
+// NewWorkingTreeSource returns a Source which reads the .gitattributes files
+// in the working tree at root.
+func NewWorkingTreeSource(root string) Source {
+	return &workingTreeSource{root: root}
+}
+
+func (s *workingTreeSource) ReadFile(dir string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.root, filepath.FromSlash(dir), ".gitattributes"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	// dir is a file rather than a directory
+	if perr, ok := err.(*os.PathError); ok && perr.Err == syscall.ENOTDIR {
+		return nil, nil
+	}
+	return data, err
+}
+
+type treeSource struct {
+	db    *odb.ObjectDatabase
+	trees map[string]*odb.Tree
+}
+
+// NewTreeSource returns a Source which reads the .gitattributes files in the
+// tree with the given sha, and the trees below it, from db.
+func NewTreeSource(db *odb.ObjectDatabase, sha string) (Source, error) {
+	root, err := db.ReadTree(sha)
+	if err != nil {
+		return nil, err
+	}
+	return &treeSource{db: db, trees: map[string]*odb.Tree{"": root}}, nil
+}
+
+func (s *treeSource) ReadFile(dir string) ([]byte, error) {
+	tree, err := s.tree(dir)
+	if tree == nil || err != nil {
+		return nil, err
+	}
+
+	entry := tree.Entry(".gitattributes")
+	if entry == nil || !entry.IsFile() {
+		return nil, nil
+	}
+	obj, err := s.db.Read(entry.Sha)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Data, nil
+}
+
+// tree returns the tree for dir, or nil if there is no such directory.
+func (s *treeSource) tree(dir string) (*odb.Tree, error) {
+	if tree, ok := s.trees[dir]; ok {
+		return tree, nil
+	}
+
+	parentDir, name := path.Split(dir)
+	parent, err := s.tree(strings.TrimSuffix(parentDir, "/"))
+	if parent == nil || err != nil {
+		return nil, err
+	}
+
+	var tree *odb.Tree
+	if entry := parent.Entry(name); entry != nil && entry.Type() == "tree" {
+		if tree, err = s.db.ReadTree(entry.Sha); err != nil {
+			return nil, err
+		}
+	}
+	s.trees[dir] = tree
+	return tree, nil
+}
+
+type indexSource struct {
+	db *odb.ObjectDatabase
+	// blobs are the shas of the .gitattributes files in the index, by
+	// directory
+	blobs map[string]string
+}
+
+// NewIndexSource returns a Source which reads the .gitattributes files staged
+// in the index of the current repository, from db.
+func NewIndexSource(db *odb.ObjectDatabase) (Source, error) {
+	output, err := subprocess.SimpleExec("git", "ls-files", "--stage", "-z", "--full-name",
+		"--", ":(top).gitattributes", ":(top)*/.gitattributes")
+	if err != nil {
+		return nil, fmt.Errorf("Error listing .gitattributes files in the index: %v", err)
+	}
+
+	s := &indexSource{db: db, blobs: make(map[string]string)}
+	for _, entry := range strings.Split(output, "\x00") {
+		// <mode> SP <sha> SP <stage> TAB <path>
+		tab := strings.IndexByte(entry, '\t')
+		if tab < 0 {
+			continue
+		}
+		fields := strings.Fields(entry[:tab])
+		name := entry[tab+1:]
+		if len(fields) != 3 || path.Base(name) != ".gitattributes" {
+			continue
+		}
+		// only the merged version of a file with conflicts applies
+		if fields[2] != "0" {
+			tracerx.Printf("gitattr: skipping unmerged %s", name)
+			continue
+		}
+		dir := path.Dir(name)
+		if dir == "." {
+			dir = ""
+		}
+		s.blobs[dir] = fields[1]
+	}
+	return s, nil
+}
+
+func (s *indexSource) ReadFile(dir string) ([]byte, error) {
+	sha, ok := s.blobs[dir]
+	if !ok {
+		return nil, nil
+	}
+	obj, err := s.db.Read(sha)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Data, nil
+}
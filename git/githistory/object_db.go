@@ -0,0 +1,224 @@
+// Package githistory rewrites the history of a git repository, by passing the
+// blobs and trees of each commit to callbacks which may replace them.
+// NOTE: Subject to change, do not rely on this package from outside git-lfs source
+package githistory
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/github/git-lfs/subprocess"
+	"github.com/rubyist/tracerx"
+)
+
+// Object is an object read from an ObjectDatabase. Its Contents must be read
+// before anything else is read from the database, or they are discarded.
+type Object struct {
+	Sha      string
+	Type     string
+	Size     int64
+	Contents io.Reader
+}
+
+// ObjectDatabase reads objects from a repository with a single `git cat-file
+// --batch` process, and writes new objects into it as loose objects.
+type ObjectDatabase struct {
+	objectsDir string
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	stdout     *bufio.Reader
+	// the contents of the last object read, which must be drained before the
+	// next object can be read
+	pending *objectContents
+}
+
+// NewObjectDatabase returns an ObjectDatabase for the repository whose objects
+// are in the objects directory of gitDir. Close must be called once it is no
+// longer needed.
+func NewObjectDatabase(gitDir string) (*ObjectDatabase, error) {
+	cmd := subprocess.ExecCommand("git", "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	tracerx.Printf("run_command: git cat-file --batch")
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &ObjectDatabase{
+		objectsDir: filepath.Join(gitDir, "objects"),
+		cmd:        cmd,
+		stdin:      stdin,
+		stdout:     bufio.NewReaderSize(stdout, 65536),
+	}, nil
+}
+
+// Close stops the cat-file process.
+func (d *ObjectDatabase) Close() error {
+	d.stdin.Close()
+	return d.cmd.Wait()
+}
+
+// Open returns the object with the given sha, discarding the contents of the
+// last object opened if they have not been read.
+func (d *ObjectDatabase) Open(sha string) (*Object, error) {
+	if d.pending != nil {
+		if err := d.pending.drain(); err != nil {
+			return nil, err
+		}
+		d.pending = nil
+	}
+
+	if _, err := fmt.Fprintln(d.stdin, sha); err != nil {
+		return nil, err
+	}
+
+	header, err := d.stdout.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("Error reading object %s: %v", sha, err)
+	}
+
+	fields := strings.Fields(header)
+	if len(fields) == 2 && fields[1] == "missing" {
+		return nil, fmt.Errorf("Object %s is missing", sha)
+	}
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("Invalid git cat-file output for %s: %q", sha, header)
+	}
+
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid size of object %s: %q", sha, fields[2])
+	}
+
+	if size == 0 {
+		// skip the newline that follows the empty contents
+		if _, err := d.stdout.ReadByte(); err != nil {
+			return nil, err
+		}
+		return &Object{Sha: fields[0], Type: fields[1], Contents: bytes.NewReader(nil)}, nil
+	}
+
+	d.pending = &objectContents{r: d.stdout, remaining: size}
+	return &Object{Sha: fields[0], Type: fields[1], Size: size, Contents: d.pending}, nil
+}
+
+// Read returns the type and the whole contents of the object with the given
+// sha. It is for small objects, such as commits and trees.
+func (d *ObjectDatabase) Read(sha string) (string, []byte, error) {
+	obj, err := d.Open(sha)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data := make([]byte, obj.Size)
+	if _, err := io.ReadFull(obj.Contents, data); err != nil {
+		return "", nil, err
+	}
+	return obj.Type, data, nil
+}
+
+// Write adds an object of the given type, with size bytes of contents read
+// from r, to the repository as a loose object and returns its sha.
+func (d *ObjectDatabase) Write(objectType string, size int64, r io.Reader) (string, error) {
+	tmp, err := ioutil.TempFile(d.objectsDir, "tmp_obj_")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha1.New()
+	zw := zlib.NewWriter(tmp)
+	w := io.MultiWriter(hash, zw)
+
+	fmt.Fprintf(w, "%s %d\x00", objectType, size)
+	n, err := io.Copy(w, r)
+	if err == nil && n != size {
+		err = fmt.Errorf("Expected %d bytes of %s, got %d", size, objectType, n)
+	}
+	if zerr := zw.Close(); err == nil {
+		err = zerr
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sha := hex.EncodeToString(hash.Sum(nil))
+	path := filepath.Join(d.objectsDir, sha[0:2], sha[2:])
+	if _, err := os.Stat(path); err == nil {
+		return sha, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0444); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return sha, nil
+}
+
+// WriteBytes adds an object of the given type with the given contents to the
+// repository and returns its sha.
+func (d *ObjectDatabase) WriteBytes(objectType string, data []byte) (string, error) {
+	return d.Write(objectType, int64(len(data)), bytes.NewReader(data))
+}
+
+// objectContents reads the contents of an object from cat-file, along with the
+// newline which follows them.
+type objectContents struct {
+	r         *bufio.Reader
+	remaining int64
+}
+
+func (c *objectContents) Read(p []byte) (int, error) {
+	if c.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if c.remaining == 0 {
+		if _, nerr := c.r.ReadByte(); nerr != nil && err == nil {
+			err = nerr
+		}
+	}
+	if err == io.EOF && c.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (c *objectContents) drain() error {
+	if c.remaining <= 0 {
+		return nil
+	}
+	_, err := io.Copy(ioutil.Discard, c)
+	return err
+}
@@ -0,0 +1,323 @@
+package githistory
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/github/git-lfs/git"
+	"github.com/github/git-lfs/git/odb"
+	"github.com/github/git-lfs/subprocess"
+	"github.com/rubyist/tracerx"
+)
+
+// Blob is the contents of a file, as given to and returned from a BlobFn. If
+// the Contents of a returned Blob are an io.Closer, they are closed once they
+// have been written.
+type Blob struct {
+	Size     int64
+	Contents io.Reader
+}
+
+// BlobFn is called with each file that the Rewriter finds, and its full path
+// in the tree. It returns the blob to replace it with, or nil to leave it as
+// it is.
+type BlobFn func(path string, b *Blob) (*Blob, error)
+
+// TreeFn is called with each tree after its entries have been rewritten, along
+// with its path ("" for the root tree). It returns the tree to replace it with,
+// which may be the one it was given.
+type TreeFn func(path string, t *odb.Tree) (*odb.Tree, error)
+
+// RewriteOptions say which commits to rewrite, and how.
+type RewriteOptions struct {
+	// Include are the revisions whose history is rewritten, e.g. refs
+	Include []string
+	// Exclude are revisions whose history is left as it is
+	Exclude []string
+	// BlobFilter returns whether BlobFn should be called with the file at
+	// path. Files that do not pass are left as they are without being read.
+	// If nil, BlobFn is called with every file.
+	BlobFilter func(path string) bool
+	BlobFn     BlobFn
+	TreeFn     TreeFn
+	// Progress is called with each commit once it has been rewritten
+	Progress func(done, total int)
+}
+
+// Rewriter rewrites commits, along with the trees and blobs in them. Trees and
+// blobs are only rewritten once for each path they are found at, however many
+// commits they are in.
+type Rewriter struct {
+	db    *ObjectDatabase
+	trees map[string]string
+	blobs map[string]string
+	// commits maps the shas of each rewritten commit to the new sha
+	commits map[string]string
+}
+
+// NewRewriter returns a Rewriter which reads and writes objects in db.
+func NewRewriter(db *ObjectDatabase) *Rewriter {
+	return &Rewriter{
+		db:      db,
+		trees:   make(map[string]string),
+		blobs:   make(map[string]string),
+		commits: make(map[string]string),
+	}
+}
+
+// Rewrite rewrites every commit in the history of opt.Include, oldest first,
+// and returns the shas of the commits in the order they were rewritten. Use
+// Rewritten to find their new shas. A commit whose tree and parents are
+// unchanged keeps its sha.
+func (r *Rewriter) Rewrite(opt *RewriteOptions) ([]string, error) {
+	commits, err := revList(opt.Include, opt.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, sha := range commits {
+		newSha, err := r.rewriteCommit(sha, opt)
+		if err != nil {
+			return nil, err
+		}
+		r.commits[sha] = newSha
+		tracerx.Printf("githistory: rewrote commit %s to %s", sha, newSha)
+
+		if opt.Progress != nil {
+			opt.Progress(i+1, len(commits))
+		}
+	}
+	return commits, nil
+}
+
+// Rewritten returns the new sha of the given commit, which is the same sha if
+// it was not rewritten.
+func (r *Rewriter) Rewritten(sha string) string {
+	if newSha, ok := r.commits[sha]; ok {
+		return newSha
+	}
+	return sha
+}
+
+// UpdateRefs points each of the given refs at the rewritten version of what it
+// pointed to, rewriting annotated tags to refer to rewritten commits. It
+// returns the refs which changed, with their new shas.
+func (r *Rewriter) UpdateRefs(refs []*git.Ref, reason string) ([]*git.Ref, error) {
+	var updated []*git.Ref
+	for _, ref := range refs {
+		newSha, err := r.rewriteRefTarget(ref.Sha)
+		if err != nil {
+			return updated, err
+		}
+		if newSha == ref.Sha {
+			continue
+		}
+
+		name := ref.FullName()
+		if _, err := subprocess.SimpleExec("git", "update-ref", "-m", reason, name, newSha, ref.Sha); err != nil {
+			return updated, fmt.Errorf("Error updating %s: %v", name, err)
+		}
+		updated = append(updated, &git.Ref{Name: ref.Name, Type: ref.Type, Sha: newSha})
+	}
+	return updated, nil
+}
+
+func (r *Rewriter) rewriteRefTarget(sha string) (string, error) {
+	if newSha, ok := r.commits[sha]; ok {
+		return newSha, nil
+	}
+
+	objectType, data, err := r.db.Read(sha)
+	if err != nil {
+		return "", err
+	}
+	if objectType != "tag" {
+		return sha, nil
+	}
+
+	// Annotated tags refer to their object on the first line
+	lines := strings.SplitN(string(data), "\n", 2)
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "object ") {
+		return "", fmt.Errorf("Invalid tag %s", sha)
+	}
+	target := lines[0][7:]
+	newTarget, err := r.rewriteRefTarget(target)
+	if err != nil || newTarget == target {
+		return sha, err
+	}
+
+	tag := fmt.Sprintf("object %s\n%s", newTarget, removeTagSignature(lines[1]))
+	return r.db.WriteBytes("tag", []byte(tag))
+}
+
+// removeTagSignature removes the signature from the end of a tag's headers and
+// message, which is no longer valid once the tag has been changed.
+func removeTagSignature(tag string) string {
+	if i := strings.Index(tag, "-----BEGIN PGP SIGNATURE-----"); i >= 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+func (r *Rewriter) rewriteCommit(sha string, opt *RewriteOptions) (string, error) {
+	_, data, err := r.db.Read(sha)
+	if err != nil {
+		return "", err
+	}
+	commit, err := odb.DecodeCommit(data)
+	if err != nil {
+		return "", err
+	}
+
+	changed := false
+	tree, err := r.rewriteTree(commit.Tree, "", opt)
+	if err != nil {
+		return "", err
+	}
+	if tree != commit.Tree {
+		commit.Tree = tree
+		changed = true
+	}
+
+	for i, parent := range commit.Parents {
+		if newParent := r.Rewritten(parent); newParent != parent {
+			commit.Parents[i] = newParent
+			changed = true
+		}
+	}
+
+	if !changed {
+		return sha, nil
+	}
+
+	commit.RemoveSignature()
+	return r.db.WriteBytes("commit", commit.Encode())
+}
+
+func (r *Rewriter) rewriteTree(sha, treePath string, opt *RewriteOptions) (string, error) {
+	key := treePath + "\x00" + sha
+	if newSha, ok := r.trees[key]; ok {
+		return newSha, nil
+	}
+
+	_, data, err := r.db.Read(sha)
+	if err != nil {
+		return "", err
+	}
+	tree, err := odb.DecodeTree(data)
+	if err != nil {
+		return "", err
+	}
+
+	for _, entry := range tree.Entries {
+		entryPath := path.Join(treePath, entry.Name)
+
+		var newSha string
+		switch {
+		case entry.Type() == "tree":
+			newSha, err = r.rewriteTree(entry.Sha, entryPath, opt)
+		case entry.IsFile():
+			newSha, err = r.rewriteBlob(entry.Sha, entryPath, opt)
+		default:
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		entry.Sha = newSha
+	}
+
+	if opt.TreeFn != nil {
+		if tree, err = opt.TreeFn(treePath, tree); err != nil {
+			return "", err
+		}
+	}
+
+	newData, err := tree.Encode()
+	if err != nil {
+		return "", err
+	}
+
+	newSha := sha
+	if !bytes.Equal(data, newData) {
+		if newSha, err = r.db.WriteBytes("tree", newData); err != nil {
+			return "", err
+		}
+	}
+	r.trees[key] = newSha
+	return newSha, nil
+}
+
+func (r *Rewriter) rewriteBlob(sha, blobPath string, opt *RewriteOptions) (string, error) {
+	if opt.BlobFn == nil || (opt.BlobFilter != nil && !opt.BlobFilter(blobPath)) {
+		return sha, nil
+	}
+
+	key := blobPath + "\x00" + sha
+	if newSha, ok := r.blobs[key]; ok {
+		return newSha, nil
+	}
+
+	obj, err := r.db.Open(sha)
+	if err != nil {
+		return "", err
+	}
+
+	blob, err := opt.BlobFn(blobPath, &Blob{Size: obj.Size, Contents: obj.Contents})
+	if err != nil {
+		return "", err
+	}
+
+	newSha := sha
+	if blob != nil {
+		newSha, err = r.db.Write("blob", blob.Size, blob.Contents)
+		if c, ok := blob.Contents.(io.Closer); ok {
+			c.Close()
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	r.blobs[key] = newSha
+	return newSha, nil
+}
+
+// revList returns the commits in the history of include but not exclude,
+// parents before children.
+func revList(include, exclude []string) ([]string, error) {
+	args := []string{"rev-list", "--topo-order", "--reverse"}
+	args = append(args, include...)
+	if len(exclude) > 0 {
+		args = append(args, "--not")
+		args = append(args, exclude...)
+	}
+	args = append(args, "--")
+
+	cmd := subprocess.ExecCommand("git", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	tracerx.Printf("run_command: git %s", strings.Join(args, " "))
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	var commits []string
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		commits = append(commits, strings.TrimSpace(scanner.Text()))
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("Error listing commits: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return commits, scanner.Err()
+}
@@ -0,0 +1,122 @@
+package githistory_test // to avoid import cycles
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/github/git-lfs/git"
+	. "github.com/github/git-lfs/git/githistory"
+	"github.com/github/git-lfs/subprocess"
+	"github.com/github/git-lfs/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectDatabaseWriteAndRead(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	db, err := NewObjectDatabase(repo.GitDir)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	sha, err := db.WriteBytes("blob", []byte("hello\n"))
+	assert.Nil(t, err)
+	// git hash-object of "hello\n"
+	assert.Equal(t, "ce013625030ba8dba906f756967f9e9ca394464a", sha)
+
+	objectType, data, err := db.Read(sha)
+	assert.Nil(t, err)
+	assert.Equal(t, "blob", objectType)
+	assert.Equal(t, "hello\n", string(data))
+
+	empty, err := db.WriteBytes("blob", nil)
+	assert.Nil(t, err)
+	_, data, err = db.Read(empty)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(data))
+
+	_, _, err = db.Read(strings.Repeat("0", 40))
+	assert.NotNil(t, err)
+}
+
+func TestRewriteBlobsAndRefs(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	writeAndCommit(t, "first", map[string]string{"a.txt": "a1", "dir/b.txt": "b1", "c.dat": "c1"})
+	writeAndCommit(t, "second", map[string]string{"a.txt": "a2"})
+	gitExec(t, "tag", "-a", "-m", "annotated", "v1")
+	oldHead := gitExec(t, "rev-parse", "HEAD")
+
+	refs, err := git.LocalRefs()
+	assert.Nil(t, err)
+
+	db, err := NewObjectDatabase(repo.GitDir)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	var seen []string
+	rewriter := NewRewriter(db)
+	commits, err := rewriter.Rewrite(&RewriteOptions{
+		Include: []string{"master"},
+		BlobFilter: func(path string) bool {
+			return strings.HasSuffix(path, ".txt")
+		},
+		BlobFn: func(path string, b *Blob) (*Blob, error) {
+			seen = append(seen, path)
+			data, err := ioutil.ReadAll(b.Contents)
+			if err != nil {
+				return nil, err
+			}
+			upper := bytes.ToUpper(data)
+			return &Blob{Size: int64(len(upper)), Contents: bytes.NewReader(upper)}, nil
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(commits))
+	assert.Equal(t, oldHead, commits[1])
+	// b.txt is unchanged in the second commit, so is only rewritten once
+	assert.Equal(t, []string{"a.txt", "dir/b.txt", "a.txt"}, seen)
+
+	updated, err := rewriter.UpdateRefs(refs, "test")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(updated))
+
+	newHead := gitExec(t, "rev-parse", "master")
+	assert.Equal(t, rewriter.Rewritten(oldHead), newHead)
+	assert.Equal(t, "A2", gitExec(t, "show", "master:a.txt"))
+	assert.Equal(t, "B1", gitExec(t, "show", "master~1:dir/b.txt"))
+	assert.Equal(t, "c1", gitExec(t, "show", "master~1:c.dat"))
+	assert.Equal(t, "second", gitExec(t, "log", "-1", "--format=%s", "master"))
+	assert.Equal(t, newHead, gitExec(t, "rev-parse", "v1^{commit}"))
+	assert.Equal(t, "annotated", gitExec(t, "tag", "-l", "--format=%(contents:subject)", "v1"))
+}
+
+func writeAndCommit(t *testing.T, message string, files map[string]string) {
+	for name, contents := range files {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(name), 0755))
+		assert.Nil(t, ioutil.WriteFile(name, []byte(contents), 0644))
+	}
+	gitExec(t, "add", ".")
+	gitExec(t, "commit", "-m", message)
+}
+
+func gitExec(t *testing.T, args ...string) string {
+	out, err := subprocess.SimpleExec("git", args...)
+	if err != nil {
+		t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return out
+}
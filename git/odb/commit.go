@@ -0,0 +1,77 @@
+package odb
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Commit is a parsed git commit object.
+type Commit struct {
+	Tree    string
+	Parents []string
+	// Headers are the other header lines, such as author and committer, in
+	// order. Continuation lines are kept with the header they continue.
+	Headers []string
+	// Message is everything after the headers, as it was written.
+	Message string
+}
+
+// DecodeCommit parses the contents of a git commit object.
+func DecodeCommit(data []byte) (*Commit, error) {
+	commit := &Commit{}
+
+	end := bytes.Index(data, []byte("\n\n"))
+	var headers string
+	if end < 0 {
+		headers = strings.TrimSuffix(string(data), "\n")
+	} else {
+		headers = string(data[:end])
+		commit.Message = string(data[end+2:])
+	}
+
+	for _, line := range strings.Split(headers, "\n") {
+		switch {
+		case strings.HasPrefix(line, " ") && len(commit.Headers) > 0:
+			commit.Headers[len(commit.Headers)-1] += "\n" + line
+		case strings.HasPrefix(line, "tree "):
+			commit.Tree = line[5:]
+		case strings.HasPrefix(line, "parent "):
+			commit.Parents = append(commit.Parents, line[7:])
+		default:
+			commit.Headers = append(commit.Headers, line)
+		}
+	}
+
+	if len(commit.Tree) == 0 {
+		return nil, fmt.Errorf("Invalid commit, no tree: %q", data)
+	}
+	return commit, nil
+}
+
+// Encode returns the contents of the git commit object for the commit.
+func (c *Commit) Encode() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "tree %s\n", c.Tree)
+	for _, parent := range c.Parents {
+		fmt.Fprintf(&buf, "parent %s\n", parent)
+	}
+	for _, header := range c.Headers {
+		fmt.Fprintf(&buf, "%s\n", header)
+	}
+	buf.WriteString("\n")
+	buf.WriteString(c.Message)
+	return buf.Bytes()
+}
+
+// RemoveSignature removes the signature from the commit, which is no longer
+// valid once the commit has been changed.
+func (c *Commit) RemoveSignature() {
+	headers := c.Headers[:0]
+	for _, header := range c.Headers {
+		if !strings.HasPrefix(header, "gpgsig ") && !strings.HasPrefix(header, "gpgsig-sha256 ") {
+			headers = append(headers, header)
+		}
+	}
+	c.Headers = headers
+}
@@ -0,0 +1,52 @@
+package odb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const signedCommit = `tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904
+parent 1111111111111111111111111111111111111111
+parent 2222222222222222222222222222222222222222
+author A U Thor <author@example.com> 1465000000 +0100
+committer C O Mitter <committer@example.com> 1465000001 +0100
+gpgsig -----BEGIN PGP SIGNATURE-----
+ 
+ iQEcBAABAgAGBQJXYRhOAAoJEGEJLoW3InGJklkIAIcnhL7RwEb/+QeX9enkXhxn
+ -----END PGP SIGNATURE-----
+
+Subject
+
+Body, with a trailing blank line
+
+`
+
+func TestCommitRoundTrip(t *testing.T) {
+	commit, err := DecodeCommit([]byte(signedCommit))
+	assert.Nil(t, err)
+	assert.Equal(t, "4b825dc642cb6eb9a060e54bf8d69288fbee4904", commit.Tree)
+	assert.Equal(t, []string{
+		"1111111111111111111111111111111111111111",
+		"2222222222222222222222222222222222222222",
+	}, commit.Parents)
+	assert.Equal(t, 3, len(commit.Headers))
+	assert.Equal(t, "Subject\n\nBody, with a trailing blank line\n\n", commit.Message)
+	assert.Equal(t, signedCommit, string(commit.Encode()))
+}
+
+func TestCommitRemoveSignature(t *testing.T) {
+	commit, err := DecodeCommit([]byte(signedCommit))
+	assert.Nil(t, err)
+
+	commit.RemoveSignature()
+	assert.Equal(t, []string{
+		"author A U Thor <author@example.com> 1465000000 +0100",
+		"committer C O Mitter <committer@example.com> 1465000001 +0100",
+	}, commit.Headers)
+}
+
+func TestDecodeCommitWithoutTree(t *testing.T) {
+	_, err := DecodeCommit([]byte("author A U Thor <author@example.com> 1465000000 +0100\n\nmessage\n"))
+	assert.NotNil(t, err)
+}
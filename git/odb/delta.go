@@ -0,0 +1,94 @@
+package odb
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errTruncatedDelta = errors.New("truncated delta")
+
+// applyDelta returns the result of applying a git delta to base.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, delta, err := deltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != uint64(len(base)) {
+		return nil, fmt.Errorf("delta base is %d bytes, expected %d", len(base), baseSize)
+	}
+
+	resultSize, delta, err := deltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 0, resultSize)
+	for len(delta) > 0 {
+		cmd := delta[0]
+		delta = delta[1:]
+
+		switch {
+		case cmd&0x80 != 0:
+			// copy from the base, with the offset and size in the bytes
+			// flagged by the low 7 bits of cmd
+			var offset, size uint64
+			for i := uint(0); i < 4; i++ {
+				if cmd&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errTruncatedDelta
+					}
+					offset |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := uint(0); i < 3; i++ {
+				if cmd&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errTruncatedDelta
+					}
+					size |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, fmt.Errorf("delta copies %d bytes from %d, past the end of the base", size, offset)
+			}
+			result = append(result, base[offset:offset+size]...)
+		case cmd != 0:
+			// insert the next cmd bytes
+			if int(cmd) > len(delta) {
+				return nil, errTruncatedDelta
+			}
+			result = append(result, delta[:cmd]...)
+			delta = delta[cmd:]
+		default:
+			return nil, errors.New("invalid delta instruction 0")
+		}
+	}
+
+	if uint64(len(result)) != resultSize {
+		return nil, fmt.Errorf("delta result is %d bytes, expected %d", len(result), resultSize)
+	}
+	return result, nil
+}
+
+// deltaSize reads one of the little-endian variable length sizes at the start
+// of a delta, and returns it along with the rest of the delta.
+func deltaSize(delta []byte) (uint64, []byte, error) {
+	var size uint64
+	shift := uint(0)
+	for i, c := range delta {
+		if shift > 63 {
+			break
+		}
+		size |= uint64(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			return size, delta[i+1:], nil
+		}
+	}
+	return 0, nil, errTruncatedDelta
+}
@@ -0,0 +1,53 @@
+package odb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("the quick brown fox")
+	delta := []byte{
+		19, // base size
+		16, // result size
+		// copy 6 bytes from offset 4: "quick "
+		0x91, 4, 6,
+		// insert "slow"
+		4, 's', 'l', 'o', 'w',
+		// copy 6 bytes from offset 13: "wn fox"
+		0x91, 13, 6,
+	}
+
+	result, err := applyDelta(base, delta)
+	assert.Nil(t, err)
+	assert.Equal(t, "quick slowwn fox", string(result))
+}
+
+func TestApplyDeltaCopySizeZero(t *testing.T) {
+	base := make([]byte, 0x10000)
+	base[0xffff] = 'x'
+	// a copy with no size bytes copies 0x10000 bytes
+	delta := []byte{0x80, 0x80, 0x04, 0x80, 0x80, 0x04, 0x80}
+
+	result, err := applyDelta(base, delta)
+	assert.Nil(t, err)
+	assert.Equal(t, base, result)
+}
+
+func TestApplyInvalidDeltas(t *testing.T) {
+	base := []byte("base")
+	for _, delta := range [][]byte{
+		{},
+		{5, 4, 0x90, 4},     // wrong base size
+		{4, 4, 0x91, 2, 4},  // copy past the end of the base
+		{4, 4, 5, 'a', 'b'}, // truncated insert
+		{4, 4, 0},           // reserved instruction
+		{4, 5, 0x90, 4},     // wrong result size
+		{4, 4, 0x91},        // truncated copy
+		{0x80, 0x80, 0x80},  // truncated size
+	} {
+		_, err := applyDelta(base, delta)
+		assert.NotNil(t, err, "%v", delta)
+	}
+}
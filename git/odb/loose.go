@@ -0,0 +1,99 @@
+package odb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// sha1 is the binary form of an object id.
+type sha1 [20]byte
+
+func parseSha(sha string) (sha1, error) {
+	var id sha1
+	b, err := hex.DecodeString(sha)
+	if err != nil || len(b) != len(id) {
+		return id, fmt.Errorf("Invalid object id %q", sha)
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+func (id sha1) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// statLoose returns the type and size of the loose object in file, from its
+// header.
+func statLoose(file string) (string, int64, error) {
+	f, zr, err := openLoose(file)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	defer zr.Close()
+
+	return readLooseHeader(bufio.NewReader(zr))
+}
+
+// readLoose returns the loose object in file.
+func readLoose(file string) (*Object, error) {
+	f, zr, err := openLoose(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	defer zr.Close()
+
+	r := bufio.NewReader(zr)
+	objectType, size, err := readLooseHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("Truncated loose object: %v", err)
+	}
+	return &Object{Type: objectType, Data: data}, nil
+}
+
+func openLoose(file string) (*os.File, io.ReadCloser, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("Invalid loose object: %v", err)
+	}
+	return f, zr, nil
+}
+
+// readLooseHeader reads the "<type> <size>\0" header of a loose object.
+func readLooseHeader(r *bufio.Reader) (string, int64, error) {
+	header, err := r.ReadBytes(0)
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid loose object header: %v", err)
+	}
+
+	space := bytes.IndexByte(header, ' ')
+	if space < 0 {
+		return "", 0, fmt.Errorf("Invalid loose object header: %q", header)
+	}
+	size, err := strconv.ParseInt(string(header[space+1:len(header)-1]), 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("Invalid loose object size: %q", header)
+	}
+	return string(header[:space]), size, nil
+}
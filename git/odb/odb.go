@@ -0,0 +1,342 @@
+// Package odb reads objects straight from a git object database, loose or in
+// packs, without running git, and parses commits and trees.
+// NOTE: Subject to change, do not rely on this package from outside git-lfs source
+package odb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned for objects which are not in the database.
+var ErrNotFound = errors.New("object not found")
+
+// maxAlternateDepth limits how deep chains of alternates are followed, as git
+// does.
+const maxAlternateDepth = 5
+
+// Object is an object read from an ObjectDatabase.
+type Object struct {
+	Type string
+	Data []byte
+}
+
+// ObjectDatabase reads the objects in a git objects directory, and those in
+// the directories listed in its info/alternates file. Like git, it looks for
+// new packs when an object cannot be found. It is safe for concurrent use.
+type ObjectDatabase struct {
+	dir        string
+	alternates []*ObjectDatabase
+	bases      *baseCache
+
+	packsMu sync.RWMutex
+	packs   []*pack
+	// the names of the packs which have been opened
+	packNames map[string]bool
+}
+
+// Open returns an ObjectDatabase for the objects directory dir, e.g.
+// .git/objects. Close must be called once it is no longer needed.
+func Open(dir string) (*ObjectDatabase, error) {
+	return open(dir, newBaseCache(), 0)
+}
+
+// OpenWithAlternates returns an ObjectDatabase for the objects directory dir
+// which also reads the objects in the given alternate directories, as git
+// does for those in $GIT_ALTERNATE_OBJECT_DIRECTORIES. Close must be called
+// once it is no longer needed.
+func OpenWithAlternates(dir string, alternates []string) (*ObjectDatabase, error) {
+	d, err := open(dir, newBaseCache(), 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, alt := range alternates {
+		altDb, err := open(alt, d.bases, 1)
+		if err != nil {
+			d.Close()
+			return nil, err
+		}
+		d.alternates = append(d.alternates, altDb)
+	}
+	return d, nil
+}
+
+func open(dir string, bases *baseCache, depth int) (*ObjectDatabase, error) {
+	d := &ObjectDatabase{dir: dir, bases: bases, packNames: make(map[string]bool)}
+	if _, err := d.openNewPacks(); err != nil {
+		d.Close()
+		return nil, err
+	}
+
+	if depth >= maxAlternateDepth {
+		return d, nil
+	}
+	for _, alt := range readAlternates(dir) {
+		altDb, err := open(alt, bases, depth+1)
+		if err != nil {
+			d.Close()
+			return nil, err
+		}
+		d.alternates = append(d.alternates, altDb)
+	}
+	return d, nil
+}
+
+// openNewPacks opens any packs which have not been opened yet, and returns
+// whether there were any.
+func (d *ObjectDatabase) openNewPacks() (bool, error) {
+	idxFiles, err := filepath.Glob(filepath.Join(d.dir, "pack", "pack-*.idx"))
+	if err != nil {
+		return false, err
+	}
+
+	d.packsMu.Lock()
+	defer d.packsMu.Unlock()
+
+	found := false
+	for _, idxFile := range idxFiles {
+		name := strings.TrimSuffix(idxFile, ".idx")
+		if d.packNames[name] {
+			continue
+		}
+
+		p, err := openPack(name, d)
+		if err != nil {
+			return found, err
+		}
+		d.packs = append(d.packs, p)
+		d.packNames[name] = true
+		found = true
+	}
+	return found, nil
+}
+
+// findPacked returns the pack containing the object with the given id, and
+// its offset in the pack.
+func (d *ObjectDatabase) findPacked(id sha1) (*pack, int64, bool) {
+	d.packsMu.RLock()
+	defer d.packsMu.RUnlock()
+
+	for _, p := range d.packs {
+		if offset, ok := p.find(id); ok {
+			return p, offset, true
+		}
+	}
+	return nil, 0, false
+}
+
+// Close closes the pack files.
+func (d *ObjectDatabase) Close() error {
+	d.packsMu.Lock()
+	defer d.packsMu.Unlock()
+
+	var err error
+	for _, p := range d.packs {
+		if perr := p.Close(); err == nil {
+			err = perr
+		}
+	}
+	for _, alt := range d.alternates {
+		if aerr := alt.Close(); err == nil {
+			err = aerr
+		}
+	}
+	return err
+}
+
+// Stat returns the type and size of the object with the given sha, reading as
+// little of it as possible.
+func (d *ObjectDatabase) Stat(sha string) (string, int64, error) {
+	id, err := parseSha(sha)
+	if err != nil {
+		return "", 0, err
+	}
+
+	objectType, size, err := d.stat(id)
+	if err != nil {
+		return "", 0, fmt.Errorf("Error reading object %s: %v", sha, err)
+	}
+	return objectType, size, nil
+}
+
+func (d *ObjectDatabase) stat(id sha1) (string, int64, error) {
+	objectType, size, err := d.statLocal(id)
+	if err == ErrNotFound {
+		// the object may have been packed since the packs were opened
+		if found, _ := d.openNewPacks(); found {
+			objectType, size, err = d.statLocal(id)
+		}
+	}
+	if err != ErrNotFound {
+		return objectType, size, err
+	}
+
+	for _, alt := range d.alternates {
+		objectType, size, err := alt.stat(id)
+		if err != ErrNotFound {
+			return objectType, size, err
+		}
+	}
+	return "", 0, ErrNotFound
+}
+
+func (d *ObjectDatabase) statLocal(id sha1) (string, int64, error) {
+	if p, offset, ok := d.findPacked(id); ok {
+		return p.stat(offset)
+	}
+	return statLoose(d.looseFile(id))
+}
+
+// Read returns the object with the given sha.
+func (d *ObjectDatabase) Read(sha string) (*Object, error) {
+	id, err := parseSha(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := d.read(id)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading object %s: %v", sha, err)
+	}
+	return obj, nil
+}
+
+func (d *ObjectDatabase) read(id sha1) (*Object, error) {
+	obj, err := d.readLocal(id)
+	if err == ErrNotFound {
+		// the object may have been packed since the packs were opened
+		if found, _ := d.openNewPacks(); found {
+			obj, err = d.readLocal(id)
+		}
+	}
+	if err != ErrNotFound {
+		return obj, err
+	}
+
+	for _, alt := range d.alternates {
+		obj, err := alt.read(id)
+		if err != ErrNotFound {
+			return obj, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (d *ObjectDatabase) readLocal(id sha1) (*Object, error) {
+	if p, offset, ok := d.findPacked(id); ok {
+		return p.read(offset)
+	}
+	return readLoose(d.looseFile(id))
+}
+
+// ReadTree returns the tree with the given sha.
+func (d *ObjectDatabase) ReadTree(sha string) (*Tree, error) {
+	obj, err := d.Read(sha)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != "tree" {
+		return nil, fmt.Errorf("Object %s is a %s, not a tree", sha, obj.Type)
+	}
+	return DecodeTree(obj.Data)
+}
+
+// ReadCommit returns the commit with the given sha.
+func (d *ObjectDatabase) ReadCommit(sha string) (*Commit, error) {
+	obj, err := d.Read(sha)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Type != "commit" {
+		return nil, fmt.Errorf("Object %s is a %s, not a commit", sha, obj.Type)
+	}
+	return DecodeCommit(obj.Data)
+}
+
+func (d *ObjectDatabase) looseFile(id sha1) string {
+	hex := id.String()
+	return filepath.Join(d.dir, hex[0:2], hex[2:])
+}
+
+// readAlternates returns the directories listed in the info/alternates file
+// in dir, relative to dir.
+func readAlternates(dir string) []string {
+	f, err := os.Open(filepath.Join(dir, "info", "alternates"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var dirs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(dir, line)
+		}
+		if _, err := ioutil.ReadDir(line); err == nil {
+			dirs = append(dirs, line)
+		}
+	}
+	return dirs
+}
+
+// baseCache keeps recently used delta bases, so that the bases shared by long
+// chains of deltas are only inflated once.
+type baseCache struct {
+	mu      sync.Mutex
+	objects map[baseKey]*Object
+	order   []baseKey
+	size    int
+}
+
+type baseKey struct {
+	pack   *pack
+	offset int64
+}
+
+// baseCacheSize is the most bytes of delta bases kept by a baseCache.
+const baseCacheSize = 16 * 1024 * 1024
+
+func newBaseCache() *baseCache {
+	return &baseCache{objects: make(map[baseKey]*Object)}
+}
+
+func (c *baseCache) get(p *pack, offset int64) (*Object, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	obj, ok := c.objects[baseKey{p, offset}]
+	return obj, ok
+}
+
+func (c *baseCache) add(p *pack, offset int64, obj *Object) {
+	if len(obj.Data) > baseCacheSize/4 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := baseKey{p, offset}
+	if _, ok := c.objects[key]; ok {
+		return
+	}
+	for c.size+len(obj.Data) > baseCacheSize && len(c.order) > 0 {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		c.size -= len(c.objects[oldest].Data)
+		delete(c.objects, oldest)
+	}
+	c.objects[key] = obj
+	c.order = append(c.order, key)
+	c.size += len(obj.Data)
+}
@@ -0,0 +1,209 @@
+package odb_test // to avoid import cycles
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/github/git-lfs/git/githistory"
+	. "github.com/github/git-lfs/git/odb"
+	"github.com/github/git-lfs/subprocess"
+	"github.com/github/git-lfs/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadLooseAndPackedObjects(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	// similar versions of the same files, so that packs have deltas
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("line %d of a file which changes a little in each commit", i))
+	}
+	for i := 0; i < 10; i++ {
+		lines[i*13] = fmt.Sprintf("changed in commit %d", i)
+		writeFile(t, "file.txt", strings.Join(lines, "\n"))
+		writeFile(t, fmt.Sprintf("dir/file%d.txt", i%3), strings.Join(lines[i:], "\n"))
+		gitExec(t, "add", ".")
+		gitExec(t, "commit", "-m", fmt.Sprintf("commit %d", i))
+	}
+	gitExec(t, "tag", "-a", "-m", "a tag", "v1")
+
+	objectsDir := filepath.Join(repo.GitDir, "objects")
+	assertSameObjects(t, repo.GitDir, objectsDir)
+
+	gitExec(t, "repack", "-adf", "--depth=50", "--window=50")
+	assert.Contains(t, gitExec(t, "verify-pack", "-v", packIdx(t, objectsDir)), "chain length")
+	assertSameObjects(t, repo.GitDir, objectsDir)
+
+	// REF_DELTA rather than OFS_DELTA bases
+	gitExec(t, "-c", "repack.useDeltaBaseOffset=false", "repack", "-adf")
+	assertSameObjects(t, repo.GitDir, objectsDir)
+
+	gitExec(t, "-c", "pack.indexVersion=1", "repack", "-adf")
+	assertSameObjects(t, repo.GitDir, objectsDir)
+}
+
+func TestReadCommitAndTree(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	writeFile(t, "a.txt", "a")
+	writeFile(t, "dir/b.txt", "b")
+	gitExec(t, "add", ".")
+	gitExec(t, "commit", "-m", "message")
+
+	db, err := Open(filepath.Join(repo.GitDir, "objects"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	commit, err := db.ReadCommit(gitExec(t, "rev-parse", "HEAD"))
+	assert.Nil(t, err)
+	assert.Equal(t, gitExec(t, "rev-parse", "HEAD^{tree}"), commit.Tree)
+	assert.Equal(t, "message\n", commit.Message)
+
+	tree, err := db.ReadTree(commit.Tree)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(tree.Entries))
+	assert.Equal(t, "tree", tree.Entry("dir").Type())
+	assert.Equal(t, gitExec(t, "rev-parse", "HEAD:a.txt"), tree.Entry("a.txt").Sha)
+
+	_, err = db.ReadTree(tree.Entry("a.txt").Sha)
+	assert.NotNil(t, err)
+
+	_, err = db.Read(strings.Repeat("0", 40))
+	assert.NotNil(t, err)
+	_, _, err = db.Stat(strings.Repeat("0", 40))
+	assert.NotNil(t, err)
+	_, err = db.Read("not a sha")
+	assert.NotNil(t, err)
+}
+
+func TestReadAlternates(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	writeFile(t, "a.txt", "in the alternate")
+	gitExec(t, "add", ".")
+	gitExec(t, "commit", "-m", "message")
+	gitExec(t, "repack", "-ad")
+	sha := gitExec(t, "rev-parse", "HEAD:a.txt")
+
+	other := test.NewRepo(t)
+	defer other.Cleanup()
+	writeFile(t, filepath.Join(other.GitDir, "objects", "info", "alternates"), filepath.Join(repo.GitDir, "objects")+"\n")
+
+	db, err := Open(filepath.Join(other.GitDir, "objects"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	obj, err := db.Read(sha)
+	assert.Nil(t, err)
+	assert.Equal(t, "in the alternate", string(obj.Data))
+}
+
+func TestOpenWithAlternates(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	writeFile(t, "a.txt", "in the repository")
+	sha := gitExec(t, "hash-object", "-w", "a.txt")
+
+	// a pre-receive hook is given an empty objects directory for the push,
+	// with the repository's own as an alternate
+	other := test.NewRepo(t)
+	defer other.Cleanup()
+
+	db, err := OpenWithAlternates(filepath.Join(other.GitDir, "objects"), []string{filepath.Join(repo.GitDir, "objects")})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer db.Close()
+
+	obj, err := db.Read(sha)
+	assert.Nil(t, err)
+	assert.Equal(t, "in the repository", string(obj.Data))
+}
+
+// assertSameObjects checks that every object in the repository is read the
+// same way by git and by an ObjectDatabase.
+func assertSameObjects(t *testing.T, gitDir, objectsDir string) {
+	db, err := Open(objectsDir)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	expected, err := githistory.NewObjectDatabase(gitDir)
+	assert.Nil(t, err)
+	defer expected.Close()
+
+	all := gitExec(t, "cat-file", "--batch-all-objects", "--batch-check")
+	scanner := bufio.NewScanner(strings.NewReader(all))
+	count := 0
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		sha := fields[0]
+
+		expectedType, expectedData, err := expected.Read(sha)
+		assert.Nil(t, err)
+
+		obj, err := db.Read(sha)
+		if assert.Nil(t, err, sha) {
+			assert.Equal(t, expectedType, obj.Type, sha)
+			assert.True(t, bytes.Equal(expectedData, obj.Data), sha)
+		}
+
+		objectType, size, err := db.Stat(sha)
+		assert.Nil(t, err, sha)
+		assert.Equal(t, expectedType, objectType, sha)
+		assert.Equal(t, int64(len(expectedData)), size, sha)
+		count++
+	}
+	assert.True(t, count > 30, "only %d objects", count)
+}
+
+func packIdx(t *testing.T, objectsDir string) string {
+	idx, err := filepath.Glob(filepath.Join(objectsDir, "pack", "*.idx"))
+	if err != nil || len(idx) != 1 {
+		t.Fatalf("expected one pack index, got %v: %v", idx, err)
+	}
+	return idx[0]
+}
+
+func writeFile(t *testing.T, name, contents string) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func gitExec(t *testing.T, args ...string) string {
+	out, err := subprocess.SimpleExec("git", args...)
+	if err != nil {
+		t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return out
+}
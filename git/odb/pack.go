@@ -0,0 +1,339 @@
+package odb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+)
+
+// The types of object in a pack, from the header of each object.
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+var packTypeNames = map[int]string{
+	packCommit: "commit",
+	packTree:   "tree",
+	packBlob:   "blob",
+	packTag:    "tag",
+}
+
+var idxV2Magic = []byte{0xff, 't', 'O', 'c'}
+
+// pack is a pack file and its index, which is read into memory.
+type pack struct {
+	db      *ObjectDatabase
+	file    *os.File
+	idx     []byte
+	version int
+	count   int
+	fanout  [256]uint32
+}
+
+// openPack opens the pack with the given path, without the .pack or .idx
+// extension. REF_DELTA bases which are not in the pack are read from db.
+func openPack(path string, db *ObjectDatabase) (*pack, error) {
+	idx, err := ioutil.ReadFile(path + ".idx")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pack{db: db, idx: idx, version: 1}
+	fanout := idx
+	if bytes.HasPrefix(idx, idxV2Magic) {
+		if len(idx) < 8 || binary.BigEndian.Uint32(idx[4:8]) != 2 {
+			return nil, fmt.Errorf("Unsupported pack index version in %s.idx", path)
+		}
+		p.version = 2
+		fanout = idx[8:]
+	}
+	if len(fanout) < 1024 {
+		return nil, fmt.Errorf("Truncated pack index %s.idx", path)
+	}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(fanout[i*4:])
+	}
+	p.count = int(p.fanout[255])
+
+	// the table of shas and offsets, and the two checksums at the end
+	minSize := 1024 + p.count*24 + 40
+	if p.version == 2 {
+		minSize = 8 + 1024 + p.count*28 + 40
+	}
+	if len(idx) < minSize {
+		return nil, fmt.Errorf("Truncated pack index %s.idx", path)
+	}
+
+	file, err := os.Open(path + ".pack")
+	if err != nil {
+		return nil, err
+	}
+
+	var header [12]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil || !bytes.Equal(header[0:4], []byte("PACK")) {
+		file.Close()
+		return nil, fmt.Errorf("Invalid pack file %s.pack", path)
+	}
+	if version := binary.BigEndian.Uint32(header[4:8]); version != 2 && version != 3 {
+		file.Close()
+		return nil, fmt.Errorf("Unsupported pack version %d in %s.pack", version, path)
+	}
+
+	p.file = file
+	return p, nil
+}
+
+func (p *pack) Close() error {
+	return p.file.Close()
+}
+
+// find returns the offset in the pack of the object with the given id.
+func (p *pack) find(id sha1) (int64, bool) {
+	lo := 0
+	if id[0] > 0 {
+		lo = int(p.fanout[id[0]-1])
+	}
+	hi := int(p.fanout[id[0]])
+
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		switch cmp := bytes.Compare(p.shaAt(mid), id[:]); {
+		case cmp == 0:
+			return p.offsetAt(mid), true
+		case cmp < 0:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, false
+}
+
+func (p *pack) shaAt(i int) []byte {
+	if p.version == 1 {
+		start := 1024 + i*24 + 4
+		return p.idx[start : start+20]
+	}
+	start := 8 + 1024 + i*20
+	return p.idx[start : start+20]
+}
+
+func (p *pack) offsetAt(i int) int64 {
+	if p.version == 1 {
+		return int64(binary.BigEndian.Uint32(p.idx[1024+i*24:]))
+	}
+
+	offsets := 8 + 1024 + p.count*24
+	offset := binary.BigEndian.Uint32(p.idx[offsets+i*4:])
+	if offset&0x80000000 == 0 {
+		return int64(offset)
+	}
+
+	// the offset is an index into the table of 64 bit offsets
+	large := offsets + p.count*4 + int(offset&0x7fffffff)*8
+	if large+8 > len(p.idx) {
+		return -1
+	}
+	return int64(binary.BigEndian.Uint64(p.idx[large:]))
+}
+
+// packHeader is the header of an object in a pack.
+type packHeader struct {
+	typ  int
+	size int64
+	// offset of the compressed data
+	dataOffset int64
+	// the base of an OFS_DELTA
+	baseOffset int64
+	// the base of a REF_DELTA
+	baseId sha1
+}
+
+func (p *pack) header(offset int64) (*packHeader, error) {
+	if offset < 12 {
+		return nil, fmt.Errorf("Invalid pack offset %d", offset)
+	}
+
+	var buf [32]byte
+	n, err := p.file.ReadAt(buf[:], offset)
+	if n == 0 && err != nil {
+		return nil, err
+	}
+	b := buf[:n]
+
+	h := &packHeader{}
+	c := b[0]
+	h.typ = int(c>>4) & 7
+	h.size = int64(c & 0x0f)
+	shift := uint(4)
+	i := 1
+	for c&0x80 != 0 {
+		if i >= len(b) || shift > 60 {
+			return nil, fmt.Errorf("Invalid pack object header at %d", offset)
+		}
+		c = b[i]
+		h.size |= int64(c&0x7f) << shift
+		shift += 7
+		i++
+	}
+
+	switch h.typ {
+	case packOfsDelta:
+		if i >= len(b) {
+			return nil, fmt.Errorf("Invalid pack object header at %d", offset)
+		}
+		c = b[i]
+		i++
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if i >= len(b) {
+				return nil, fmt.Errorf("Invalid delta offset at %d", offset)
+			}
+			c = b[i]
+			i++
+			rel = ((rel + 1) << 7) | int64(c&0x7f)
+		}
+		h.baseOffset = offset - rel
+	case packRefDelta:
+		if i+20 > len(b) {
+			return nil, fmt.Errorf("Invalid pack object header at %d", offset)
+		}
+		copy(h.baseId[:], b[i:i+20])
+		i += 20
+	case packCommit, packTree, packBlob, packTag:
+	default:
+		return nil, fmt.Errorf("Invalid pack object type %d at %d", h.typ, offset)
+	}
+
+	h.dataOffset = offset + int64(i)
+	return h, nil
+}
+
+// inflate returns size bytes of the compressed data at offset.
+func (p *pack) inflate(offset, size int64) ([]byte, error) {
+	zr, err := zlib.NewReader(bufio.NewReader(io.NewSectionReader(p.file, offset, math.MaxInt64-offset)))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, fmt.Errorf("Truncated pack object at %d: %v", offset, err)
+	}
+	return data, nil
+}
+
+// read returns the object at offset, resolving deltas.
+func (p *pack) read(offset int64) (*Object, error) {
+	h, err := p.header(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var base *Object
+	switch h.typ {
+	case packOfsDelta:
+		base, err = p.base(h.baseOffset)
+	case packRefDelta:
+		if baseOffset, ok := p.find(h.baseId); ok {
+			base, err = p.base(baseOffset)
+		} else {
+			base, err = p.db.read(h.baseId)
+		}
+	default:
+		data, err := p.inflate(h.dataOffset, h.size)
+		if err != nil {
+			return nil, err
+		}
+		return &Object{Type: packTypeNames[h.typ], Data: data}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	delta, err := p.inflate(h.dataOffset, h.size)
+	if err != nil {
+		return nil, err
+	}
+	data, err := applyDelta(base.Data, delta)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid delta at %d: %v", offset, err)
+	}
+	return &Object{Type: base.Type, Data: data}, nil
+}
+
+// base returns the delta base at offset, from the cache if it is there. It
+// must not be modified.
+func (p *pack) base(offset int64) (*Object, error) {
+	if obj, ok := p.db.bases.get(p, offset); ok {
+		return obj, nil
+	}
+
+	obj, err := p.read(offset)
+	if err != nil {
+		return nil, err
+	}
+	p.db.bases.add(p, offset, obj)
+	return obj, nil
+}
+
+// stat returns the type and size of the object at offset. The size of a delta
+// is read from the start of the delta, and its type from its base.
+func (p *pack) stat(offset int64) (string, int64, error) {
+	h, err := p.header(offset)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var objectType string
+	switch h.typ {
+	case packOfsDelta:
+		objectType, _, err = p.stat(h.baseOffset)
+	case packRefDelta:
+		if baseOffset, ok := p.find(h.baseId); ok {
+			objectType, _, err = p.stat(baseOffset)
+		} else {
+			objectType, _, err = p.db.stat(h.baseId)
+		}
+	default:
+		return packTypeNames[h.typ], h.size, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	// the delta starts with the sizes of the base and the result, which are
+	// at most 10 bytes each
+	deltaHeader, err := p.inflate(h.dataOffset, minInt64(h.size, 20))
+	if err != nil {
+		return "", 0, err
+	}
+	_, rest, err := deltaSize(deltaHeader)
+	if err != nil {
+		return "", 0, err
+	}
+	size, _, err := deltaSize(rest)
+	if err != nil {
+		return "", 0, err
+	}
+	return objectType, int64(size), nil
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
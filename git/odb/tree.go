@@ -0,0 +1,128 @@
+package odb
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// TreeEntry is a file, directory or submodule in a Tree.
+type TreeEntry struct {
+	// Mode is the octal file mode, as git writes it, e.g. 100644 or 40000.
+	Mode string
+	Name string
+	Sha  string
+}
+
+// Type returns the type of the object that the entry refers to.
+func (e *TreeEntry) Type() string {
+	switch e.Mode {
+	case "40000":
+		return "tree"
+	case "160000":
+		return "commit"
+	default:
+		return "blob"
+	}
+}
+
+// IsFile returns whether the entry is a regular file, as opposed to a
+// directory, symlink or submodule.
+func (e *TreeEntry) IsFile() bool {
+	return e.Mode == "100644" || e.Mode == "100755"
+}
+
+// Tree is the list of entries in a git tree object.
+type Tree struct {
+	Entries []*TreeEntry
+}
+
+// DecodeTree parses the contents of a git tree object.
+func DecodeTree(data []byte) (*Tree, error) {
+	tree := &Tree{}
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		null := bytes.IndexByte(data, 0)
+		if space < 0 || null < space || len(data) < null+21 {
+			return nil, fmt.Errorf("Invalid tree entry: %q", data)
+		}
+
+		tree.Entries = append(tree.Entries, &TreeEntry{
+			Mode: string(data[:space]),
+			Name: string(data[space+1 : null]),
+			Sha:  hex.EncodeToString(data[null+1 : null+21]),
+		})
+		data = data[null+21:]
+	}
+	return tree, nil
+}
+
+// Encode returns the contents of the git tree object for the tree, with the
+// entries sorted as git requires.
+func (t *Tree) Encode() ([]byte, error) {
+	entries := make(treeEntriesByName, len(t.Entries))
+	copy(entries, t.Entries)
+	sort.Sort(entries)
+
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		sha, err := hex.DecodeString(entry.Sha)
+		if err != nil || len(sha) != 20 {
+			return nil, fmt.Errorf("Invalid sha for tree entry %s: %q", entry.Name, entry.Sha)
+		}
+		fmt.Fprintf(&buf, "%s %s\x00", entry.Mode, entry.Name)
+		buf.Write(sha)
+	}
+	return buf.Bytes(), nil
+}
+
+// Entry returns the entry with the given name, or nil if there is none.
+func (t *Tree) Entry(name string) *TreeEntry {
+	for _, entry := range t.Entries {
+		if entry.Name == name {
+			return entry
+		}
+	}
+	return nil
+}
+
+// SetEntry adds the given entry to the tree, replacing any entry with the same
+// name.
+func (t *Tree) SetEntry(entry *TreeEntry) {
+	for i, existing := range t.Entries {
+		if existing.Name == entry.Name {
+			t.Entries[i] = entry
+			return
+		}
+	}
+	t.Entries = append(t.Entries, entry)
+}
+
+// RemoveEntry removes the entry with the given name from the tree, if there is
+// one.
+func (t *Tree) RemoveEntry(name string) {
+	for i, existing := range t.Entries {
+		if existing.Name == name {
+			t.Entries = append(t.Entries[:i], t.Entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// treeEntriesByName sorts tree entries as git does, comparing the names of
+// directories as though they end in a slash.
+type treeEntriesByName []*TreeEntry
+
+func (a treeEntriesByName) Len() int      { return len(a) }
+func (a treeEntriesByName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a treeEntriesByName) Less(i, j int) bool {
+	return a[i].sortName() < a[j].sortName()
+}
+
+func (e *TreeEntry) sortName() string {
+	if e.Type() == "tree" {
+		return e.Name + "/"
+	}
+	return e.Name
+}
@@ -0,0 +1,49 @@
+package odb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreeEncodeSortsLikeGit(t *testing.T) {
+	tree := &Tree{Entries: []*TreeEntry{
+		{Mode: "100644", Name: "a0", Sha: strings.Repeat("1", 40)},
+		{Mode: "40000", Name: "a", Sha: strings.Repeat("2", 40)},
+		{Mode: "100644", Name: "a.b", Sha: strings.Repeat("3", 40)},
+	}}
+
+	data, err := tree.Encode()
+	assert.Nil(t, err)
+
+	decoded, err := DecodeTree(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []*TreeEntry{
+		{Mode: "100644", Name: "a.b", Sha: strings.Repeat("3", 40)},
+		{Mode: "40000", Name: "a", Sha: strings.Repeat("2", 40)},
+		{Mode: "100644", Name: "a0", Sha: strings.Repeat("1", 40)},
+	}, decoded.Entries)
+}
+
+func TestTreeEntries(t *testing.T) {
+	tree := &Tree{}
+	tree.SetEntry(&TreeEntry{Mode: "100644", Name: "a", Sha: strings.Repeat("1", 40)})
+	tree.SetEntry(&TreeEntry{Mode: "100755", Name: "a", Sha: strings.Repeat("2", 40)})
+	tree.SetEntry(&TreeEntry{Mode: "120000", Name: "b", Sha: strings.Repeat("3", 40)})
+
+	assert.Equal(t, 2, len(tree.Entries))
+	assert.Equal(t, strings.Repeat("2", 40), tree.Entry("a").Sha)
+	assert.True(t, tree.Entry("a").IsFile())
+	assert.False(t, tree.Entry("b").IsFile())
+	assert.Equal(t, "blob", tree.Entry("b").Type())
+
+	tree.RemoveEntry("a")
+	assert.Nil(t, tree.Entry("a"))
+	assert.Equal(t, 1, len(tree.Entries))
+}
+
+func TestDecodeInvalidTree(t *testing.T) {
+	_, err := DecodeTree([]byte("100644 truncated\x00abc"))
+	assert.NotNil(t, err)
+}
@@ -1,72 +1,70 @@
 package httputil
 
 import (
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
+	"net/url"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
+	"github.com/github/git-lfs/auth"
 	"github.com/github/git-lfs/config"
 	"github.com/rubyist/tracerx"
 )
 
-// isCertVerificationDisabledForHost returns whether SSL certificate verification
-// has been disabled for the given host, or globally
-func isCertVerificationDisabledForHost(host string) bool {
-	hostSslVerify, _ := config.Config.GitConfig(fmt.Sprintf("http.https://%v/.sslverify", host))
-	if hostSslVerify == "false" {
+// isCertVerificationDisabledForUrl returns whether SSL certificate verification
+// has been disabled for the given URL, either by the most specific
+// http.<url>.sslverify setting, or globally
+func isCertVerificationDisabledForUrl(rawurl string) bool {
+	if config.Config.GetenvBool("GIT_SSL_NO_VERIFY", false) {
 		return true
 	}
 
-	globalSslVerify, _ := config.Config.GitConfig("http.sslverify")
-	if globalSslVerify == "false" || config.Config.GetenvBool("GIT_SSL_NO_VERIFY", false) {
-		return true
-	}
-
-	return false
-
+	return !config.Config.HttpConfigBool(rawurl, "sslverify", true)
 }
 
-// getRootCAsForHost returns a certificate pool for that specific host (which may
-// be "host:port" loaded from either the gitconfig or from a platform-specific
-// source which is not included by default in the golang certificate search)
+// getRootCAsForUrl returns a certificate pool for the host of the given URL
+// loaded from either the gitconfig or from a platform-specific source which is
+// not included by default in the golang certificate search)
 // May return nil if it doesn't have anything to add, in which case the default
 // RootCAs will be used if passed to TLSClientConfig.RootCAs
-func getRootCAsForHost(host string) *x509.CertPool {
+func getRootCAsForUrl(rawurl string) *x509.CertPool {
 
 	// don't init pool, want to return nil not empty if none found; init only on successful add cert
 	var pool *x509.CertPool
 
 	// gitconfig first
-	pool = appendRootCAsForHostFromGitconfig(pool, host)
+	pool = appendRootCAsForUrlFromGitconfig(pool, rawurl)
 	// Platform specific
-	return appendRootCAsForHostFromPlatform(pool, host)
+	if u, err := url.Parse(rawurl); err == nil {
+		pool = appendRootCAsForHostFromPlatform(pool, u.Host)
+	}
+	return pool
 
 }
 
-func appendRootCAsForHostFromGitconfig(pool *x509.CertPool, host string) *x509.CertPool {
+func appendRootCAsForUrlFromGitconfig(pool *x509.CertPool, rawurl string) *x509.CertPool {
 	// Accumulate certs from all these locations:
 
 	// GIT_SSL_CAINFO first
 	if cafile := config.Config.Getenv("GIT_SSL_CAINFO"); len(cafile) > 0 {
 		return appendCertsFromFile(pool, cafile)
 	}
-	// http.<url>.sslcainfo
-	// we know we have simply "host" or "host:port"
-	key := fmt.Sprintf("http.https://%v/.sslcainfo", host)
-	if cafile, ok := config.Config.GitConfig(key); ok {
-		return appendCertsFromFile(pool, cafile)
-	}
-	// http.sslcainfo
-	if cafile, ok := config.Config.GitConfig("http.sslcainfo"); ok {
+	// http.<url>.sslcainfo, or http.sslcainfo
+	if cafile, ok := config.Config.HttpConfig(rawurl, "sslcainfo"); ok {
 		return appendCertsFromFile(pool, cafile)
 	}
 	// GIT_SSL_CAPATH
 	if cadir := config.Config.Getenv("GIT_SSL_CAPATH"); len(cadir) > 0 {
 		return appendCertsFromFilesInDir(pool, cadir)
 	}
-	// http.sslcapath
-	if cadir, ok := config.Config.GitConfig("http.sslcapath"); ok {
+	// http.<url>.sslcapath, or http.sslcapath
+	if cadir, ok := config.Config.HttpConfig(rawurl, "sslcapath"); ok {
 		return appendCertsFromFilesInDir(pool, cadir)
 	}
 
@@ -74,6 +72,121 @@ func appendRootCAsForHostFromGitconfig(pool *x509.CertPool, host string) *x509.C
 
 }
 
+// getClientCertFuncForUrl returns a tls.Config.GetClientCertificate function
+// which loads the client certificate for the given URL the first time the
+// server asks for one, so that the passphrase of an encrypted key is only
+// requested when needed. Returns nil if no client certificate is configured.
+func getClientCertFuncForUrl(c *config.Configuration, rawurl, host string) func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if len(sslConfigForUrl(c, rawurl, "GIT_SSL_CERT", "sslcert")) == 0 {
+		return nil
+	}
+
+	var once sync.Once
+	var cert *tls.Certificate
+	return func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		once.Do(func() {
+			var err error
+			if cert, err = getClientCertForUrl(c, rawurl); err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading client certificate for %s: %s\n", host, err)
+			}
+		})
+
+		if cert == nil {
+			// send no certificate, and leave it to the server to refuse
+			return &tls.Certificate{}, nil
+		}
+		return cert, nil
+	}
+}
+
+// getClientCertForUrl returns the client certificate to present to the host of
+// the given URL.
+// The certificate and key are loaded from GIT_SSL_CERT/http.<url>.sslcert and
+// GIT_SSL_KEY/http.<url>.sslkey, falling back to the certificate file for the
+// key as curl does. If the key is encrypted, its passphrase is requested
+// through 'git credential'.
+// Returns nil if no client certificate is configured for the URL.
+func getClientCertForUrl(c *config.Configuration, rawurl string) (*tls.Certificate, error) {
+	certfile := sslConfigForUrl(c, rawurl, "GIT_SSL_CERT", "sslcert")
+	if len(certfile) == 0 {
+		return nil, nil
+	}
+
+	keyfile := sslConfigForUrl(c, rawurl, "GIT_SSL_KEY", "sslkey")
+	if len(keyfile) == 0 {
+		keyfile = certfile
+	}
+
+	certData, err := ioutil.ReadFile(certfile)
+	if err != nil {
+		return nil, err
+	}
+
+	keyData, err := ioutil.ReadFile(keyfile)
+	if err != nil {
+		return nil, err
+	}
+
+	keyData, err = decryptKeyPEMData(keyfile, keyData)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return nil, err
+	}
+
+	tracerx.Printf("Using client certificate %q for %s", certfile, rawurl)
+	return &cert, nil
+}
+
+// sslConfigForUrl returns the value of the given environment variable if
+// set, or else the http.<url>.<key> or http.<key> git config value that best
+// matches the given URL.
+func sslConfigForUrl(c *config.Configuration, rawurl, envKey, key string) string {
+	if value := c.Getenv(envKey); len(value) > 0 {
+		return value
+	}
+
+	value, _ := c.HttpConfig(rawurl, key)
+	return value
+}
+
+// decryptKeyPEMData returns the private key from the given PEM data, decrypted
+// with the passphrase from 'git credential' if necessary. Unencrypted data is
+// returned unmodified.
+func decryptKeyPEMData(keyfile string, data []byte) ([]byte, error) {
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return data, nil
+		}
+
+		if block.Type == "ENCRYPTED PRIVATE KEY" {
+			return nil, fmt.Errorf("Unsupported PKCS#8 encrypted private key in %s", keyfile)
+		}
+
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") || !x509.IsEncryptedPEMBlock(block) {
+			continue
+		}
+
+		creds, err := auth.FillCertPassword(keyfile)
+		if err != nil {
+			return nil, err
+		}
+
+		der, err := x509.DecryptPEMBlock(block, []byte(creds["password"]))
+		auth.SaveCertPassword(creds, err == nil)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to decrypt private key in %s: %s", keyfile, err)
+		}
+
+		return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+	}
+}
+
 func appendCertsFromFilesInDir(pool *x509.CertPool, dir string) *x509.CertPool {
 	files, err := ioutil.ReadDir(dir)
 	if err != nil {
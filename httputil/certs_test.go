@@ -1,11 +1,21 @@
 package httputil
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"io/ioutil"
+	"math/big"
+	"net/http"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/github/git-lfs/auth"
 	"github.com/github/git-lfs/config"
 	"github.com/stretchr/testify/assert"
 )
@@ -49,15 +59,15 @@ func TestCertFromSSLCAInfoConfig(t *testing.T) {
 	config.Config.SetConfig("http.https://git-lfs.local/.sslcainfo", tempfile.Name())
 
 	// Should match
-	pool := getRootCAsForHost("git-lfs.local")
+	pool := getRootCAsForUrl("https://git-lfs.local/")
 	assert.NotNil(t, pool)
 
 	// Shouldn't match
-	pool = getRootCAsForHost("wronghost.com")
+	pool = getRootCAsForUrl("https://wronghost.com/")
 	assert.Nil(t, pool)
 
 	// Ports have to match
-	pool = getRootCAsForHost("git-lfs.local:8443")
+	pool = getRootCAsForUrl("https://git-lfs.local:8443/")
 	assert.Nil(t, pool)
 
 	// Now use global sslcainfo
@@ -65,11 +75,11 @@ func TestCertFromSSLCAInfoConfig(t *testing.T) {
 	config.Config.SetConfig("http.sslcainfo", tempfile.Name())
 
 	// Should match anything
-	pool = getRootCAsForHost("git-lfs.local")
+	pool = getRootCAsForUrl("https://git-lfs.local/")
 	assert.NotNil(t, pool)
-	pool = getRootCAsForHost("wronghost.com")
+	pool = getRootCAsForUrl("https://wronghost.com/")
 	assert.NotNil(t, pool)
-	pool = getRootCAsForHost("git-lfs.local:8443")
+	pool = getRootCAsForUrl("https://git-lfs.local:8443/")
 	assert.NotNil(t, pool)
 
 }
@@ -91,11 +101,11 @@ func TestCertFromSSLCAInfoEnv(t *testing.T) {
 	config.Config.SetAllEnv(map[string]string{"GIT_SSL_CAINFO": tempfile.Name()})
 
 	// Should match any host at all
-	pool := getRootCAsForHost("git-lfs.local")
+	pool := getRootCAsForUrl("https://git-lfs.local/")
 	assert.NotNil(t, pool)
-	pool = getRootCAsForHost("wronghost.com")
+	pool = getRootCAsForUrl("https://wronghost.com/")
 	assert.NotNil(t, pool)
-	pool = getRootCAsForHost("notthisone.com:8888")
+	pool = getRootCAsForUrl("https://notthisone.com:8888/")
 	assert.NotNil(t, pool)
 
 }
@@ -114,11 +124,11 @@ func TestCertFromSSLCAPathConfig(t *testing.T) {
 	config.Config.SetConfig("http.sslcapath", tempdir)
 
 	// Should match any host at all
-	pool := getRootCAsForHost("git-lfs.local")
+	pool := getRootCAsForUrl("https://git-lfs.local/")
 	assert.NotNil(t, pool)
-	pool = getRootCAsForHost("wronghost.com")
+	pool = getRootCAsForUrl("https://wronghost.com/")
 	assert.NotNil(t, pool)
-	pool = getRootCAsForHost("notthisone.com:8888")
+	pool = getRootCAsForUrl("https://notthisone.com:8888/")
 	assert.NotNil(t, pool)
 
 }
@@ -139,18 +149,18 @@ func TestCertFromSSLCAPathEnv(t *testing.T) {
 	config.Config.SetAllEnv(map[string]string{"GIT_SSL_CAPATH": tempdir})
 
 	// Should match any host at all
-	pool := getRootCAsForHost("git-lfs.local")
+	pool := getRootCAsForUrl("https://git-lfs.local/")
 	assert.NotNil(t, pool)
-	pool = getRootCAsForHost("wronghost.com")
+	pool = getRootCAsForUrl("https://wronghost.com/")
 	assert.NotNil(t, pool)
-	pool = getRootCAsForHost("notthisone.com:8888")
+	pool = getRootCAsForUrl("https://notthisone.com:8888/")
 	assert.NotNil(t, pool)
 
 }
 
 func TestCertVerifyDisabledGlobalEnv(t *testing.T) {
 
-	assert.False(t, isCertVerificationDisabledForHost("anyhost.com"))
+	assert.False(t, isCertVerificationDisabledForUrl("https://anyhost.com/"))
 
 	oldEnv := config.Config.GetAllEnv()
 	defer func() {
@@ -158,29 +168,162 @@ func TestCertVerifyDisabledGlobalEnv(t *testing.T) {
 	}()
 	config.Config.SetAllEnv(map[string]string{"GIT_SSL_NO_VERIFY": "1"})
 
-	assert.True(t, isCertVerificationDisabledForHost("anyhost.com"))
+	assert.True(t, isCertVerificationDisabledForUrl("https://anyhost.com/"))
 }
 
 func TestCertVerifyDisabledGlobalConfig(t *testing.T) {
 	defer config.Config.ResetConfig()
 
-	assert.False(t, isCertVerificationDisabledForHost("anyhost.com"))
+	assert.False(t, isCertVerificationDisabledForUrl("https://anyhost.com/"))
 
 	config.Config.ClearConfig()
 	config.Config.SetConfig("http.sslverify", "false")
 
-	assert.True(t, isCertVerificationDisabledForHost("anyhost.com"))
+	assert.True(t, isCertVerificationDisabledForUrl("https://anyhost.com/"))
 }
 
 func TestCertVerifyDisabledHostConfig(t *testing.T) {
 	defer config.Config.ResetConfig()
 
-	assert.False(t, isCertVerificationDisabledForHost("specifichost.com"))
-	assert.False(t, isCertVerificationDisabledForHost("otherhost.com"))
+	assert.False(t, isCertVerificationDisabledForUrl("https://specifichost.com/"))
+	assert.False(t, isCertVerificationDisabledForUrl("https://otherhost.com/"))
 
 	config.Config.ClearConfig()
 	config.Config.SetConfig("http.https://specifichost.com/.sslverify", "false")
 
-	assert.True(t, isCertVerificationDisabledForHost("specifichost.com"))
-	assert.False(t, isCertVerificationDisabledForHost("otherhost.com"))
+	assert.True(t, isCertVerificationDisabledForUrl("https://specifichost.com/"))
+	assert.False(t, isCertVerificationDisabledForUrl("https://otherhost.com/"))
+}
+
+func TestClientCertFromHostConfig(t *testing.T) {
+	defer config.Config.ResetConfig()
+
+	tempdir, certfile, keyfile := writeTestClientCert(t, "")
+	defer os.RemoveAll(tempdir)
+
+	config.Config.ClearConfig()
+	config.Config.SetConfig("http.https://git-lfs.local/.sslcert", certfile)
+	config.Config.SetConfig("http.https://git-lfs.local/.sslkey", keyfile)
+
+	cert, err := getClientCertForUrl(config.Config, "https://git-lfs.local/")
+	assert.Nil(t, err)
+	assert.NotNil(t, cert)
+
+	cert, err = getClientCertForUrl(config.Config, "https://wronghost.com/")
+	assert.Nil(t, err)
+	assert.Nil(t, cert)
+}
+
+func TestClientCertWithEncryptedKey(t *testing.T) {
+	defer config.Config.ResetConfig()
+
+	tempdir, certfile, keyfile := writeTestClientCert(t, "monkey")
+	defer os.RemoveAll(tempdir)
+
+	var filled, approved []auth.Creds
+	oldCredsFunc := auth.SetCredentialsFunc(func(input auth.Creds, subCommand string) (auth.Creds, error) {
+		switch subCommand {
+		case "fill":
+			filled = append(filled, input)
+			return auth.Creds{"protocol": "cert", "path": input["path"], "password": "monkey"}, nil
+		case "approve":
+			approved = append(approved, input)
+		}
+		return nil, nil
+	})
+	defer auth.SetCredentialsFunc(oldCredsFunc)
+
+	config.Config.ClearConfig()
+	config.Config.SetConfig("http.sslcert", certfile)
+	config.Config.SetConfig("http.sslkey", keyfile)
+
+	cert, err := getClientCertForUrl(config.Config, "https://git-lfs.local/")
+	assert.Nil(t, err)
+	assert.NotNil(t, cert)
+
+	if assert.Len(t, filled, 1) {
+		assert.Equal(t, "cert", filled[0]["protocol"])
+		assert.Equal(t, keyfile, filled[0]["path"])
+	}
+	assert.Len(t, approved, 1)
+}
+
+func TestClientCertLoadedDuringHandshake(t *testing.T) {
+	tempdir, certfile, keyfile := writeTestClientCert(t, "monkey")
+	defer os.RemoveAll(tempdir)
+
+	var filled int
+	oldCredsFunc := auth.SetCredentialsFunc(func(input auth.Creds, subCommand string) (auth.Creds, error) {
+		if subCommand == "fill" {
+			filled++
+			return auth.Creds{"protocol": "cert", "path": input["path"], "password": "monkey"}, nil
+		}
+		return nil, nil
+	})
+	defer auth.SetCredentialsFunc(oldCredsFunc)
+
+	cfg := config.NewConfig()
+	cfg.ClearConfig()
+	cfg.SetConfig("http.https://client-cert.local/.sslcert", certfile)
+	cfg.SetConfig("http.https://client-cert.local/.sslkey", keyfile)
+
+	client := NewHttpClient(cfg, "client-cert.local")
+	tr := client.Client.Transport.(*http.Transport)
+	assert.Equal(t, 0, filled)
+
+	for i := 0; i < 2; i++ {
+		cert, err := tr.TLSClientConfig.GetClientCertificate(&tls.CertificateRequestInfo{})
+		assert.Nil(t, err)
+		assert.NotEmpty(t, cert.Certificate)
+	}
+	assert.Equal(t, 1, filled)
+
+	client = NewHttpClient(cfg, "no-client-cert.local")
+	tr = client.Client.Transport.(*http.Transport)
+	assert.Nil(t, tr.TLSClientConfig.GetClientCertificate)
+}
+
+func writeTestClientCert(t *testing.T, passphrase string) (dir, certfile, keyfile string) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, err, "Error generating key")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "git-lfs client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err, "Error creating cert")
+
+	keyBlock := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if len(passphrase) > 0 {
+		keyBlock, err = x509.EncryptPEMBlock(rand.Reader, keyBlock.Type, keyBlock.Bytes, []byte(passphrase), x509.PEMCipherAES256)
+		assert.Nil(t, err, "Error encrypting key")
+	}
+
+	dir, err = ioutil.TempDir("", "testclientcert")
+	assert.Nil(t, err, "Error creating temp cert dir")
+
+	certfile = filepath.Join(dir, "client.crt")
+	keyfile = filepath.Join(dir, "client.key")
+
+	err = ioutil.WriteFile(certfile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	assert.Nil(t, err, "Error writing cert file")
+	err = ioutil.WriteFile(keyfile, pem.EncodeToMemory(keyBlock), 0600)
+	assert.Nil(t, err, "Error writing key file")
+
+	return dir, certfile, keyfile
+}
+
+func TestCertVerifyEnabledForMoreSpecificUrl(t *testing.T) {
+	defer config.Config.ResetConfig()
+
+	config.Config.ClearConfig()
+	config.Config.SetConfig("http.https://specifichost.com/.sslverify", "false")
+	config.Config.SetConfig("http.https://specifichost.com/secure/.sslverify", "true")
+
+	assert.True(t, isCertVerificationDisabledForUrl("https://specifichost.com/repo.git/info/lfs"))
+	assert.False(t, isCertVerificationDisabledForUrl("https://specifichost.com/secure/repo.git/info/lfs"))
 }
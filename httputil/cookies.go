@@ -0,0 +1,142 @@
+package httputil
+
+import (
+	"bufio"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/github/git-lfs/config"
+	"github.com/rubyist/tracerx"
+)
+
+var (
+	cookieFiles      = make(map[string][]*fileCookie)
+	cookieFilesMutex sync.Mutex
+)
+
+// fileCookie is a single cookie read from a Netscape-format cookie file, as
+// written by curl and read by git's http.cookieFile setting.
+type fileCookie struct {
+	*http.Cookie
+	// includeSubdomains determines whether the cookie is also sent to
+	// subdomains of its domain.
+	includeSubdomains bool
+}
+
+// setCookiesFromFile adds the cookies from the file given by the
+// http.<url>.cookiefile setting matching the request URL, unless the request
+// already has a cookie with the same name.
+func setCookiesFromFile(req *http.Request) {
+	filename, ok := config.Config.HttpConfig(req.URL.String(), "cookiefile")
+	if !ok || len(filename) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for _, cookie := range loadCookieFile(filename) {
+		if !cookie.matches(req.URL, now) {
+			continue
+		}
+
+		if _, err := req.Cookie(cookie.Name); err == nil {
+			continue
+		}
+
+		req.AddCookie(cookie.Cookie)
+	}
+}
+
+// loadCookieFile returns the cookies in the given file, reading it only once
+// per process.
+func loadCookieFile(filename string) []*fileCookie {
+	if strings.HasPrefix(filename, "~/") {
+		filename = filepath.Join(config.Config.Getenv("HOME"), filename[2:])
+	}
+
+	cookieFilesMutex.Lock()
+	defer cookieFilesMutex.Unlock()
+
+	if cookies, ok := cookieFiles[filename]; ok {
+		return cookies
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		tracerx.Printf("Error reading cookie file %q: %v", filename, err)
+		cookieFiles[filename] = nil
+		return nil
+	}
+	defer file.Close()
+
+	var cookies []*fileCookie
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if cookie := parseCookieLine(scanner.Text()); cookie != nil {
+			cookies = append(cookies, cookie)
+		}
+	}
+
+	cookieFiles[filename] = cookies
+	return cookies
+}
+
+// parseCookieLine parses a single line of a Netscape-format cookie file:
+//
+//	domain <TAB> subdomains <TAB> path <TAB> secure <TAB> expires <TAB> name <TAB> value
+//
+// Returns nil for blank lines, comments and malformed lines.
+func parseCookieLine(line string) *fileCookie {
+	line = strings.TrimPrefix(line, "#HttpOnly_")
+	if len(line) == 0 || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	fields := strings.Split(line, "\t")
+	if len(fields) != 7 {
+		return nil
+	}
+
+	cookie := &http.Cookie{
+		Domain: fields[0],
+		Path:   fields[2],
+		Secure: strings.EqualFold(fields[3], "TRUE"),
+		Name:   fields[5],
+		Value:  fields[6],
+	}
+
+	if expires, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expires > 0 {
+		cookie.Expires = time.Unix(expires, 0)
+	}
+
+	return &fileCookie{cookie, strings.EqualFold(fields[1], "TRUE")}
+}
+
+// matches returns whether the cookie should be sent with a request to u at the
+// given time.
+func (c *fileCookie) matches(u *url.URL, now time.Time) bool {
+	if !c.Expires.IsZero() && c.Expires.Before(now) {
+		return false
+	}
+
+	if c.Secure && u.Scheme != "https" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+	if host != domain && !(c.includeSubdomains && strings.HasSuffix(host, "."+domain)) {
+		return false
+	}
+
+	path := u.Path
+	if len(path) == 0 {
+		path = "/"
+	}
+	return strings.HasPrefix(path, c.Path)
+}
@@ -0,0 +1,72 @@
+package httputil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/github/git-lfs/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCookieLine(t *testing.T) {
+	cookie := parseCookieLine("#HttpOnly_.example.com\tTRUE\t/foo\tTRUE\t0\tsession\tabc123")
+	if assert.NotNil(t, cookie) {
+		assert.Equal(t, ".example.com", cookie.Domain)
+		assert.Equal(t, "/foo", cookie.Path)
+		assert.Equal(t, "session", cookie.Name)
+		assert.Equal(t, "abc123", cookie.Value)
+		assert.True(t, cookie.Secure)
+		assert.True(t, cookie.includeSubdomains)
+		assert.True(t, cookie.Expires.IsZero())
+	}
+
+	assert.Nil(t, parseCookieLine("# Netscape HTTP Cookie File"))
+	assert.Nil(t, parseCookieLine(""))
+	assert.Nil(t, parseCookieLine("example.com\tFALSE\t/"))
+}
+
+func TestSetCookiesFromFile(t *testing.T) {
+	defer config.Config.ResetConfig()
+
+	file, err := ioutil.TempFile("", "cookies")
+	assert.Nil(t, err)
+	defer os.Remove(file.Name())
+
+	expired := time.Now().Add(-time.Hour).Unix()
+	file.WriteString("# Netscape HTTP Cookie File\n")
+	file.WriteString("example.com\tFALSE\t/\tFALSE\t0\tplain\t1\n")
+	file.WriteString(".example.com\tTRUE\t/repo\tTRUE\t0\tsecure\t2\n")
+	file.WriteString(".example.com\tTRUE\t/\tFALSE\t" + strconv.FormatInt(expired, 10) + "\told\t3\n")
+	file.Close()
+
+	config.Config.ClearConfig()
+	config.Config.SetConfig("http.cookiefile", file.Name())
+
+	req, _ := http.NewRequest("GET", "https://git.example.com/repo/info/lfs", nil)
+	setCookiesFromFile(req)
+	assert.Equal(t, "secure=2", req.Header.Get("Cookie"))
+
+	req, _ = http.NewRequest("GET", "http://example.com/repo/info/lfs", nil)
+	setCookiesFromFile(req)
+	assert.Equal(t, "plain=1", req.Header.Get("Cookie"))
+}
+
+func TestSetExtraHeaders(t *testing.T) {
+	defer config.Config.ResetConfig()
+
+	config.Config.ClearConfig()
+	config.Config.SetConfig("http.https://example.com/.extraheader", "X-Auth-Token: abc")
+
+	req, _ := http.NewRequest("GET", "https://example.com/repo/info/lfs", nil)
+	setExtraHeaders(req)
+	setExtraHeaders(req)
+	assert.Equal(t, []string{"abc"}, req.Header["X-Auth-Token"])
+
+	req, _ = http.NewRequest("GET", "https://other.com/repo/info/lfs", nil)
+	setExtraHeaders(req)
+	assert.Empty(t, req.Header.Get("X-Auth-Token"))
+}
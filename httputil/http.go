@@ -12,6 +12,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -58,6 +59,8 @@ type HttpClient struct {
 }
 
 func (c *HttpClient) Do(req *http.Request) (*http.Response, error) {
+	setExtraHeaders(req)
+	setCookiesFromFile(req)
 	traceHttpRequest(req)
 
 	crc := countingRequest(req)
@@ -69,12 +72,14 @@ func (c *HttpClient) Do(req *http.Request) (*http.Response, error) {
 
 	start := time.Now()
 	res, err := c.Client.Do(req)
+	saveProxyCredentials(req, res, err)
 	if err != nil {
 		return res, err
 	}
 
 	traceHttpResponse(res)
 
+	res.Body = lowSpeedLimitedBody(req, res.Body)
 	cresp := countingResponse(res)
 	res.Body = cresp
 
@@ -106,13 +111,23 @@ func (c *HttpClient) Do(req *http.Request) (*http.Response, error) {
 
 // NewHttpClient returns a new HttpClient for the given host (which may be "host:port")
 func NewHttpClient(c *config.Configuration, host string) *HttpClient {
+	return NewHttpClientForUrl(c, &url.URL{Scheme: "https", Host: host, Path: "/"})
+}
+
+// NewHttpClientForUrl returns a HttpClient whose transport is configured with
+// the http.<url>.* settings that best match the given URL. Clients are shared
+// between URLs on the same host which resolve to the same settings.
+func NewHttpClientForUrl(c *config.Configuration, u *url.URL) *HttpClient {
+	rawurl := u.String()
+	key := httpClientKey(c, u.Host, rawurl)
+
 	httpClientsMutex.Lock()
 	defer httpClientsMutex.Unlock()
 
 	if httpClients == nil {
 		httpClients = make(map[string]*HttpClient)
 	}
-	if client, ok := httpClients[host]; ok {
+	if client, ok := httpClients[key]; ok {
 		return client
 	}
 
@@ -121,7 +136,7 @@ func NewHttpClient(c *config.Configuration, host string) *HttpClient {
 	tlstime := c.GitConfigInt("lfs.tlstimeout", 30)
 
 	tr := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
+		Proxy: proxyFromConfig,
 		Dial: (&net.Dialer{
 			Timeout:   time.Duration(dialtime) * time.Second,
 			KeepAlive: time.Duration(keepalivetime) * time.Second,
@@ -131,20 +146,33 @@ func NewHttpClient(c *config.Configuration, host string) *HttpClient {
 	}
 
 	tr.TLSClientConfig = &tls.Config{}
-	if isCertVerificationDisabledForHost(host) {
+	if isCertVerificationDisabledForUrl(rawurl) {
 		tr.TLSClientConfig.InsecureSkipVerify = true
 	} else {
-		tr.TLSClientConfig.RootCAs = getRootCAsForHost(host)
+		tr.TLSClientConfig.RootCAs = getRootCAsForUrl(rawurl)
 	}
 
+	tr.TLSClientConfig.GetClientCertificate = getClientCertFuncForUrl(c, rawurl, u.Host)
+
 	client := &HttpClient{
-		&http.Client{Transport: tr, CheckRedirect: CheckRedirect},
+		&http.Client{Transport: tr, CheckRedirect: checkClientRedirect},
 	}
-	httpClients[host] = client
+	httpClients[key] = client
 
 	return client
 }
 
+// httpClientKey returns the key used to share HttpClients between URLs, made
+// up of the host and every http.<url>.* setting that affects the transport.
+func httpClientKey(c *config.Configuration, host, rawurl string) string {
+	parts := []string{host}
+	for _, key := range []string{"sslverify", "sslcainfo", "sslcapath", "sslcert", "sslkey"} {
+		value, _ := c.HttpConfig(rawurl, key)
+		parts = append(parts, value)
+	}
+	return strings.Join(parts, "\x00")
+}
+
 func CheckRedirect(req *http.Request, via []*http.Request) error {
 	if len(via) >= 3 {
 		return errors.New("stopped after 3 redirects")
@@ -160,13 +188,40 @@ func CheckRedirect(req *http.Request, via []*http.Request) error {
 		req.Header.Set(key, oldest.Header.Get(key))
 	}
 
-	oldestUrl := strings.SplitN(oldest.URL.String(), "?", 2)[0]
-	newUrl := strings.SplitN(req.URL.String(), "?", 2)[0]
-	tracerx.Printf("api: redirect %s %s to %s", oldest.Method, oldestUrl, newUrl)
+	tracerx.Printf("api: redirect %s %s to %s", oldest.Method, traceableUrl(oldest.URL), traceableUrl(req.URL))
 
 	return nil
 }
 
+// setExtraHeaders adds the headers given by the http.<url>.extraheader settings
+// matching the request URL, unless the request already has them.
+func setExtraHeaders(req *http.Request) {
+	for _, header := range config.Config.HttpConfigAll(req.URL.String(), "extraheader") {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) < 2 {
+			tracerx.Printf("http: ignoring invalid extraheader %q", header)
+			continue
+		}
+
+		name := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if hasHeaderValue(req.Header, name, value) {
+			continue
+		}
+
+		req.Header.Add(name, value)
+	}
+}
+
+func hasHeaderValue(header http.Header, name, value string) bool {
+	for _, v := range header[http.CanonicalHeaderKey(name)] {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 var tracedTypes = []string{"json", "text", "xml", "html"}
 
 func traceHttpRequest(req *http.Request) {
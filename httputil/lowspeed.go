@@ -0,0 +1,124 @@
+package httputil
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/github/git-lfs/config"
+	"github.com/github/git-lfs/errutil"
+)
+
+// lowSpeedLimitedBody wraps the given response body so that reading fails once
+// the transfer has been slower than http.<url>.lowspeedlimit bytes per second
+// for http.<url>.lowspeedtime seconds, like git and curl. The
+// GIT_HTTP_LOW_SPEED_LIMIT and GIT_HTTP_LOW_SPEED_TIME environment variables
+// override the git config values.
+//
+// If either setting is missing, the body is returned unmodified.
+func lowSpeedLimitedBody(req *http.Request, body io.ReadCloser) io.ReadCloser {
+	rawurl := req.URL.String()
+	limit := lowSpeedSetting(rawurl, "GIT_HTTP_LOW_SPEED_LIMIT", "lowspeedlimit")
+	seconds := lowSpeedSetting(rawurl, "GIT_HTTP_LOW_SPEED_TIME", "lowspeedtime")
+	if body == nil || limit < 1 || seconds < 1 {
+		return body
+	}
+
+	return newLowSpeedReadCloser(body, limit, time.Duration(seconds)*time.Second)
+}
+
+func lowSpeedSetting(rawurl, envKey, key string) int64 {
+	value := config.Config.Getenv(envKey)
+	if len(value) == 0 {
+		value, _ = config.Config.HttpConfig(rawurl, key)
+	}
+
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return n
+}
+
+// lowSpeedReadCloser is an io.ReadCloser which closes the underlying reader,
+// and fails all further reads, if less than limit bytes per second were read
+// over a period of the given window.
+type lowSpeedReadCloser struct {
+	io.ReadCloser
+
+	limit  int64
+	window time.Duration
+
+	mu    sync.Mutex
+	count int64
+	err   error
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newLowSpeedReadCloser(r io.ReadCloser, limit int64, window time.Duration) *lowSpeedReadCloser {
+	l := &lowSpeedReadCloser{
+		ReadCloser: r,
+		limit:      limit,
+		window:     window,
+		done:       make(chan struct{}),
+	}
+
+	go l.monitor()
+	return l
+}
+
+func (l *lowSpeedReadCloser) Read(b []byte) (int, error) {
+	n, err := l.ReadCloser.Read(b)
+
+	l.mu.Lock()
+	l.count += int64(n)
+	if l.err != nil {
+		err = l.err
+	}
+	l.mu.Unlock()
+
+	if err != nil {
+		l.stop()
+	}
+	return n, err
+}
+
+func (l *lowSpeedReadCloser) Close() error {
+	l.stop()
+	return l.ReadCloser.Close()
+}
+
+func (l *lowSpeedReadCloser) stop() {
+	l.closeOnce.Do(func() { close(l.done) })
+}
+
+// monitor checks the number of bytes read at the end of every window, and
+// aborts the transfer if it fell below the limit.
+func (l *lowSpeedReadCloser) monitor() {
+	ticker := time.NewTicker(l.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			rate := float64(l.count) / l.window.Seconds()
+			l.count = 0
+			if rate < float64(l.limit) {
+				l.err = errutil.NewRetriableError(fmt.Errorf("Transfer rate was below %d bytes/sec for the last %s", l.limit, l.window))
+			}
+			failed := l.err != nil
+			l.mu.Unlock()
+
+			if failed {
+				l.stop()
+				l.ReadCloser.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package httputil
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/github/git-lfs/errutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLowSpeedReadCloserAbortsStalledTransfer(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	body := newLowSpeedReadCloser(pr, 1024, 50*time.Millisecond)
+
+	_, err := ioutil.ReadAll(body)
+	if assert.NotNil(t, err) {
+		assert.True(t, errutil.IsRetriableError(err))
+	}
+}
+
+func TestLowSpeedReadCloserAllowsFastTransfer(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < 4; i++ {
+			pw.Write(make([]byte, 1024))
+			time.Sleep(10 * time.Millisecond)
+		}
+		pw.Close()
+	}()
+
+	body := newLowSpeedReadCloser(pr, 1024, 50*time.Millisecond)
+
+	data, err := ioutil.ReadAll(body)
+	assert.Nil(t, err)
+	assert.Len(t, data, 4096)
+}
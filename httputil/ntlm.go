@@ -45,7 +45,7 @@ func doNTLMRequest(request *http.Request, retry bool) (*http.Response, error) {
 		return nil, err
 	}
 
-	res, err := NewHttpClient(config.Config, handReq.Host).Do(handReq)
+	res, err := NewHttpClientForUrl(config.Config, handReq.URL).Do(handReq)
 	if err != nil && res == nil {
 		return nil, err
 	}
@@ -92,7 +92,7 @@ func doNTLMRequest(request *http.Request, retry bool) (*http.Response, error) {
 
 func negotiate(request *http.Request, message string) ([]byte, error) {
 	request.Header.Add("Authorization", message)
-	res, err := NewHttpClient(config.Config, request.Host).Do(request)
+	res, err := NewHttpClientForUrl(config.Config, request.URL).Do(request)
 
 	if res == nil && err != nil {
 		return nil, err
@@ -128,7 +128,7 @@ func challenge(request *http.Request, challengeBytes []byte, creds auth.Creds) (
 
 	authMsg := base64.StdEncoding.EncodeToString(authenticate.Bytes())
 	request.Header.Add("Authorization", "NTLM "+authMsg)
-	return NewHttpClient(config.Config, request.Host).Do(request)
+	return NewHttpClientForUrl(config.Config, request.URL).Do(request)
 }
 
 func parseChallengeResponse(response *http.Response) ([]byte, error) {
@@ -157,6 +157,8 @@ func cloneRequest(request *http.Request) (*http.Request, error) {
 	if err != nil {
 		return nil, err
 	}
+	// keep the context, which marks requests that follow redirects manually
+	clonedReq = clonedReq.WithContext(request.Context())
 
 	for k, _ := range request.Header {
 		clonedReq.Header.Add(k, request.Header.Get(k))
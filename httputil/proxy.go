@@ -0,0 +1,212 @@
+package httputil
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/github/git-lfs/auth"
+	"github.com/github/git-lfs/config"
+	"github.com/rubyist/tracerx"
+)
+
+var (
+	// proxyUsers caches the credentials filled for each proxy URL, so that
+	// 'git credential' is only asked once per proxy per process, unless the
+	// proxy rejects them.
+	proxyUsers      = make(map[string]*proxyUser)
+	proxyUsersMutex sync.Mutex
+)
+
+// proxyUser is the user for a proxy URL, with the credentials from 'git
+// credential' that its password came from, if any.
+type proxyUser struct {
+	user     *url.Userinfo
+	creds    auth.Creds
+	approved bool
+}
+
+// proxyFromConfig implements the http.Transport Proxy function using
+// ProxyForUrl for the current remote, filling in the proxy password through
+// 'git credential' if needed.
+func proxyFromConfig(req *http.Request) (*url.URL, error) {
+	proxyUrl, err := ProxyForUrl(req.URL, config.Config.CurrentRemote)
+	if err != nil || proxyUrl == nil {
+		return proxyUrl, err
+	}
+
+	setProxyCredentials(proxyUrl)
+	return proxyUrl, nil
+}
+
+// ProxyForUrl returns the proxy to use for requests to the given URL on behalf
+// of the given remote, or nil if the request should be made directly. As in
+// git, the proxy is taken from the first of these that is set:
+//
+//  1. remote.<name>.proxy, for the given remote.
+//  2. The http.<url>.proxy setting that best matches the URL, or http.proxy.
+//  3. The https_proxy (for https URLs), http_proxy (for http URLs) or
+//     all_proxy environment variables.
+//
+// Hosts listed in the no_proxy environment variable are never proxied.
+func ProxyForUrl(u *url.URL, remote string) (*url.URL, error) {
+	if isNoProxyHost(u.Host) {
+		return nil, nil
+	}
+
+	proxy := configuredProxy(u, remote)
+	if len(proxy) == 0 {
+		return nil, nil
+	}
+
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+
+	return url.Parse(proxy)
+}
+
+func configuredProxy(u *url.URL, remote string) string {
+	cfg := config.Config
+
+	if len(remote) > 0 {
+		if proxy, _ := cfg.GitConfig("remote." + remote + ".proxy"); len(proxy) > 0 {
+			return proxy
+		}
+	}
+
+	if proxy, _ := cfg.HttpConfig(u.String(), "proxy"); len(proxy) > 0 {
+		return proxy
+	}
+
+	var keys []string
+	switch u.Scheme {
+	case "https":
+		keys = []string{"https_proxy", "HTTPS_PROXY"}
+	case "http":
+		keys = []string{"http_proxy", "HTTP_PROXY"}
+	}
+
+	for _, key := range append(keys, "all_proxy", "ALL_PROXY") {
+		if proxy := cfg.Getenv(key); len(proxy) > 0 {
+			return proxy
+		}
+	}
+
+	return ""
+}
+
+// isNoProxyHost returns whether the given host (which may be "host:port")
+// matches the comma-separated no_proxy (or NO_PROXY) environment variable,
+// following curl's rules: "*" matches every host, and any other entry matches
+// that host and all of its subdomains, with or without a leading ".".
+func isNoProxyHost(host string) bool {
+	noProxy := config.Config.Getenv("no_proxy")
+	if len(noProxy) == 0 {
+		noProxy = config.Config.Getenv("NO_PROXY")
+	}
+	if len(noProxy) == 0 {
+		return false
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "*" {
+			return true
+		}
+
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		entry = strings.TrimPrefix(strings.Trim(entry, "[]"), ".")
+		if len(entry) == 0 {
+			continue
+		}
+
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setProxyCredentials fills in the password for a proxy URL that has a user
+// name but no password, asking 'git credential' the first time it is needed.
+func setProxyCredentials(proxyUrl *url.URL) {
+	if proxyUrl.User == nil || len(proxyUrl.User.Username()) == 0 {
+		return
+	}
+
+	if _, ok := proxyUrl.User.Password(); ok {
+		return
+	}
+
+	key := proxyUrl.String()
+
+	proxyUsersMutex.Lock()
+	defer proxyUsersMutex.Unlock()
+
+	if u, ok := proxyUsers[key]; ok {
+		proxyUrl.User = u.user
+		return
+	}
+
+	creds, err := auth.FillProxyCredentials(proxyUrl)
+	if err != nil {
+		tracerx.Printf("proxy: unable to get credentials for %s: %s", key, err)
+		proxyUsers[key] = &proxyUser{user: proxyUrl.User}
+		return
+	}
+
+	proxyUrl.User = url.UserPassword(creds["username"], creds["password"])
+	proxyUsers[key] = &proxyUser{user: proxyUrl.User, creds: creds}
+}
+
+// saveProxyCredentials tells 'git credential' whether the proxy credentials
+// that it filled in for req were accepted, given the result of the request.
+// They are approved after the first request that gets a response through the
+// proxy, and rejected if the proxy responds with 407 Proxy Authentication
+// Required, so that they are asked for again on the next request.
+func saveProxyCredentials(req *http.Request, res *http.Response, err error) {
+	proxyUrl, perr := ProxyForUrl(req.URL, config.Config.CurrentRemote)
+	if perr != nil || proxyUrl == nil {
+		return
+	}
+
+	key := proxyUrl.String()
+
+	proxyUsersMutex.Lock()
+	defer proxyUsersMutex.Unlock()
+
+	u, ok := proxyUsers[key]
+	if !ok || u.creds == nil {
+		return
+	}
+
+	if isProxyAuthRequired(res, err) {
+		tracerx.Printf("proxy: credentials for %s were rejected", proxyUrl.Host)
+		auth.SaveProxyCredentials(u.creds, false)
+		delete(proxyUsers, key)
+	} else if err == nil && !u.approved {
+		auth.SaveProxyCredentials(u.creds, true)
+		u.approved = true
+	}
+}
+
+// isProxyAuthRequired returns whether the proxy responded with 407 Proxy
+// Authentication Required. For https URLs, this is the response to CONNECT,
+// which http.Client returns as an error with the status text.
+func isProxyAuthRequired(res *http.Response, err error) bool {
+	if res != nil {
+		return res.StatusCode == http.StatusProxyAuthRequired
+	}
+	return err != nil && strings.Contains(err.Error(), http.StatusText(http.StatusProxyAuthRequired))
+}
@@ -0,0 +1,164 @@
+package httputil
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/github/git-lfs/auth"
+	"github.com/github/git-lfs/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyFromGitConfig(t *testing.T) {
+	defer config.Config.ResetConfig()
+	defer setProxyTestEnv(map[string]string{"https_proxy": "https://env-proxy:8080"})()
+
+	config.Config.ClearConfig()
+	config.Config.SetConfig("http.proxy", "global-proxy:3128")
+	config.Config.SetConfig("http.https://internal.com/.proxy", "http://internal-proxy")
+	config.Config.SetConfig("remote.mirror.proxy", "http://mirror-proxy")
+
+	assertProxy(t, "http://global-proxy:3128", "https://example.com/repo.git/info/lfs", "origin")
+	assertProxy(t, "http://internal-proxy", "https://internal.com/repo.git/info/lfs", "origin")
+	assertProxy(t, "http://mirror-proxy", "https://internal.com/repo.git/info/lfs", "mirror")
+}
+
+func TestProxyFromEnvironment(t *testing.T) {
+	defer config.Config.ResetConfig()
+	defer setProxyTestEnv(map[string]string{
+		"https_proxy": "https://secure-proxy",
+		"http_proxy":  "http://plain-proxy",
+		"no_proxy":    "localhost, .internal.com",
+	})()
+
+	config.Config.ClearConfig()
+
+	assertProxy(t, "https://secure-proxy", "https://example.com/info/lfs", "origin")
+	assertProxy(t, "http://plain-proxy", "http://example.com/info/lfs", "origin")
+	assertProxy(t, "", "http://localhost:8080/info/lfs", "origin")
+	assertProxy(t, "", "https://git.internal.com/info/lfs", "origin")
+	assertProxy(t, "", "https://internal.com/info/lfs", "origin")
+	assertProxy(t, "https://secure-proxy", "https://notinternal.com/info/lfs", "origin")
+}
+
+func TestNoProxyWildcard(t *testing.T) {
+	defer config.Config.ResetConfig()
+	defer setProxyTestEnv(map[string]string{"no_proxy": "*"})()
+
+	config.Config.ClearConfig()
+	config.Config.SetConfig("http.proxy", "http://proxy")
+
+	assertProxy(t, "", "https://example.com/info/lfs", "origin")
+}
+
+func TestProxyCredentialsFromHelper(t *testing.T) {
+	defer config.Config.ResetConfig()
+	defer setProxyTestEnv(map[string]string{})()
+
+	var filled []auth.Creds
+	oldCredsFunc := auth.SetCredentialsFunc(func(input auth.Creds, subCommand string) (auth.Creds, error) {
+		filled = append(filled, input)
+		return auth.Creds{"username": input["username"], "password": "monkey"}, nil
+	})
+	defer auth.SetCredentialsFunc(oldCredsFunc)
+
+	config.Config.ClearConfig()
+	config.Config.SetConfig("http.proxy", "http://proxyuser@auth-proxy:3128")
+
+	req, _ := http.NewRequest("GET", "https://example.com/info/lfs", nil)
+	proxy, err := proxyFromConfig(req)
+	assert.Nil(t, err)
+	if assert.NotNil(t, proxy) {
+		password, _ := proxy.User.Password()
+		assert.Equal(t, "proxyuser", proxy.User.Username())
+		assert.Equal(t, "monkey", password)
+	}
+
+	// Only asks once per proxy
+	proxyFromConfig(req)
+	if assert.Len(t, filled, 1) {
+		assert.Equal(t, "http", filled[0]["protocol"])
+		assert.Equal(t, "auth-proxy:3128", filled[0]["host"])
+		assert.Equal(t, "proxyuser", filled[0]["username"])
+	}
+}
+
+func TestProxyCredentialsApprovedAndRejected(t *testing.T) {
+	defer config.Config.ResetConfig()
+	defer setProxyTestEnv(map[string]string{})()
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("proxyuser:monkey")) {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer proxy.Close()
+
+	passwords := []string{"wrong", "monkey"}
+	var filled, approved, rejected []string
+	oldCredsFunc := auth.SetCredentialsFunc(func(input auth.Creds, subCommand string) (auth.Creds, error) {
+		switch subCommand {
+		case "fill":
+			password := passwords[len(filled)]
+			filled = append(filled, password)
+			return auth.Creds{"protocol": input["protocol"], "host": input["host"], "username": input["username"], "password": password}, nil
+		case "approve":
+			approved = append(approved, input["password"])
+		case "reject":
+			rejected = append(rejected, input["password"])
+		}
+		return nil, nil
+	})
+	defer auth.SetCredentialsFunc(oldCredsFunc)
+
+	config.Config.ClearConfig()
+	config.Config.SetConfig("http.proxy", "http://proxyuser@"+strings.TrimPrefix(proxy.URL, "http://"))
+
+	client := NewHttpClient(config.Config, "proxied.local")
+	for _, status := range []int{407, 200, 200} {
+		req, _ := http.NewRequest("GET", "http://proxied.local/info/lfs", nil)
+		res, err := client.Do(req)
+		if assert.Nil(t, err) {
+			assert.Equal(t, status, res.StatusCode)
+			res.Body.Close()
+		}
+	}
+
+	assert.Equal(t, []string{"wrong", "monkey"}, filled)
+	assert.Equal(t, []string{"wrong"}, rejected)
+	assert.Equal(t, []string{"monkey"}, approved)
+}
+
+func assertProxy(t *testing.T, expected, rawurl, remote string) {
+	u, err := url.Parse(rawurl)
+	assert.Nil(t, err)
+
+	proxy, err := ProxyForUrl(u, remote)
+	assert.Nil(t, err)
+
+	if len(expected) == 0 {
+		assert.Nil(t, proxy, rawurl)
+	} else if assert.NotNil(t, proxy, rawurl) {
+		assert.Equal(t, expected, proxy.String(), rawurl)
+	}
+}
+
+func setProxyTestEnv(env map[string]string) func() {
+	oldEnv := config.Config.GetAllEnv()
+	for _, key := range []string{"http_proxy", "HTTP_PROXY", "https_proxy", "HTTPS_PROXY", "all_proxy", "ALL_PROXY", "no_proxy", "NO_PROXY"} {
+		if _, ok := env[key]; !ok {
+			env[key] = ""
+		}
+	}
+	config.Config.SetAllEnv(env)
+
+	return func() {
+		config.Config.SetAllEnv(oldEnv)
+	}
+}
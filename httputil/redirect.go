@@ -0,0 +1,171 @@
+package httputil
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/github/git-lfs/auth"
+	"github.com/github/git-lfs/config"
+	"github.com/github/git-lfs/errutil"
+	"github.com/github/git-lfs/git"
+	"github.com/rubyist/tracerx"
+)
+
+// manualRedirectsKey is the context key used to mark requests whose redirects
+// are followed by DoHttpRequestWithRedirects, rather than by the http.Client.
+type manualRedirectsKey struct{}
+
+var (
+	// persistedRedirects records the config keys that have already been
+	// updated after a permanent redirect, so that each is written once.
+	persistedRedirects   = make(map[string]bool)
+	persistedRedirectsMu sync.Mutex
+)
+
+// withManualRedirects returns a copy of req which the http.Client will not
+// follow redirects for, returning the redirect response instead.
+func withManualRedirects(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), manualRedirectsKey{}, true))
+}
+
+// checkClientRedirect is the CheckRedirect function given to each http.Client.
+// Redirects for requests marked by withManualRedirects are returned to the
+// caller, and all others are handled by CheckRedirect.
+func checkClientRedirect(req *http.Request, via []*http.Request) error {
+	if manual, _ := via[0].Context().Value(manualRedirectsKey{}).(bool); manual {
+		return http.ErrUseLastResponse
+	}
+	return CheckRedirect(req, via)
+}
+
+// isRedirect returns whether the given status code is a redirect that should be
+// followed.
+func isRedirect(status int) bool {
+	switch status {
+	case 301, 302, 303, 307, 308:
+		return true
+	}
+	return false
+}
+
+// isPermanentRedirect returns whether the given status code indicates that the
+// requested resource has moved for good.
+func isPermanentRedirect(status int) bool {
+	return status == 301 || status == 308
+}
+
+// newRedirectRequest returns the request to make in order to follow the
+// redirect in res, which was received in response to req.
+//
+// A 303 See Other changes the method to GET, as described in RFC 7231. All
+// other redirects keep the original method and re-send the body. This differs
+// from browsers, which change POST to GET for a 301 or 302, but is what the
+// LFS API needs and is allowed by the RFC.
+func newRedirectRequest(req *http.Request, res *http.Response) (*http.Request, error) {
+	location := res.Header.Get("Location")
+	if len(location) == 0 {
+		return nil, errutil.Errorf(nil, "HTTP %d redirect from %s has no Location", res.StatusCode, traceableUrl(req.URL))
+	}
+
+	locurl, err := req.URL.Parse(location)
+	if err != nil {
+		return nil, errutil.Errorf(err, "Invalid redirect Location %q: %s", location, err)
+	}
+
+	if req.URL.Scheme == "https" && locurl.Scheme != "https" {
+		return nil, errutil.Errorf(nil, "Refusing to follow redirect from %s to insecure URL %s", traceableUrl(req.URL), traceableUrl(locurl))
+	}
+
+	method := req.Method
+	if res.StatusCode == 303 && method != "HEAD" {
+		method = "GET"
+	}
+
+	redirectedReq, err := NewHttpRequest(method, locurl.String(), nil)
+	if err != nil {
+		return nil, errutil.Error(err)
+	}
+
+	if method == req.Method && req.Body != nil {
+		body, err := rewindRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+
+		redirectedReq.Body = body
+		redirectedReq.ContentLength = req.ContentLength
+	}
+
+	return redirectedReq, nil
+}
+
+// rewindRequestBody returns the body of req, ready to be sent again.
+func rewindRequestBody(req *http.Request) (io.ReadCloser, error) {
+	// Avoid seeking and re-wrapping the CountingReadCloser, just get the "real" body
+	realBody := req.Body
+	if wrappedBody, ok := req.Body.(*CountingReadCloser); ok {
+		realBody = wrappedBody.ReadCloser
+	}
+
+	seeker, ok := realBody.(io.Seeker)
+	if !ok {
+		return nil, errutil.Errorf(nil, "Request body needs to be an io.Seeker to handle redirects.")
+	}
+
+	if _, err := seeker.Seek(0, os.SEEK_SET); err != nil {
+		return nil, errutil.Error(err)
+	}
+
+	return realBody, nil
+}
+
+// persistPermanentRedirect updates the git config so that later requests go
+// straight to the new location of the LFS API, when a request to the current
+// LFS endpoint was permanently redirected to locurl. This is only done if
+// lfs.persistredirects is enabled, and if the redirect kept the request path
+// relative to the endpoint, so that the new endpoint can be derived from it.
+func persistPermanentRedirect(req *http.Request, locurl *url.URL) {
+	if !config.Config.GitConfigBool("lfs.persistredirects") {
+		return
+	}
+
+	operation := auth.GetOperationForRequest(req)
+	key := config.Config.EndpointConfigKey(operation)
+	if len(key) == 0 {
+		return
+	}
+
+	endpoint := strings.TrimSuffix(config.Config.Endpoint(operation).Url, "/")
+	requested := traceableUrl(req.URL)
+	location := traceableUrl(locurl)
+	if len(endpoint) == 0 || !strings.HasPrefix(requested, endpoint) {
+		return
+	}
+
+	suffix := requested[len(endpoint):]
+	if !strings.HasSuffix(location, suffix) || len(location) == len(suffix) {
+		return
+	}
+	newEndpoint := location[:len(location)-len(suffix)]
+
+	persistedRedirectsMu.Lock()
+	defer persistedRedirectsMu.Unlock()
+
+	if persistedRedirects[key] {
+		return
+	}
+	persistedRedirects[key] = true
+
+	tracerx.Printf("api: permanent redirect, setting %s to %s", key, newEndpoint)
+	git.Config.SetLocal("", key, newEndpoint)
+}
+
+// traceableUrl returns u without its query string.
+func traceableUrl(u *url.URL) string {
+	return strings.SplitN(u.String(), "?", 2)[0]
+}
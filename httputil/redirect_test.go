@@ -0,0 +1,136 @@
+package httputil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/github/git-lfs/config"
+	"github.com/github/git-lfs/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectKeepsMethodAndBody(t *testing.T) {
+	for _, status := range []int{301, 302, 307, 308} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/old" {
+				w.Header().Set("Location", "/new")
+				w.WriteHeader(status)
+				return
+			}
+
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.Equal(t, "POST", r.Method, "status %d", status)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"), "status %d", status)
+			assert.Equal(t, `{"a":1}`, string(body), "status %d", status)
+			w.WriteHeader(200)
+		}))
+
+		res, err := doTestRedirectRequest(t, "POST", server.URL+"/old", `{"a":1}`)
+		if assert.Nil(t, err, "status %d", status) {
+			assert.Equal(t, 200, res.StatusCode, "status %d", status)
+			assert.Equal(t, "/new", res.Request.URL.Path)
+		}
+
+		server.Close()
+	}
+}
+
+func TestRedirectSeeOtherChangesToGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			w.Header().Set("Location", "/new")
+			w.WriteHeader(303)
+			return
+		}
+
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "", r.Header.Get("Content-Type"))
+		assert.Equal(t, int64(0), r.ContentLength)
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	res, err := doTestRedirectRequest(t, "POST", server.URL+"/old", `{"a":1}`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 200, res.StatusCode)
+	}
+}
+
+func TestRedirectToOtherHostDropsAuthorization(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "", r.Header.Get("Authorization"))
+		assert.Equal(t, "1", r.Header.Get("X-Custom"))
+		w.WriteHeader(200)
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Basic abc", r.Header.Get("Authorization"))
+		w.Header().Set("Location", other.URL+"/new")
+		w.WriteHeader(301)
+	}))
+	defer server.Close()
+
+	req, err := NewHttpRequest("GET", server.URL+"/old", map[string]string{
+		"Authorization": "Basic abc",
+		"X-Custom":      "1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := DoHttpRequestWithRedirects(req, nil, false)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 200, res.StatusCode)
+	}
+}
+
+func TestRedirectLimit(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Location", "/loop")
+		w.WriteHeader(302)
+	}))
+	defer server.Close()
+
+	_, err := doTestRedirectRequest(t, "POST", server.URL+"/loop", "{}")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "stopped after 3 redirects")
+	}
+	assert.Equal(t, 3, requests)
+}
+
+func TestRedirectRefusesHttpsDowngrade(t *testing.T) {
+	defer config.Config.ResetConfig()
+	config.Config.SetConfig("http.sslverify", "false")
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "http://example.com/new")
+		w.WriteHeader(301)
+	}))
+	defer server.Close()
+
+	_, err := doTestRedirectRequest(t, "GET", server.URL+"/old", "")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "insecure URL http://example.com/new")
+	}
+}
+
+func doTestRedirectRequest(t *testing.T, method, rawurl, body string) (*http.Response, error) {
+	req, err := NewHttpRequest(method, rawurl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+		req.Body = tools.NewReadSeekCloserWrapper(bytes.NewReader([]byte(body)))
+		req.ContentLength = int64(len(body))
+	}
+
+	return DoHttpRequestWithRedirects(req, nil, false)
+}
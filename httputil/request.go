@@ -5,7 +5,6 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"net/url"
 	"strings"
 
 	"github.com/github/git-lfs/auth"
@@ -42,7 +41,7 @@ func doHttpRequest(req *http.Request, creds auth.Creds) (*http.Response, error)
 	if config.Config.NtlmAccess(auth.GetOperationForRequest(req)) {
 		res, err = doNTLMRequest(req, true)
 	} else {
-		res, err = NewHttpClient(config.Config, req.Host).Do(req)
+		res, err = NewHttpClientForUrl(config.Config, req.URL).Do(req)
 	}
 
 	if res == nil {
@@ -76,6 +75,43 @@ func doHttpRequest(req *http.Request, creds auth.Creds) (*http.Response, error)
 	return res, err
 }
 
+// doAuthenticatedHttpRequest runs doHttpRequest, and if the server responds
+// with a 401, records its WWW-Authenticate challenges for the credential
+// helper. If the request was authorized with a token from the credential
+// helper, it is refreshed and the request is retried once.
+func doAuthenticatedHttpRequest(req *http.Request, creds auth.Creds) (*http.Response, error) {
+	res, err := doHttpRequest(req, creds)
+	if res == nil || res.StatusCode != 401 {
+		return res, err
+	}
+
+	auth.SetAuthChallenges(req.URL, res.Header["Www-Authenticate"])
+
+	refreshed, rerr := auth.RefreshCreds(req)
+	if rerr != nil {
+		tracerx.Printf("api: unable to refresh credentials: %s", rerr)
+		return res, err
+	}
+
+	if refreshed == nil {
+		return res, err
+	}
+
+	if req.Body != nil {
+		body, berr := rewindRequestBody(req)
+		if berr != nil {
+			return res, err
+		}
+		req.Body = body
+	}
+
+	// the 401 is not returned, so make sure its connection is released
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	return doHttpRequest(req, refreshed)
+}
+
 // DoHttpRequest performs a single HTTP request
 func DoHttpRequest(req *http.Request, useCreds bool) (*http.Response, error) {
 	var creds auth.Creds
@@ -87,10 +123,12 @@ func DoHttpRequest(req *http.Request, useCreds bool) (*http.Response, error) {
 		creds = c
 	}
 
-	return doHttpRequest(req, creds)
+	return doAuthenticatedHttpRequest(req, creds)
 }
 
-// DoHttpRequestWithRedirects runs a HTTP request and responds to redirects
+// DoHttpRequestWithRedirects runs a HTTP request and responds to redirects.
+// Credentials are looked up again for each request, so that they are not sent
+// to a different host than they were given for.
 func DoHttpRequestWithRedirects(req *http.Request, via []*http.Request, useCreds bool) (*http.Response, error) {
 	var creds auth.Creds
 	if useCreds {
@@ -101,51 +139,39 @@ func DoHttpRequestWithRedirects(req *http.Request, via []*http.Request, useCreds
 		creds = c
 	}
 
-	res, err := doHttpRequest(req, creds)
+	req = withManualRedirects(req)
+	res, err := doAuthenticatedHttpRequest(req, creds)
 	if err != nil {
 		return res, err
 	}
 
-	if res.StatusCode == 307 {
-		redirectTo := res.Header.Get("Location")
-		locurl, err := url.Parse(redirectTo)
-		if err == nil && !locurl.IsAbs() {
-			locurl = req.URL.ResolveReference(locurl)
-			redirectTo = locurl.String()
-		}
-
-		redirectedReq, err := NewHttpRequest(req.Method, redirectTo, nil)
-		if err != nil {
-			return res, errutil.Errorf(err, err.Error())
-		}
-
-		via = append(via, req)
-
-		// Avoid seeking and re-wrapping the CountingReadCloser, just get the "real" body
-		realBody := req.Body
-		if wrappedBody, ok := req.Body.(*CountingReadCloser); ok {
-			realBody = wrappedBody.ReadCloser
-		}
+	if !isRedirect(res.StatusCode) {
+		return res, nil
+	}
 
-		seeker, ok := realBody.(io.Seeker)
-		if !ok {
-			return res, errutil.Errorf(nil, "Request body needs to be an io.Seeker to handle redirects.")
-		}
+	redirectedReq, err := newRedirectRequest(req, res)
+	if err != nil {
+		return res, err
+	}
 
-		if _, err := seeker.Seek(0, 0); err != nil {
-			return res, errutil.Error(err)
-		}
-		redirectedReq.Body = realBody
-		redirectedReq.ContentLength = req.ContentLength
+	via = append(via, req)
+	if err = CheckRedirect(redirectedReq, via); err != nil {
+		return res, errutil.Error(err)
+	}
 
-		if err = CheckRedirect(redirectedReq, via); err != nil {
-			return res, errutil.Errorf(err, err.Error())
-		}
+	if redirectedReq.Body == nil {
+		redirectedReq.Header.Del("Content-Type")
+		redirectedReq.Header.Del("Content-Length")
+	}
 
-		return DoHttpRequestWithRedirects(redirectedReq, via, useCreds)
+	if len(via) == 1 && isPermanentRedirect(res.StatusCode) {
+		persistPermanentRedirect(req, redirectedReq.URL)
 	}
 
-	return res, nil
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	return DoHttpRequestWithRedirects(redirectedReq, via, useCreds)
 }
 
 // NewHttpRequest creates a template request, with the given headers & UserAgent supplied
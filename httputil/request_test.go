@@ -0,0 +1,60 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/github/git-lfs/auth"
+	"github.com/github/git-lfs/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestRefreshesRejectedToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.Header().Set("Www-Authenticate", `Bearer realm="sso"`)
+			w.WriteHeader(401)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	defer config.Config.ResetConfig()
+	config.Config.SetConfig("lfs.url", server.URL)
+
+	var fills, rejects int
+	var challenge string
+	defer auth.SetCredentialsFunc(auth.SetCredentialsFunc(func(input auth.Creds, subCommand string) (auth.Creds, error) {
+		switch subCommand {
+		case "reject":
+			rejects++
+			return nil, nil
+		case "fill":
+			fills++
+			challenge = input["wwwauth[]"]
+			return auth.Creds{
+				"protocol":   input["protocol"],
+				"host":       input["host"],
+				"authtype":   "Bearer",
+				"credential": "token-" + strconv.Itoa(fills),
+			}, nil
+		}
+		return nil, nil
+	}))
+
+	req, err := NewHttpRequest("GET", server.URL+"/locks", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := DoHttpRequestWithRedirects(req, nil, true)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 200, res.StatusCode)
+	}
+	assert.Equal(t, 2, fills)
+	assert.Equal(t, 1, rejects)
+	assert.Equal(t, `Bearer realm="sso"`, challenge)
+}
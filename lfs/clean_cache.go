@@ -0,0 +1,236 @@
+package lfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/github/git-lfs/config"
+	"github.com/github/git-lfs/tools"
+	"github.com/rubyist/tracerx"
+)
+
+// cleanCacheRacyWindow is how long before a file is cleaned that it must have
+// last been modified for the pointer to be cached. On filesystems with coarse
+// timestamps, a file modified within this window could be modified again
+// without its mtime changing.
+const cleanCacheRacyWindow = 2 * time.Second
+
+// cleanCacheEntry is the pointer that cleaning a file produced, along with the
+// stat info of the file and the clean extensions at the time.
+type cleanCacheEntry struct {
+	Path       string
+	Size       int64
+	ModTime    int64
+	Inode      uint64
+	Extensions string
+	Pointer    string
+}
+
+// LocalCleanCacheDir returns the directory that clean cache entries are kept
+// in. Each worktree has its own, since the entries depend on the files in it.
+func LocalCleanCacheDir() string {
+	return filepath.Join(config.LocalGitDir, "lfs", "cache", "clean")
+}
+
+// CachedCleanPointer returns the pointer from the last time that the file at
+// fileName was cleaned, if its size, mtime and inode in info, and the clean
+// extensions, are all unchanged since then and the object is still in the
+// local store. The file is not read.
+func CachedCleanPointer(fileName string, info os.FileInfo) (*Pointer, bool) {
+	if !config.Config.CleanCache() {
+		return nil, false
+	}
+
+	entry, err := readCleanCacheEntry(fileName)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			tracerx.Printf("clean cache: unable to read entry for %s: %v", fileName, err)
+		}
+		return nil, false
+	}
+
+	extensions, err := cleanCacheExtensions()
+	if err != nil {
+		return nil, false
+	}
+
+	if entry.Path != fileName || !entry.matches(info) || entry.Extensions != extensions {
+		tracerx.Printf("clean cache: %s has changed", fileName)
+		return nil, false
+	}
+
+	pointer, err := DecodePointer(strings.NewReader(entry.Pointer))
+	if err != nil {
+		tracerx.Printf("clean cache: invalid pointer for %s: %v", fileName, err)
+		return nil, false
+	}
+
+	if !ObjectExistsOfSize(pointer.Oid, pointer.Size) {
+		tracerx.Printf("clean cache: %s is no longer in the local store", pointer.Oid)
+		return nil, false
+	}
+
+	return pointer, true
+}
+
+// CacheCleanPointer records the pointer that cleaning the file at fileName
+// produced, where info is the stat info of the file from before it was read
+// at the started time. Nothing is cached if the file may have changed while it
+// was being cleaned, or so recently before that a later change could go
+// unnoticed, or if its contents are not what was cleaned. Git can clean
+// content that is not in the worktree under its path, such as with
+// 'git hash-object --stdin --path'.
+func CacheCleanPointer(fileName string, info os.FileInfo, started time.Time, pointer *Pointer) error {
+	if !config.Config.CleanCache() {
+		return nil
+	}
+
+	if started.Sub(info.ModTime()) <= cleanCacheRacyWindow {
+		tracerx.Printf("clean cache: not caching %s, it was modified too recently", fileName)
+		return nil
+	}
+
+	// Without extensions the pointer is the size of what was cleaned, so
+	// most content that did not come from the file is caught before it
+	// is hashed
+	if len(pointer.Extensions) == 0 && pointer.Size != info.Size() {
+		tracerx.Printf("clean cache: not caching %s, the cleaned content was not the file", fileName)
+		return nil
+	}
+
+	oid, err := cleanCacheFileOid(fileName)
+	if err != nil {
+		return err
+	}
+	if oid != cleanedOid(pointer) {
+		tracerx.Printf("clean cache: not caching %s, the cleaned content was not the file", fileName)
+		return nil
+	}
+
+	extensions, err := cleanCacheExtensions()
+	if err != nil {
+		return err
+	}
+
+	entry := &cleanCacheEntry{
+		Path:       fileName,
+		Size:       info.Size(),
+		ModTime:    info.ModTime().UnixNano(),
+		Inode:      tools.FileInode(info),
+		Extensions: extensions,
+		Pointer:    pointer.Encoded(),
+	}
+
+	after, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+	if !entry.matches(after) {
+		tracerx.Printf("clean cache: not caching %s, it changed while being cleaned", fileName)
+		return nil
+	}
+
+	return writeCleanCacheEntry(entry)
+}
+
+// cleanedOid returns the OID of the content that was cleaned to produce
+// pointer, which is the input to its first extension, if it has any.
+func cleanedOid(pointer *Pointer) string {
+	if len(pointer.Extensions) > 0 {
+		return pointer.Extensions[0].Oid
+	}
+	return pointer.Oid
+}
+
+// cleanCacheFileOid returns the OID of the contents of the file at fileName.
+func cleanCacheFileOid(fileName string) (string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := tools.NewLfsContentHash()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (e *cleanCacheEntry) matches(info os.FileInfo) bool {
+	return e.Size == info.Size() &&
+		e.ModTime == info.ModTime().UnixNano() &&
+		e.Inode == tools.FileInode(info)
+}
+
+func cleanCacheEntryPath(fileName string) string {
+	sum := sha256.Sum256([]byte(fileName))
+	return filepath.Join(LocalCleanCacheDir(), hex.EncodeToString(sum[:]))
+}
+
+func readCleanCacheEntry(fileName string) (*cleanCacheEntry, error) {
+	by, err := ioutil.ReadFile(cleanCacheEntryPath(fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &cleanCacheEntry{}
+	if err := json.Unmarshal(by, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func writeCleanCacheEntry(entry *cleanCacheEntry) error {
+	by, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	dir := LocalCleanCacheDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename, since git may clean many files at
+	// once and a partial entry must never be read
+	f, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(by)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), cleanCacheEntryPath(entry.Path))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// cleanCacheExtensions returns a digest of the configured clean extensions, so
+// that cached pointers are not used once they change.
+func cleanCacheExtensions() (string, error) {
+	extensions, err := config.Config.SortedExtensions()
+	if err != nil || len(extensions) == 0 {
+		return "", err
+	}
+
+	hash := sha256.New()
+	for _, ext := range extensions {
+		fmt.Fprintf(hash, "%s\x00%s\x00%d\n", ext.Name, ext.Clean, ext.Priority)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
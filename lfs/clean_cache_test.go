@@ -0,0 +1,141 @@
+package lfs_test // avoid import cycle
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/github/git-lfs/lfs"
+	"github.com/github/git-lfs/localstorage"
+	"github.com/github/git-lfs/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanCache(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	contents := "cached contents"
+	oid := "eef8219849897a2e0276012e9ad8d1d6e74bbff2da929beb0600a4043c2518be"
+	pointer := lfs.NewPointer(oid, int64(len(contents)), nil)
+	writeCleanCacheObject(t, oid, contents)
+
+	if err := ioutil.WriteFile("a.dat", []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(-time.Hour)
+	info := touchCleanCacheFile(t, "a.dat", modified)
+
+	_, ok := lfs.CachedCleanPointer("a.dat", info)
+	assert.False(t, ok, "nothing cached yet")
+
+	assert.Nil(t, lfs.CacheCleanPointer("a.dat", info, time.Now(), pointer))
+	cached, ok := lfs.CachedCleanPointer("a.dat", info)
+	assert.True(t, ok)
+	assert.Equal(t, pointer.Encoded(), cached.Encoded())
+
+	// the same stat info at another path is not the same file
+	_, ok = lfs.CachedCleanPointer("b.dat", info)
+	assert.False(t, ok)
+
+	// touching the file invalidates the entry
+	info = touchCleanCacheFile(t, "a.dat", modified.Add(time.Second))
+	_, ok = lfs.CachedCleanPointer("a.dat", info)
+	assert.False(t, ok)
+
+	// as does the object leaving the local store
+	assert.Nil(t, lfs.CacheCleanPointer("a.dat", info, time.Now(), pointer))
+	_, ok = lfs.CachedCleanPointer("a.dat", info)
+	assert.True(t, ok)
+	assert.Nil(t, localstorage.CurrentStore().Delete(oid))
+	_, ok = lfs.CachedCleanPointer("a.dat", info)
+	assert.False(t, ok)
+}
+
+func TestCleanCacheSkipsRacyFiles(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	contents := "racy contents"
+	oid := "ee15403ee3a53ac8b2e629abbfedf6f7cf3242abca4cd38e4fe056232afa18a0"
+	pointer := lfs.NewPointer(oid, int64(len(contents)), nil)
+	writeCleanCacheObject(t, oid, contents)
+
+	if err := ioutil.WriteFile("a.dat", []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat("a.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// just modified, so a change in the same mtime tick could be missed
+	assert.Nil(t, lfs.CacheCleanPointer("a.dat", info, time.Now(), pointer))
+	_, ok := lfs.CachedCleanPointer("a.dat", info)
+	assert.False(t, ok)
+
+	// cleaned content that was not the file
+	info = touchCleanCacheFile(t, "a.dat", time.Now().Add(-time.Hour))
+	other := lfs.NewPointer(oid, 3, nil)
+	assert.Nil(t, lfs.CacheCleanPointer("a.dat", info, time.Now(), other))
+	_, ok = lfs.CachedCleanPointer("a.dat", info)
+	assert.False(t, ok)
+}
+
+func TestCleanCacheSkipsOtherContentOfTheSameSize(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	// what was cleaned, e.g. from 'git hash-object --stdin --path a.dat'
+	contents := "stdin contents"
+	oid := "940aab5698027384e2de9f3a327445ad381651a48d548d622888cdb409b36084"
+	pointer := lfs.NewPointer(oid, int64(len(contents)), nil)
+	writeCleanCacheObject(t, oid, contents)
+
+	if err := ioutil.WriteFile("a.dat", []byte("file contents!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := touchCleanCacheFile(t, "a.dat", time.Now().Add(-time.Hour))
+	assert.Equal(t, pointer.Size, info.Size())
+
+	assert.Nil(t, lfs.CacheCleanPointer("a.dat", info, time.Now(), pointer))
+	_, ok := lfs.CachedCleanPointer("a.dat", info)
+	assert.False(t, ok)
+}
+
+func writeCleanCacheObject(t *testing.T, oid, contents string) {
+	w, err := localstorage.CurrentStore().Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(contents)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Commit(oid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func touchCleanCacheFile(t *testing.T, path string, modified time.Time) os.FileInfo {
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
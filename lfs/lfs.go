@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/github/git-lfs/config"
 	"github.com/github/git-lfs/localstorage"
@@ -53,16 +54,53 @@ func LocalMediaPathReadOnly(oid string) string {
 	return localstorage.Objects().ObjectPath(oid)
 }
 
-func LocalReferencePath(sha string) string {
-	if config.LocalReferenceDir == "" {
-		return ""
+// LocalReferencePaths returns the paths where the object with the given sha
+// may be found in other local LFS object directories: those alongside each git
+// alternate, followed by those listed in lfs.alternates.
+func LocalReferencePaths(sha string) []string {
+	dirs := append([]string{}, config.LocalReferenceDirs...)
+	dirs = append(dirs, config.Config.LfsAlternates()...)
+
+	paths := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		paths = append(paths, filepath.Join(dir, sha[0:2], sha[2:4], sha))
 	}
-	return filepath.Join(config.LocalReferenceDir, sha[0:2], sha[2:4], sha)
+	return paths
+}
+
+// LocalSharedPath returns the path of the object with the given oid in the
+// shared object cache set by lfs.storage.shared, or an empty string if there
+// is no shared cache.
+func LocalSharedPath(oid string) string {
+	if shared := localstorage.SharedObjects(); shared != nil {
+		return shared.ObjectPath(oid)
+	}
+	return ""
+}
+
+// RecordObjectAccess marks the local object with the given oid as used now, by
+// updating its modification time, so that prune --to-size removes the least
+// recently used objects first. The contents of an object never change, so its
+// modification time is otherwise unused.
+func RecordObjectAccess(oid string) {
+	now := time.Now()
+	if err := os.Chtimes(LocalMediaPathReadOnly(oid), now, now); err != nil {
+		tracerx.Printf("Unable to record access to %s: %s", oid, err)
+	}
+}
+
+// ObjectAccessTime returns the last time the local object with the given oid
+// was used, as recorded by RecordObjectAccess.
+func ObjectAccessTime(oid string) (time.Time, error) {
+	fi, err := os.Stat(LocalMediaPathReadOnly(oid))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
 }
 
 func ObjectExistsOfSize(oid string, size int64) bool {
-	path := localstorage.Objects().ObjectPath(oid)
-	return tools.FileExistsOfSize(path, size)
+	return localstorage.CurrentStore().Has(oid, size)
 }
 
 func Environ() []string {
@@ -121,7 +159,7 @@ func ClearTempObjects() error {
 }
 
 func ScanObjectsChan() <-chan localstorage.Object {
-	return localstorage.Objects().ScanObjectsChan()
+	return localstorage.ScanObjectsChan(localstorage.CurrentStore())
 }
 
 func init() {
@@ -138,20 +176,71 @@ const (
 
 // only used in tests
 func AllObjects() []localstorage.Object {
-	return localstorage.Objects().AllObjects()
+	return localstorage.AllObjects(localstorage.CurrentStore())
 }
 
 func LinkOrCopyFromReference(oid string, size int64) error {
 	if ObjectExistsOfSize(oid, size) {
 		return nil
 	}
-	altMediafile := LocalReferencePath(oid)
 	mediafile, err := LocalMediaPath(oid)
 	if err != nil {
 		return err
 	}
-	if altMediafile != "" && tools.FileExistsOfSize(altMediafile, size) {
-		return LinkOrCopy(altMediafile, mediafile)
+	if sharedMediafile := LocalSharedPath(oid); len(sharedMediafile) > 0 && localstorage.ObjectFileExistsOfSize(sharedMediafile, size) {
+		tracerx.Printf("Using %s from shared storage %s", oid, sharedMediafile)
+		return LinkOrCopy(sharedMediafile, mediafile)
+	}
+	for _, altMediafile := range LocalReferencePaths(oid) {
+		if localstorage.ObjectFileExistsOfSize(altMediafile, size) {
+			tracerx.Printf("Using %s from %s", oid, altMediafile)
+			return LinkOrCopy(altMediafile, mediafile)
+		}
 	}
 	return nil
 }
+
+// LinkToSharedStorage adds the local copy of the object with the given oid to
+// the shared object cache, if there is one and it does not have the object
+// already, so that other repositories can use it without downloading it. The
+// object is hard-linked into the cache where possible, so that it is only
+// stored once.
+func LinkToSharedStorage(oid string, size int64) error {
+	shared := localstorage.SharedObjects()
+	if shared == nil || shared.Has(oid, size) {
+		return nil
+	}
+	mediafile := LocalMediaPathReadOnly(oid)
+	if !localstorage.ObjectFileExistsOfSize(mediafile, size) {
+		return nil
+	}
+	sharedMediafile, err := shared.BuildObjectPath(oid)
+	if err != nil {
+		return err
+	}
+	tracerx.Printf("Adding %s to shared storage %s", oid, sharedMediafile)
+	return LinkOrCopy(mediafile, sharedMediafile)
+}
+
+// RemoveFromSharedStorage removes the object with the given oid from the shared
+// object cache, unless another repository still has a hard link to it. It
+// should be called once the object has been removed from this repository. If
+// the number of links can not be determined, the object is kept.
+func RemoveFromSharedStorage(oid string) error {
+	sharedMediafile := LocalSharedPath(oid)
+	if len(sharedMediafile) == 0 {
+		return nil
+	}
+	links, err := tools.HardLinkCount(sharedMediafile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if links > 1 {
+		tracerx.Printf("Keeping %s in shared storage, %d other links remain", oid, links-1)
+		return nil
+	}
+	return os.Remove(sharedMediafile)
+}
@@ -2,8 +2,10 @@ package lfs_test // avoid import cycle
 
 import (
 	"fmt"
+	"os"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/github/git-lfs/lfs"
 	"github.com/github/git-lfs/test"
@@ -67,3 +69,32 @@ func TestAllCurrentObjectsSome(t *testing.T) {
 	assert.Equal(t, expected, actual, "Oids from disk should be the same as in commits")
 
 }
+
+func TestRecordObjectAccess(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	outputs := repo.AddCommits([]*test.CommitInput{
+		{Files: []*test.FileInput{{Filename: "file.txt", Size: 30}}},
+	})
+	oid := outputs[0].Files[0].Oid
+
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+	if err := os.Chtimes(lfs.LocalMediaPathReadOnly(oid), lastWeek, lastWeek); err != nil {
+		t.Fatal(err)
+	}
+
+	accessTime, err := lfs.ObjectAccessTime(oid)
+	assert.Nil(t, err)
+	assert.WithinDuration(t, lastWeek, accessTime, time.Second)
+
+	lfs.RecordObjectAccess(oid)
+
+	accessTime, err = lfs.ObjectAccessTime(oid)
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now(), accessTime, time.Minute)
+}
@@ -0,0 +1,140 @@
+package lfs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/github/git-lfs/config"
+	"github.com/rubyist/tracerx"
+)
+
+// PinType is the kind of thing that a pin keeps from being pruned.
+type PinType string
+
+const (
+	// PinTypeObject pins a single object, which may have been pinned by the
+	// path it was at.
+	PinTypeObject = PinType("oid")
+	// PinTypeRef pins every object at a ref, wherever the ref points to when
+	// prune is run.
+	PinTypeRef = PinType("ref")
+)
+
+// A Pin protects objects from `git lfs prune`. Pins are added with `git lfs
+// pin` and stored one per line in LocalPinFile().
+type Pin struct {
+	Type PinType
+	// The oid of an object pin, or the name of a ref pin
+	Value string
+	// The path an object was pinned at, if any
+	Path string
+}
+
+func (p *Pin) String() string {
+	if len(p.Path) > 0 {
+		return fmt.Sprintf("%s %s %s", p.Type, p.Value, p.Path)
+	}
+	return fmt.Sprintf("%s %s", p.Type, p.Value)
+}
+
+// LocalPinFile returns the file that pins are stored in. It is alongside the
+// objects so that every worktree shares the same pins.
+func LocalPinFile() string {
+	return filepath.Join(config.LocalGitStorageDir, "lfs", "pins")
+}
+
+// ReadPins returns the pins in LocalPinFile(), which are none if it does not
+// exist.
+func ReadPins() ([]*Pin, error) {
+	f, err := os.Open(LocalPinFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	return readPins(f)
+}
+
+// WritePins replaces the pins in LocalPinFile() with the given pins, removing
+// the file if there are none.
+func WritePins(pins []*Pin) error {
+	path := LocalPinFile()
+	if len(pins) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-pins")
+	if err != nil {
+		return err
+	}
+
+	err = writePins(f, pins)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func readPins(r io.Reader) ([]*Pin, error) {
+	var pins []*Pin
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// the path is last so that it may contain spaces
+		parts := strings.SplitN(line, " ", 3)
+		if len(parts) < 2 {
+			tracerx.Printf("Invalid pin: %q", line)
+			continue
+		}
+
+		pin := &Pin{Type: PinType(parts[0]), Value: parts[1]}
+		switch pin.Type {
+		case PinTypeObject:
+			if len(parts) == 3 {
+				pin.Path = parts[2]
+			}
+		case PinTypeRef:
+		default:
+			tracerx.Printf("Unknown pin type: %q", line)
+			continue
+		}
+		pins = append(pins, pin)
+	}
+
+	return pins, scanner.Err()
+}
+
+func writePins(w io.Writer, pins []*Pin) error {
+	for _, pin := range pins {
+		if _, err := fmt.Fprintln(w, pin.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package lfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/github/git-lfs/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPins(t *testing.T) {
+	pins, err := readPins(strings.NewReader(`
+# comment
+oid 4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393
+oid 6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b path/with spaces.bin
+ref refs/tags/v1.0
+ref
+tag refs/tags/v2.0
+`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*Pin{
+		{Type: PinTypeObject, Value: "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"},
+		{Type: PinTypeObject, Value: "6b86b273ff34fce19d6b804eff5a3f5747ada4eaa22f1d49c01e52ddb7875b4b", Path: "path/with spaces.bin"},
+		{Type: PinTypeRef, Value: "refs/tags/v1.0"},
+	}, pins)
+}
+
+func TestWritePinsRoundTrip(t *testing.T) {
+	pins := []*Pin{
+		{Type: PinTypeObject, Value: "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393", Path: "a b.bin"},
+		{Type: PinTypeRef, Value: "refs/heads/release/1.0"},
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, writePins(&buf, pins))
+	assert.Equal(t, "oid 4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393 a b.bin\n"+
+		"ref refs/heads/release/1.0\n", buf.String())
+
+	read, err := readPins(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, pins, read)
+}
+
+func TestPinFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lfs-pins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldStorageDir := config.LocalGitStorageDir
+	config.LocalGitStorageDir = dir
+	defer func() { config.LocalGitStorageDir = oldStorageDir }()
+
+	pins, err := ReadPins()
+	assert.Nil(t, err)
+	assert.Empty(t, pins)
+
+	pins = []*Pin{{Type: PinTypeRef, Value: "refs/tags/v1.0"}}
+	assert.Nil(t, WritePins(pins))
+	read, err := ReadPins()
+	assert.Nil(t, err)
+	assert.Equal(t, pins, read)
+
+	assert.Nil(t, WritePins(nil))
+	_, err = os.Stat(LocalPinFile())
+	assert.True(t, os.IsNotExist(err))
+}
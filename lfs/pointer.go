@@ -83,6 +83,19 @@ func (p *Pointer) Encoded() string {
 	return buffer.String()
 }
 
+// IsCanonical returns whether data is exactly the encoding of the pointer, as
+// written by Encoded, except that the version line may name any of the
+// versions that are read as the current one.
+func (p *Pointer) IsCanonical(data []byte) bool {
+	encoded := p.Encoded()
+	for _, v := range v1Aliases {
+		if string(data) == strings.Replace(encoded, "version "+latest, "version "+v, 1) {
+			return true
+		}
+	}
+	return false
+}
+
 func EncodePointer(writer io.Writer, pointer *Pointer) (int, error) {
 	return writer.Write([]byte(pointer.Encoded()))
 }
@@ -9,13 +9,20 @@ import (
 
 	"github.com/github/git-lfs/config"
 	"github.com/github/git-lfs/errutil"
+	"github.com/github/git-lfs/localstorage"
 	"github.com/github/git-lfs/progress"
 	"github.com/github/git-lfs/tools"
 )
 
+// cleanedAsset is a pointer along with the cleaned content it points to, which
+// is not in the local store until Commit is called. Teardown must always be
+// called, to discard the content if it was not committed.
 type cleanedAsset struct {
-	Filename string
 	*Pointer
+	// writer holds the content when it was written straight to the store
+	writer localstorage.ObjectWriter
+	// filename is the temp file holding the output of clean extensions
+	filename string
 }
 
 func PointerClean(reader io.Reader, fileName string, fileSize int64, cb progress.CopyCallback) (*cleanedAsset, error) {
@@ -24,68 +31,68 @@ func PointerClean(reader io.Reader, fileName string, fileSize int64, cb progress
 		return nil, err
 	}
 
-	var oid string
-	var size int64
-	var tmp *os.File
-	var exts []*PointerExtension
-	if len(extensions) > 0 {
-		request := &pipeRequest{"clean", reader, fileName, extensions}
-
-		var response pipeResponse
-		if response, err = pipeExtensions(request); err != nil {
+	if len(extensions) == 0 {
+		oid, size, w, err := copyToStore(reader, fileSize, cb)
+		if err != nil {
 			return nil, err
 		}
+		return &cleanedAsset{Pointer: NewPointer(oid, size, nil), writer: w}, nil
+	}
 
-		oid = response.results[len(response.results)-1].oidOut
-		tmp = response.file
-		var stat os.FileInfo
-		if stat, err = os.Stat(tmp.Name()); err != nil {
-			return nil, err
-		}
-		size = stat.Size()
+	request := &pipeRequest{"clean", reader, fileName, extensions}
 
-		for _, result := range response.results {
-			if result.oidIn != result.oidOut {
-				ext := NewPointerExtension(result.name, len(exts), result.oidIn)
-				exts = append(exts, ext)
-			}
-		}
-	} else {
-		oid, size, tmp, err = copyToTemp(reader, fileSize, cb)
-		if err != nil {
-			return nil, err
+	var response pipeResponse
+	if response, err = pipeExtensions(request); err != nil {
+		return nil, err
+	}
+
+	oid := response.results[len(response.results)-1].oidOut
+	tmp := response.file
+	stat, err := os.Stat(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	var exts []*PointerExtension
+	for _, result := range response.results {
+		if result.oidIn != result.oidOut {
+			ext := NewPointerExtension(result.name, len(exts), result.oidIn)
+			exts = append(exts, ext)
 		}
 	}
 
-	pointer := NewPointer(oid, size, exts)
-	return &cleanedAsset{tmp.Name(), pointer}, err
+	pointer := NewPointer(oid, stat.Size(), exts)
+	return &cleanedAsset{Pointer: pointer, filename: tmp.Name()}, nil
 }
 
-func copyToTemp(reader io.Reader, fileSize int64, cb progress.CopyCallback) (oid string, size int64, tmp *os.File, err error) {
-	tmp, err = TempFile("")
-	if err != nil {
+// copyToStore hashes the content from reader as it writes it to a new object
+// in the local store, so that it only has to be written once. The store writes
+// it to a temp file alongside the objects, which Commit renames into place.
+func copyToStore(reader io.Reader, fileSize int64, cb progress.CopyCallback) (oid string, size int64, w localstorage.ObjectWriter, err error) {
+	by, ptr, err := DecodeFrom(reader)
+	if err == nil && len(by) < 512 {
+		err = errutil.NewCleanPointerError(err, ptr, by)
 		return
 	}
 
-	defer tmp.Close()
+	w, err = localstorage.CurrentStore().Create()
+	if err != nil {
+		return
+	}
 
 	oidHash := sha256.New()
-	writer := io.MultiWriter(oidHash, tmp)
+	writer := io.MultiWriter(oidHash, w)
 
 	if fileSize == 0 {
 		cb = nil
 	}
 
-	by, ptr, err := DecodeFrom(reader)
-	if err == nil && len(by) < 512 {
-		err = errutil.NewCleanPointerError(err, ptr, by)
-		return
-	}
-
 	multi := io.MultiReader(bytes.NewReader(by), reader)
 	size, err = tools.CopyWithCallback(writer, multi, fileSize, cb)
 
 	if err != nil {
+		w.Close()
+		w = nil
 		return
 	}
 
@@ -93,6 +100,22 @@ func copyToTemp(reader io.Reader, fileSize int64, cb progress.CopyCallback) (oid
 	return
 }
 
+// Commit adds the cleaned content to the local store as the object a.Oid,
+// replacing any existing object.
+func (a *cleanedAsset) Commit() error {
+	if a.writer != nil {
+		return a.writer.Commit(a.Oid)
+	}
+	return localstorage.ImportFile(localstorage.CurrentStore(), a.Oid, a.filename)
+}
+
+// Teardown discards the cleaned content, unless it was committed.
 func (a *cleanedAsset) Teardown() error {
-	return os.Remove(a.Filename)
+	if a.writer != nil {
+		return a.writer.Close()
+	}
+	if err := os.Remove(a.filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,70 @@
+package lfs_test // avoid import cycle
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/github/git-lfs/lfs"
+	"github.com/github/git-lfs/localstorage"
+	"github.com/github/git-lfs/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPointerCleanCommitsToStore(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	contents := strings.Repeat("streamed ", 100)
+	cleaned, err := lfs.PointerClean(strings.NewReader(contents), "a.dat", int64(len(contents)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleaned.Teardown()
+
+	assert.Equal(t, int64(len(contents)), cleaned.Size)
+	assert.False(t, lfs.ObjectExistsOfSize(cleaned.Oid, cleaned.Size), "not in the store until committed")
+
+	assert.Nil(t, cleaned.Commit())
+	assert.Nil(t, cleaned.Teardown())
+
+	r, err := localstorage.CurrentStore().Open(cleaned.Oid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	by, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, contents, string(by))
+	assertNoStoreTempFiles(t)
+}
+
+func TestPointerCleanTeardownDiscardsContent(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	contents := strings.Repeat("discarded ", 100)
+	cleaned, err := lfs.PointerClean(strings.NewReader(contents), "a.dat", int64(len(contents)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, cleaned.Teardown())
+	assert.False(t, lfs.ObjectExistsOfSize(cleaned.Oid, cleaned.Size))
+	assertNoStoreTempFiles(t)
+}
+
+func assertNoStoreTempFiles(t *testing.T) {
+	matches, err := filepath.Glob(filepath.Join(lfs.LocalMediaDir(), ".tmp-*"))
+	assert.Nil(t, err)
+	assert.Empty(t, matches)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/github/git-lfs/api"
 	"github.com/github/git-lfs/config"
 	"github.com/github/git-lfs/errutil"
+	"github.com/github/git-lfs/localstorage"
 	"github.com/github/git-lfs/progress"
 	"github.com/rubyist/tracerx"
 )
@@ -45,18 +46,17 @@ func PointerSmudge(writer io.Writer, ptr *Pointer, workingfile string, download
 
 	LinkOrCopyFromReference(ptr.Oid, ptr.Size)
 
-	stat, statErr := os.Stat(mediafile)
+	store := localstorage.CurrentStore()
+	obj, statErr := store.Stat(ptr.Oid)
+	exists := statErr == nil
 
-	if statErr == nil && stat != nil {
-		fileSize := stat.Size()
-		if fileSize == 0 || fileSize != ptr.Size {
-			tracerx.Printf("Removing %s, size %d is invalid", mediafile, fileSize)
-			os.RemoveAll(mediafile)
-			stat = nil
-		}
+	if exists && (obj.Size == 0 || obj.Size != ptr.Size) {
+		tracerx.Printf("Removing %s, size %d is invalid", mediafile, obj.Size)
+		store.Delete(ptr.Oid)
+		exists = false
 	}
 
-	if statErr != nil || stat == nil {
+	if !exists {
 		if download {
 			err = downloadFile(writer, ptr, workingfile, mediafile, cb)
 		} else {
@@ -104,19 +104,25 @@ func downloadFile(writer io.Writer, ptr *Pointer, workingfile, mediafile string,
 		return errutil.Errorf(err, "Error buffering media file: %s", res.Error)
 	}
 
+	if err := LinkToSharedStorage(ptr.Oid, ptr.Size); err != nil {
+		tracerx.Printf("Unable to add %s to shared storage: %s", ptr.Oid, err)
+	}
+
 	return readLocalFile(writer, ptr, mediafile, workingfile, nil)
 }
 
 func readLocalFile(writer io.Writer, ptr *Pointer, mediafile string, workingfile string, cb progress.CopyCallback) error {
-	reader, err := os.Open(mediafile)
+	store := localstorage.CurrentStore()
+	reader, err := store.Open(ptr.Oid)
 	if err != nil {
 		return errutil.Errorf(err, "Error opening media file.")
 	}
 	defer reader.Close()
+	RecordObjectAccess(ptr.Oid)
 
 	if ptr.Size == 0 {
-		if stat, _ := os.Stat(mediafile); stat != nil {
-			ptr.Size = stat.Size()
+		if obj, err := store.Stat(ptr.Oid); err == nil {
+			ptr.Size = obj.Size
 		}
 	}
 
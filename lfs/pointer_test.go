@@ -74,6 +74,19 @@ func assertLine(t *testing.T, r *bufio.Reader, expected string) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestIsCanonical(t *testing.T) {
+	pointer := NewPointer("4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393", 12345, nil)
+	body := "oid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 12345\n"
+
+	assert.True(t, pointer.IsCanonical([]byte("version https://git-lfs.github.com/spec/v1\n"+body)))
+	assert.True(t, pointer.IsCanonical([]byte("version https://hawser.github.com/spec/v1\n"+body)))
+	assert.True(t, pointer.IsCanonical([]byte("version http://git-media.io/v/2\n"+body)))
+
+	assert.False(t, pointer.IsCanonical([]byte("version https://git-lfs.github.com/spec/v1\n"+strings.TrimSuffix(body, "\n"))))
+	assert.False(t, pointer.IsCanonical([]byte("version https://example.com/spec/v1\n"+body)))
+	assert.False(t, pointer.IsCanonical([]byte("version  https://git-lfs.github.com/spec/v1\n"+body)))
+}
+
 func TestDecodeTinyFile(t *testing.T) {
 	ex := "this is not a git-lfs file!"
 	p, err := DecodePointer(bytes.NewBufferString(ex))
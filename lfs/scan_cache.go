@@ -0,0 +1,424 @@
+package lfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/github/git-lfs/config"
+	"github.com/github/git-lfs/subprocess"
+	"github.com/rubyist/tracerx"
+)
+
+const (
+	// scanCacheVersion is recorded in each scan cache entry. It must be
+	// changed whenever what is cached for a tree or commit changes, such as
+	// blobSizeCutoff or logLfsSearchArgs, so that older entries are not used.
+	scanCacheVersion = 1
+
+	// scanCacheMaxAge is how long a scan cache entry may go unused before
+	// PruneScanCache removes it.
+	scanCacheMaxAge = 30 * 24 * time.Hour
+
+	// scanCacheTouchAge is how old the mtime of an entry must be for it to be
+	// updated when the entry is used, so that each use doesn't write to disk.
+	scanCacheTouchAge = 24 * time.Hour
+)
+
+// treeCacheEntry is every Git LFS pointer in a tree and the trees under it, as
+// found by scanTree.
+type treeCacheEntry struct {
+	Version  int
+	Tree     string
+	Pointers []treeCachePointer
+}
+
+type treeCachePointer struct {
+	Sha1    string
+	Name    string
+	Pointer string
+}
+
+// commitCacheEntry is the output of `git log` with logLfsSearchArgs for a
+// single commit, which is empty if it changes no pointers.
+type commitCacheEntry struct {
+	Version int
+	Commit  string
+	Log     string
+}
+
+// LocalScanCacheDir returns the directory that scan cache entries are kept in.
+// They only depend on git objects, so are shared by every worktree.
+func LocalScanCacheDir() string {
+	return filepath.Join(config.LocalGitStorageDir, "lfs", "cache", "scan")
+}
+
+// scanCacheTree returns the sha of the tree at ref, if the scan cache is in
+// use and ref can be resolved. Otherwise it returns an empty string.
+func scanCacheTree(ref string) string {
+	if !config.Config.ScanCache() || len(config.LocalGitStorageDir) == 0 {
+		return ""
+	}
+
+	tree, err := subprocess.SimpleExec("git", "rev-parse", "--verify", "--quiet", ref+"^{tree}")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(tree)
+}
+
+// scanTreeCached does the same as scanTree, using the scan cache entry for the
+// tree if there is one, and adding one if not.
+func scanTreeCached(ctx context.Context, tree string) ([]*WrappedPointer, error) {
+	if pointers, ok := readTreeCache(tree); ok {
+		tracerx.Printf("scan cache: using pointers in tree %s", tree)
+		return pointers, nil
+	}
+
+	pointers, err := scanTree(ctx, tree)
+	if err != nil {
+		return pointers, err
+	}
+
+	if err := writeTreeCache(tree, pointers); err != nil {
+		tracerx.Printf("scan cache: unable to cache tree %s: %v", tree, err)
+	}
+	return pointers, nil
+}
+
+// scanRefTreeCached does the same as ScanRefsToChan for a single ref without
+// its history, using the pointers in the ref's tree from scanTreeCached. Like
+// `git rev-list --objects`, each blob is reported once, with the first path it
+// is found at.
+func scanRefTreeCached(ctx context.Context, tree string, opt *ScanRefsOptions) (*PointerChannelWrapper, error) {
+	pointers, err := scanTreeCached(ctx, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(pointers))
+	unique := make(chan *WrappedPointer, len(pointers))
+	for _, p := range pointers {
+		if seen[p.Sha1] {
+			continue
+		}
+		seen[p.Sha1] = true
+		opt.SetName(p.Sha1, p.Name)
+		unique <- p
+	}
+	close(unique)
+
+	errchan := make(chan error)
+	close(errchan)
+	return NewPointerChannelWrapper(unique, errchan), nil
+}
+
+// logPointersCached does the same as parsing the output of `git log` with
+// logLfsSearchArgs and revArgs, using the scan cache entry for each commit
+// that has one, and only running `git log` for the others.
+func logPointersCached(ctx context.Context, revArgs []string, dir LogDiffDirection) (*PointerChannelWrapper, error) {
+	commits, err := revListCommits(ctx, revArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make(map[string]string, len(commits))
+	var uncached []string
+	for _, commit := range commits {
+		if log, ok := readCommitCache(commit); ok {
+			logs[commit] = log
+		} else {
+			uncached = append(uncached, commit)
+		}
+	}
+	tracerx.Printf("scan cache: %d of %d commits cached", len(commits)-len(uncached), len(commits))
+
+	if len(uncached) > 0 {
+		fresh, err := logCommits(ctx, uncached)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, commit := range uncached {
+			logs[commit] = fresh[commit]
+			if err := writeCommitCache(commit, fresh[commit]); err != nil {
+				tracerx.Printf("scan cache: unable to cache commit %s: %v", commit, err)
+			}
+		}
+	}
+
+	var log bytes.Buffer
+	for _, commit := range commits {
+		log.WriteString(logs[commit])
+	}
+
+	pchan := make(chan *WrappedPointer, chanBufSize)
+	errchan := make(chan error, 1)
+
+	go func() {
+		parseLogOutputToPointers(ctx, &log, dir, nil, nil, pchan)
+		if err := ctx.Err(); err != nil {
+			errchan <- err
+		}
+		close(pchan)
+		close(errchan)
+	}()
+
+	return NewPointerChannelWrapper(pchan, errchan), nil
+}
+
+// revListCommits returns the commits that `git rev-list` lists for revArgs.
+func revListCommits(ctx context.Context, revArgs []string) ([]string, error) {
+	cmd, err := startCommand(ctx, "git", append([]string{"rev-list"}, revArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.Stdin.Close()
+
+	var commits []string
+	scanner := bufio.NewScanner(cmd.Stdout)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); len(line) > 0 {
+			commits = append(commits, line)
+		}
+	}
+
+	if _, err := cmd.waitScan("git rev-list"); err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
+
+// logCommits runs `git log` with logLfsSearchArgs for the given commits alone,
+// and returns its output split up by commit. Commits which change no pointers
+// are left out.
+func logCommits(ctx context.Context, commits []string) (map[string]string, error) {
+	logArgs := append([]string{"log", "--no-walk=unsorted", "--stdin"}, logLfsSearchArgs...)
+	cmd, err := startCommand(ctx, "git", logArgs...)
+	if err != nil {
+		return nil, err
+	}
+
+	// write on another goroutine, since git writes its output as it reads
+	go func() {
+		for _, commit := range commits {
+			cmd.Stdin.Write([]byte(commit + "\n"))
+		}
+		cmd.Stdin.Close()
+	}()
+
+	logs := make(map[string]string, len(commits))
+	var commit string
+	var log bytes.Buffer
+	finishCommit := func() {
+		if len(commit) > 0 {
+			logs[commit] = log.String()
+		}
+		log.Reset()
+	}
+
+	for {
+		line, err := cmd.Stdout.ReadString('\n')
+		if strings.HasPrefix(line, logCommitHeader) {
+			finishCommit()
+			if fields := strings.Fields(line[len(logCommitHeader):]); len(fields) > 0 {
+				commit = fields[0]
+			}
+		}
+		log.WriteString(line)
+
+		if err != nil {
+			break
+		}
+	}
+	finishCommit()
+
+	if _, err := cmd.waitScan("git log"); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// readTreeCache returns the pointers in the scan cache entry for tree, if there
+// is one.
+func readTreeCache(tree string) ([]*WrappedPointer, bool) {
+	entry := &treeCacheEntry{}
+	if !readScanCacheEntry("trees", tree, entry) {
+		return nil, false
+	}
+
+	if entry.Tree != tree {
+		removeScanCacheEntry("trees", tree, fmt.Errorf("entry is for tree %s", entry.Tree))
+		return nil, false
+	}
+
+	pointers := make([]*WrappedPointer, 0, len(entry.Pointers))
+	for _, cached := range entry.Pointers {
+		p, err := DecodePointer(strings.NewReader(cached.Pointer))
+		if err != nil {
+			removeScanCacheEntry("trees", tree, err)
+			return nil, false
+		}
+
+		pointers = append(pointers, &WrappedPointer{
+			Sha1:    cached.Sha1,
+			Name:    cached.Name,
+			Size:    p.Size,
+			Pointer: p,
+		})
+	}
+	return pointers, true
+}
+
+func writeTreeCache(tree string, pointers []*WrappedPointer) error {
+	entry := &treeCacheEntry{
+		Version:  scanCacheVersion,
+		Tree:     tree,
+		Pointers: make([]treeCachePointer, 0, len(pointers)),
+	}
+	for _, p := range pointers {
+		entry.Pointers = append(entry.Pointers, treeCachePointer{
+			Sha1:    p.Sha1,
+			Name:    p.Name,
+			Pointer: p.Pointer.Encoded(),
+		})
+	}
+	return writeScanCacheEntry("trees", tree, entry)
+}
+
+// readCommitCache returns the log in the scan cache entry for commit, if there
+// is one.
+func readCommitCache(commit string) (string, bool) {
+	entry := &commitCacheEntry{}
+	if !readScanCacheEntry("commits", commit, entry) {
+		return "", false
+	}
+
+	if entry.Commit != commit {
+		removeScanCacheEntry("commits", commit, fmt.Errorf("entry is for commit %s", entry.Commit))
+		return "", false
+	}
+	if len(entry.Log) > 0 && !strings.HasPrefix(entry.Log, logCommitHeader+commit) {
+		removeScanCacheEntry("commits", commit, fmt.Errorf("log is not for commit %s", commit))
+		return "", false
+	}
+	return entry.Log, true
+}
+
+func writeCommitCache(commit, log string) error {
+	return writeScanCacheEntry("commits", commit, &commitCacheEntry{
+		Version: scanCacheVersion,
+		Commit:  commit,
+		Log:     log,
+	})
+}
+
+func scanCacheEntryPath(kind, sha string) string {
+	if len(sha) < 3 {
+		return filepath.Join(LocalScanCacheDir(), kind, sha)
+	}
+	return filepath.Join(LocalScanCacheDir(), kind, sha[0:2], sha[2:])
+}
+
+// readScanCacheEntry decodes the scan cache entry of the given kind for sha
+// into entry, and returns whether it is there and is for this version of Git
+// LFS. Entries which cannot be decoded are removed, so they are rewritten.
+func readScanCacheEntry(kind, sha string, entry interface{}) bool {
+	path := scanCacheEntryPath(kind, sha)
+	by, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			tracerx.Printf("scan cache: unable to read %s %s: %v", kind, sha, err)
+		}
+		return false
+	}
+
+	var version struct{ Version int }
+	if err := json.Unmarshal(by, &version); err != nil {
+		removeScanCacheEntry(kind, sha, err)
+		return false
+	}
+	if version.Version != scanCacheVersion {
+		removeScanCacheEntry(kind, sha, fmt.Errorf("version %d", version.Version))
+		return false
+	}
+	if err := json.Unmarshal(by, entry); err != nil {
+		removeScanCacheEntry(kind, sha, err)
+		return false
+	}
+
+	// keep entries in use from being pruned
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > scanCacheTouchAge {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+	}
+	return true
+}
+
+func removeScanCacheEntry(kind, sha string, reason error) {
+	tracerx.Printf("scan cache: removing invalid entry for %s %s: %v", kind, sha, reason)
+	os.Remove(scanCacheEntryPath(kind, sha))
+}
+
+func writeScanCacheEntry(kind, sha string, entry interface{}) error {
+	by, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	path := scanCacheEntryPath(kind, sha)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename, since other processes may be reading
+	// or writing the same entry
+	f, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(by)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// PruneScanCache removes the scan cache entries which have not been used for
+// a while, along with any temp files left by processes which were killed.
+func PruneScanCache() error {
+	dir := LocalScanCacheDir()
+	before := time.Now().Add(-scanCacheMaxAge)
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if info.Mode().IsRegular() && info.ModTime().Before(before) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	})
+}
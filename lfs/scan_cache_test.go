@@ -0,0 +1,211 @@
+package lfs_test // avoid import cycle
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/github/git-lfs/config"
+	"github.com/github/git-lfs/lfs"
+	"github.com/github/git-lfs/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanTreeCache(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	repo.AddCommits([]*test.CommitInput{
+		{
+			Files: []*test.FileInput{
+				{Filename: "file1.txt", Size: 20},
+				{Filename: "dir/file2.txt", Data: "copied contents", Size: 15},
+				{Filename: "dir/copy.txt", Data: "copied contents", Size: 15},
+			},
+		},
+	})
+
+	config.Config.SetConfig("lfs.scancache", "false")
+	expected, err := lfs.ScanTree(context.Background(), "master")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(expected))
+	assert.Equal(t, 0, len(scanCacheEntries(t, "trees")))
+	config.Config.ResetConfig()
+
+	// once to cache the tree, and again to use the cached pointers
+	for i := 0; i < 2; i++ {
+		pointers, err := lfs.ScanTree(context.Background(), "master")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, pointers)
+	}
+
+	entries := scanCacheEntries(t, "trees")
+	assert.Equal(t, 1, len(entries))
+
+	tree := strings.TrimSpace(test.RunGitCommand(t, true, "rev-parse", "master^{tree}"))
+	cached := lfs.NewPointer("4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393", 7, nil)
+	writeScanCacheEntry(t, entries[0], fmt.Sprintf(`{"Version":1,"Tree":%q,"Pointers":[{"Sha1":"abc","Name":"cached.dat","Pointer":%q}]}`, tree, cached.Encoded()))
+
+	pointers, err := lfs.ScanTree(context.Background(), "master")
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(pointers)) {
+		assert.Equal(t, "cached.dat", pointers[0].Name)
+		assert.Equal(t, cached.Oid, pointers[0].Oid)
+	}
+
+	// the same tree from the history of a ref is the same pointers, once each
+	opt := lfs.NewScanRefsOptions()
+	opt.SkipDeletedBlobs = true
+	pointers, err = lfs.ScanRefs(context.Background(), "master", "", opt)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(pointers))
+
+	// entries which are corrupt, or for another tree, are replaced
+	for _, invalid := range []string{
+		"garbage",
+		`{"Version":1,"Tree":"0000000000000000000000000000000000000000","Pointers":[]}`,
+		fmt.Sprintf(`{"Version":1,"Tree":%q,"Pointers":[{"Sha1":"abc","Name":"a.dat","Pointer":"not a pointer"}]}`, tree),
+		fmt.Sprintf(`{"Version":0,"Tree":%q,"Pointers":[]}`, tree),
+	} {
+		writeScanCacheEntry(t, entries[0], invalid)
+
+		pointers, err = lfs.ScanTree(context.Background(), "master")
+		assert.Nil(t, err)
+		assert.Equal(t, expected, pointers, "with entry %q", invalid)
+		assert.Equal(t, entries, scanCacheEntries(t, "trees"))
+	}
+
+	opt = lfs.NewScanRefsOptions()
+	opt.SkipDeletedBlobs = true
+	pointers, err = lfs.ScanRefs(context.Background(), "master", "", opt)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(pointers))
+}
+
+func TestScanCommitCache(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	now := time.Now()
+	repo.AddCommits([]*test.CommitInput{
+		{
+			CommitDate: now.AddDate(0, 0, -6),
+			Files: []*test.FileInput{
+				{Filename: "file1.txt", Size: 20},
+				{Filename: "file2.txt", Size: 30},
+			},
+		},
+		{
+			CommitDate: now.AddDate(0, 0, -4),
+			Files: []*test.FileInput{
+				{Filename: "file1.txt", Size: 21},
+			},
+		},
+		{
+			CommitDate: now.AddDate(0, 0, -2),
+			Files: []*test.FileInput{
+				{Filename: "file1.txt", Size: 22},
+				{Filename: "file2.txt", Size: 32},
+			},
+		},
+	})
+	// a commit which changes no pointers
+	test.RunGitCommand(t, true, "commit", "--allow-empty", "-m", "empty")
+
+	since := now.AddDate(0, 0, -7)
+	scan := func() ([]*lfs.WrappedPointer, []*lfs.WrappedPointer) {
+		previous, err := lfs.ScanPreviousVersions(context.Background(), "master", since)
+		assert.Nil(t, err)
+		unpushed, err := lfs.ScanUnpushed(context.Background(), "")
+		assert.Nil(t, err)
+		sort.Sort(test.WrappedPointersByOid(previous))
+		sort.Sort(test.WrappedPointersByOid(unpushed))
+		return previous, unpushed
+	}
+
+	config.Config.SetConfig("lfs.scancache", "false")
+	expectedPrevious, expectedUnpushed := scan()
+	assert.Equal(t, 3, len(expectedPrevious))
+	assert.Equal(t, 5, len(expectedUnpushed))
+	assert.Equal(t, 0, len(scanCacheEntries(t, "commits")))
+	config.Config.ResetConfig()
+
+	for i := 0; i < 2; i++ {
+		previous, unpushed := scan()
+		assert.Equal(t, expectedPrevious, previous)
+		assert.Equal(t, expectedUnpushed, unpushed)
+	}
+
+	entries := scanCacheEntries(t, "commits")
+	assert.Equal(t, 4, len(entries))
+
+	// corrupt entries are replaced
+	for _, entry := range entries {
+		writeScanCacheEntry(t, entry, `{"Version":1,"Commit":"0000000000000000000000000000000000000000","Log":""}`)
+	}
+	previous, unpushed := scan()
+	assert.Equal(t, expectedPrevious, previous)
+	assert.Equal(t, expectedUnpushed, unpushed)
+
+	for _, entry := range entries {
+		by, err := ioutil.ReadFile(entry)
+		assert.Nil(t, err)
+		assert.NotContains(t, string(by), "0000000000000000000000000000000000000000")
+	}
+}
+
+func TestPruneScanCache(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	repo.AddCommits([]*test.CommitInput{
+		{Files: []*test.FileInput{{Filename: "file1.txt", Size: 20}}},
+		{Files: []*test.FileInput{{Filename: "file1.txt", Size: 21}}},
+	})
+
+	_, err := lfs.ScanTree(context.Background(), "master")
+	assert.Nil(t, err)
+	_, err = lfs.ScanTree(context.Background(), "master^")
+	assert.Nil(t, err)
+
+	entries := scanCacheEntries(t, "trees")
+	assert.Equal(t, 2, len(entries))
+
+	unused := time.Now().AddDate(0, 0, -31)
+	assert.Nil(t, os.Chtimes(entries[0], unused, unused))
+
+	assert.Nil(t, lfs.PruneScanCache())
+	assert.Equal(t, entries[1:], scanCacheEntries(t, "trees"))
+}
+
+func scanCacheEntries(t *testing.T, kind string) []string {
+	entries, err := filepath.Glob(filepath.Join(lfs.LocalScanCacheDir(), kind, "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func writeScanCacheEntry(t *testing.T, path, contents string) {
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,6 +3,7 @@ package lfs
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +15,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/github/git-lfs/config"
 	"github.com/github/git-lfs/git"
 	"github.com/rubyist/tracerx"
 )
@@ -29,6 +31,10 @@ const (
 	// chanBufSize is the size of the channels used to pass data from one
 	// sub-process to another.
 	chanBufSize = 100
+
+	// logCommitHeader starts the line for each commit in git log output
+	// formatted by logLfsSearchArgs
+	logCommitHeader = "lfs-commit-sha: "
 )
 
 var (
@@ -38,7 +44,7 @@ var (
 		"-G", "oid sha256:", // only diffs which include an lfs file SHA change
 		"-p",   // include diff so we can read the SHA
 		"-U12", // Make sure diff context is always big enough to support 10 extension lines to get whole pointer
-		`--format=lfs-commit-sha: %H %P`, // just a predictable commit header we can detect
+		"--format=" + logCommitHeader + "%H %P", // just a predictable commit header we can detect
 	}
 )
 
@@ -71,12 +77,14 @@ const (
 	ScanRefsMode         = ScanningMode(iota) // 0 - or default scan mode
 	ScanAllMode          = ScanningMode(iota)
 	ScanLeftToRemoteMode = ScanningMode(iota)
+	ScanRefsListMode     = ScanningMode(iota) // the history of every ref in Refs
 )
 
 type ScanRefsOptions struct {
 	ScanMode         ScanningMode
 	RemoteName       string
 	SkipDeletedBlobs bool
+	Refs             []string // refs to scan in ScanRefsListMode, or ^<ref> to exclude the history of one
 	nameMap          map[string]string
 	mutex            *sync.Mutex
 }
@@ -104,8 +112,8 @@ func NewScanRefsOptions() *ScanRefsOptions {
 // ScanRefs takes a ref and returns a slice of WrappedPointer objects
 // for all Git LFS pointers it finds for that ref.
 // Reports unique oids once only, not multiple times if >1 file uses the same content
-func ScanRefs(refLeft, refRight string, opt *ScanRefsOptions) ([]*WrappedPointer, error) {
-	s, err := ScanRefsToChan(refLeft, refRight, opt)
+func ScanRefs(ctx context.Context, refLeft, refRight string, opt *ScanRefsOptions) ([]*WrappedPointer, error) {
+	s, err := ScanRefsToChan(ctx, refLeft, refRight, opt)
 	if err != nil {
 		return nil, err
 	}
@@ -122,47 +130,63 @@ func ScanRefs(refLeft, refRight string, opt *ScanRefsOptions) ([]*WrappedPointer
 // ScanRefsToChan takes a ref and returns a channel of WrappedPointer objects
 // for all Git LFS pointers it finds for that ref.
 // Reports unique oids once only, not multiple times if >1 file uses the same content
-func ScanRefsToChan(refLeft, refRight string, opt *ScanRefsOptions) (*PointerChannelWrapper, error) {
+// Cancelling ctx stops the scan, and Wait() then returns ctx.Err().
+func ScanRefsToChan(ctx context.Context, refLeft, refRight string, opt *ScanRefsOptions) (*PointerChannelWrapper, error) {
 	if opt == nil {
 		opt = NewScanRefsOptions()
 	}
-	if refLeft == "" {
+	if refLeft == "" && opt.ScanMode == ScanRefsMode {
 		opt.ScanMode = ScanAllMode
 	}
 
+	// Without history, the blobs are those in the tree at refLeft
+	if opt.ScanMode == ScanRefsMode && opt.SkipDeletedBlobs && (refRight == "" || z40.MatchString(refRight)) {
+		if tree := scanCacheTree(refLeft); len(tree) > 0 {
+			return scanRefTreeCached(ctx, tree, opt)
+		}
+	}
+
 	start := time.Now()
 	defer func() {
 		tracerx.PerformanceSince("scan", start)
 	}()
 
-	revs, err := revListShas(refLeft, refRight, opt)
+	revs, err := revListShas(ctx, refLeft, refRight, opt)
 	if err != nil {
 		return nil, err
 	}
 
-	smallShas, err := catFileBatchCheck(revs)
+	smallShas, err := catFileBatchCheck(ctx, revs)
 	if err != nil {
 		return nil, err
 	}
 
-	pointers, err := catFileBatch(smallShas)
+	pointers, err := catFileBatch(ctx, smallShas)
 	if err != nil {
 		return nil, err
 	}
 
 	retchan := make(chan *WrappedPointer, chanBufSize)
-	errchan := make(chan error, 1)
+	errchan := make(chan error, 2)
 	go func() {
+	loop:
 		for p := range pointers.Results {
 			if name, ok := opt.GetName(p.Sha1); ok {
 				p.Name = name
 			}
-			retchan <- p
+			select {
+			case retchan <- p:
+			case <-ctx.Done():
+				break loop
+			}
 		}
 		err := pointers.Wait()
 		if err != nil {
 			errchan <- err
 		}
+		if err := ctx.Err(); err != nil {
+			errchan <- err
+		}
 		close(retchan)
 		close(errchan)
 	}()
@@ -191,7 +215,7 @@ func (m *indexFileMap) Set(sha string, index *indexFile) {
 // ScanIndex returns a slice of WrappedPointer objects for all
 // Git LFS pointers it finds in the index.
 // Reports unique oids once only, not multiple times if >1 file uses the same content
-func ScanIndex() ([]*WrappedPointer, error) {
+func ScanIndex(ctx context.Context) ([]*WrappedPointer, error) {
 	indexMap := &indexFileMap{
 		nameMap: make(map[string]*indexFile, 0),
 		mutex:   &sync.Mutex{},
@@ -202,12 +226,12 @@ func ScanIndex() ([]*WrappedPointer, error) {
 		tracerx.PerformanceSince("scan-staging", start)
 	}()
 
-	revs, err := revListIndex(false, indexMap)
+	revs, err := revListIndex(ctx, false, indexMap)
 	if err != nil {
 		return nil, err
 	}
 
-	cachedRevs, err := revListIndex(true, indexMap)
+	cachedRevs, err := revListIndex(ctx, true, indexMap)
 	if err != nil {
 		return nil, err
 	}
@@ -218,34 +242,47 @@ func ScanIndex() ([]*WrappedPointer, error) {
 	go func() {
 		seenRevs := make(map[string]bool, 0)
 
+	uncached:
 		for rev := range revs.Results {
 			seenRevs[rev] = true
-			allRevsChan <- rev
+			select {
+			case allRevsChan <- rev:
+			case <-ctx.Done():
+				break uncached
+			}
 		}
 		err := revs.Wait()
 		if err != nil {
 			allRevsErr <- err
 		}
 
+	cached:
 		for rev := range cachedRevs.Results {
 			if _, ok := seenRevs[rev]; !ok {
-				allRevsChan <- rev
+				select {
+				case allRevsChan <- rev:
+				case <-ctx.Done():
+					break cached
+				}
 			}
 		}
 		err = cachedRevs.Wait()
 		if err != nil {
 			allRevsErr <- err
 		}
+		if err := ctx.Err(); err != nil {
+			allRevsErr <- err
+		}
 		close(allRevsChan)
 		close(allRevsErr)
 	}()
 
-	smallShas, err := catFileBatchCheck(allRevs)
+	smallShas, err := catFileBatchCheck(ctx, allRevs)
 	if err != nil {
 		return nil, err
 	}
 
-	pointerc, err := catFileBatch(smallShas)
+	pointerc, err := catFileBatch(ctx, smallShas)
 	if err != nil {
 		return nil, err
 	}
@@ -317,7 +354,7 @@ func revListArgsRefVsRemote(refTo, remoteName string) ([]string, []string) {
 // revListShas uses git rev-list to return the list of object sha1s
 // for the given ref. If all is true, ref is ignored. It returns a
 // channel from which sha1 strings can be read.
-func revListShas(refLeft, refRight string, opt *ScanRefsOptions) (*StringChannelWrapper, error) {
+func revListShas(ctx context.Context, refLeft, refRight string, opt *ScanRefsOptions) (*StringChannelWrapper, error) {
 	refArgs := []string{"rev-list", "--objects"}
 	var stdin []string
 	switch opt.ScanMode {
@@ -340,6 +377,9 @@ func revListShas(refLeft, refRight string, opt *ScanRefsOptions) (*StringChannel
 		if len(commits) > 0 {
 			stdin = commits
 		}
+	case ScanRefsListMode:
+		refArgs = append(refArgs, "--stdin")
+		stdin = opt.Refs
 	default:
 		return nil, errors.New("scanner: unknown scan type: " + strconv.Itoa(int(opt.ScanMode)))
 	}
@@ -349,7 +389,7 @@ func revListShas(refLeft, refRight string, opt *ScanRefsOptions) (*StringChannel
 	// file named "master".
 	refArgs = append(refArgs, "--")
 
-	cmd, err := startCommand("git", refArgs...)
+	cmd, err := startCommand(ctx, "git", refArgs...)
 	if err != nil {
 		return nil, err
 	}
@@ -365,6 +405,7 @@ func revListShas(refLeft, refRight string, opt *ScanRefsOptions) (*StringChannel
 
 	go func() {
 		scanner := bufio.NewScanner(cmd.Stdout)
+	loop:
 		for scanner.Scan() {
 			line := strings.TrimSpace(scanner.Text())
 			if len(line) < 40 {
@@ -375,13 +416,16 @@ func revListShas(refLeft, refRight string, opt *ScanRefsOptions) (*StringChannel
 			if len(line) > 40 {
 				opt.SetName(sha1, line[41:len(line)])
 			}
-			revs <- sha1
+			select {
+			case revs <- sha1:
+			case <-ctx.Done():
+				break loop
+			}
 		}
 
-		stderr, _ := ioutil.ReadAll(cmd.Stderr)
-		err := cmd.Wait()
+		stderr, err := cmd.waitScan("git rev-list --objects")
 		if err != nil {
-			errchan <- fmt.Errorf("Error in git rev-list --objects: %v %v", err, string(stderr))
+			errchan <- err
 		} else {
 			// Special case detection of ambiguous refs; lower level commands like
 			// git rev-list do not return non-zero exit codes in this case, just warn
@@ -401,14 +445,14 @@ func revListShas(refLeft, refRight string, opt *ScanRefsOptions) (*StringChannel
 // revListIndex uses git diff-index to return the list of object sha1s
 // for in the indexf. It returns a channel from which sha1 strings can be read.
 // The namMap will be filled indexFile pointers mapping sha1s to indexFiles.
-func revListIndex(cache bool, indexMap *indexFileMap) (*StringChannelWrapper, error) {
+func revListIndex(ctx context.Context, cache bool, indexMap *indexFileMap) (*StringChannelWrapper, error) {
 	cmdArgs := []string{"diff-index", "-M"}
 	if cache {
 		cmdArgs = append(cmdArgs, "--cached")
 	}
 	cmdArgs = append(cmdArgs, "HEAD")
 
-	cmd, err := startCommand("git", cmdArgs...)
+	cmd, err := startCommand(ctx, "git", cmdArgs...)
 	if err != nil {
 		return nil, err
 	}
@@ -420,6 +464,7 @@ func revListIndex(cache bool, indexMap *indexFileMap) (*StringChannelWrapper, er
 
 	go func() {
 		scanner := bufio.NewScanner(cmd.Stdout)
+	loop:
 		for scanner.Scan() {
 			// Format is:
 			// :100644 100644 c5b3d83a7542255ec7856487baa5e83d65b1624c 9e82ac1b514be060945392291b5b3108c22f6fe3 M foo.gif
@@ -440,7 +485,11 @@ func revListIndex(cache bool, indexMap *indexFileMap) (*StringChannelWrapper, er
 					sha1 = description[2] // This one is modified but not added
 				}
 				indexMap.Set(sha1, &indexFile{files[len(files)-1], files[0], status})
-				revs <- sha1
+				select {
+				case revs <- sha1:
+				case <-ctx.Done():
+					break loop
+				}
 			}
 		}
 
@@ -453,6 +502,9 @@ func revListIndex(cache bool, indexMap *indexFileMap) (*StringChannelWrapper, er
 		// 	errchan <- fmt.Errorf("Error in git diff-index: %v %v", err, string(stderr))
 		// }
 		cmd.Wait()
+		if err := ctx.Err(); err != nil {
+			errchan <- err
+		}
 		close(revs)
 		close(errchan)
 	}()
@@ -465,8 +517,12 @@ func revListIndex(cache bool, indexMap *indexFileMap) (*StringChannelWrapper, er
 // under the blobSizeCutoff will be ignored. revs is a channel over
 // which strings containing git sha1s will be sent. It returns a channel
 // from which sha1 strings can be read.
-func catFileBatchCheck(revs *StringChannelWrapper) (*StringChannelWrapper, error) {
-	cmd, err := startCommand("git", "cat-file", "--batch-check")
+func catFileBatchCheck(ctx context.Context, revs *StringChannelWrapper) (*StringChannelWrapper, error) {
+	if db := nativeObjectDatabase(); db != nil {
+		return catFileBatchCheckNative(ctx, db, revs)
+	}
+
+	cmd, err := startCommand(ctx, "git", "cat-file", "--batch-check")
 	if err != nil {
 		return nil, err
 	}
@@ -476,6 +532,7 @@ func catFileBatchCheck(revs *StringChannelWrapper) (*StringChannelWrapper, error
 
 	go func() {
 		scanner := bufio.NewScanner(cmd.Stdout)
+	loop:
 		for scanner.Scan() {
 			line := scanner.Text()
 			lineLen := len(line)
@@ -498,14 +555,16 @@ func catFileBatchCheck(revs *StringChannelWrapper) (*StringChannelWrapper, error
 			}
 
 			if size < blobSizeCutoff {
-				smallRevs <- line[0:40]
+				select {
+				case smallRevs <- line[0:40]:
+				case <-ctx.Done():
+					break loop
+				}
 			}
 		}
 
-		stderr, _ := ioutil.ReadAll(cmd.Stderr)
-		err := cmd.Wait()
-		if err != nil {
-			errchan <- fmt.Errorf("Error in git cat-file --batch-check: %v %v", err, string(stderr))
+		if _, err := cmd.waitScan("git cat-file --batch-check"); err != nil {
+			errchan <- err
 		}
 		close(smallRevs)
 		close(errchan)
@@ -532,8 +591,12 @@ func catFileBatchCheck(revs *StringChannelWrapper) (*StringChannelWrapper, error
 // of a git object, given its sha1. The contents will be decoded into
 // a Git LFS pointer. revs is a channel over which strings containing Git SHA1s
 // will be sent. It returns a channel from which point.Pointers can be read.
-func catFileBatch(revs *StringChannelWrapper) (*PointerChannelWrapper, error) {
-	cmd, err := startCommand("git", "cat-file", "--batch")
+func catFileBatch(ctx context.Context, revs *StringChannelWrapper) (*PointerChannelWrapper, error) {
+	if db := nativeObjectDatabase(); db != nil {
+		return catFileBatchNative(ctx, db, revs)
+	}
+
+	cmd, err := startCommand(ctx, "git", "cat-file", "--batch")
 	if err != nil {
 		return nil, err
 	}
@@ -542,6 +605,7 @@ func catFileBatch(revs *StringChannelWrapper) (*PointerChannelWrapper, error) {
 	errchan := make(chan error, 5) // shared by 2 goroutines & may add more detail errors?
 
 	go func() {
+	loop:
 		for {
 			l, err := cmd.Stdout.ReadBytes('\n')
 			if err != nil {
@@ -561,10 +625,14 @@ func catFileBatch(revs *StringChannelWrapper) (*PointerChannelWrapper, error) {
 
 			p, err := DecodePointer(bytes.NewBuffer(nbuf))
 			if err == nil {
-				pointers <- &WrappedPointer{
+				select {
+				case pointers <- &WrappedPointer{
 					Sha1:    string(fields[0]),
 					Size:    p.Size,
 					Pointer: p,
+				}:
+				case <-ctx.Done():
+					break loop
 				}
 			}
 
@@ -574,10 +642,8 @@ func catFileBatch(revs *StringChannelWrapper) (*PointerChannelWrapper, error) {
 			}
 		}
 
-		stderr, _ := ioutil.ReadAll(cmd.Stderr)
-		err = cmd.Wait()
-		if err != nil {
-			errchan <- fmt.Errorf("Error in git cat-file --batch: %v %v", err, string(stderr))
+		if _, err := cmd.waitScan("git cat-file --batch"); err != nil {
+			errchan <- err
 		}
 		close(pointers)
 		close(errchan)
@@ -604,13 +670,14 @@ type wrappedCmd struct {
 	Stdout *bufio.Reader
 	Stderr *bufio.Reader
 	*exec.Cmd
+	ctx context.Context
 }
 
 // startCommand starts up a command and creates a stdin pipe and a buffered
 // stdout & stderr pipes, wrapped in a wrappedCmd. The stdout buffer will be of stdoutBufSize
-// bytes.
-func startCommand(command string, args ...string) (*wrappedCmd, error) {
-	cmd := exec.Command(command, args...)
+// bytes. The command is killed if ctx is cancelled before it exits.
+func startCommand(ctx context.Context, command string, args ...string) (*wrappedCmd, error) {
+	cmd := exec.CommandContext(ctx, command, args...)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return nil, err
@@ -635,9 +702,26 @@ func startCommand(command string, args ...string) (*wrappedCmd, error) {
 		bufio.NewReaderSize(stdout, stdoutBufSize),
 		bufio.NewReaderSize(stderr, stdoutBufSize),
 		cmd,
+		ctx,
 	}, nil
 }
 
+// waitScan reads the rest of stderr and waits for the command to exit. It
+// returns the stderr, and an error naming the command if it failed. If the
+// scan has been cancelled it returns the context's error instead, since that
+// is why the command stopped.
+func (c *wrappedCmd) waitScan(name string) ([]byte, error) {
+	stderr, _ := ioutil.ReadAll(c.Stderr)
+	err := c.Wait()
+	if ctxErr := c.ctx.Err(); ctxErr != nil {
+		return stderr, ctxErr
+	}
+	if err != nil {
+		return stderr, fmt.Errorf("Error in %s: %v %v", name, err, string(stderr))
+	}
+	return stderr, nil
+}
+
 // An entry from ls-tree or rev-list including a blob sha and tree path
 type TreeBlob struct {
 	Sha1     string
@@ -646,20 +730,28 @@ type TreeBlob struct {
 
 // ScanTree takes a ref and returns a slice of WrappedPointer objects in the tree at that ref
 // Differs from ScanRefs in that multiple files in the tree with the same content are all reported
-func ScanTree(ref string) ([]*WrappedPointer, error) {
+func ScanTree(ctx context.Context, ref string) ([]*WrappedPointer, error) {
 	start := time.Now()
 	defer func() {
 		tracerx.PerformanceSince("scan", start)
 	}()
 
+	if tree := scanCacheTree(ref); len(tree) > 0 {
+		return scanTreeCached(ctx, tree)
+	}
+	return scanTree(ctx, ref)
+}
+
+// scanTree does the same as ScanTree, without the scan cache.
+func scanTree(ctx context.Context, ref string) ([]*WrappedPointer, error) {
 	// We don't use the nameMap approach here since that's imprecise when >1 file
 	// can be using the same content
-	treeShas, err := lsTreeBlobs(ref)
+	treeShas, err := lsTreeBlobs(ctx, ref)
 	if err != nil {
 		return nil, err
 	}
 
-	pointerc, err := catFileBatchTree(treeShas)
+	pointerc, err := catFileBatchTree(ctx, treeShas)
 	if err != nil {
 		return nil, err
 	}
@@ -677,8 +769,12 @@ func ScanTree(ref string) ([]*WrappedPointer, error) {
 // of a git object, given its sha1. The contents will be decoded into
 // a Git LFS pointer. treeblobs is a channel over which blob entries
 // will be sent. It returns a channel from which point.Pointers can be read.
-func catFileBatchTree(treeblobs *TreeBlobChannelWrapper) (*PointerChannelWrapper, error) {
-	cmd, err := startCommand("git", "cat-file", "--batch")
+func catFileBatchTree(ctx context.Context, treeblobs *TreeBlobChannelWrapper) (*PointerChannelWrapper, error) {
+	if db := nativeObjectDatabase(); db != nil {
+		return catFileBatchTreeNative(ctx, db, treeblobs)
+	}
+
+	cmd, err := startCommand(ctx, "git", "cat-file", "--batch")
 	if err != nil {
 		return nil, err
 	}
@@ -687,6 +783,7 @@ func catFileBatchTree(treeblobs *TreeBlobChannelWrapper) (*PointerChannelWrapper
 	errchan := make(chan error, 10) // Multiple errors possible
 
 	go func() {
+	loop:
 		for t := range treeblobs.Results {
 			cmd.Stdin.Write([]byte(t.Sha1 + "\n"))
 			l, err := cmd.Stdout.ReadBytes('\n')
@@ -707,11 +804,15 @@ func catFileBatchTree(treeblobs *TreeBlobChannelWrapper) (*PointerChannelWrapper
 
 			p, err := DecodePointer(bytes.NewBuffer(nbuf))
 			if err == nil {
-				pointers <- &WrappedPointer{
+				select {
+				case pointers <- &WrappedPointer{
 					Sha1:    string(fields[0]),
 					Size:    p.Size,
 					Pointer: p,
 					Name:    t.Filename,
+				}:
+				case <-ctx.Done():
+					break loop
 				}
 			}
 
@@ -729,10 +830,8 @@ func catFileBatchTree(treeblobs *TreeBlobChannelWrapper) (*PointerChannelWrapper
 		cmd.Stdin.Close()
 
 		// also errors from our command
-		stderr, _ := ioutil.ReadAll(cmd.Stderr)
-		err = cmd.Wait()
-		if err != nil {
-			errchan <- fmt.Errorf("Error in git cat-file: %v %v", err, string(stderr))
+		if _, err := cmd.waitScan("git cat-file"); err != nil {
+			errchan <- err
 		}
 		close(pointers)
 		close(errchan)
@@ -744,7 +843,11 @@ func catFileBatchTree(treeblobs *TreeBlobChannelWrapper) (*PointerChannelWrapper
 // Use ls-tree at ref to find a list of candidate tree blobs which might be lfs files
 // The returned channel will be sent these blobs which should be sent to catFileBatchTree
 // for final check & conversion to Pointer
-func lsTreeBlobs(ref string) (*TreeBlobChannelWrapper, error) {
+func lsTreeBlobs(ctx context.Context, ref string) (*TreeBlobChannelWrapper, error) {
+	if db := nativeObjectDatabase(); db != nil {
+		return lsTreeBlobsNative(ctx, db, ref)
+	}
+
 	// Snapshot using ls-tree
 	lsArgs := []string{"ls-tree",
 		"-r",          // recurse
@@ -753,7 +856,7 @@ func lsTreeBlobs(ref string) (*TreeBlobChannelWrapper, error) {
 		"--full-tree", // start at the root regardless of where we are in it
 		ref}
 
-	cmd, err := startCommand("git", lsArgs...)
+	cmd, err := startCommand(ctx, "git", lsArgs...)
 	if err != nil {
 		return nil, err
 	}
@@ -764,11 +867,9 @@ func lsTreeBlobs(ref string) (*TreeBlobChannelWrapper, error) {
 	errchan := make(chan error, 1)
 
 	go func() {
-		parseLsTree(cmd.Stdout, blobs)
-		stderr, _ := ioutil.ReadAll(cmd.Stderr)
-		err := cmd.Wait()
-		if err != nil {
-			errchan <- fmt.Errorf("Error in git ls-tree: %v %v", err, string(stderr))
+		parseLsTree(ctx, cmd.Stdout, blobs)
+		if _, err := cmd.waitScan("git ls-tree"); err != nil {
+			errchan <- err
 		}
 		close(blobs)
 		close(errchan)
@@ -777,7 +878,7 @@ func lsTreeBlobs(ref string) (*TreeBlobChannelWrapper, error) {
 	return NewTreeBlobChannelWrapper(blobs, errchan), nil
 }
 
-func parseLsTree(reader io.Reader, output chan TreeBlob) {
+func parseLsTree(ctx context.Context, reader io.Reader, output chan TreeBlob) {
 	scanner := bufio.NewScanner(reader)
 	scanner.Split(scanNullLines)
 	for scanner.Scan() {
@@ -804,7 +905,11 @@ func parseLsTree(reader io.Reader, output chan TreeBlob) {
 		if sz < blobSizeCutoff {
 			sha1 := attrs[2]
 			filename := parts[1]
-			output <- TreeBlob{sha1, filename}
+			select {
+			case output <- TreeBlob{sha1, filename}:
+			case <-ctx.Done():
+				return
+			}
 		}
 	}
 }
@@ -830,14 +935,14 @@ func scanNullLines(data []byte, atEOF bool) (advance int, token []byte, err erro
 
 // ScanUnpushed scans history for all LFS pointers which have been added but not
 // pushed to the named remote. remoteName can be left blank to mean 'any remote'
-func ScanUnpushed(remoteName string) ([]*WrappedPointer, error) {
+func ScanUnpushed(ctx context.Context, remoteName string) ([]*WrappedPointer, error) {
 
 	start := time.Now()
 	defer func() {
 		tracerx.PerformanceSince("scan", start)
 	}()
 
-	pointerchan, err := ScanUnpushedToChan(remoteName)
+	pointerchan, err := ScanUnpushedToChan(ctx, remoteName)
 	if err != nil {
 		return nil, err
 	}
@@ -852,13 +957,13 @@ func ScanUnpushed(remoteName string) ([]*WrappedPointer, error) {
 // ScanPreviousVersions scans changes reachable from ref (commit) back to since.
 // Returns pointers for *previous* versions that overlap that time. Does not
 // return pointers which were still in use at ref (use ScanRef for that)
-func ScanPreviousVersions(ref string, since time.Time) ([]*WrappedPointer, error) {
+func ScanPreviousVersions(ctx context.Context, ref string, since time.Time) ([]*WrappedPointer, error) {
 	start := time.Now()
 	defer func() {
 		tracerx.PerformanceSince("scan", start)
 	}()
 
-	pointerchan, err := ScanPreviousVersionsToChan(ref, since)
+	pointerchan, err := ScanPreviousVersionsToChan(ctx, ref, since)
 	if err != nil {
 		return nil, err
 	}
@@ -874,27 +979,33 @@ func ScanPreviousVersions(ref string, since time.Time) ([]*WrappedPointer, error
 // ScanPreviousVersionsToChan scans changes reachable from ref (commit) back to since.
 // Returns channel of pointers for *previous* versions that overlap that time. Does not
 // include pointers which were still in use at ref (use ScanRefsToChan for that)
-func ScanPreviousVersionsToChan(ref string, since time.Time) (*PointerChannelWrapper, error) {
-	return logPreviousSHAs(ref, since)
+func ScanPreviousVersionsToChan(ctx context.Context, ref string, since time.Time) (*PointerChannelWrapper, error) {
+	return logPreviousSHAs(ctx, ref, since)
 }
 
 // ScanUnpushedToChan scans history for all LFS pointers which have been added but
 // not pushed to the named remote. remoteName can be left blank to mean 'any remote'
 // return progressively in a channel
-func ScanUnpushedToChan(remoteName string) (*PointerChannelWrapper, error) {
-	logArgs := []string{"log",
+func ScanUnpushedToChan(ctx context.Context, remoteName string) (*PointerChannelWrapper, error) {
+	revArgs := []string{
 		"--branches", "--tags", // include all locally referenced commits
 		"--not"} // but exclude everything that comes after
 
 	if len(remoteName) == 0 {
-		logArgs = append(logArgs, "--remotes")
+		revArgs = append(revArgs, "--remotes")
 	} else {
-		logArgs = append(logArgs, fmt.Sprintf("--remotes=%v", remoteName))
+		revArgs = append(revArgs, fmt.Sprintf("--remotes=%v", remoteName))
 	}
+
+	if config.Config.ScanCache() {
+		return logPointersCached(ctx, revArgs, LogDiffAdditions)
+	}
+
+	logArgs := append([]string{"log"}, revArgs...)
 	// Add standard search args to find lfs references
 	logArgs = append(logArgs, logLfsSearchArgs...)
 
-	cmd, err := startCommand("git", logArgs...)
+	cmd, err := startCommand(ctx, "git", logArgs...)
 	if err != nil {
 		return nil, err
 	}
@@ -905,11 +1016,9 @@ func ScanUnpushedToChan(remoteName string) (*PointerChannelWrapper, error) {
 	errchan := make(chan error, 1)
 
 	go func() {
-		parseLogOutputToPointers(cmd.Stdout, LogDiffAdditions, nil, nil, pchan)
-		stderr, _ := ioutil.ReadAll(cmd.Stderr)
-		err := cmd.Wait()
-		if err != nil {
-			errchan <- fmt.Errorf("Error in git log: %v %v", err, string(stderr))
+		parseLogOutputToPointers(ctx, cmd.Stdout, LogDiffAdditions, nil, nil, pchan)
+		if _, err := cmd.waitScan("git log"); err != nil {
+			errchan <- err
 		}
 		close(pchan)
 		close(errchan)
@@ -921,16 +1030,19 @@ func ScanUnpushedToChan(remoteName string) (*PointerChannelWrapper, error) {
 
 // logPreviousVersions scans history for all previous versions of LFS pointers
 // from 'since' up to (but not including) the final state at ref
-func logPreviousSHAs(ref string, since time.Time) (*PointerChannelWrapper, error) {
-	logArgs := []string{"log",
-		fmt.Sprintf("--since=%v", git.FormatGitDate(since)),
+func logPreviousSHAs(ctx context.Context, ref string, since time.Time) (*PointerChannelWrapper, error) {
+	sinceArg := fmt.Sprintf("--since=%v", git.FormatGitDate(since))
+	if config.Config.ScanCache() {
+		return logPointersCached(ctx, []string{sinceArg, ref}, LogDiffDeletions)
 	}
+
+	logArgs := []string{"log", sinceArg}
 	// Add standard search args to find lfs references
 	logArgs = append(logArgs, logLfsSearchArgs...)
 	// ending at ref
 	logArgs = append(logArgs, ref)
 
-	cmd, err := startCommand("git", logArgs...)
+	cmd, err := startCommand(ctx, "git", logArgs...)
 	if err != nil {
 		return nil, err
 	}
@@ -944,11 +1056,9 @@ func logPreviousSHAs(ref string, since time.Time) (*PointerChannelWrapper, error
 	// this means we pick up all previous versions that could have been checked
 	// out in the date range, not just if the commit which *introduced* them is in the range
 	go func() {
-		parseLogOutputToPointers(cmd.Stdout, LogDiffDeletions, nil, nil, pchan)
-		stderr, _ := ioutil.ReadAll(cmd.Stderr)
-		err := cmd.Wait()
-		if err != nil {
-			errchan <- fmt.Errorf("Error in git log: %v %v", err, string(stderr))
+		parseLogOutputToPointers(ctx, cmd.Stdout, LogDiffDeletions, nil, nil, pchan)
+		if _, err := cmd.waitScan("git log"); err != nil {
+			errchan <- err
 		}
 		close(pchan)
 		close(errchan)
@@ -972,7 +1082,8 @@ const (
 // dir: whether to include results from + or - diffs
 // includePaths, excludePaths: filter the results by filename
 // results: a channel which will receive the pointers (caller must close)
-func parseLogOutputToPointers(log io.Reader, dir LogDiffDirection,
+// Parsing stops early if ctx is cancelled.
+func parseLogOutputToPointers(ctx context.Context, log io.Reader, dir LogDiffDirection,
 	includePaths, excludePaths []string, results chan *WrappedPointer) {
 
 	// For each commit we'll get something like this:
@@ -1007,7 +1118,10 @@ func parseLogOutputToPointers(log io.Reader, dir LogDiffDirection,
 			if currentFileIncluded {
 				p, err := DecodePointer(&pointerData)
 				if err == nil {
-					results <- &WrappedPointer{Name: currentFilename, Size: p.Size, Pointer: p}
+					select {
+					case results <- &WrappedPointer{Name: currentFilename, Size: p.Size, Pointer: p}:
+					case <-ctx.Done():
+					}
 				} else {
 					tracerx.Printf("Unable to parse pointer from log: %v", err)
 				}
@@ -1018,6 +1132,10 @@ func parseLogOutputToPointers(log io.Reader, dir LogDiffDirection,
 
 	scanner := bufio.NewScanner(log)
 	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return
+		}
+
 		line := scanner.Text()
 		if match := commitHeaderRegex.FindStringSubmatch(line); match != nil {
 			// Currently we're not pulling out commit groupings, but could if we wanted
@@ -1071,13 +1189,24 @@ type ChannelWrapper interface {
 // Base implementation of channel wrapper to just deal with errors
 type BaseChannelWrapper struct {
 	errorChan <-chan error
+	// drain discards any results which have not been read
+	drain func()
 }
 
+// Wait discards any results which have not been read, so that the goroutines
+// sending them can finish, and returns any errors combined into one. If the
+// scan was cancelled it returns only the context's error, since the others
+// are caused by the cancellation.
 func (w *BaseChannelWrapper) Wait() error {
+	if w.drain != nil {
+		w.drain()
+	}
 
-	var err error
+	var err, cancelled error
 	for e := range w.errorChan {
-		if err != nil {
+		if e == context.Canceled || e == context.DeadlineExceeded {
+			cancelled = e
+		} else if err != nil {
 			// Combine in case multiple errors
 			err = fmt.Errorf("%v\n%v", err, e)
 
@@ -1086,6 +1215,9 @@ func (w *BaseChannelWrapper) Wait() error {
 		}
 	}
 
+	if cancelled != nil {
+		return cancelled
+	}
 	return err
 }
 
@@ -1100,7 +1232,11 @@ type PointerChannelWrapper struct {
 // Caller can use s.Results directly for normal processing then call Wait() to finish & check for errors
 // Scan function is required to create error channel large enough not to block (usually 1 is ok)
 func NewPointerChannelWrapper(pointerChan <-chan *WrappedPointer, errorChan <-chan error) *PointerChannelWrapper {
-	return &PointerChannelWrapper{&BaseChannelWrapper{errorChan}, pointerChan}
+	drain := func() {
+		for range pointerChan {
+		}
+	}
+	return &PointerChannelWrapper{&BaseChannelWrapper{errorChan, drain}, pointerChan}
 }
 
 // ChannelWrapper for string channel functions to more easily return async error data via Wait()
@@ -1114,7 +1250,11 @@ type StringChannelWrapper struct {
 // Construct a new channel wrapper for string
 // Caller can use s.Results directly for normal processing then call Wait() to finish & check for errors
 func NewStringChannelWrapper(stringChan <-chan string, errorChan <-chan error) *StringChannelWrapper {
-	return &StringChannelWrapper{&BaseChannelWrapper{errorChan}, stringChan}
+	drain := func() {
+		for range stringChan {
+		}
+	}
+	return &StringChannelWrapper{&BaseChannelWrapper{errorChan, drain}, stringChan}
 }
 
 // ChannelWrapper for TreeBlob channel functions to more easily return async error data via Wait()
@@ -1127,5 +1267,9 @@ type TreeBlobChannelWrapper struct {
 // Construct a new channel wrapper for TreeBlob
 // Caller can use s.Results directly for normal processing then call Wait() to finish & check for errors
 func NewTreeBlobChannelWrapper(treeBlobChan <-chan TreeBlob, errorChan <-chan error) *TreeBlobChannelWrapper {
-	return &TreeBlobChannelWrapper{&BaseChannelWrapper{errorChan}, treeBlobChan}
+	drain := func() {
+		for range treeBlobChan {
+		}
+	}
+	return &TreeBlobChannelWrapper{&BaseChannelWrapper{errorChan, drain}, treeBlobChan}
 }
@@ -0,0 +1,292 @@
+package lfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/github/git-lfs/git"
+	"github.com/rubyist/tracerx"
+)
+
+// emptyTreeSha is the sha of the tree with no entries, which git knows of
+// whether or not it is in the repository.
+const emptyTreeSha = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// ScannedBlob is a blob found in history by ScanBlobs, with the path it was
+// first found at.
+type ScannedBlob struct {
+	Sha1 string
+	Name string
+	Size int64
+	// Pointer is the Git LFS pointer in the blob, or nil if it is not one
+	Pointer *Pointer
+	// Commit is the commit which added or changed the blob at Name, for
+	// the blobs returned by ScanChangedBlobs
+	Commit string
+}
+
+// ScanBlobs returns every blob in the history of the given refs, or of every
+// ref in opt.Refs in ScanRefsListMode, whether or not it is a Git LFS pointer.
+// Each blob is returned once, however many paths and commits it is in.
+func ScanBlobs(ctx context.Context, refLeft, refRight string, opt *ScanRefsOptions) ([]*ScannedBlob, error) {
+	if opt == nil {
+		opt = NewScanRefsOptions()
+	}
+	if refLeft == "" && opt.ScanMode == ScanRefsMode {
+		opt.ScanMode = ScanAllMode
+	}
+
+	start := time.Now()
+	defer func() {
+		tracerx.PerformanceSince("scan blobs", start)
+	}()
+
+	revs, err := revListShas(ctx, refLeft, refRight, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	sized, err := catFileBatchCheckBlobs(ctx, revs)
+	if err != nil {
+		return nil, err
+	}
+
+	var blobs []*ScannedBlob
+	bySha := make(map[string]*ScannedBlob)
+	var smallShas []string
+	for b := range sized.Results {
+		if name, ok := opt.GetName(b.Sha1); ok {
+			b.Name = name
+		}
+		blobs = append(blobs, b)
+		bySha[b.Sha1] = b
+		if b.Size < blobSizeCutoff {
+			smallShas = append(smallShas, b.Sha1)
+		}
+	}
+	if err := sized.Wait(); err != nil {
+		return nil, err
+	}
+
+	if err := scanBlobPointers(ctx, bySha, smallShas); err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+// ScanChangedBlobs returns the blob at each path which is added or modified by
+// the commits that git log lists for revArgs, e.g. "master" or
+// "origin/master..master", with a ScannedBlob for each commit, path and blob.
+// For a merge, these are the paths which differ from every parent, i.e. those
+// changed by the merge resolution. Only regular files are included.
+func ScanChangedBlobs(ctx context.Context, revArgs []string) ([]*ScannedBlob, error) {
+	args := []string{"log", "--format=commit %H", "--raw", "-z", "-c", "-r", "--root", "--no-abbrev", "--no-renames", "--diff-filter=AMT"}
+	args = append(args, revArgs...)
+	args = append(args, "--")
+	return scanRawDiffBlobs(ctx, args...)
+}
+
+// ScanStagedBlobs returns the blob at each path which is added or modified in
+// the index compared to HEAD, or every path in the index if there is no HEAD
+// yet. Only regular files are included.
+func ScanStagedBlobs(ctx context.Context) ([]*ScannedBlob, error) {
+	base := "HEAD"
+	if _, err := git.ResolveRef("HEAD"); err != nil {
+		base = emptyTreeSha
+	}
+	return scanRawDiffBlobs(ctx, "diff-index", "--cached", "--raw", "-z", "--no-abbrev", "--no-renames", "--diff-filter=AMT", base, "--")
+}
+
+// scanRawDiffBlobs runs a git command which outputs a raw diff with -z, and
+// returns the new blob of each regular file in it, with its size and pointer,
+// and the commit it is in if the command is git log.
+func scanRawDiffBlobs(ctx context.Context, args ...string) ([]*ScannedBlob, error) {
+	cmd, err := startCommand(ctx, "git", args...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin.Close()
+
+	var blobs []*ScannedBlob
+	var commit string
+	var description []string
+	scanner := bufio.NewScanner(cmd.Stdout)
+	scanner.Split(scanNulTerminated)
+	for scanner.Scan() {
+		field := scanner.Text()
+
+		// Format is:
+		// :<old mode> <new mode> <old sha1> <new sha1> <status>\0<file name>\0
+		// with the file name unquoted, whatever core.quotePath is. For a
+		// merge, there is a colon, an old mode and an old sha1 per parent.
+		if description != nil {
+			parents := len(description[0]) - len(strings.TrimLeft(description[0], ":"))
+			if len(description) >= 2*parents+3 && strings.HasPrefix(description[parents], "100") {
+				blobs = append(blobs, &ScannedBlob{Sha1: description[2*parents+1], Name: field, Commit: commit})
+			}
+			description = nil
+			continue
+		}
+
+		field = strings.TrimLeft(field, "\n")
+		if strings.HasPrefix(field, "commit ") {
+			commit = field[len("commit "):]
+		} else if strings.HasPrefix(field, ":") {
+			description = strings.Fields(field)
+		}
+	}
+	if _, err := cmd.waitScan("git " + args[0]); err != nil {
+		return nil, err
+	}
+
+	bySha := make(map[string]*ScannedBlob)
+	revs := make(chan string, len(blobs))
+	for _, b := range blobs {
+		if _, ok := bySha[b.Sha1]; !ok {
+			bySha[b.Sha1] = &ScannedBlob{Sha1: b.Sha1}
+			revs <- b.Sha1
+		}
+	}
+	close(revs)
+	noErrors := make(chan error)
+	close(noErrors)
+
+	sized, err := catFileBatchCheckBlobs(ctx, NewStringChannelWrapper(revs, noErrors))
+	if err != nil {
+		return nil, err
+	}
+	var smallShas []string
+	for b := range sized.Results {
+		bySha[b.Sha1].Size = b.Size
+		if b.Size < blobSizeCutoff {
+			smallShas = append(smallShas, b.Sha1)
+		}
+	}
+	if err := sized.Wait(); err != nil {
+		return nil, err
+	}
+
+	if err := scanBlobPointers(ctx, bySha, smallShas); err != nil {
+		return nil, err
+	}
+	for _, b := range blobs {
+		b.Size = bySha[b.Sha1].Size
+		b.Pointer = bySha[b.Sha1].Pointer
+	}
+	return blobs, nil
+}
+
+// scanNulTerminated is a bufio.SplitFunc which returns each NUL terminated
+// field in the output of a git command run with -z.
+func scanNulTerminated(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// scanBlobPointers reads each of the given small blobs, and sets the Pointer
+// of its entry in bySha if it is a Git LFS pointer.
+func scanBlobPointers(ctx context.Context, bySha map[string]*ScannedBlob, smallShas []string) error {
+	smallRevs := make(chan string, len(smallShas))
+	for _, sha := range smallShas {
+		smallRevs <- sha
+	}
+	close(smallRevs)
+	noErrors := make(chan error)
+	close(noErrors)
+
+	pointers, err := catFileBatch(ctx, NewStringChannelWrapper(smallRevs, noErrors))
+	if err != nil {
+		return err
+	}
+	for p := range pointers.Results {
+		if b, ok := bySha[p.Sha1]; ok {
+			b.Pointer = p.Pointer
+		}
+	}
+	return pointers.Wait()
+}
+
+// catFileBatchCheckBlobs uses git cat-file --batch-check to get the size of
+// each blob in revs, ignoring other objects. It returns a channel from which
+// the blobs can be read.
+func catFileBatchCheckBlobs(ctx context.Context, revs *StringChannelWrapper) (*ScannedBlobChannelWrapper, error) {
+	cmd, err := startCommand(ctx, "git", "cat-file", "--batch-check")
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := make(chan *ScannedBlob, chanBufSize)
+	errchan := make(chan error, 2) // up to 2 errors, one from each goroutine
+
+	go func() {
+		scanner := bufio.NewScanner(cmd.Stdout)
+	loop:
+		for scanner.Scan() {
+			line := scanner.Text()
+
+			// Format is:
+			// <sha1> <type> <size>
+			if len(line) < 46 || line[41:45] != "blob" {
+				continue
+			}
+
+			size, err := strconv.ParseInt(line[46:], 10, 64)
+			if err != nil {
+				continue
+			}
+			select {
+			case blobs <- &ScannedBlob{Sha1: line[0:40], Size: size}:
+			case <-ctx.Done():
+				break loop
+			}
+		}
+
+		if _, err := cmd.waitScan("git cat-file --batch-check"); err != nil {
+			errchan <- err
+		}
+		close(blobs)
+		close(errchan)
+	}()
+
+	go func() {
+		for r := range revs.Results {
+			cmd.Stdin.Write([]byte(r + "\n"))
+		}
+		err := revs.Wait()
+		if err != nil {
+			// We can share errchan with other goroutine since that won't close it
+			// until we close the stdin below
+			errchan <- err
+		}
+
+		cmd.Stdin.Close()
+	}()
+
+	return NewScannedBlobChannelWrapper(blobs, errchan), nil
+}
+
+// ChannelWrapper for ScannedBlob channel functions to more easily return async error data via Wait()
+// See NewScannedBlobChannelWrapper for construction / use
+type ScannedBlobChannelWrapper struct {
+	*BaseChannelWrapper
+	Results <-chan *ScannedBlob
+}
+
+// Construct a new channel wrapper for ScannedBlob
+// Caller can use s.Results directly for normal processing then call Wait() to finish & check for errors
+func NewScannedBlobChannelWrapper(blobChan <-chan *ScannedBlob, errorChan <-chan error) *ScannedBlobChannelWrapper {
+	drain := func() {
+		for range blobChan {
+		}
+	}
+	return &ScannedBlobChannelWrapper{&BaseChannelWrapper{errorChan, drain}, blobChan}
+}
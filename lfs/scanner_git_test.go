@@ -5,10 +5,14 @@ package lfs_test // to avoid import cycles
 // which avoids import cycles with testutils
 
 import (
+	"context"
+	"io/ioutil"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/github/git-lfs/config"
 	. "github.com/github/git-lfs/lfs"
 	"github.com/github/git-lfs/test"
 	"github.com/stretchr/testify/assert"
@@ -53,35 +57,35 @@ func TestScanUnpushed(t *testing.T) {
 	repo.AddRemote("origin")
 	repo.AddRemote("upstream")
 
-	pointers, err := ScanUnpushed("")
+	pointers, err := ScanUnpushed(context.Background(), "")
 	assert.Nil(t, err, "Should be no error calling ScanUnpushed")
 	assert.Len(t, pointers, 4, "Should be 4 pointers because none pushed")
 
 	test.RunGitCommand(t, true, "push", "origin", "branch2")
 	// Branch2 will have pushed 2 commits
-	pointers, err = ScanUnpushed("")
+	pointers, err = ScanUnpushed(context.Background(), "")
 	assert.Nil(t, err, "Should be no error calling ScanUnpushed")
 	assert.Len(t, pointers, 2, "Should be 2 pointers")
 
 	test.RunGitCommand(t, true, "push", "upstream", "master")
 	// Master pushes 1 more commit
-	pointers, err = ScanUnpushed("")
+	pointers, err = ScanUnpushed(context.Background(), "")
 	assert.Nil(t, err, "Should be no error calling ScanUnpushed")
 	assert.Len(t, pointers, 1, "Should be 1 pointer")
 
 	test.RunGitCommand(t, true, "push", "origin", "branch3")
 	// All pushed (somewhere)
-	pointers, err = ScanUnpushed("")
+	pointers, err = ScanUnpushed(context.Background(), "")
 	assert.Nil(t, err, "Should be no error calling ScanUnpushed")
 	assert.Empty(t, pointers, "Should be 0 pointers unpushed")
 
 	// Check origin
-	pointers, err = ScanUnpushed("origin")
+	pointers, err = ScanUnpushed(context.Background(), "origin")
 	assert.Nil(t, err, "Should be no error calling ScanUnpushed")
 	assert.Empty(t, pointers, "Should be 0 pointers unpushed to origin")
 
 	// Check upstream
-	pointers, err = ScanUnpushed("upstream")
+	pointers, err = ScanUnpushed(context.Background(), "upstream")
 	assert.Nil(t, err, "Should be no error calling ScanUnpushed")
 	assert.Len(t, pointers, 2, "Should be 2 pointers unpushed to upstream")
 }
@@ -147,7 +151,7 @@ func TestScanPreviousVersions(t *testing.T) {
 
 	// 7 day limit excludes [0] commit, but includes state from that if there
 	// was a subsequent change
-	pointers, err := ScanPreviousVersions("master", now.AddDate(0, 0, -7))
+	pointers, err := ScanPreviousVersions(context.Background(), "master", now.AddDate(0, 0, -7))
 	assert.Equal(t, nil, err)
 
 	// Includes the following 'before' state at commits:
@@ -165,3 +169,327 @@ func TestScanPreviousVersions(t *testing.T) {
 	assert.Equal(t, expected, pointers)
 
 }
+
+func TestScanBlobs(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	outputs := repo.AddCommits([]*test.CommitInput{
+		{ // 0
+			Files: []*test.FileInput{
+				{Filename: "lfs.dat", Size: 20},
+			},
+		},
+		{ // 1
+			NewBranch: "branch2",
+			Files: []*test.FileInput{
+				{Filename: "lfs.dat", Size: 25},
+			},
+		},
+	})
+
+	test.RunGitCommand(t, true, "checkout", "master")
+	if err := ioutil.WriteFile("plain.txt", []byte("not a pointer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	test.RunGitCommand(t, true, "add", "plain.txt")
+	test.RunGitCommand(t, true, "commit", "-m", "plain file")
+
+	opt := NewScanRefsOptions()
+	opt.ScanMode = ScanRefsListMode
+	opt.Refs = []string{"master"}
+	blobs, err := ScanBlobs(context.Background(), "", "", opt)
+	assert.Nil(t, err)
+
+	byName := make(map[string]*ScannedBlob)
+	for _, b := range blobs {
+		byName[b.Name] = b
+	}
+	assert.Equal(t, 2, len(byName))
+	assert.Equal(t, outputs[0].Files[0].Oid, byName["lfs.dat"].Pointer.Oid)
+	assert.Equal(t, int64(len("not a pointer")), byName["plain.txt"].Size)
+	assert.Nil(t, byName["plain.txt"].Pointer)
+
+	opt = NewScanRefsOptions()
+	opt.ScanMode = ScanRefsListMode
+	opt.Refs = []string{"master", "branch2"}
+	blobs, err = ScanBlobs(context.Background(), "", "", opt)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(blobs))
+}
+
+func TestScanChangedAndStagedBlobs(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	// before the first commit, everything in the index is staged
+	if err := ioutil.WriteFile("plain.txt", []byte("not a pointer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	test.RunGitCommand(t, true, "add", "plain.txt")
+	blobs, err := ScanStagedBlobs(context.Background())
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(blobs)) {
+		assert.Equal(t, "plain.txt", blobs[0].Name)
+		assert.Nil(t, blobs[0].Pointer)
+	}
+
+	outputs := repo.AddCommits([]*test.CommitInput{
+		{ // 0
+			Files: []*test.FileInput{
+				{Filename: "lfs.dat", Size: 20},
+				{Filename: "copy.dat", Data: "copied contents", Size: 15},
+			},
+		},
+		{ // 1
+			Files: []*test.FileInput{
+				{Filename: "lfs.dat", Size: 25},
+				{Filename: "dir/copy.dat", Data: "copied contents", Size: 15},
+			},
+		},
+	})
+
+	blobs, err = ScanChangedBlobs(context.Background(), []string{"master^..master"})
+	assert.Nil(t, err)
+	byName := make(map[string]*ScannedBlob)
+	for _, b := range blobs {
+		byName[b.Name] = b
+	}
+	assert.Equal(t, 2, len(byName))
+	assert.Equal(t, outputs[1].Files[0].Oid, byName["lfs.dat"].Pointer.Oid)
+	assert.Equal(t, outputs[1].Files[1].Oid, byName["dir/copy.dat"].Pointer.Oid)
+	assert.Equal(t, int64(len(outputs[1].Files[1].Encoded())), byName["dir/copy.dat"].Size)
+	assert.Equal(t, outputs[1].Sha, byName["lfs.dat"].Commit)
+
+	// the same blob at another path is found at both
+	blobs, err = ScanChangedBlobs(context.Background(), []string{"master"})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(blobs))
+
+	blobs, err = ScanStagedBlobs(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, blobs)
+
+	test.RunGitCommand(t, true, "rm", "-q", "plain.txt")
+	if err := ioutil.WriteFile("lfs.dat", []byte("raw contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	test.RunGitCommand(t, true, "add", "lfs.dat")
+	blobs, err = ScanStagedBlobs(context.Background())
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(blobs)) {
+		assert.Equal(t, "lfs.dat", blobs[0].Name)
+		assert.Equal(t, int64(len("raw contents")), blobs[0].Size)
+		assert.Nil(t, blobs[0].Pointer)
+	}
+}
+
+func TestScanChangedAndStagedBlobsWithUnquotedNames(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	// core.quotePath would quote these in a raw diff without -z
+	names := []string{"caf\u00e9.bin", "tab\tname.bin"}
+	for _, name := range names {
+		if err := ioutil.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	test.RunGitCommand(t, true, "add", ".")
+
+	blobs, err := ScanStagedBlobs(context.Background())
+	assert.Nil(t, err)
+	if assert.Equal(t, 2, len(blobs)) {
+		assert.Equal(t, names[0], blobs[0].Name)
+		assert.Equal(t, names[1], blobs[1].Name)
+	}
+
+	test.RunGitCommand(t, true, "commit", "-q", "-m", "add files")
+	blobs, err = ScanChangedBlobs(context.Background(), []string{"master"})
+	assert.Nil(t, err)
+	if assert.Equal(t, 2, len(blobs)) {
+		assert.Equal(t, names[0], blobs[0].Name)
+		assert.Equal(t, names[1], blobs[1].Name)
+		assert.Equal(t, int64(len(names[0])), blobs[0].Size)
+		assert.Len(t, blobs[0].Commit, 40)
+	}
+}
+
+func TestScanChangedBlobsInMergeResolution(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	writeAndAdd := func(name, contents string) {
+		if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		test.RunGitCommand(t, true, "add", name)
+	}
+
+	writeAndAdd("base.txt", "base")
+	test.RunGitCommand(t, true, "commit", "-q", "-m", "base")
+	test.RunGitCommand(t, true, "checkout", "-q", "-b", "branch")
+	writeAndAdd("branch.txt", "branch")
+	test.RunGitCommand(t, true, "commit", "-q", "-m", "branch")
+	test.RunGitCommand(t, true, "checkout", "-q", "master")
+	writeAndAdd("master.txt", "master")
+	test.RunGitCommand(t, true, "commit", "-q", "-m", "master")
+
+	// only the file added while resolving the merge differs from both parents
+	test.RunGitCommand(t, true, "merge", "-q", "--no-commit", "branch")
+	writeAndAdd("merge.txt", "merge")
+	test.RunGitCommand(t, true, "commit", "-q", "-m", "merge")
+
+	blobs, err := ScanChangedBlobs(context.Background(), []string{"master^!"})
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(blobs)) {
+		assert.Equal(t, "merge.txt", blobs[0].Name)
+		assert.Equal(t, int64(len("merge")), blobs[0].Size)
+		assert.Equal(t, strings.TrimSpace(test.RunGitCommand(t, true, "rev-parse", "master")), blobs[0].Commit)
+	}
+}
+
+func TestScanNativeObjects(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	repo.AddCommits([]*test.CommitInput{
+		{ // 0
+			Files: []*test.FileInput{
+				{Filename: "file1.txt", Size: 20},
+				{Filename: "dir/file2.txt", Size: 30},
+			},
+		},
+		{ // 1
+			NewBranch: "branch2",
+			Files: []*test.FileInput{
+				{Filename: "file1.txt", Size: 25},
+				{Filename: "dir/sub/file3.txt", Size: 35},
+			},
+		},
+	})
+
+	// compare fresh scans, not cached ones
+	config.Config.SetConfig("lfs.scancache", "false")
+	defer config.Config.ResetConfig()
+
+	scan := func() ([]*WrappedPointer, []*WrappedPointer) {
+		tree, err := ScanTree(context.Background(), "branch2")
+		assert.Nil(t, err)
+		refs, err := ScanRefs(context.Background(), "master", "branch2", nil)
+		assert.Nil(t, err)
+		sort.Sort(test.WrappedPointersByOid(tree))
+		sort.Sort(test.WrappedPointersByOid(refs))
+		return tree, refs
+	}
+
+	expectedTree, expectedRefs := scan()
+	assert.Equal(t, 3, len(expectedTree))
+	assert.Equal(t, 4, len(expectedRefs))
+
+	config.Config.SetConfig("lfs.nativeobjects", "true")
+
+	tree, refs := scan()
+	assert.Equal(t, expectedTree, tree)
+	assert.Equal(t, expectedRefs, refs)
+
+	test.RunGitCommand(t, true, "repack", "-adf")
+	tree, refs = scan()
+	assert.Equal(t, expectedTree, tree)
+	assert.Equal(t, expectedRefs, refs)
+}
+
+func TestScanCancelled(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	repo.AddCommits([]*test.CommitInput{
+		{
+			Files: []*test.FileInput{
+				{Filename: "file1.txt", Size: 20},
+				{Filename: "file2.txt", Size: 30},
+			},
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	pointers, err := ScanRefs(ctx, "master", "", nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, len(pointers))
+
+	pointers, err = ScanTree(ctx, "master")
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, len(pointers))
+
+	pointers, err = ScanUnpushed(ctx, "")
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, len(pointers))
+
+	config.Config.SetConfig("lfs.nativeobjects", "true")
+	defer config.Config.ResetConfig()
+
+	pointers, err = ScanRefs(ctx, "master", "", nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, len(pointers))
+
+	pointers, err = ScanTree(ctx, "master")
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, len(pointers))
+}
+
+func TestScanRefsToChanStopsWhenCancelled(t *testing.T) {
+	repo := test.NewRepo(t)
+	repo.Pushd()
+	defer func() {
+		repo.Popd()
+		repo.Cleanup()
+	}()
+
+	var inputs []*test.CommitInput
+	for i := 0; i < 10; i++ {
+		inputs = append(inputs, &test.CommitInput{
+			Files: []*test.FileInput{
+				{Filename: "file.txt", Size: int64(20 + i)},
+			},
+		})
+	}
+	repo.AddCommits(inputs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := ScanRefsToChan(ctx, "master", "", nil)
+	assert.Nil(t, err)
+
+	// stop reading after the first pointer
+	_, ok := <-s.Results
+	assert.True(t, ok)
+	cancel()
+
+	err = s.Wait()
+	assert.True(t, err == nil || err == context.Canceled, "unexpected error: %v", err)
+}
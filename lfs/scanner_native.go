@@ -0,0 +1,258 @@
+package lfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/github/git-lfs/config"
+	"github.com/github/git-lfs/git/odb"
+	"github.com/github/git-lfs/subprocess"
+	"github.com/rubyist/tracerx"
+)
+
+var (
+	nativeDb      *odb.ObjectDatabase
+	nativeDbDirs  string
+	nativeDbMutex sync.Mutex
+)
+
+// OpenObjectDatabase opens the object database of the current repository. In
+// a pre-receive hook this includes the objects being pushed, which git keeps
+// in quarantine and gives hooks with GIT_OBJECT_DIRECTORY and
+// GIT_ALTERNATE_OBJECT_DIRECTORIES.
+func OpenObjectDatabase() (*odb.ObjectDatabase, error) {
+	dir, alternates := objectDirs()
+	return odb.OpenWithAlternates(dir, alternates)
+}
+
+// objectDirs returns the objects directory of the current repository, and
+// any alternates given in the environment.
+func objectDirs() (string, []string) {
+	dir := config.Config.Getenv("GIT_OBJECT_DIRECTORY")
+	if len(dir) == 0 {
+		dir = filepath.Join(config.LocalGitStorageDir, "objects")
+	}
+
+	var alternates []string
+	if alt := config.Config.Getenv("GIT_ALTERNATE_OBJECT_DIRECTORIES"); len(alt) > 0 {
+		alternates = filepath.SplitList(alt)
+	}
+	return dir, alternates
+}
+
+// nativeObjectDatabase returns the object database of the current repository,
+// to be read directly instead of by running git, if lfs.nativeobjects is set.
+// Otherwise, or if it cannot be opened, it returns nil.
+func nativeObjectDatabase() *odb.ObjectDatabase {
+	if !config.Config.NativeObjects() || len(config.LocalGitStorageDir) == 0 {
+		return nil
+	}
+
+	nativeDbMutex.Lock()
+	defer nativeDbMutex.Unlock()
+
+	dir, alternates := objectDirs()
+	dirs := strings.Join(append([]string{dir}, alternates...), string(filepath.ListSeparator))
+	if nativeDb != nil && nativeDbDirs == dirs {
+		return nativeDb
+	}
+	if nativeDb != nil {
+		nativeDb.Close()
+		nativeDb = nil
+	}
+
+	db, err := odb.OpenWithAlternates(dir, alternates)
+	if err != nil {
+		tracerx.Printf("scanner: unable to read objects in %s, using git: %v", dirs, err)
+		return nil
+	}
+	nativeDb, nativeDbDirs = db, dirs
+	return db
+}
+
+// catFileBatchCheckNative does the same as catFileBatchCheck, reading the
+// objects from db.
+func catFileBatchCheckNative(ctx context.Context, db *odb.ObjectDatabase, revs *StringChannelWrapper) (*StringChannelWrapper, error) {
+	smallRevs := make(chan string, chanBufSize)
+	errchan := make(chan error, 2)
+
+	go func() {
+	loop:
+		for sha := range revs.Results {
+			if ctx.Err() != nil {
+				break
+			}
+			objectType, size, err := db.Stat(sha)
+			if err != nil {
+				// git cat-file --batch-check skips missing objects too
+				tracerx.Printf("scanner: %v", err)
+				continue
+			}
+			if objectType == "blob" && size < blobSizeCutoff {
+				select {
+				case smallRevs <- sha:
+				case <-ctx.Done():
+					break loop
+				}
+			}
+		}
+
+		if err := revs.Wait(); err != nil {
+			errchan <- err
+		}
+		if err := ctx.Err(); err != nil {
+			errchan <- err
+		}
+		close(smallRevs)
+		close(errchan)
+	}()
+
+	return NewStringChannelWrapper(smallRevs, errchan), nil
+}
+
+// catFileBatchNative does the same as catFileBatch, reading the objects from
+// db.
+func catFileBatchNative(ctx context.Context, db *odb.ObjectDatabase, revs *StringChannelWrapper) (*PointerChannelWrapper, error) {
+	pointers := make(chan *WrappedPointer, chanBufSize)
+	errchan := make(chan error, 2)
+
+	go func() {
+	loop:
+		for sha := range revs.Results {
+			if ctx.Err() != nil {
+				break
+			}
+			if p := readNativePointer(db, sha, ""); p != nil {
+				select {
+				case pointers <- p:
+				case <-ctx.Done():
+					break loop
+				}
+			}
+		}
+
+		if err := revs.Wait(); err != nil {
+			errchan <- err
+		}
+		if err := ctx.Err(); err != nil {
+			errchan <- err
+		}
+		close(pointers)
+		close(errchan)
+	}()
+
+	return NewPointerChannelWrapper(pointers, errchan), nil
+}
+
+// catFileBatchTreeNative does the same as catFileBatchTree, reading the
+// objects from db.
+func catFileBatchTreeNative(ctx context.Context, db *odb.ObjectDatabase, treeblobs *TreeBlobChannelWrapper) (*PointerChannelWrapper, error) {
+	pointers := make(chan *WrappedPointer, chanBufSize)
+	errchan := make(chan error, 2)
+
+	go func() {
+	loop:
+		for t := range treeblobs.Results {
+			if ctx.Err() != nil {
+				break
+			}
+			if p := readNativePointer(db, t.Sha1, t.Filename); p != nil {
+				select {
+				case pointers <- p:
+				case <-ctx.Done():
+					break loop
+				}
+			}
+		}
+
+		if err := treeblobs.Wait(); err != nil {
+			errchan <- err
+		}
+		if err := ctx.Err(); err != nil {
+			errchan <- err
+		}
+		close(pointers)
+		close(errchan)
+	}()
+
+	return NewPointerChannelWrapper(pointers, errchan), nil
+}
+
+// readNativePointer returns the Git LFS pointer in the given object, or nil if
+// it is not a pointer or cannot be read.
+func readNativePointer(db *odb.ObjectDatabase, sha, name string) *WrappedPointer {
+	obj, err := db.Read(sha)
+	if err != nil {
+		tracerx.Printf("scanner: %v", err)
+		return nil
+	}
+
+	p, err := DecodePointer(bytes.NewReader(obj.Data))
+	if err != nil {
+		return nil
+	}
+	return &WrappedPointer{Sha1: sha, Name: name, Size: p.Size, Pointer: p}
+}
+
+// lsTreeBlobsNative does the same as lsTreeBlobs, reading the trees from db.
+func lsTreeBlobsNative(ctx context.Context, db *odb.ObjectDatabase, ref string) (*TreeBlobChannelWrapper, error) {
+	tree, err := subprocess.SimpleExec("git", "rev-parse", "--verify", "--quiet", ref+"^{tree}")
+	if err != nil || len(tree) == 0 {
+		return nil, fmt.Errorf("Error in git rev-parse: %q is not a tree-ish: %v", ref, err)
+	}
+
+	blobs := make(chan TreeBlob, chanBufSize)
+	errchan := make(chan error, 1)
+
+	go func() {
+		if err := walkNativeTree(ctx, db, strings.TrimSpace(tree), "", blobs); err != nil {
+			errchan <- err
+		}
+		close(blobs)
+		close(errchan)
+	}()
+
+	return NewTreeBlobChannelWrapper(blobs, errchan), nil
+}
+
+// walkNativeTree sends each blob under the given tree which is small enough to
+// be a pointer to output, in the order `git ls-tree -r` lists them. It stops
+// with the context's error if ctx is cancelled.
+func walkNativeTree(ctx context.Context, db *odb.ObjectDatabase, sha, dir string, output chan TreeBlob) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tree, err := db.ReadTree(sha)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range tree.Entries {
+		name := path.Join(dir, entry.Name)
+		switch entry.Type() {
+		case "tree":
+			if err := walkNativeTree(ctx, db, entry.Sha, name, output); err != nil {
+				return err
+			}
+		case "blob":
+			_, size, err := db.Stat(entry.Sha)
+			if err != nil {
+				return err
+			}
+			if size < blobSizeCutoff {
+				select {
+				case output <- TreeBlob{entry.Sha, name}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package lfs
 
 import (
+	"context"
+	"errors"
 	"strings"
 	"testing"
 
@@ -101,7 +103,7 @@ func TestParseLogOutputToPointersAdditions(t *testing.T) {
 	r := strings.NewReader(pointerParseLogOutput)
 	pchan := make(chan *WrappedPointer, chanBufSize)
 	go func() {
-		parseLogOutputToPointers(r, LogDiffAdditions, nil, nil, pchan)
+		parseLogOutputToPointers(context.Background(), r, LogDiffAdditions, nil, nil, pchan)
 		close(pchan)
 	}()
 	pointers := make([]*WrappedPointer, 0, 5)
@@ -136,7 +138,7 @@ func TestParseLogOutputToPointersAdditions(t *testing.T) {
 	pointers = pointers[:0]
 	pchan = make(chan *WrappedPointer, chanBufSize)
 	go func() {
-		parseLogOutputToPointers(r, LogDiffAdditions, []string{"wave*"}, nil, pchan)
+		parseLogOutputToPointers(context.Background(), r, LogDiffAdditions, []string{"wave*"}, nil, pchan)
 		close(pchan)
 	}()
 	for p := range pchan {
@@ -152,7 +154,7 @@ func TestParseLogOutputToPointersAdditions(t *testing.T) {
 	pointers = pointers[:0]
 	pchan = make(chan *WrappedPointer, chanBufSize)
 	go func() {
-		parseLogOutputToPointers(r, LogDiffAdditions, nil, []string{"wave*"}, pchan)
+		parseLogOutputToPointers(context.Background(), r, LogDiffAdditions, nil, []string{"wave*"}, pchan)
 		close(pchan)
 	}()
 	for p := range pchan {
@@ -180,7 +182,7 @@ func TestParseLogOutputToPointersDeletion(t *testing.T) {
 	r := strings.NewReader(pointerParseLogOutput)
 	pchan := make(chan *WrappedPointer, chanBufSize)
 	go func() {
-		parseLogOutputToPointers(r, LogDiffDeletions, nil, nil, pchan)
+		parseLogOutputToPointers(context.Background(), r, LogDiffDeletions, nil, nil, pchan)
 		close(pchan)
 	}()
 	pointers := make([]*WrappedPointer, 0, 5)
@@ -212,7 +214,7 @@ func TestParseLogOutputToPointersDeletion(t *testing.T) {
 	pointers = pointers[:0]
 	pchan = make(chan *WrappedPointer, chanBufSize)
 	go func() {
-		parseLogOutputToPointers(r, LogDiffDeletions, []string{"flare*"}, nil, pchan)
+		parseLogOutputToPointers(context.Background(), r, LogDiffDeletions, []string{"flare*"}, nil, pchan)
 		close(pchan)
 	}()
 	for p := range pchan {
@@ -228,7 +230,7 @@ func TestParseLogOutputToPointersDeletion(t *testing.T) {
 	pointers = pointers[:0]
 	pchan = make(chan *WrappedPointer, chanBufSize)
 	go func() {
-		parseLogOutputToPointers(r, LogDiffDeletions, nil, []string{"flare*"}, pchan)
+		parseLogOutputToPointers(context.Background(), r, LogDiffDeletions, nil, []string{"flare*"}, pchan)
 		close(pchan)
 	}()
 	for p := range pchan {
@@ -251,7 +253,7 @@ func TestLsTreeParser(t *testing.T) {
 	stdout := "100644 blob d899f6551a51cf19763c5955c7a06a2726f018e9      42	.gitattributes\000100644 blob 4d343e022e11a8618db494dc3c501e80c7e18197     126	PB SCN 16 Odhrán.wav"
 
 	blobs := make(chan TreeBlob, 2)
-	parseLsTree(strings.NewReader(stdout), blobs)
+	parseLsTree(context.Background(), strings.NewReader(stdout), blobs)
 	close(blobs)
 
 	<-blobs // gitattributes
@@ -265,12 +267,56 @@ func TestLsTreeParser(t *testing.T) {
 	}
 }
 
+func TestParseLogOutputToPointersCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := strings.NewReader(pointerParseLogOutput)
+	pchan := make(chan *WrappedPointer) // unbuffered, and never read
+	cancel()
+
+	// must return without blocking on pchan
+	parseLogOutputToPointers(ctx, r, LogDiffAdditions, nil, nil, pchan)
+	close(pchan)
+
+	_, ok := <-pchan
+	assert.False(t, ok)
+}
+
+func TestChannelWrapperWaitDrainsResults(t *testing.T) {
+	pchan := make(chan *WrappedPointer)
+	errchan := make(chan error, 1)
+	go func() {
+		for i := 0; i < 3; i++ {
+			pchan <- &WrappedPointer{}
+		}
+		errchan <- errors.New("error 1")
+		close(pchan)
+		close(errchan)
+	}()
+
+	// none of the results are read before Wait()
+	err := NewPointerChannelWrapper(pchan, errchan).Wait()
+	assert.EqualError(t, err, "error 1")
+}
+
+func TestChannelWrapperWaitCancelled(t *testing.T) {
+	errchan := make(chan error, 3)
+	errchan <- errors.New("Error in git cat-file: signal: killed")
+	errchan <- context.Canceled
+	errchan <- context.Canceled
+	close(errchan)
+	revs := make(chan string)
+	close(revs)
+
+	err := NewStringChannelWrapper(revs, errchan).Wait()
+	assert.Equal(t, context.Canceled, err)
+}
+
 func BenchmarkLsTreeParser(b *testing.B) {
 	stdout := "100644 blob d899f6551a51cf19763c5955c7a06a2726f018e9      42	.gitattributes\000100644 blob 4d343e022e11a8618db494dc3c501e80c7e18197     126	PB SCN 16 Odhrán.wav"
 	blobs := make(chan TreeBlob, b.N*2)
 	// run the Fib function b.N times
 	for n := 0; n < b.N; n++ {
-		parseLsTree(strings.NewReader(stdout), blobs)
+		parseLsTree(context.Background(), strings.NewReader(stdout), blobs)
 	}
 	close(blobs)
 }
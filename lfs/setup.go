@@ -19,10 +19,22 @@ var (
 		},
 	}
 
+	// preCommitHook invokes `git lfs fsck --pointers --staged` at the
+	// pre-commit phase, to stop files that should be pointers from being
+	// committed without Git LFS. It is only installed on request.
+	preCommitHook = &Hook{
+		Type:     "pre-commit",
+		Contents: "#!/bin/sh\ncommand -v git-lfs >/dev/null 2>&1 || { echo >&2 \"\\nThis repository is configured for Git LFS but 'git-lfs' was not found on your path. If you no longer wish to use Git LFS, remove this hook by deleting .git/hooks/pre-commit.\\n\"; exit 2; }\ngit lfs fsck --pointers --staged",
+	}
+
 	hooks = []*Hook{
 		prePushHook,
 	}
 
+	optionalHooks = []*Hook{
+		preCommitHook,
+	}
+
 	filters = &Attribute{
 		Section: "filter.lfs",
 		Properties: map[string]string{
@@ -65,7 +77,15 @@ func InstallHooks(force bool) error {
 	return nil
 }
 
-// UninstallHooks removes all hooks in range of the `hooks` var.
+// InstallPointerCheckHook installs the pre-commit hook which checks that the
+// files being committed are pointers if and only if they are tracked by Git
+// LFS.
+func InstallPointerCheckHook(force bool) error {
+	return preCommitHook.Install(force)
+}
+
+// UninstallHooks removes all hooks in range of the `hooks` var, and those in
+// `optionalHooks` which are installed.
 func UninstallHooks() error {
 	for _, h := range hooks {
 		if err := h.Uninstall(); err != nil {
@@ -73,6 +93,15 @@ func UninstallHooks() error {
 		}
 	}
 
+	for _, h := range optionalHooks {
+		if !h.Exists() {
+			continue
+		}
+		if err := h.Uninstall(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -191,6 +191,11 @@ func (q *TransferQueue) handleTransferResult(res transfer.TransferResult) {
 		}
 	} else {
 		oid := res.Transfer.Object.Oid
+		if q.direction == transfer.Download {
+			if err := LinkToSharedStorage(oid, res.Transfer.Object.Size); err != nil {
+				tracerx.Printf("tq: unable to add %s to shared storage: %s", oid, err)
+			}
+		}
 		for _, c := range q.watchers {
 			c <- oid
 		}
@@ -8,6 +8,7 @@ import (
 	"github.com/github/git-lfs/api"
 	"github.com/github/git-lfs/config"
 	"github.com/github/git-lfs/errutil"
+	"github.com/github/git-lfs/localstorage"
 	"github.com/github/git-lfs/transfer"
 )
 
@@ -63,12 +64,13 @@ func NewUploadable(oid, filename string) (*Uploadable, error) {
 		}
 	}
 
-	fi, err := os.Stat(localMediaPath)
+	obj, err := localstorage.CurrentStore().Stat(oid)
 	if err != nil {
 		return nil, errutil.Errorf(err, "Error uploading file %s (%s)", filename, oid)
 	}
+	RecordObjectAccess(oid)
 
-	return &Uploadable{oid: oid, OidPath: localMediaPath, Filename: filename, size: fi.Size()}, nil
+	return &Uploadable{oid: oid, OidPath: localMediaPath, Filename: filename, size: obj.Size}, nil
 }
 
 // NewUploadQueue builds an UploadQueue, allowing `workers` concurrent uploads.
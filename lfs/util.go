@@ -2,7 +2,6 @@ package lfs
 
 import (
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -244,8 +243,11 @@ func IsWindows() bool {
 	return GetPlatform() == PlatformWindows
 }
 
+// CopyFileContents copies src to dst, through a temporary file alongside dst so
+// that dst is replaced atomically, even if it is on a different device to the
+// LFS temp directory. The copy is a reflink where the filesystem supports it.
 func CopyFileContents(src string, dst string) error {
-	tmp, err := ioutil.TempFile(TempDir(), filepath.Base(dst))
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), ".tmp-"+filepath.Base(dst))
 	if err != nil {
 		return err
 	}
@@ -258,7 +260,7 @@ func CopyFileContents(src string, dst string) error {
 		return err
 	}
 	defer in.Close()
-	_, err = io.Copy(tmp, in)
+	_, err = tools.CopyWithCallback(tmp, in, 0, nil)
 	if err != nil {
 		return err
 	}
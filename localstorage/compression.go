@@ -0,0 +1,190 @@
+package localstorage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// compressedMagic starts every compressed object file. It is followed by
+	// the name of the compression algorithm, a space, the oid of the object, a
+	// space, the size of the uncompressed object padded to
+	// compressedSizeWidth digits, and a newline.
+	compressedMagic     = "\x00git-lfs-compressed\x00"
+	compressedOidWidth  = 64
+	compressedSizeWidth = 20
+
+	// maxCompressedHeader is longer than any header this package writes.
+	maxCompressedHeader = 128
+)
+
+// compressionAlgorithms are the supported values of lfs.storage.compression.
+var compressionAlgorithms = map[string]bool{
+	"gzip": true,
+	"zstd": true,
+}
+
+// ValidCompression returns whether the given lfs.storage.compression
+// algorithm is supported. An empty string or "none" turns compression off.
+func ValidCompression(algorithm string) bool {
+	return len(algorithm) == 0 || algorithm == "none" || compressionAlgorithms[algorithm]
+}
+
+// ObjectFileSize returns the size of the object in the object file at path,
+// which is smaller than the file if it is compressed.
+func ObjectFileSize(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if fi.IsDir() {
+		return 0, fmt.Errorf("%s is a directory", path)
+	}
+
+	_, size := readCompressionHeader(bufio.NewReaderSize(f, maxCompressedHeader), filepath.Base(path))
+	if size < 0 {
+		return fi.Size(), nil
+	}
+	return size, nil
+}
+
+// ObjectFileExistsOfSize returns whether the file at path holds an object of
+// the given size, whether or not it is compressed.
+func ObjectFileExistsOfSize(path string, size int64) bool {
+	actual, err := ObjectFileSize(path)
+	return err == nil && actual == size
+}
+
+// openObjectFile returns a reader for the object in the object file at path,
+// decompressing it if needed. Uncompressed objects are returned as the
+// *os.File, so that they can be seeked.
+func openObjectFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReaderSize(f, maxCompressedHeader)
+	algorithm, _ := readCompressionHeader(br, filepath.Base(path))
+	if len(algorithm) == 0 {
+		if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+
+	r, err := newDecompressor(algorithm, br)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("Error reading compressed object %s: %s", path, err)
+	}
+	return &decompressingReader{r, f}, nil
+}
+
+// readCompressionHeader reads the header of a compressed object file from r,
+// returning the compression algorithm and the size of the uncompressed
+// object. The header names the oid of the object, so an uncompressed object
+// which happens to start with compressedMagic, such as a compressed object
+// file committed as a file of its own, can't be mistaken for a compressed
+// one: its oid is the hash of a file containing the oid in the header. If the
+// file is not compressed with a valid header for oid, nothing is read from r
+// and the algorithm is empty, with a size of -1.
+func readCompressionHeader(r *bufio.Reader, oid string) (string, int64) {
+	header, _ := r.Peek(maxCompressedHeader)
+	if !bytes.HasPrefix(header, []byte(compressedMagic)) {
+		return "", -1
+	}
+
+	line := header[len(compressedMagic):]
+	end := bytes.IndexByte(line, '\n')
+	if end < 0 {
+		return "", -1
+	}
+
+	parts := strings.Split(string(line[:end]), " ")
+	if len(parts) != 3 || !compressionAlgorithms[parts[0]] || parts[1] != oid || len(parts[2]) != compressedSizeWidth {
+		return "", -1
+	}
+
+	size, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || size < 0 {
+		return "", -1
+	}
+
+	r.Discard(len(compressedMagic) + end + 1)
+	return parts[0], size
+}
+
+// writeCompressionHeader writes the header of an object file compressed with
+// the given algorithm to w, with placeholders for the oid and size of the
+// object, which are written at the returned offset by writeCompressedObject.
+func writeCompressionHeader(w io.Writer, algorithm string) (int64, error) {
+	header := compressedMagic + algorithm + " "
+	placeholder := strings.Repeat("0", compressedOidWidth) + " " + strings.Repeat("0", compressedSizeWidth)
+	if _, err := io.WriteString(w, header+placeholder+"\n"); err != nil {
+		return 0, err
+	}
+	return int64(len(header)), nil
+}
+
+// writeCompressedObject writes the oid and size of the uncompressed object
+// into the header of the compressed object file f, at the given offset.
+func writeCompressedObject(f *os.File, offset int64, oid string, size int64) error {
+	if len(oid) != compressedOidWidth {
+		return fmt.Errorf("Invalid object oid: %q", oid)
+	}
+	_, err := f.WriteAt([]byte(fmt.Sprintf("%s %0*d", oid, compressedSizeWidth, size)), offset)
+	return err
+}
+
+func newCompressor(algorithm string, w io.Writer) (io.WriteCloser, error) {
+	switch algorithm {
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	case "zstd":
+		return zstd.NewWriter(w)
+	}
+	return nil, fmt.Errorf("Unsupported compression algorithm: %q", algorithm)
+}
+
+func newDecompressor(algorithm string, r io.Reader) (io.ReadCloser, error) {
+	switch algorithm {
+	case "gzip":
+		return gzip.NewReader(r)
+	case "zstd":
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("Unsupported compression algorithm: %q", algorithm)
+}
+
+// decompressingReader reads a decompressed object, and closes both the
+// decompressor and the underlying file.
+type decompressingReader struct {
+	io.ReadCloser
+	f *os.File
+}
+
+func (r *decompressingReader) Close() error {
+	r.ReadCloser.Close()
+	return r.f.Close()
+}
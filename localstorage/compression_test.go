@@ -0,0 +1,147 @@
+package localstorage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressedObjectsRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{"gzip", "zstd"} {
+		s := newTestStorage(t)
+		s.Compression = algorithm
+
+		contents := strings.Repeat("compressible,csv,data\n", 1000)
+		writeTestObject(t, s, testOid1, contents)
+
+		raw, err := ioutil.ReadFile(s.ObjectPath(testOid1))
+		assert.Nil(t, err, algorithm)
+		assert.True(t, bytes.HasPrefix(raw, []byte(compressedMagic+algorithm+" ")), algorithm)
+		assert.True(t, len(raw) < len(contents), algorithm)
+
+		assert.True(t, s.Has(testOid1, int64(len(contents))), algorithm)
+		obj, err := s.Stat(testOid1)
+		assert.Nil(t, err, algorithm)
+		assert.Equal(t, int64(len(contents)), obj.Size, algorithm)
+		assert.Equal(t, []Object{{testOid1, int64(len(contents))}}, s.AllObjects(), algorithm)
+
+		assert.Equal(t, contents, readTestObject(t, s, testOid1), algorithm)
+		assertNoTempFiles(t, s)
+
+		os.RemoveAll(filepath.Dir(s.RootDir))
+	}
+}
+
+func TestUncompressedObjectsReadWithCompression(t *testing.T) {
+	s := newTestStorage(t)
+	defer os.RemoveAll(filepath.Dir(s.RootDir))
+
+	writeTestObject(t, s, testOid1, "uncompressed")
+	s.Compression = "zstd"
+	writeTestObject(t, s, testOid2, "compressed")
+
+	assert.True(t, s.Has(testOid1, 12))
+	assert.Equal(t, "uncompressed", readTestObject(t, s, testOid1))
+	assert.True(t, s.Has(testOid2, 10))
+	assert.Equal(t, "compressed", readTestObject(t, s, testOid2))
+
+	// compressed objects are still read once compression is turned off
+	s.Compression = "none"
+	assert.Equal(t, "compressed", readTestObject(t, s, testOid2))
+}
+
+func TestImportFileCompresses(t *testing.T) {
+	s := newTestStorage(t)
+	defer os.RemoveAll(filepath.Dir(s.RootDir))
+	s.Compression = "gzip"
+
+	path := filepath.Join(s.TempDir, "download")
+	if err := ioutil.WriteFile(path, []byte("downloaded"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, s.ImportFile(testOid1, path))
+
+	raw, err := ioutil.ReadFile(s.ObjectPath(testOid1))
+	assert.Nil(t, err)
+	assert.True(t, bytes.HasPrefix(raw, []byte(compressedMagic)))
+	assert.Equal(t, "downloaded", readTestObject(t, s, testOid1))
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestInvalidCompressedObjectHeaderIsReadRaw(t *testing.T) {
+	s := newTestStorage(t)
+	defer os.RemoveAll(filepath.Dir(s.RootDir))
+
+	for _, contents := range []string{
+		compressedMagic + "lzma 5\nhello",
+		compressedMagic + "gzip " + testOid1 + " 5\nhello",
+		compressedMagic + "gzip",
+		compressedMagic,
+	} {
+		path, err := s.BuildObjectPath(testOid1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, s.Has(testOid1, int64(len(contents))), contents)
+		assert.Equal(t, contents, readTestObject(t, s, testOid1), contents)
+	}
+}
+
+func TestCompressedObjectFileStoredAsObjectIsReadRaw(t *testing.T) {
+	s := newTestStorage(t)
+	defer os.RemoveAll(filepath.Dir(s.RootDir))
+	s.Compression = "gzip"
+
+	// an object whose contents are another store's compressed object file
+	writeTestObject(t, s, testOid1, "compressed")
+	raw, err := ioutil.ReadFile(s.ObjectPath(testOid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Compression = "none"
+	path, err := s.BuildObjectPath(testOid2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, s.Has(testOid2, int64(len(raw))))
+	assert.Equal(t, string(raw), readTestObject(t, s, testOid2))
+	assert.Equal(t, "compressed", readTestObject(t, s, testOid1))
+}
+
+func TestValidCompression(t *testing.T) {
+	for _, algorithm := range []string{"", "none", "gzip", "zstd"} {
+		assert.True(t, ValidCompression(algorithm), algorithm)
+	}
+	assert.False(t, ValidCompression("lzma"))
+}
+
+func readTestObject(t *testing.T, s *LocalStorage, oid string) string {
+	r, err := s.Open(oid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	by, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(by)
+}
@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/github/git-lfs/config"
 )
@@ -17,14 +19,62 @@ const (
 
 var (
 	objects        *LocalStorage
+	store          Store
+	shared         *LocalStorage
+	configureOnce  sync.Once
 	TempDir        = filepath.Join(os.TempDir(), "git-lfs")
 	checkedTempDir string
 )
 
+// Objects returns the repository's LocalStorage, for code which needs the
+// paths of object files. Everything else should use CurrentStore.
 func Objects() *LocalStorage {
+	configureOnce.Do(configure)
 	return objects
 }
 
+// CurrentStore returns the Store that the repository's objects are kept in,
+// which is Objects() unless SetCurrentStore has been called.
+func CurrentStore() Store {
+	configureOnce.Do(configure)
+	return store
+}
+
+// SetCurrentStore replaces the Store that the repository's objects are kept
+// in. It must be called after ResolveDirs, which resets it.
+func SetCurrentStore(s Store) {
+	store = s
+}
+
+// SharedObjects returns the user-wide object cache set by lfs.storage.shared,
+// or nil if there is none.
+func SharedObjects() *LocalStorage {
+	configureOnce.Do(configure)
+	return shared
+}
+
+// configure applies lfs.storage.compression and lfs.storage.shared. It is run
+// once on first use after each ResolveDirs, rather than by ResolveDirs itself,
+// because ResolveDirs runs on init, and git config read then would be cached
+// before 'git lfs clone' has moved into the new repository.
+func configure() {
+	compression, _ := config.Config.GitConfig("lfs.storage.compression")
+	compression = strings.ToLower(compression)
+	if ValidCompression(compression) {
+		objects.Compression = compression
+	} else {
+		fmt.Fprintf(os.Stderr, "WARNING: Unsupported lfs.storage.compression %q, objects will not be compressed.\n", compression)
+	}
+
+	if dir := config.Config.SharedStorageDir(); len(dir) > 0 {
+		sharedObjs, err := NewStorage(dir, filepath.Join(dir, "tmp"))
+		if err != nil {
+			panic(fmt.Sprintf("Error trying to init shared LocalStorage: %s", err))
+		}
+		shared = sharedObjs
+	}
+}
+
 func ResolveDirs() {
 
 	config.ResolveGitBasicDirs()
@@ -40,6 +90,10 @@ func ResolveDirs() {
 	}
 
 	objects = objs
+	store = objs
+	shared = nil
+	configureOnce = sync.Once{}
+
 	config.LocalLogDir = filepath.Join(objs.RootDir, "logs")
 	if err := os.MkdirAll(config.LocalLogDir, localLogDirPerms); err != nil {
 		panic(fmt.Errorf("Error trying to create log directory in '%s': %s", config.LocalLogDir, err))
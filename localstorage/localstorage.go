@@ -22,6 +22,10 @@ var (
 type LocalStorage struct {
 	RootDir string
 	TempDir string
+	// Compression is the algorithm new objects are compressed with, from
+	// lfs.storage.compression. Objects are not compressed if it is empty or
+	// "none", but compressed objects can always be read.
+	Compression string
 }
 
 // Object represents a locally stored LFS object.
@@ -39,7 +43,7 @@ func NewStorage(storageDir, tempDir string) (*LocalStorage, error) {
 		return nil, err
 	}
 
-	return &LocalStorage{storageDir, tempDir}, nil
+	return &LocalStorage{RootDir: storageDir, TempDir: tempDir}, nil
 }
 
 func (s *LocalStorage) ObjectPath(oid string) string {
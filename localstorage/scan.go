@@ -11,8 +11,13 @@ import (
 // object. This does not necessarily mean referenced by commits, just stored.
 // Note: reports final SHA only, extensions are ignored.
 func (s *LocalStorage) AllObjects() []Object {
+	return AllObjects(s)
+}
+
+// AllObjects returns a slice of the objects in the given Store.
+func AllObjects(store Store) []Object {
 	objects := make([]Object, 0, 100)
-	for o := range s.ScanObjectsChan() {
+	for o := range ScanObjectsChan(store) {
 		objects = append(objects, o)
 	}
 	return objects
@@ -23,38 +28,57 @@ func (s *LocalStorage) AllObjects() []Object {
 // just stored. You should not alter the store until this channel is closed.
 // Note: reports final SHA only, extensions are ignored.
 func (s *LocalStorage) ScanObjectsChan() <-chan Object {
+	return ScanObjectsChan(s)
+}
+
+// ScanObjectsChan returns a channel of all the objects in the given Store,
+// which is closed once they have all been sent. You should not alter the store
+// until this channel is closed.
+func ScanObjectsChan(store Store) <-chan Object {
 	ch := make(chan Object, chanBufSize)
 
 	go func() {
 		defer close(ch)
-		scanObjects(s.RootDir, ch)
+		store.Walk(func(o Object) error {
+			ch <- o
+			return nil
+		})
 	}()
 
 	return ch
 }
 
-func scanObjects(dir string, ch chan<- Object) {
+func walkObjects(dir string, fn func(Object) error) error {
 	dirf, err := os.Open(dir)
 	if err != nil {
-		return
+		return nil
 	}
 	defer dirf.Close()
 
 	direntries, err := dirf.Readdir(0)
 	if err != nil {
 		tracerx.Printf("Problem with Readdir in %q: %s", dir, err)
-		return
+		return nil
 	}
 
 	for _, dirfi := range direntries {
 		if dirfi.IsDir() {
 			subpath := filepath.Join(dir, dirfi.Name())
-			scanObjects(subpath, ch)
+			if err := walkObjects(subpath, fn); err != nil {
+				return err
+			}
 		} else {
 			// Make sure it's really an object file & not .DS_Store etc
 			if oidRE.MatchString(dirfi.Name()) {
-				ch <- Object{dirfi.Name(), dirfi.Size()}
+				size := dirfi.Size()
+				if objectSize, err := ObjectFileSize(filepath.Join(dir, dirfi.Name())); err == nil {
+					size = objectSize
+				}
+				if err := fn(Object{dirfi.Name(), size}); err != nil {
+					return err
+				}
 			}
 		}
 	}
+	return nil
 }
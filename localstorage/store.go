@@ -0,0 +1,236 @@
+package localstorage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/github/git-lfs/tools"
+)
+
+// storeTempPrefix begins the names of the temp files that objects are written
+// to in the root of a LocalStorage before they are committed.
+const storeTempPrefix = ".tmp-"
+
+// Store is a local store of LFS objects. The rest of git-lfs reads and writes
+// objects through the current Store (see CurrentStore), so that objects can be
+// kept in other ways without changing the code which uses them. LocalStorage,
+// which keeps each object in its own file, is the default Store.
+type Store interface {
+	// Has returns whether the store holds the object with the given oid and
+	// size.
+	Has(oid string, size int64) bool
+
+	// Stat returns the stored object with the given oid. If there is none, the
+	// error satisfies os.IsNotExist.
+	Stat(oid string) (Object, error)
+
+	// Open returns a reader for the contents of the object with the given oid.
+	// If there is none, the error satisfies os.IsNotExist.
+	Open(oid string) (io.ReadCloser, error)
+
+	// Create returns an ObjectWriter for adding a new object to the store. Its
+	// oid is given once all of it has been written.
+	Create() (ObjectWriter, error)
+
+	// Delete removes the object with the given oid from the store.
+	Delete(oid string) error
+
+	// Walk calls fn for every object in the store, stopping at the first error
+	// returned by fn, which Walk returns.
+	Walk(fn func(Object) error) error
+}
+
+// ObjectWriter writes the contents of a new object to a Store. Nothing is
+// added to the store until Commit is called, and Close discards the contents
+// if Commit has not been called. Close must always be called.
+type ObjectWriter interface {
+	io.WriteCloser
+
+	// Commit adds the contents written so far to the store as the object with
+	// the given oid, replacing any existing object.
+	Commit(oid string) error
+}
+
+// FileImporter is implemented by stores which can take ownership of a file
+// holding a complete object, such as a finished download, without copying it.
+type FileImporter interface {
+	// ImportFile moves the file at path into the store as the object with the
+	// given oid, replacing any existing object.
+	ImportFile(oid, path string) error
+}
+
+// ImportFile adds the file at path to the store as the object with the given
+// oid. The file is moved if the store implements FileImporter, and otherwise
+// is copied into the store and removed.
+func ImportFile(store Store, oid, path string) error {
+	if importer, ok := store.(FileImporter); ok {
+		return importer.ImportFile(oid, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := store.Create()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if _, err := io.Copy(w, f); err != nil {
+		return err
+	}
+	if err := w.Commit(oid); err != nil {
+		return err
+	}
+
+	f.Close()
+	return os.Remove(path)
+}
+
+func (s *LocalStorage) Has(oid string, size int64) bool {
+	return ObjectFileExistsOfSize(s.ObjectPath(oid), size)
+}
+
+func (s *LocalStorage) Stat(oid string) (Object, error) {
+	size, err := ObjectFileSize(s.ObjectPath(oid))
+	if err != nil {
+		return Object{}, err
+	}
+	return Object{Oid: oid, Size: size}, nil
+}
+
+// Open returns a reader for the object with the given oid, which decompresses
+// it if it was stored compressed.
+func (s *LocalStorage) Open(oid string) (io.ReadCloser, error) {
+	return openObjectFile(s.ObjectPath(oid))
+}
+
+// Create returns an ObjectWriter which writes to a temporary file in the root
+// of the store, so that it can be renamed into place by Commit. The name of the
+// file never matches an oid, so it is not mistaken for an object. The object is
+// compressed if s.Compression is set.
+func (s *LocalStorage) Create() (ObjectWriter, error) {
+	f, err := ioutil.TempFile(s.RootDir, storeTempPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.compressing() {
+		return &localObjectWriter{File: f, s: s}, nil
+	}
+
+	headerOffset, err := writeCompressionHeader(f, s.Compression)
+	if err == nil {
+		var c io.WriteCloser
+		if c, err = newCompressor(s.Compression, f); err == nil {
+			return &compressedObjectWriter{f: f, c: c, s: s, headerOffset: headerOffset}, nil
+		}
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil, err
+}
+
+func (s *LocalStorage) Delete(oid string) error {
+	return os.Remove(s.ObjectPath(oid))
+}
+
+func (s *LocalStorage) Walk(fn func(Object) error) error {
+	return walkObjects(s.RootDir, fn)
+}
+
+// ImportFile moves the file at path into the store, unless s.Compression is
+// set, in which case it is compressed into the store and then removed.
+func (s *LocalStorage) ImportFile(oid, path string) error {
+	if s.compressing() {
+		return ImportFile(struct{ Store }{s}, oid, path)
+	}
+	return s.moveIntoStore(oid, path)
+}
+
+func (s *LocalStorage) compressing() bool {
+	return len(s.Compression) > 0 && s.Compression != "none"
+}
+
+func (s *LocalStorage) moveIntoStore(oid, path string) error {
+	objectPath, err := s.BuildObjectPath(oid)
+	if err != nil {
+		return err
+	}
+	return tools.RenameFileCopyPermissions(path, objectPath)
+}
+
+// localObjectWriter is the ObjectWriter for a LocalStorage. It embeds the
+// temporary file so that io.Copy can use its ReadFrom.
+type localObjectWriter struct {
+	*os.File
+	s         *LocalStorage
+	committed bool
+}
+
+func (w *localObjectWriter) Commit(oid string) error {
+	if err := w.File.Close(); err != nil {
+		return err
+	}
+	if err := w.s.moveIntoStore(oid, w.Name()); err != nil {
+		return err
+	}
+	w.committed = true
+	return nil
+}
+
+func (w *localObjectWriter) Close() error {
+	if w.committed {
+		return nil
+	}
+	w.File.Close()
+	return os.Remove(w.Name())
+}
+
+// compressedObjectWriter is the ObjectWriter for a LocalStorage which
+// compresses objects. The oid and size of the object are written into the
+// header of the file once they are known, by Commit.
+type compressedObjectWriter struct {
+	f            *os.File
+	c            io.WriteCloser
+	s            *LocalStorage
+	headerOffset int64
+	size         int64
+	committed    bool
+}
+
+func (w *compressedObjectWriter) Write(p []byte) (int, error) {
+	n, err := w.c.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *compressedObjectWriter) Commit(oid string) error {
+	if err := w.c.Close(); err != nil {
+		return err
+	}
+	if err := writeCompressedObject(w.f, w.headerOffset, oid, w.size); err != nil {
+		return err
+	}
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	if err := w.s.moveIntoStore(oid, w.f.Name()); err != nil {
+		return err
+	}
+	w.committed = true
+	return nil
+}
+
+func (w *compressedObjectWriter) Close() error {
+	if w.committed {
+		return nil
+	}
+	w.c.Close()
+	w.f.Close()
+	return os.Remove(w.f.Name())
+}
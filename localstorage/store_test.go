@@ -0,0 +1,142 @@
+package localstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testOid1 = "1111111111111111111111111111111111111111111111111111111111111111"
+	testOid2 = "2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+func TestLocalStorageCreateAndCommit(t *testing.T) {
+	s := newTestStorage(t)
+	defer os.RemoveAll(filepath.Dir(s.RootDir))
+
+	assert.False(t, s.Has(testOid1, 5))
+	_, err := s.Stat(testOid1)
+	assert.True(t, os.IsNotExist(err))
+
+	w, err := s.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("hello"))
+	assert.Empty(t, s.AllObjects(), "uncommitted objects should not be visible")
+
+	assert.Nil(t, w.Commit(testOid1))
+	assert.Nil(t, w.Close())
+
+	assert.True(t, s.Has(testOid1, 5))
+	assert.False(t, s.Has(testOid1, 4))
+	obj, err := s.Stat(testOid1)
+	assert.Nil(t, err)
+	assert.Equal(t, Object{Oid: testOid1, Size: 5}, obj)
+
+	r, err := s.Open(testOid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	by, err := ioutil.ReadAll(r)
+	r.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(by))
+
+	assert.Nil(t, s.Delete(testOid1))
+	assert.False(t, s.Has(testOid1, 5))
+	assertNoTempFiles(t, s)
+}
+
+func TestLocalStorageCloseDiscardsUncommittedObject(t *testing.T) {
+	s := newTestStorage(t)
+	defer os.RemoveAll(filepath.Dir(s.RootDir))
+
+	w, err := s.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("discarded"))
+	assert.Nil(t, w.Close())
+
+	assert.Empty(t, s.AllObjects())
+	assertNoTempFiles(t, s)
+}
+
+func TestLocalStorageWalk(t *testing.T) {
+	s := newTestStorage(t)
+	defer os.RemoveAll(filepath.Dir(s.RootDir))
+
+	writeTestObject(t, s, testOid1, "a")
+	writeTestObject(t, s, testOid2, "bb")
+
+	var oids []string
+	assert.Nil(t, s.Walk(func(o Object) error {
+		oids = append(oids, o.Oid)
+		return nil
+	}))
+	sort.Strings(oids)
+	assert.Equal(t, []string{testOid1, testOid2}, oids)
+
+	stop := os.ErrInvalid
+	count := 0
+	assert.Equal(t, stop, s.Walk(func(o Object) error {
+		count++
+		return stop
+	}))
+	assert.Equal(t, 1, count)
+}
+
+func TestImportFileCopiesWithoutFileImporter(t *testing.T) {
+	s := newTestStorage(t)
+	defer os.RemoveAll(filepath.Dir(s.RootDir))
+
+	path := filepath.Join(s.TempDir, "download")
+	if err := ioutil.WriteFile(path, []byte("imported"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hide LocalStorage's ImportFile, so the object is copied
+	assert.Nil(t, ImportFile(struct{ Store }{s}, testOid1, path))
+
+	assert.True(t, s.Has(testOid1, 8))
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+	assertNoTempFiles(t, s)
+}
+
+func newTestStorage(t *testing.T) *LocalStorage {
+	dir, err := ioutil.TempDir("", "lfs-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStorage(filepath.Join(dir, "objects"), filepath.Join(dir, "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func writeTestObject(t *testing.T, s *LocalStorage, oid, contents string) {
+	w, err := s.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.Write([]byte(contents))
+	if err := w.Commit(oid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertNoTempFiles(t *testing.T, s *LocalStorage) {
+	matches, err := filepath.Glob(filepath.Join(s.RootDir, ".tmp-*"))
+	assert.Nil(t, err)
+	assert.Empty(t, matches)
+}
@@ -10,6 +10,8 @@ import (
 )
 
 func (s *LocalStorage) ClearTempObjects() error {
+	s.clearStoreTempFiles()
+
 	if len(s.TempDir) == 0 {
 		return nil
 	}
@@ -61,3 +63,31 @@ func shouldDeleteTempObject(s *LocalStorage, path string) bool {
 
 	return false
 }
+
+// clearStoreTempFiles removes the temp files that Create and ImportFile write
+// objects to in the root of the store, if they were not committed or removed
+// an hour later, e.g. because git-lfs was killed while writing them.
+func (s *LocalStorage) clearStoreTempFiles() {
+	d, err := os.Open(s.RootDir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	filenames, _ := d.Readdirnames(-1)
+	for _, filename := range filenames {
+		if !strings.HasPrefix(filename, storeTempPrefix) {
+			continue
+		}
+
+		path := filepath.Join(s.RootDir, filename)
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if time.Since(info.ModTime()) > time.Hour {
+			tracerx.Printf("Removing old tmp object file: %s", path)
+			os.Remove(path)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package localstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClearTempObjectsRemovesOldStoreTempFiles(t *testing.T) {
+	s := newTestStorage(t)
+	defer os.RemoveAll(filepath.Dir(s.RootDir))
+
+	old := filepath.Join(s.RootDir, storeTempPrefix+"old")
+	recent := filepath.Join(s.RootDir, storeTempPrefix+"recent")
+	for _, path := range []string{old, recent} {
+		if err := ioutil.WriteFile(path, []byte("partial"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	modified := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(old, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	writeTestObject(t, s, testOid1, "committed")
+
+	s.ClearTempObjects()
+
+	_, err := os.Stat(old)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(recent)
+	assert.Nil(t, err)
+	assert.True(t, s.Has(testOid1, 9))
+}
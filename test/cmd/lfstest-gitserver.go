@@ -67,7 +67,11 @@ func main() {
 	})
 
 	mux.HandleFunc("/storage/", storageHandler)
-	mux.HandleFunc("/redirect307/", redirect307Handler)
+	mux.HandleFunc("/redirect301/", redirectHandler(301))
+	mux.HandleFunc("/redirect302/", redirectHandler(302))
+	mux.HandleFunc("/redirect303/", redirectHandler(303))
+	mux.HandleFunc("/redirect307/", redirectHandler(307))
+	mux.HandleFunc("/redirect308/", redirectHandler(308))
 	mux.HandleFunc("/locks", locksHandler)
 	mux.HandleFunc("/locks/", locksHandler)
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -698,28 +702,32 @@ func gitHandler(w http.ResponseWriter, r *http.Request) {
 	io.Copy(w, text.R)
 }
 
-func redirect307Handler(w http.ResponseWriter, r *http.Request) {
-	id, ok := reqId(w)
-	if !ok {
-		return
-	}
+// redirectHandler returns a handler which redirects to info/lfs with the given
+// status code.
+func redirectHandler(status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := reqId(w)
+		if !ok {
+			return
+		}
 
-	// Send a redirect to info/lfs
-	// Make it either absolute or relative depending on subpath
-	parts := strings.Split(r.URL.Path, "/")
-	// first element is always blank since rooted
-	var redirectTo string
-	if parts[2] == "rel" {
-		redirectTo = "/" + strings.Join(parts[3:], "/")
-	} else if parts[2] == "abs" {
-		redirectTo = server.URL + "/" + strings.Join(parts[3:], "/")
-	} else {
-		debug(id, "Invalid URL for redirect: %v", r.URL)
-		w.WriteHeader(404)
-		return
+		// Send a redirect to info/lfs
+		// Make it either absolute or relative depending on subpath
+		parts := strings.Split(r.URL.Path, "/")
+		// first element is always blank since rooted
+		var redirectTo string
+		if parts[2] == "rel" {
+			redirectTo = "/" + strings.Join(parts[3:], "/")
+		} else if parts[2] == "abs" {
+			redirectTo = server.URL + "/" + strings.Join(parts[3:], "/")
+		} else {
+			debug(id, "Invalid URL for redirect: %v", r.URL)
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("Location", redirectTo)
+		w.WriteHeader(status)
 	}
-	w.Header().Set("Location", redirectTo)
-	w.WriteHeader(307)
 }
 
 type Committer struct {
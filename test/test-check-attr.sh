@@ -0,0 +1,82 @@
+#!/usr/bin/env bash
+
+. "test/testlib.sh"
+
+begin_test "check-attr"
+(
+  set -e
+
+  reponame="check-attr"
+  git init $reponame
+  cd $reponame
+
+  printf '[attr]lfs filter=lfs diff=lfs merge=lfs -text\n*.dat lfs\n*.bin binary\n' > .gitattributes
+  mkdir -p dir/sub
+  printf '*.dat -filter\n' > dir/.gitattributes
+  printf 'keep.dat filter=lfs\n' > dir/sub/.gitattributes
+  printf 'info.dat !filter\n' > .git/info/attributes
+
+  paths="a.dat dir/a.dat dir/sub/keep.dat info.dat x.bin none"
+  for attr in filter diff text; do
+    git check-attr $attr -- $paths > expected.log
+    git lfs check-attr $attr -- $paths > actual.log
+    diff -u expected.log actual.log
+  done
+
+  [ "$(git check-attr --all -- $paths | sort)" = "$(git lfs check-attr --all $paths | sort)" ]
+  [ "$(git check-attr filter text -- $paths)" = "$(git lfs check-attr filter text -- $paths)" ]
+  [ "$(printf 'a.dat\nx.bin\n' | git check-attr --stdin diff)" = "$(printf 'a.dat\nx.bin\n' | git lfs check-attr --stdin diff)" ]
+
+  # paths are relative to the current directory
+  cd dir
+  [ "a.dat: filter: unset" = "$(git lfs check-attr filter a.dat)" ]
+  [ "../a.dat: filter: lfs" = "$(git lfs check-attr filter ../a.dat)" ]
+)
+end_test
+
+begin_test "check-attr --cached and --source"
+(
+  set -e
+
+  reponame="check-attr-cached"
+  git init $reponame
+  cd $reponame
+
+  printf '*.dat filter=lfs\n' > .gitattributes
+  git add .gitattributes
+  git commit -m "track *.dat"
+
+  printf '*.psd filter=lfs\n' > .gitattributes
+  git add .gitattributes
+  printf '*.bin filter=lfs\n' > .gitattributes
+
+  [ "a.bin: filter: lfs" = "$(git lfs check-attr filter a.bin)" ]
+  [ "a.psd: filter: lfs" = "$(git lfs check-attr --cached filter a.psd)" ]
+  [ "a.bin: filter: unspecified" = "$(git lfs check-attr --cached filter a.bin)" ]
+  [ "a.dat: filter: lfs" = "$(git lfs check-attr --source HEAD filter a.dat)" ]
+  [ "a.psd: filter: unspecified" = "$(git lfs check-attr --source HEAD filter a.psd)" ]
+
+  set +e
+  git lfs check-attr --cached --source HEAD filter a.dat 2> check-attr.log
+  res=$?
+  set -e
+  [ "2" = "$res" ]
+  grep "Cannot use --cached with --source" check-attr.log
+)
+end_test
+
+begin_test "check-attr: outside git repository"
+(
+  set +e
+  git lfs check-attr filter a.dat 2>&1 > check-attr.log
+  res=$?
+
+  set -e
+  if [ "$res" = "0" ]; then
+    echo "Passes because $GIT_LFS_TEST_DIR is unset."
+    exit 0
+  fi
+  [ "$res" = "128" ]
+  grep "Not in a git repository" check-attr.log
+)
+end_test
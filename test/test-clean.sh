@@ -56,3 +56,63 @@ This is my test pointer.  There are many like it, but this one is mine.\n" | git
   [ "$(pointer c2f909f6961bf85a92e2942ef3ed80c938a3d0ebaee6e72940692581052333be 586)" = "$(cat clean.log)" ]
 )
 end_test
+
+begin_test "clean cache"
+(
+  set -e
+  clean_setup "cache"
+
+  printf "cached" > a.dat
+  oid=$(calc_oid "cached")
+  touch -t 201601010000 a.dat
+
+  git lfs clean a.dat < a.dat | tee clean.log
+  [ "$(pointer $oid 6)" = "$(cat clean.log)" ]
+  [ "1" -eq "$(ls .git/lfs/cache/clean | wc -l)" ]
+
+  # an unchanged file is not read again
+  printf "" | git lfs clean a.dat | tee clean.log
+  [ "$(pointer $oid 6)" = "$(cat clean.log)" ]
+
+  printf "" | git -c lfs.cleancache=false lfs clean a.dat | tee clean.log
+  [ "$(pointer $oid 6)" != "$(cat clean.log)" ]
+
+  # nor is a file that is only touched, once it has been cleaned again
+  touch -t 201602010000 a.dat
+  printf "" | git lfs clean a.dat | tee clean.log
+  [ "$(pointer $oid 6)" != "$(cat clean.log)" ]
+  git lfs clean a.dat < a.dat
+  printf "" | git lfs clean a.dat | tee clean.log
+  [ "$(pointer $oid 6)" = "$(cat clean.log)" ]
+
+  # a changed file is read
+  printf "changed" > a.dat
+  touch -t 201602010000 a.dat
+  git lfs clean a.dat < a.dat | tee clean.log
+  [ "$(pointer $(calc_oid "changed") 7)" = "$(cat clean.log)" ]
+
+  # and a recently modified file is never cached
+  printf "recent" > b.dat
+  git lfs clean b.dat < b.dat
+  printf "" | git lfs clean b.dat | tee clean.log
+  [ "$(pointer $(calc_oid "recent") 6)" != "$(cat clean.log)" ]
+)
+end_test
+
+begin_test "clean cache ignores other content for the same path"
+(
+  set -e
+  clean_setup "cache-stdin"
+
+  git lfs track "*.dat"
+  printf "file!" > a.dat
+  touch -t 201601010000 a.dat
+
+  # the same size as a.dat, but not its contents
+  printf "other" | git hash-object --stdin --path=a.dat
+  [ "0" -eq "$(ls .git/lfs/cache/clean 2>/dev/null | wc -l)" ]
+
+  git add a.dat
+  [ "$(pointer $(calc_oid "file!") 5)" = "$(git cat-file -p :a.dat)" ]
+)
+end_test
@@ -104,6 +104,330 @@ begin_test "fsck dry run"
 )
 end_test
 
+begin_test "fsck missing objects"
+(
+  set -e
+
+  reponame="fsck-missing"
+  git init $reponame
+  cd $reponame
+
+  git lfs track "*.dat"
+  printf "a" > a.dat
+  printf "b" > b.dat
+  git add .gitattributes *.dat
+  git commit -m "first commit"
+
+  printf "a2" > a.dat
+  git add a.dat
+  git commit -m "second commit"
+
+  aOid=$(calc_oid "a")
+  delete_local_object "$aOid"
+
+  # the old version of a.dat is in history, not the current checkout
+  set +e
+  git lfs fsck > fsck.log
+  res=$?
+  set -e
+
+  [ "1" = "$res" ]
+  [ "Object a.dat ($aOid) is missing" = "$(cat fsck.log)" ]
+)
+end_test
+
+begin_test "fsck --all and refs"
+(
+  set -e
+
+  reponame="fsck-all"
+  git init $reponame
+  cd $reponame
+
+  git lfs track "*.dat"
+  printf "a" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "first commit"
+
+  git checkout -b other
+  printf "b" > b.dat
+  git add b.dat
+  git commit -m "other commit"
+  git checkout master
+
+  bOid=$(calc_oid "b")
+  delete_local_object "$bOid"
+
+  [ "Git LFS fsck OK" = "$(git lfs fsck)" ]
+  [ "Git LFS fsck OK" = "$(git lfs fsck master)" ]
+
+  [ "Object b.dat ($bOid) is missing" = "$(git lfs fsck --all)" ]
+  [ "Object b.dat ($bOid) is missing" = "$(git lfs fsck master other)" ]
+
+  set +e
+  git lfs fsck --all master 2> fsck.log
+  res=$?
+  set -e
+
+  [ "2" = "$res" ]
+  grep "Cannot use --all with explicit refs" fsck.log
+)
+end_test
+
+begin_test "fsck malformed pointers"
+(
+  set -e
+
+  reponame="fsck-malformed"
+  git init $reponame
+  cd $reponame
+
+  git lfs track "*.dat"
+  printf "a" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "first commit"
+
+  # a pointer without its trailing newline can be read, but is not canonical
+  git cat-file -p HEAD:a.dat | head -c -1 > pointer
+  sha=$(git hash-object -w pointer)
+  git update-index --add --cacheinfo 100644 "$sha" b.dat
+  git commit -m "malformed pointer"
+
+  set +e
+  git lfs fsck > fsck.log
+  res=$?
+  set -e
+
+  [ "1" = "$res" ]
+  [ "Pointer b.dat ($sha) is malformed" = "$(cat fsck.log)" ]
+)
+end_test
+
+begin_test "fsck pointers with an older version"
+(
+  set -e
+
+  reponame="fsck-legacy-version"
+  git init $reponame
+  cd $reponame
+
+  git lfs track "*.bin"
+  printf "legacy" > legacy.bin
+  git add .gitattributes legacy.bin
+  git commit -m "first commit"
+
+  # pointers written before the public launch are still valid
+  git cat-file -p HEAD:legacy.bin |
+    sed "s,^version .*,version https://hawser.github.com/spec/v1," > pointer
+  sha=$(git hash-object -w pointer)
+  git update-index --cacheinfo 100644 "$sha" legacy.bin
+  git commit -m "legacy pointer"
+
+  git lfs fsck > fsck.log
+  [ "Git LFS fsck OK" = "$(cat fsck.log)" ]
+)
+end_test
+
+begin_test "fsck --repair"
+(
+  set -e
+
+  reponame="fsck-repair"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "a" > a.dat
+  printf "b" > b.dat
+  git add .gitattributes *.dat
+  git commit -m "first commit"
+
+  # the old version of a.dat is only in history, so that it can not be
+  # cleaned again from the working copy
+  printf "a2" > a.dat
+  git add a.dat
+  git commit -m "second commit"
+  git push origin master
+
+  aOid=$(calc_oid "a")
+  bOid=$(calc_oid "b")
+  delete_local_object "$aOid"
+  echo "CORRUPTION" >> ".git/lfs/objects/${bOid:0:2}/${bOid:2:2}/$bOid"
+
+  set +e
+  git lfs fsck --repair > fsck.log
+  res=$?
+  set -e
+
+  cat fsck.log
+  [ "0" = "$res" ]
+  grep "Object a.dat ($aOid) is missing" fsck.log
+  grep "Object b.dat ($bOid) is corrupt" fsck.log
+  grep "Object a.dat ($aOid) was repaired" fsck.log
+  grep "Object b.dat ($bOid) was repaired" fsck.log
+  grep "Git LFS fsck OK" fsck.log
+
+  assert_local_object "$aOid" 1
+  assert_local_object "$bOid" 1
+  [ "Git LFS fsck OK" = "$(git lfs fsck)" ]
+
+  # objects which are not on the server can not be repaired
+  printf "c" > c.dat
+  git add c.dat
+  git commit -m "unpushed commit"
+  git rm c.dat
+  git commit -m "remove c.dat"
+  cOid=$(calc_oid "c")
+  delete_local_object "$cOid"
+
+  set +e
+  git lfs fsck --repair > fsck.log
+  res=$?
+  set -e
+
+  cat fsck.log
+  [ "1" = "$res" ]
+  grep "Object c.dat ($cOid) could not be repaired" fsck.log
+
+  set +e
+  git lfs fsck --repair --dry-run 2> fsck.log
+  res=$?
+  set -e
+
+  [ "2" = "$res" ]
+  grep "Cannot use --dry-run with --repair" fsck.log
+)
+end_test
+
+begin_test "fsck --pointers"
+(
+  set -e
+
+  reponame="fsck-pointers"
+  git init $reponame
+  cd $reponame
+
+  git lfs track "*.dat"
+  printf "a" > a.dat
+  printf "" > empty.dat
+  git add .gitattributes a.dat empty.dat
+  git commit -m "first commit"
+
+  [ "Git LFS fsck OK" = "$(git lfs fsck --pointers)" ]
+
+  # a file committed without the clean filter, as if Git LFS were not
+  # installed, and a pointer committed where Git LFS does not track it
+  printf "raw" > raw
+  rawSha=$(git hash-object -w raw)
+  git update-index --add --cacheinfo 100644 "$rawSha" b.dat
+  pointerSha=$(git rev-parse HEAD:a.dat)
+  git update-index --add --cacheinfo 100644 "$pointerSha" a.txt
+
+  expected="$(printf 'Pointer a.txt (%s) is not in a Git LFS path
+File b.dat (%s) should be a Git LFS pointer' "$pointerSha" "$rawSha")"
+
+  set +e
+  git lfs fsck --pointers --staged > fsck.log
+  res=$?
+  set -e
+
+  [ "1" = "$res" ]
+  [ "$expected" = "$(cat fsck.log)" ]
+
+  git commit -m "second commit"
+  git commit --allow-empty -m "third commit"
+
+  [ "Git LFS fsck OK" = "$(git lfs fsck --pointers --staged)" ]
+  [ "Git LFS fsck OK" = "$(git lfs fsck --pointers HEAD^..HEAD)" ]
+  [ "$expected" = "$(git lfs fsck --pointers)" ]
+  [ "$expected" = "$(git lfs fsck --pointers --all)" ]
+  [ "$expected" = "$(git lfs fsck --pointers HEAD~2..HEAD)" ]
+
+  # the objects themselves are not checked
+  delete_local_object "$(calc_oid "a")"
+  [ "$expected" = "$(git lfs fsck --pointers HEAD~2..HEAD)" ]
+
+  set +e
+  git lfs fsck --staged 2> fsck.log
+  res=$?
+  set -e
+  [ "2" = "$res" ]
+  grep "Cannot use --staged without --pointers" fsck.log
+)
+end_test
+
+begin_test "fsck --pointers with quoted file names"
+(
+  set -e
+
+  reponame="fsck-pointers-quoted"
+  git init $reponame
+  cd $reponame
+
+  git lfs track "*.bin"
+  git add .gitattributes
+  git commit -m "add git attributes"
+
+  # core.quotePath escapes the name in git's output unless it is run with -z
+  name="$(printf 'caf\303\251.bin')"
+  printf "raw" > "$name"
+  git -c filter.lfs.clean=cat -c filter.lfs.required=false add "$name"
+  sha=$(git rev-parse ":$name")
+  expected="File $name ($sha) should be a Git LFS pointer"
+
+  set +e
+  git lfs fsck --pointers --staged > fsck.log
+  res=$?
+  set -e
+
+  [ "1" = "$res" ]
+  [ "$expected" = "$(cat fsck.log)" ]
+
+  git commit -m "add raw file"
+  [ "$expected" = "$(git lfs fsck --pointers)" ]
+)
+end_test
+
+begin_test "fsck --pointers uses the attributes of each commit"
+(
+  set -e
+
+  reponame="fsck-pointers-attributes"
+  git init $reponame
+  cd $reponame
+
+  # stored in Git before *.bin was tracked
+  printf "raw" > a.bin
+  git add a.bin
+  git commit -m "before tracking"
+
+  mkdir dir
+  printf '[attr]lfs filter=lfs diff=lfs merge=lfs -text\n*.bin lfs\n' > .gitattributes
+  printf '*.bin -filter\n' > dir/.gitattributes
+  printf "raw in dir" > dir/b.bin
+  git add .gitattributes dir
+  git commit -m "track *.bin"
+
+  [ "Git LFS fsck OK" = "$(git lfs fsck --pointers)" ]
+
+  # dir/b.bin is not changed by the commit which starts tracking it
+  printf "" > dir/.gitattributes
+  git add dir/.gitattributes
+  git commit -m "track dir/*.bin too"
+  [ "Git LFS fsck OK" = "$(git lfs fsck --pointers)" ]
+
+  # but is checked once it is changed again
+  printf "changed in dir" > changed
+  changedSha=$(git hash-object -w changed)
+  git update-index --add --cacheinfo 100644 "$changedSha" dir/b.bin
+  expected="File dir/b.bin ($changedSha) should be a Git LFS pointer"
+  [ "$expected" = "$(git lfs fsck --pointers --staged)" ]
+
+  git commit -m "change dir/b.bin"
+  [ "$expected" = "$(git lfs fsck --pointers)" ]
+)
+end_test
+
 begin_test "fsck: outside git repository"
 (
   set +e
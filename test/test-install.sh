@@ -191,3 +191,59 @@ begin_test "install --local outside repository"
   [ "0" != "$res" ]
 )
 end_test
+
+begin_test "install --pointer-check"
+(
+  set -e
+
+  reponame="install-pointer-check"
+  mkdir "$reponame"
+  cd "$reponame"
+  git init
+
+  pre_commit_hook="#!/bin/sh
+command -v git-lfs >/dev/null 2>&1 || { echo >&2 \"\\nThis repository is configured for Git LFS but 'git-lfs' was not found on your path. If you no longer wish to use Git LFS, remove this hook by deleting .git/hooks/pre-commit.\\n\"; exit 2; }
+git lfs fsck --pointers --staged"
+
+  [ "Updated pre-push hook.
+Updated pre-commit hook.
+Git LFS initialized." = "$(git lfs install --pointer-check)" ]
+  [ "$pre_commit_hook" = "$(cat .git/hooks/pre-commit)" ]
+
+  git lfs track "*.dat"
+  printf "a" > a.dat
+  git add .gitattributes a.dat
+  git commit -m "add a.dat"
+
+  # a file added without the clean filter, as if Git LFS were not installed
+  printf "raw" > raw
+  sha=$(git hash-object -w raw)
+  git update-index --add --cacheinfo 100644 "$sha" b.dat
+
+  set +e
+  git commit -m "add b.dat" > commit.log 2>&1
+  res=$?
+  set -e
+
+  cat commit.log
+  [ "0" != "$res" ]
+  grep "File b.dat ($sha) should be a Git LFS pointer" commit.log
+  [ "add a.dat" = "$(git log -1 --format=%s)" ]
+
+  # don't replace unexpected hook
+  echo "test" > .git/hooks/pre-commit
+  set +e
+  git lfs install --pointer-check > install.log 2>&1
+  res=$?
+  set -e
+  [ "0" != "$res" ]
+  grep "Hook already exists: pre-commit" install.log
+  [ "test" = "$(cat .git/hooks/pre-commit)" ]
+
+  git lfs install --pointer-check --force
+  [ "$pre_commit_hook" = "$(cat .git/hooks/pre-commit)" ]
+
+  git lfs uninstall
+  [ ! -e .git/hooks/pre-commit ]
+)
+end_test
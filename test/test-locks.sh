@@ -88,3 +88,21 @@ begin_test "list locks with pagination"
   grep "4 lock(s) matched query" locks.log
 )
 end_test
+
+begin_test "list locks with --watch rejects --id"
+(
+  set -e
+
+  reponame="locks_watch_id"
+  setup_remote_repo "remote_$reponame"
+  clone_repo "remote_$reponame" "clone_$reponame"
+
+  set +e
+  git lfs locks --watch --id 1 > locks.log 2>&1
+  res=$?
+  set -e
+
+  [ "$res" != "0" ]
+  grep -- "--id cannot be used with --watch" locks.log
+)
+end_test
@@ -0,0 +1,92 @@
+#!/usr/bin/env bash
+
+. "test/testlib.sh"
+
+begin_test "migrate export"
+(
+  set -e
+
+  mkdir migrate-export
+  cd migrate-export
+  git init
+
+  printf "*.txt text\n" > .gitattributes
+  printf "a.bin v1" > a.bin
+  mkdir dir
+  printf "b.bin v1" > dir/b.bin
+  git add .
+  git commit -m "initial commit"
+
+  printf "a.bin v2" > a.bin
+  git commit -am "update a.bin"
+
+  original="$(git rev-parse master)"
+
+  git lfs migrate import --include="*.bin"
+  assert_pointer "master" "a.bin" "$(calc_oid "a.bin v2")" 8
+
+  git lfs migrate export --include="*.bin" 2>&1 | tee migrate.log
+  [ "2" -eq "$(wc -l < migrate.log | tr -d '[[:space:]]')" ]
+
+  # exporting everything that was imported restores the original history
+  [ "$original" = "$(git rev-parse master)" ]
+  [ "a.bin v1" = "$(git cat-file -p master~1:a.bin)" ]
+  [ "*.txt text" = "$(git cat-file -p master:.gitattributes)" ]
+  [ "a.bin v2" = "$(cat a.bin)" ]
+)
+end_test
+
+begin_test "migrate export (removes only the exported patterns)"
+(
+  set -e
+
+  mkdir migrate-export-partial
+  cd migrate-export-partial
+  git init
+
+  git lfs track "*.bin" "*.psd"
+  mkdir dir
+  printf "*.bin filter=lfs diff=lfs merge=lfs -text\n" > dir/.gitattributes
+  printf "a.bin" > a.bin
+  printf "a.psd" > a.psd
+  git add .
+  git commit -m "initial commit"
+
+  git lfs migrate export --include="*.bin"
+
+  [ "a.bin" = "$(git cat-file -p master:a.bin)" ]
+  assert_pointer "master" "a.psd" "$(calc_oid "a.psd")" 5
+  [ "*.psd filter=lfs diff=lfs merge=lfs -text" = "$(git cat-file -p master:.gitattributes)" ]
+  [ -z "$(git ls-tree master dir/.gitattributes)" ]
+)
+end_test
+
+begin_test "migrate export (refuses when objects are missing)"
+(
+  set -e
+
+  mkdir migrate-export-missing
+  cd migrate-export-missing
+  git init
+
+  git lfs track "*.bin"
+  printf "a.bin v1" > a.bin
+  git add .
+  git commit -m "initial commit"
+  printf "a.bin v2" > a.bin
+  git commit -am "update a.bin"
+
+  oid="$(calc_oid "a.bin v1")"
+  delete_local_object "$oid"
+  master="$(git rev-parse master)"
+
+  git lfs migrate export --include="*.bin" 2>&1 | tee migrate.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "expected migrate export to fail"
+    exit 1
+  fi
+  grep "Unable to export, 1 Git LFS objects are not available:" migrate.log
+  grep "a.bin ($oid)" migrate.log
+  [ "$master" = "$(git rev-parse master)" ]
+)
+end_test
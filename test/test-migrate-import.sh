@@ -0,0 +1,132 @@
+#!/usr/bin/env bash
+
+. "test/testlib.sh"
+
+begin_test "migrate import"
+(
+  set -e
+
+  mkdir migrate-import
+  cd migrate-import
+  git init
+
+  printf "*.txt text\n" > .gitattributes
+  printf "a.bin v1" > a.bin
+  mkdir dir
+  printf "b.bin v1" > dir/b.bin
+  printf "readme" > readme.txt
+  git add .
+  git commit -m "initial commit"
+
+  printf "a.bin v2" > a.bin
+  git commit -am "update a.bin"
+  git tag -a -m "release" v1
+
+  git checkout -b feature
+  printf "b.bin v2" > dir/b.bin
+  git commit -am "update b.bin"
+  git checkout master
+
+  old_master="$(git rev-parse master)"
+  old_feature="$(git rev-parse feature)"
+
+  git lfs migrate import --include="*.bin" --everything 2>&1 | tee migrate.log
+  [ "3" -eq "$(wc -l < migrate.log | tr -d '[[:space:]]')" ]
+  grep "^$old_master $(git rev-parse master)\$" migrate.log
+  grep "^$old_feature $(git rev-parse feature)\$" migrate.log
+
+  assert_pointer "master" "a.bin" "$(calc_oid "a.bin v2")" 8
+  assert_pointer "master~1" "a.bin" "$(calc_oid "a.bin v1")" 8
+  assert_pointer "feature" "dir/b.bin" "$(calc_oid "b.bin v2")" 8
+  assert_local_object "$(calc_oid "a.bin v1")" 8
+  assert_local_object "$(calc_oid "b.bin v2")" 8
+  [ "readme" = "$(git cat-file -p master:readme.txt)" ]
+
+  printf "*.txt text\n*.bin filter=lfs diff=lfs merge=lfs -text\n" > expected.gitattributes
+  git cat-file -p master~1:.gitattributes > actual.gitattributes
+  diff -u expected.gitattributes actual.gitattributes
+
+  [ "update a.bin" = "$(git log -1 --format=%s master)" ]
+  [ "$(git rev-parse master)" = "$(git rev-parse "v1^{commit}")" ]
+
+  # the working copy is reset to the rewritten branch
+  [ "a.bin v2" = "$(cat a.bin)" ]
+  [ -z "$(git status --porcelain --untracked-files=no)" ]
+)
+end_test
+
+begin_test "migrate import (current branch only)"
+(
+  set -e
+
+  mkdir migrate-import-current
+  cd migrate-import-current
+  git init
+
+  printf "a.bin" > a.bin
+  git add a.bin
+  git commit -m "initial commit"
+  git branch other
+
+  git lfs migrate import --include="*.bin"
+
+  assert_pointer "master" "a.bin" "$(calc_oid "a.bin")" 5
+  [ "a.bin" = "$(git cat-file -p other:a.bin)" ]
+
+  # running it again changes nothing
+  master="$(git rev-parse master)"
+  [ -z "$(git lfs migrate import --include="*.bin")" ]
+  [ "$master" = "$(git rev-parse master)" ]
+)
+end_test
+
+begin_test "migrate import (exclude)"
+(
+  set -e
+
+  mkdir migrate-import-exclude
+  cd migrate-import-exclude
+  git init
+
+  mkdir keep
+  printf "a.bin" > a.bin
+  printf "keep.bin" > keep/keep.bin
+  git add .
+  git commit -m "initial commit"
+
+  git lfs migrate import --include="*.bin" --exclude="keep/**"
+
+  assert_pointer "master" "a.bin" "$(calc_oid "a.bin")" 5
+  [ "keep.bin" = "$(git cat-file -p master:keep/keep.bin)" ]
+)
+end_test
+
+begin_test "migrate import (requires --include and a clean working copy)"
+(
+  set -e
+
+  mkdir migrate-import-errors
+  cd migrate-import-errors
+  git init
+
+  printf "a.bin" > a.bin
+  git add a.bin
+  git commit -m "initial commit"
+
+  git lfs migrate import 2>&1 | tee migrate.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "expected migrate import without --include to fail"
+    exit 1
+  fi
+  grep "Usage: git lfs migrate import" migrate.log
+
+  printf "changed" > a.bin
+  git lfs migrate import --include="*.bin" 2>&1 | tee migrate.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "expected migrate import with uncommitted changes to fail"
+    exit 1
+  fi
+  grep "uncommitted changes" migrate.log
+  [ "a.bin" = "$(git cat-file -p master:a.bin)" ]
+)
+end_test
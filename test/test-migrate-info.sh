@@ -0,0 +1,72 @@
+#!/usr/bin/env bash
+
+. "test/testlib.sh"
+
+begin_test "migrate info"
+(
+  set -e
+
+  mkdir migrate-info
+  cd migrate-info
+  git init
+
+  git lfs track "*.psd"
+  printf "%0.s-" {1..2048} > a.bin
+  mkdir dir
+  printf "%0.s-" {1..1024} > dir/b.bin
+  printf "small" > small.txt
+  printf "%0.s-" {1..4096} > image.psd
+  git add .
+  git commit -m "initial commit"
+
+  printf "%0.s+" {1..2048} > a.bin
+  git commit -am "update a.bin"
+
+  git lfs migrate info 2>&1 | tee info.log
+  [ "PATTERN" = "$(head -n 1 info.log | awk '{ print $1 }')" ]
+  [ "*.bin" = "$(sed -n 2p info.log | awk '{ print $1 }')" ]
+  grep "^\*\.bin  *5.0 KB  *3  *0 B  *0$" info.log
+  grep "^\*\.psd  *0 B  *0  *4.0 KB  *1$" info.log
+  grep "^\*\.txt  *5 B  *1  *0 B  *0$" info.log
+
+  git lfs migrate info --above=2k --top=1 2>&1 | tee info.log
+  [ "2" -eq "$(wc -l < info.log | tr -d '[[:space:]]')" ]
+  grep "^\*\.bin  *4.0 KB  *2  *0 B  *0$" info.log
+
+  git lfs migrate info --include="dir/**" --json 2>&1 | tee info.log
+  grep '"pattern": "dir/\*\*"' info.log
+  grep '"files": 1' info.log
+  grep '"size": 1024' info.log
+)
+end_test
+
+begin_test "migrate info (refs)"
+(
+  set -e
+
+  mkdir migrate-info-refs
+  cd migrate-info-refs
+  git init
+
+  printf "a" > a.bin
+  git add a.bin
+  git commit -m "initial commit"
+
+  git checkout -b other
+  printf "b" > b.dat
+  git add b.dat
+  git commit -m "add b.dat"
+  git checkout master
+
+  git lfs migrate info 2>&1 | tee info.log
+  grep "^\*\.bin" info.log
+  [ "0" -eq "$(grep -c "^\*\.dat" info.log)" ]
+
+  git lfs migrate info other 2>&1 | tee info.log
+  grep "^\*\.dat" info.log
+
+  git lfs migrate info --everything 2>&1 | tee info.log
+  grep "^\*\.bin" info.log
+  grep "^\*\.dat" info.log
+)
+end_test
@@ -0,0 +1,180 @@
+#!/usr/bin/env bash
+
+. "test/testlib.sh"
+
+begin_test "pin and unpin"
+(
+  set -e
+
+  reponame="pin_and_unpin"
+  setup_remote_repo "remote_$reponame"
+
+  clone_repo "remote_$reponame" "clone_$reponame"
+
+  git lfs track "*.dat" 2>&1 | tee track.log
+  grep "Tracking \*.dat" track.log
+
+  content_a1="a.dat pinned by path"
+  content_b1="b.dat pinned by oid"
+  content_a2="a.dat pinned by tag"
+  content_b2="b.dat pinned by tag"
+  content_r1="r.dat on a protected release branch"
+  content_a3="a.dat current"
+  content_b3="b.dat current"
+  oid_a1=$(calc_oid "$content_a1")
+  oid_b1=$(calc_oid "$content_b1")
+  oid_a2=$(calc_oid "$content_a2")
+  oid_b2=$(calc_oid "$content_b2")
+  oid_r1=$(calc_oid "$content_r1")
+  oid_a3=$(calc_oid "$content_a3")
+  oid_b3=$(calc_oid "$content_b3")
+
+  echo "[
+  {
+    \"Files\":[
+      {\"Filename\":\"a.dat\",\"Size\":${#content_a1}, \"Data\":\"$content_a1\"},
+      {\"Filename\":\"b.dat\",\"Size\":${#content_b1}, \"Data\":\"$content_b1\"}]
+  },
+  {
+    \"Files\":[
+      {\"Filename\":\"a.dat\",\"Size\":${#content_a2}, \"Data\":\"$content_a2\"},
+      {\"Filename\":\"b.dat\",\"Size\":${#content_b2}, \"Data\":\"$content_b2\"}]
+  },
+  {
+    \"NewBranch\":\"release/1.0\",
+    \"Files\":[
+      {\"Filename\":\"a.dat\",\"Size\":${#content_a3}, \"Data\":\"$content_a3\"},
+      {\"Filename\":\"b.dat\",\"Size\":${#content_b3}, \"Data\":\"$content_b3\"},
+      {\"Filename\":\"r.dat\",\"Size\":${#content_r1}, \"Data\":\"$content_r1\"}]
+  },
+  {
+    \"ParentBranches\":[\"master\"],
+    \"Files\":[
+      {\"Filename\":\"a.dat\",\"Size\":${#content_a3}, \"Data\":\"$content_a3\"},
+      {\"Filename\":\"b.dat\",\"Size\":${#content_b3}, \"Data\":\"$content_b3\"}]
+  }
+  ]" | lfstest-testutils addcommits
+
+  git push origin master release/1.0
+  git tag v1.0 master~1
+
+  # only keep the current checkout, unless pinned
+  git config lfs.fetchrecentrefsdays 0
+  git config lfs.fetchrecentcommitsdays 0
+
+  git checkout -q master~2
+  git lfs pin a.dat 2>&1 | tee pin.log
+  grep "Pinned a.dat ($oid_a1)" pin.log
+  git checkout -q master
+
+  git lfs pin "$oid_b1" v1.0 2>&1 | tee pin.log
+  grep "Pinned $oid_b1" pin.log
+  grep "Pinned ref refs/tags/v1.0" pin.log
+
+  git lfs pin v1.0 2>&1 | tee pin.log
+  grep "ref refs/tags/v1.0 already pinned" pin.log
+
+  git lfs pin 2>&1 | tee pin.log
+  grep "Listing pins" pin.log
+  grep "    a.dat ($oid_a1)" pin.log
+  grep "    $oid_b1" pin.log
+  grep "    ref refs/tags/v1.0" pin.log
+  [ -f .git/lfs/pins ]
+
+  git config lfs.pruneprotectrefs "release/*"
+
+  git lfs prune --dry-run --verbose 2>&1 | tee prune.log
+  grep "7 local objects, 7 retained" prune.log
+  grep "7 pinned files retained" prune.log
+  for oid in "$oid_a1" "$oid_b1" "$oid_a2" "$oid_b2" "$oid_r1"; do
+    grep "$oid" prune.log
+  done
+  grep "Nothing to prune" prune.log
+
+  git lfs prune
+  assert_local_object "$oid_a1" "${#content_a1}"
+  assert_local_object "$oid_b1" "${#content_b1}"
+  assert_local_object "$oid_a2" "${#content_a2}"
+  assert_local_object "$oid_b2" "${#content_b2}"
+  assert_local_object "$oid_r1" "${#content_r1}"
+
+  git lfs unpin a.dat 2>&1 | tee unpin.log
+  grep "Unpinned a.dat ($oid_a1)" unpin.log
+  git lfs prune --verbose 2>&1 | tee prune.log
+  grep "Pruning 1 files" prune.log
+  grep "$oid_a1" prune.log
+  refute_local_object "$oid_a1"
+
+  git lfs unpin v1.0 2>&1 | tee unpin.log
+  grep "Unpinned ref refs/tags/v1.0" unpin.log
+  git lfs prune
+  refute_local_object "$oid_a2"
+  refute_local_object "$oid_b2"
+  assert_local_object "$oid_b1" "${#content_b1}"
+  assert_local_object "$oid_r1" "${#content_r1}"
+
+  git lfs unpin v1.0 2>&1 | tee unpin.log
+  grep "v1.0 is not pinned" unpin.log
+
+  git lfs unpin "$oid_b1"
+  [ ! -e .git/lfs/pins ]
+  git config --unset lfs.pruneprotectrefs
+  git lfs prune
+  refute_local_object "$oid_b1"
+  refute_local_object "$oid_r1"
+  assert_local_object "$oid_a3" "${#content_a3}"
+  assert_local_object "$oid_b3" "${#content_b3}"
+)
+end_test
+
+begin_test "pin to size"
+(
+  set -e
+
+  reponame="pin_to_size"
+  setup_remote_repo "remote_$reponame"
+
+  clone_repo "remote_$reponame" "clone_$reponame"
+
+  git lfs track "*.dat"
+
+  content_old="pinned and too large"
+  content_new="current"
+  oid_old=$(calc_oid "$content_old")
+  oid_new=$(calc_oid "$content_new")
+
+  printf "$content_old" > a.dat
+  git add a.dat .gitattributes
+  git commit -m "add a.dat" 2>&1
+  git lfs pin a.dat
+  printf "$content_new" > a.dat
+  git add a.dat
+  git commit -m "update a.dat" 2>&1
+  git push origin master
+
+  git lfs prune --to-size=1 2>&1 | tee prune.log
+  grep "Unable to prune to 1 B" prune.log
+  assert_local_object "$oid_old" "${#content_old}"
+  assert_local_object "$oid_new" "${#content_new}"
+)
+end_test
+
+begin_test "pin with invalid arguments"
+(
+  set -e
+
+  reponame="pin_invalid"
+  git init "$reponame"
+  cd "$reponame"
+
+  git lfs pin not-a-ref-or-path 2>&1 | tee pin.log
+  if [ "0" -eq "${PIPESTATUS[0]}" ]; then
+    echo >&2 "expected pin to fail"
+    exit 1
+  fi
+  grep "\"not-a-ref-or-path\" is not an oid, a path or a ref" pin.log
+
+  git lfs unpin 2>&1 | tee unpin.log
+  grep "git lfs unpin <oid|path|ref>" unpin.log
+)
+end_test
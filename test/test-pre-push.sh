@@ -120,6 +120,85 @@ begin_test "pre-push 307 redirects"
 )
 end_test
 
+begin_test "pre-push permanent and temporary redirects"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-redirects"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" repo-redirects
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "add git attributes"
+
+  for status in 301 302 308; do
+    git config remote.origin.lfsurl "$GITSERVER/redirect$status/rel/$reponame.git/info/lfs"
+
+    echo "redirect $status" > "$status.dat"
+    git add "$status.dat"
+    git commit -m "add $status.dat"
+
+    echo "refs/heads/master master refs/heads/master 0000000000000000000000000000000000000000" |
+      git lfs pre-push origin "$GITSERVER/redirect$status/rel/$reponame.git/info/lfs" 2>&1 |
+      tee push.log
+
+    # the endpoint is only updated when lfs.persistredirects is set
+    [ "$GITSERVER/redirect$status/rel/$reponame.git/info/lfs" = "$(git config remote.origin.lfsurl)" ]
+  done
+
+  assert_server_object "$reponame" af7eb6b6e41e2a9714f9b3c443cbc4bf323e4104221e9c1beaa57cbdd9de2d5e
+  assert_server_object "$reponame" a06d4ed8d0457c85f6895906b734d01f5b8a9af267659ef2e76f79b9409f6dec
+  assert_server_object "$reponame" e0463043e226a33898845842128fa99451bc13fe5e532d7cd8402ed0b839df14
+
+  git config lfs.persistredirects true
+  echo "persisted" > persisted.dat
+  git add persisted.dat
+  git commit -m "add persisted.dat"
+
+  echo "refs/heads/master master refs/heads/master 0000000000000000000000000000000000000000" |
+    git lfs pre-push origin "$GITSERVER/redirect308/rel/$reponame.git/info/lfs" 2>&1 |
+    tee push.log
+
+  assert_server_object "$reponame" 80edd0b9aafc458cf494c990d33401f5b2747a06d12eb55422bf4af43144917f
+  [ "$GITSERVER/$reponame.git/info/lfs" = "$(git config remote.origin.lfsurl)" ]
+)
+end_test
+
+begin_test "pre-push 303 redirects"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-303"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" repo-303
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "add git attributes"
+
+  git config remote.origin.lfsurl "$GITSERVER/redirect303/rel/$reponame.git/info/lfs"
+
+  echo "see other" > see-other.dat
+  git add see-other.dat
+  git commit -m "add see-other.dat"
+
+  # a 303 See Other is followed with a GET, and the batch request is not
+  # re-sent to the new location
+  set +e
+  echo "refs/heads/master master refs/heads/master 0000000000000000000000000000000000000000" |
+    GIT_TRACE=1 git lfs pre-push origin "$GITSERVER/redirect303/rel/$reponame.git/info/lfs" > push.log 2>&1
+  res=$?
+  set -e
+
+  [ "$res" != "0" ]
+  grep "HTTP: GET $GITSERVER/$reponame.git/info/lfs/objects/batch" push.log
+  [ "0" = "$(grep -c "HTTP: POST $GITSERVER/$reponame.git/info/lfs" push.log)" ]
+
+  refute_server_object "$reponame" 55a960b3e2ca5bceb672659716a05953933bea3b5bf889b15b613fb840a59534
+)
+end_test
+
 begin_test "pre-push with existing file"
 (
   set -e
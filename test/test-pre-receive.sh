@@ -0,0 +1,211 @@
+#!/usr/bin/env bash
+
+. "test/testlib.sh"
+
+# setup_pre_receive_hook installs a pre-receive hook in the bare repository
+# $REMOTEDIR/$1.git which runs git lfs pre-receive with the given arguments,
+# against the Git LFS server of that repository.
+setup_pre_receive_hook() {
+  local repodir="$REMOTEDIR/$1.git"
+  shift
+
+  git --git-dir="$repodir" config lfs.url "$GITSERVER/$(basename "$repodir")/info/lfs"
+  printf '#!/bin/sh\nexec git lfs pre-receive %s\n' "$*" > "$repodir/hooks/pre-receive"
+  chmod +x "$repodir/hooks/pre-receive"
+}
+
+begin_test "pre-receive"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")"
+  setup_remote_repo "$reponame"
+  setup_pre_receive_hook "$reponame"
+
+  clone_repo "$reponame" repo
+  git remote add bare "$REMOTEDIR/$reponame.git"
+
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "add git attributes"
+  git push --no-verify bare master
+
+  echo "hi" > hi.dat
+  git add hi.dat
+  git commit -m "add hi.dat"
+  oid="98ea6e4f216f2fb4b69fff9b3a44842c38686ca685f3f55dc48c5d3fb1107be4"
+
+  # the object was never uploaded, since the pre-push hook was skipped
+  set +e
+  git push --no-verify bare master 2>&1 | tee push.log
+  res="${PIPESTATUS[0]}"
+  set -e
+  [ "0" != "$res" ]
+  grep "Object hi.dat ($oid) is missing from the Git LFS server" push.log
+  grep "Push rejected: 1 missing Git LFS object(s), 0 file(s) not converted" push.log
+  [ "$(git rev-parse HEAD^)" = "$(git --git-dir="$REMOTEDIR/$reponame.git" rev-parse master)" ]
+
+  git lfs push origin master
+  assert_server_object "$reponame" "$oid"
+
+  git push --no-verify bare master 2>&1 | tee push.log
+  [ "0" = "$(grep -c "Git LFS" push.log)" ]
+  [ "$(git rev-parse HEAD)" = "$(git --git-dir="$REMOTEDIR/$reponame.git" rev-parse master)" ]
+
+  # objects already in the repository are not checked again
+  git checkout -b branch
+  git push --no-verify bare branch
+  git push --no-verify bare :branch
+)
+end_test
+
+begin_test "pre-receive rejects files which should be pointers"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-unconverted"
+  setup_remote_repo "$reponame"
+  setup_pre_receive_hook "$reponame"
+
+  clone_repo "$reponame" repo-unconverted
+  git remote add bare "$REMOTEDIR/$reponame.git"
+
+  git lfs track "*.dat"
+  echo "small" > small.txt
+  git add .gitattributes small.txt
+  git commit -m "add git attributes"
+
+  # commit the file as if Git LFS were not installed
+  echo "raw" > raw.dat
+  git -c filter.lfs.clean=cat -c filter.lfs.required=false add raw.dat
+  git commit -m "add raw.dat"
+  sha="$(git rev-parse HEAD:raw.dat)"
+
+  set +e
+  git push --no-verify bare master 2>&1 | tee push.log
+  res="${PIPESTATUS[0]}"
+  set -e
+  [ "0" != "$res" ]
+  grep "File raw.dat ($sha) should be a Git LFS pointer" push.log
+  grep "Push rejected: 0 missing Git LFS object(s), 1 file(s) not converted" push.log
+  [ "0" = "$(grep -c "small.txt" push.log)" ]
+
+  git rm --cached raw.dat
+  git add raw.dat
+  git commit -m "convert raw.dat"
+  git lfs push origin master
+
+  # the history still has the raw file
+  set +e
+  git push --no-verify bare master 2>&1 | tee push.log
+  res="${PIPESTATUS[0]}"
+  set -e
+  [ "0" != "$res" ]
+  grep "File raw.dat ($sha) should be a Git LFS pointer" push.log
+)
+end_test
+
+begin_test "pre-receive rejects files added in a merge resolution"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-merge"
+  setup_remote_repo "$reponame"
+  setup_pre_receive_hook "$reponame"
+
+  clone_repo "$reponame" repo-merge
+  git remote add bare "$REMOTEDIR/$reponame.git"
+
+  git lfs track "*.dat"
+  git add .gitattributes
+  git commit -m "add git attributes"
+  git push --no-verify bare master
+
+  git checkout -b branch
+  echo "branch" > branch.txt
+  git add branch.txt
+  git commit -m "add branch.txt"
+  git checkout master
+  echo "master" > master.txt
+  git add master.txt
+  git commit -m "add master.txt"
+
+  # the merge commit is the only one which adds raw.dat
+  git merge --no-commit branch
+  echo "raw" > raw.dat
+  git -c filter.lfs.clean=cat -c filter.lfs.required=false add raw.dat
+  git commit -m "merge branch"
+  sha="$(git rev-parse HEAD:raw.dat)"
+
+  set +e
+  git push --no-verify bare master 2>&1 | tee push.log
+  res="${PIPESTATUS[0]}"
+  set -e
+  [ "0" != "$res" ]
+  grep "File raw.dat ($sha) should be a Git LFS pointer" push.log
+  grep "Push rejected: 0 missing Git LFS object(s), 1 file(s) not converted" push.log
+)
+end_test
+
+begin_test "pre-receive --store"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-store"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" repo-store
+  store="$TRASHDIR/$reponame-objects"
+  mkdir -p "$store"
+  setup_pre_receive_hook "$reponame" --store "$store"
+  git remote add bare "$REMOTEDIR/$reponame.git"
+
+  git lfs track "*.dat"
+  contents="stored"
+  oid="$(calc_oid "$contents")"
+  printf "$contents" > stored.dat
+  git add .gitattributes stored.dat
+  git commit -m "add stored.dat"
+
+  set +e
+  git push --no-verify bare master 2>&1 | tee push.log
+  res="${PIPESTATUS[0]}"
+  set -e
+  [ "0" != "$res" ]
+  grep "Object stored.dat ($oid) is missing from $store" push.log
+
+  mkdir -p "$store/${oid:0:2}/${oid:2:2}"
+  cp ".git/lfs/objects/${oid:0:2}/${oid:2:2}/$oid" "$store/${oid:0:2}/${oid:2:2}/$oid"
+  git push --no-verify bare master
+)
+end_test
+
+begin_test "pre-receive: run directly in a bare repository"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")-direct"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" repo-direct
+
+  git lfs track "*.dat"
+  echo "direct" > direct.dat
+  git add .gitattributes direct.dat
+  git commit -m "add direct.dat"
+  git push origin master
+  sha="$(git rev-parse HEAD)"
+
+  # the commit is in the repository but no ref points to it yet
+  cd "$REMOTEDIR/$reponame.git"
+  git config lfs.url "$GITSERVER/$reponame.git/info/lfs"
+  git update-ref -d refs/heads/master
+
+  echo "0000000000000000000000000000000000000000 $sha refs/heads/master" |
+    git lfs pre-receive 2>&1 | tee receive.log
+  [ "" = "$(cat receive.log)" ]
+
+  echo "$sha 0000000000000000000000000000000000000000 refs/heads/master" |
+    git lfs pre-receive 2>&1 | tee receive.log
+  [ "" = "$(cat receive.log)" ]
+)
+end_test
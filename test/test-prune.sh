@@ -537,4 +537,75 @@ begin_test "prune verify"
   refute_local_object "$oid_commit3"
 
 )
-end_test
\ No newline at end of file
+end_test
+begin_test "prune to size"
+(
+  set -e
+
+  reponame="prune_to_size"
+  setup_remote_repo "remote_$reponame"
+
+  clone_repo "remote_$reponame" "clone_$reponame"
+
+  git lfs track "*.dat" 2>&1 | tee track.log
+  grep "Tracking \*.dat" track.log
+
+  content_current="Keep: needed by the current checkout"
+  content_other1="Evict first: least recently used on a recent branch"
+  content_other2="Evict second: more recently used on a recent branch"
+  content_unpushed="Keep: only on an unpushed branch"
+  oid_current=$(calc_oid "$content_current")
+  oid_other1=$(calc_oid "$content_other1")
+  oid_other2=$(calc_oid "$content_other2")
+  oid_unpushed=$(calc_oid "$content_unpushed")
+
+  echo "[
+  {
+    \"Files\":[
+      {\"Filename\":\"current.dat\",\"Size\":${#content_current}, \"Data\":\"$content_current\"}]
+  },
+  {
+    \"NewBranch\":\"other\",
+    \"Files\":[
+      {\"Filename\":\"other1.dat\",\"Size\":${#content_other1}, \"Data\":\"$content_other1\"},
+      {\"Filename\":\"other2.dat\",\"Size\":${#content_other2}, \"Data\":\"$content_other2\"}]
+  },
+  {
+    \"ParentBranches\":[\"master\"],
+    \"NewBranch\":\"unpushed\",
+    \"Files\":[
+      {\"Filename\":\"unpushed.dat\",\"Size\":${#content_unpushed}, \"Data\":\"$content_unpushed\"}]
+  }
+  ]" | lfstest-testutils addcommits
+
+  git push origin master other
+  git checkout master
+
+  git config lfs.fetchrecentrefsdays 5
+
+  touch -t 201601010000 ".git/lfs/objects/${oid_other1:0:2}/${oid_other1:2:2}/$oid_other1"
+  touch -t 201602010000 ".git/lfs/objects/${oid_other2:0:2}/${oid_other2:2:2}/$oid_other2"
+
+  git lfs prune 2>&1 | tee prune.log
+  grep "Nothing to prune" prune.log
+
+  size=$((${#content_current} + ${#content_other2} + ${#content_unpushed}))
+  git lfs prune --to-size=$size --verbose 2>&1 | tee prune.log
+  grep "Pruning 1 files" prune.log
+  grep "$oid_other1" prune.log
+
+  refute_local_object "$oid_other1"
+  assert_local_object "$oid_other2" "${#content_other2}"
+  assert_local_object "$oid_current" "${#content_current}"
+  assert_local_object "$oid_unpushed" "${#content_unpushed}"
+
+  git config lfs.storage.maxsize 1
+  git lfs prune --to-size 2>&1 | tee prune.log
+  grep "Pruning 1 files" prune.log
+  grep "Unable to prune to 1 B" prune.log
+
+  refute_local_object "$oid_other2"
+  assert_local_object "$oid_current" "${#content_current}"
+  assert_local_object "$oid_unpushed" "${#content_unpushed}"
+)
+end_test
@@ -95,3 +95,44 @@ begin_test "fetch from clone reference"
   assert_same_inode "$TRASHDIR/$repo" "$TRASHDIR/$ref_repo" "$oid"
 )
 end_test
+
+begin_test "fetch from lfs.alternates"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")3"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" lfs_alternates_source
+  git lfs track "*.dat"
+  contents="a"
+  oid=$(calc_oid "$contents")
+
+  printf "$contents" > a.dat
+  git add a.dat
+  git add .gitattributes
+  git commit -m "add a.dat" 2>&1
+  git push origin master
+
+  delete_server_object "$reponame" "$oid"
+
+  shared_dir="$TRASHDIR/lfs_alternates_shared"
+  mkdir -p "$shared_dir/${oid:0:2}/${oid:2:2}"
+  cp ".git/lfs/objects/${oid:0:2}/${oid:2:2}/$oid" "$shared_dir/${oid:0:2}/${oid:2:2}/$oid"
+
+  repo_dir="$TRASHDIR/lfs_alternates_repo"
+  GIT_LFS_SKIP_SMUDGE=1 git clone "$GITSERVER/$reponame" "$repo_dir"
+
+  cd "$repo_dir"
+  git config --add lfs.alternates "$TRASHDIR/missing"
+  git config --add lfs.alternates "$shared_dir"
+  git lfs pull 2>&1 | tee pull.log
+
+  if grep -q "error" pull.log; then
+    exit 1
+  fi
+
+  [ "$contents" = "$(cat a.dat)" ]
+  assert_local_object "$oid" 1
+)
+end_test
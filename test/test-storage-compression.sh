@@ -0,0 +1,102 @@
+#!/usr/bin/env bash
+
+. "test/testlib.sh"
+
+local_object_path() {
+  local oid=$1
+  local cfg=$(git lfs env | grep LocalMediaDir)
+  echo "${cfg:14}/${oid:0:2}/${oid:2:2}/$oid"
+}
+
+assert_compressed_object() {
+  local oid=$1
+  local algorithm=$2
+
+  [ "git-lfs-compressed" = "$(head -c 19 "$(local_object_path "$oid")" | tail -c 18)" ]
+  grep -a -q "$algorithm $oid 0" "$(local_object_path "$oid")"
+}
+
+for algorithm in zstd gzip; do
+  begin_test "compressed storage ($algorithm)"
+  (
+    set -e
+
+    reponame="storage-compression-$algorithm"
+    setup_remote_repo "$reponame"
+    clone_repo "$reponame" "$reponame"
+
+    git config lfs.storage.compression "$algorithm"
+    git lfs track "*.csv"
+
+    for i in $(seq 1 500); do
+      echo "row $i,compressible,data" >> a.csv
+    done
+    contents=$(cat a.csv)
+    size=$(wc -c < a.csv | tr -d '[[:space:]]')
+    oid=$(shasum -a 256 a.csv | cut -f 1 -d " ")
+
+    git add a.csv .gitattributes
+    git commit -m "add a.csv" 2>&1
+
+    assert_compressed_object "$oid" "$algorithm"
+    [ "$(wc -c < "$(local_object_path "$oid")")" -lt "$size" ]
+    git lfs ls-files | grep "a.csv"
+
+    rm a.csv
+    git lfs checkout
+    [ "$contents" = "$(cat a.csv)" ]
+
+    git lfs fsck | tee fsck.log
+    grep "Git LFS fsck OK" fsck.log
+
+    git push origin master 2>&1 | tee push.log
+    grep "(1 of 1 files)" push.log
+    assert_server_object "$reponame" "$oid"
+
+    # objects are stored uncompressed on the server
+    cd "$TRASHDIR"
+    clone_repo "$reponame" "$reponame-clone"
+    [ "$contents" = "$(cat a.csv)" ]
+    assert_local_object "$oid" "$size"
+  )
+  end_test
+done
+
+begin_test "compressed storage with uncompressed objects"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")"
+  setup_remote_repo "$reponame"
+  clone_repo "$reponame" "$reponame"
+
+  git lfs track "*.dat"
+  printf "plain" > plain.dat
+  plain_oid=$(calc_oid "plain")
+  git add plain.dat .gitattributes
+  git commit -m "add plain.dat" 2>&1
+  assert_local_object "$plain_oid" 5
+
+  git config lfs.storage.compression zstd
+  printf "compressed" > compressed.dat
+  compressed_oid=$(calc_oid "compressed")
+  git add compressed.dat
+  git commit -m "add compressed.dat" 2>&1
+  assert_local_object "$plain_oid" 5
+  assert_compressed_object "$compressed_oid" zstd
+
+  rm plain.dat compressed.dat
+  git lfs checkout
+  [ "plain" = "$(cat plain.dat)" ]
+  [ "compressed" = "$(cat compressed.dat)" ]
+
+  # compressed objects are still read once compression is turned off
+  git config --unset lfs.storage.compression
+  rm compressed.dat
+  git lfs checkout
+  [ "compressed" = "$(cat compressed.dat)" ]
+
+  git lfs fsck | tee fsck.log
+  grep "Git LFS fsck OK" fsck.log
+)
+end_test
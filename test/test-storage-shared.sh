@@ -0,0 +1,94 @@
+#!/usr/bin/env bash
+
+. "test/testlib.sh"
+
+shared_object_path() {
+  local shared_dir=$1
+  local oid=$2
+  echo "$shared_dir/${oid:0:2}/${oid:2:2}/$oid"
+}
+
+assert_shared_inode() {
+  local shared_dir=$1
+  local oid=$2
+
+  if ! uname -s | grep -qE 'CYGWIN|MSYS|MINGW'; then
+    cfg=$(git lfs env | grep LocalMediaDir)
+    inode1=$(ls -i "${cfg:14}/${oid:0:2}/${oid:2:2}/$oid" | cut -f1 -d\ )
+    inode2=$(ls -i "$(shared_object_path "$shared_dir" "$oid")" | cut -f1 -d\ )
+
+    [ "$inode1" == "$inode2" ]
+  fi
+}
+
+begin_test "shared storage"
+(
+  set -e
+
+  reponame="$(basename "$0" ".sh")"
+  setup_remote_repo "$reponame"
+
+  clone_repo "$reponame" shared_storage_source
+  git lfs track "*.dat"
+  contents="a"
+  oid=$(calc_oid "$contents")
+  feature_contents="feature"
+  feature_oid=$(calc_oid "$feature_contents")
+
+  printf "$contents" > a.dat
+  git add a.dat .gitattributes
+  git commit -m "add a.dat" 2>&1
+  git checkout -b feature
+  printf "$feature_contents" > feature.dat
+  git add feature.dat
+  git commit -m "add feature.dat" 2>&1
+  git push origin master feature
+  git checkout master
+
+  shared_dir="$TRASHDIR/shared_storage"
+
+  for repo in shared_storage_repo1 shared_storage_repo2; do
+    cd "$TRASHDIR"
+    GIT_LFS_SKIP_SMUDGE=1 git clone "$GITSERVER/$reponame" "$repo"
+    cd "$repo"
+    git config credential.helper lfstest
+    git config lfs.storage.shared "$shared_dir"
+    git lfs pull
+    git lfs fetch origin origin/feature
+
+    [ "$contents" = "$(cat a.dat)" ]
+    assert_local_object "$oid" 1
+    assert_local_object "$feature_oid" "${#feature_contents}"
+    assert_shared_inode "$shared_dir" "$oid"
+    assert_shared_inode "$shared_dir" "$feature_oid"
+
+    if [ "$repo" = "shared_storage_repo1" ]; then
+      # the second repository must take both objects from the shared storage
+      delete_server_object "$reponame" "$oid"
+      delete_server_object "$reponame" "$feature_oid"
+    fi
+  done
+
+  cd "$TRASHDIR/shared_storage_source"
+  git push origin --delete feature
+
+  cd "$TRASHDIR/shared_storage_repo1"
+  git fetch --prune origin
+  git lfs prune
+
+  refute_local_object "$feature_oid"
+  assert_local_object "$oid" 1
+  # still linked from the second repository
+  [ -f "$(shared_object_path "$shared_dir" "$feature_oid")" ]
+
+  cd "$TRASHDIR/shared_storage_repo2"
+  git fetch --prune origin
+  git lfs prune
+
+  refute_local_object "$feature_oid"
+  if ! uname -s | grep -qE 'CYGWIN|MSYS|MINGW'; then
+    [ ! -e "$(shared_object_path "$shared_dir" "$feature_oid")" ]
+  fi
+  [ -f "$(shared_object_path "$shared_dir" "$oid")" ]
+)
+end_test
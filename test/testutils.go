@@ -296,19 +296,15 @@ func (repo *Repo) AddCommits(inputs []*CommitInput) []*CommitOutput {
 				repo.callback.Errorf("Error creating pointer file: %v", err)
 				continue
 			}
-			// this only created the temp file, move to final location
-			tmpfile := cleaned.Filename
-			mediafile, err := lfs.LocalMediaPath(cleaned.Oid)
-			if err != nil {
-				repo.callback.Errorf("Unable to get local media path: %v", err)
-				continue
-			}
-			if _, err := os.Stat(mediafile); err != nil {
-				if err := os.Rename(tmpfile, mediafile); err != nil {
-					repo.callback.Errorf("Unable to move %s to %s: %v", tmpfile, mediafile, err)
+			// this hasn't added the object to the store yet
+			if _, err := localstorage.CurrentStore().Stat(cleaned.Oid); err != nil {
+				if err := cleaned.Commit(); err != nil {
+					repo.callback.Errorf("Unable to add %s to the local store: %v", cleaned.Oid, err)
+					cleaned.Teardown()
 					continue
 				}
 			}
+			cleaned.Teardown()
 
 			output.Files = append(output.Files, cleaned.Pointer)
 			// Write pointer to local filename for adding (not using clean filter)
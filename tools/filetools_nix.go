@@ -0,0 +1,31 @@
+// +build !windows
+
+package tools
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// HardLinkCount returns the number of hard links to the file at path.
+func HardLinkCount(path string) (uint64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("Unable to count links to %s", path)
+	}
+	return uint64(stat.Nlink), nil
+}
+
+// FileInode returns the inode number of the file described by fi, or 0 if it
+// is not known.
+func FileInode(fi os.FileInfo) uint64 {
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
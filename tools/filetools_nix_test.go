@@ -0,0 +1,70 @@
+// +build !windows
+
+package tools_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/github/git-lfs/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHardLinkCount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lfs-links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "a")
+	if err := ioutil.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	links, err := tools.HardLinkCount(file)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), links)
+
+	if err := os.Link(file, filepath.Join(dir, "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	links, err = tools.HardLinkCount(file)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), links)
+
+	_, err = tools.HardLinkCount(filepath.Join(dir, "missing"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileInode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lfs-inodes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	if err := ioutil.WriteFile(a, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	infoA, err := os.Stat(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEqual(t, uint64(0), tools.FileInode(infoA))
+	assert.Equal(t, tools.FileInode(infoA), tools.FileInode(infoB))
+}
@@ -0,0 +1,23 @@
+// +build windows
+
+package tools
+
+import (
+	"fmt"
+	"os"
+)
+
+// HardLinkCount returns the number of hard links to the file at path. This is
+// not supported on Windows, so an error is returned for any existing file.
+func HardLinkCount(path string) (uint64, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("Unable to count links to %s", path)
+}
+
+// FileInode returns the inode number of the file described by fi, or 0 if it
+// is not known. Windows has no inode numbers in file info, so it is always 0.
+func FileInode(fi os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,35 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseSize parses a size in bytes written as git-config(1) writes integers: a
+// whole number, optionally followed by "k", "m" or "g" to multiply it by 1024,
+// 1024^2 or 1024^3. A trailing "b" is also allowed, as in "10gb".
+func ParseSize(s string) (int64, error) {
+	str := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "b")
+
+	multiplier := int64(1)
+	if len(str) > 0 {
+		switch str[len(str)-1] {
+		case 'k':
+			multiplier = 1 << 10
+		case 'm':
+			multiplier = 1 << 20
+		case 'g':
+			multiplier = 1 << 30
+		}
+		if multiplier > 1 {
+			str = str[:len(str)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("Invalid size: %q", s)
+	}
+	return n * multiplier, nil
+}
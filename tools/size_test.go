@@ -0,0 +1,32 @@
+package tools_test
+
+import (
+	"testing"
+
+	"github.com/github/git-lfs/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSize(t *testing.T) {
+	for s, expected := range map[string]int64{
+		"0":     0,
+		"1234":  1234,
+		"10k":   10 * 1024,
+		"10K":   10 * 1024,
+		"3m":    3 * 1024 * 1024,
+		"2g":    2 * 1024 * 1024 * 1024,
+		"2GB":   2 * 1024 * 1024 * 1024,
+		" 5mb ": 5 * 1024 * 1024,
+	} {
+		n, err := tools.ParseSize(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, expected, n, s)
+	}
+}
+
+func TestParseSizeRejectsInvalidSizes(t *testing.T) {
+	for _, s := range []string{"", "k", "ten", "-1", "1t", "1.5g"} {
+		_, err := tools.ParseSize(s)
+		assert.NotNil(t, err, s)
+	}
+}
@@ -207,7 +207,7 @@ func (a *basicDownloadAdapter) download(t *Transfer, cb TransferProgressCallback
 		return fmt.Errorf("Expected OID %s, got %s after %d bytes written", t.Object.Oid, actual, written)
 	}
 
-	return tools.RenameFileCopyPermissions(dlfilename, t.Path)
+	return localstorage.ImportFile(localstorage.CurrentStore(), t.Object.Oid, dlfilename)
 
 }
 
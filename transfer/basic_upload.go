@@ -11,6 +11,7 @@ import (
 	"github.com/github/git-lfs/api"
 	"github.com/github/git-lfs/errutil"
 	"github.com/github/git-lfs/httputil"
+	"github.com/github/git-lfs/localstorage"
 	"github.com/github/git-lfs/progress"
 )
 
@@ -60,7 +61,7 @@ func (a *basicUploadAdapter) DoTransfer(t *Transfer, cb TransferProgressCallback
 
 	req.ContentLength = t.Object.Size
 
-	f, err := os.OpenFile(t.Path, os.O_RDONLY, 0644)
+	f, err := localstorage.CurrentStore().Open(t.Object.Oid)
 	if err != nil {
 		return errutil.Error(err)
 	}
@@ -77,7 +77,9 @@ type Transfer struct {
 	// Object from API which provides the core data for this transfer
 	Object *api.ObjectResource
 	// Path for uploads is the source of data to send, for downloads is the
-	// location to place the final result
+	// location to place the final result. The built-in adapters read and write
+	// objects through localstorage.CurrentStore() instead, which need not keep
+	// objects in files, so this is only for adapters which need a file.
 	Path string
 }
 
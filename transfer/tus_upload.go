@@ -10,6 +10,7 @@ import (
 	"github.com/github/git-lfs/api"
 	"github.com/github/git-lfs/errutil"
 	"github.com/github/git-lfs/httputil"
+	"github.com/github/git-lfs/localstorage"
 	"github.com/github/git-lfs/progress"
 	"github.com/rubyist/tracerx"
 )
@@ -69,7 +70,7 @@ func (a *tusUploadAdapter) DoTransfer(t *Transfer, cb TransferProgressCallback,
 	}
 
 	// Open file for uploading
-	f, err := os.OpenFile(t.Path, os.O_RDONLY, 0644)
+	f, err := localstorage.CurrentStore().Open(t.Object.Oid)
 	if err != nil {
 		return errutil.Error(err)
 	}
@@ -81,7 +82,11 @@ func (a *tusUploadAdapter) DoTransfer(t *Transfer, cb TransferProgressCallback,
 	} else {
 		tracerx.Printf("xfer: tus.io resuming upload %q from %d", t.Object.Oid, offset)
 		advanceCallbackProgress(cb, t, offset)
-		_, err := f.Seek(offset, os.SEEK_CUR)
+		if seeker, ok := f.(io.Seeker); ok {
+			_, err = seeker.Seek(offset, os.SEEK_CUR)
+		} else {
+			_, err = io.CopyN(ioutil.Discard, f, offset)
+		}
 		if err != nil {
 			return errutil.Error(err)
 		}